@@ -17,6 +17,7 @@
 package abi
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -145,6 +146,44 @@ func (abi ABI) Pack(name string, args ...interface{}) ([]byte, error) {
 	return packed, nil
 }
 
+// MethodById looks up the method whose id is the given 4 byte prefix of
+// call data.
+func (abi ABI) MethodById(id []byte) (Method, error) {
+	if len(id) < 4 {
+		return Method{}, fmt.Errorf("method id too short: %d bytes", len(id))
+	}
+	for _, method := range abi.Methods {
+		if bytes.Equal(method.Id(), id[:4]) {
+			return method, nil
+		}
+	}
+	return Method{}, fmt.Errorf("no method with id %x", id[:4])
+}
+
+// UnpackInput decodes the data of a method call created by Pack, returning
+// the called method and its arguments. Numbers are returned as *big.Int,
+// addresses as common.Address and fixed size number slices as []*big.Int.
+func (abi ABI) UnpackInput(data []byte) (Method, []interface{}, error) {
+	method, err := abi.MethodById(data)
+	if err != nil {
+		return Method{}, nil, err
+	}
+	data = data[4:]
+
+	args := make([]interface{}, len(method.Inputs))
+	for i, input := range method.Inputs {
+		arg, n, err := input.Type.unpack(data)
+		if err != nil {
+			return Method{}, nil, fmt.Errorf("`%s` argument %d: %v", method.Name, i, err)
+		}
+		args[i], data = arg, data[n:]
+	}
+	if len(data) > 0 {
+		return Method{}, nil, fmt.Errorf("`%s` %d bytes of trailing data", method.Name, len(data))
+	}
+	return method, args, nil
+}
+
 func (abi *ABI) UnmarshalJSON(data []byte) error {
 	var methods []Method
 	if err := json.Unmarshal(data, &methods); err != nil {
@@ -325,6 +325,52 @@ func TestPackSlice(t *testing.T) {
 	}
 }
 
+func TestUnpackInput(t *testing.T) {
+	abi, err := JSON(strings.NewReader(`[
+		{ "name" : "transfer", "const" : false, "inputs" : [
+			{ "name" : "to", "type" : "address" },
+			{ "name" : "amount", "type" : "uint256" },
+			{ "name" : "delta", "type" : "int256" },
+			{ "name" : "force", "type" : "bool" },
+			{ "name" : "limits", "type" : "uint64[2]" }
+		] },
+		{ "name" : "dynamic", "const" : false, "inputs" : [ { "name" : "inputs", "type" : "uint256[]" } ] }
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := common.HexToAddress("0x289d485d9771714cce91d3393d764e1311907acc")
+	packed, err := abi.Pack("transfer", to, big.NewInt(1000), big.NewInt(7), true, []uint64{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	method, args, err := abi.UnpackInput(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method.Name != "transfer" {
+		t.Errorf("method mismatch: have %s, want transfer", method.Name)
+	}
+	want := []interface{}{to, big.NewInt(1000), big.NewInt(7), true, []*big.Int{big.NewInt(1), big.NewInt(2)}}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("arguments mismatch: have %v, want %v", args, want)
+	}
+	// Truncated and unknown calls are rejected
+	if _, _, err := abi.UnpackInput(packed[:len(packed)-1]); err == nil {
+		t.Errorf("expected error for truncated call")
+	}
+	if _, _, err := abi.UnpackInput(append(packed, 0)); err == nil {
+		t.Errorf("expected error for trailing data")
+	}
+	if _, _, err := abi.UnpackInput([]byte{1, 2, 3, 4}); err == nil {
+		t.Errorf("expected error for unknown method")
+	}
+	dynamic, _ := abi.Pack("dynamic", []uint64{1})
+	if _, _, err := abi.UnpackInput(dynamic); err == nil {
+		t.Errorf("expected error for dynamic slice")
+	}
+}
+
 func TestPackSliceBig(t *testing.T) {
 	abi, err := JSON(strings.NewReader(jsondata2))
 	if err != nil {
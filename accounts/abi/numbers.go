@@ -120,3 +120,13 @@ func isSigned(v reflect.Value) bool {
 	}
 	return false
 }
+
+// unpackNum decodes a 32 byte big endian number, interpreting it as two's
+// complement if signed is set.
+func unpackNum(word []byte, signed bool) *big.Int {
+	n := new(big.Int).SetBytes(word)
+	if signed {
+		return common.S256(n)
+	}
+	return n
+}
@@ -18,9 +18,11 @@ package abi
 
 import (
 	"fmt"
+	"math/big"
 	"reflect"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/krypton/go-krypton/common"
 )
@@ -214,3 +216,37 @@ func (t Type) pack(v interface{}) ([]byte, error) {
 
 	return nil, fmt.Errorf("ABI: bad input given %T", value.Kind())
 }
+
+// unpack decodes the value of the type from the head of the call data,
+// returning it along with the number of bytes consumed. Only the static
+// types produced by pack are supported: numbers, bools, addresses and
+// fixed size number slices.
+func (t Type) unpack(data []byte) (interface{}, int, error) {
+	if t.Kind == reflect.Slice && t.T != AddressTy {
+		if t.Type != big_ts || t.Size < 0 {
+			return nil, 0, fmt.Errorf("unpacking %s not supported", t)
+		}
+		if len(data) < 32*t.Size {
+			return nil, 0, fmt.Errorf("%s: insufficient data: %d bytes", t, len(data))
+		}
+		signed := !strings.HasPrefix(t.stringKind, "uint")
+		slice := make([]*big.Int, t.Size)
+		for i := range slice {
+			slice[i] = unpackNum(data[32*i:32*i+32], signed)
+		}
+		return slice, 32 * t.Size, nil
+	}
+	if len(data) < 32 {
+		return nil, 0, fmt.Errorf("%s: insufficient data: %d bytes", t, len(data))
+	}
+	word := data[:32]
+	switch {
+	case t.T == AddressTy:
+		return common.BytesToAddress(word), 32, nil
+	case t.Kind == reflect.Bool:
+		return word[31] != 0, 32, nil
+	case t.Kind == reflect.Ptr:
+		return unpackNum(word, t.T == IntTy), 32, nil
+	}
+	return nil, 0, fmt.Errorf("unpacking %s not supported", t)
+}
@@ -25,6 +25,7 @@ package accounts
 import (
 	"crypto/ecdsa"
 	crand "crypto/rand"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -32,12 +33,16 @@ import (
 	"time"
 
 	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/core/types"
 	"github.com/krypton/go-krypton/crypto"
+	"github.com/krypton/go-krypton/event"
 )
 
 var (
-	ErrLocked = errors.New("account is locked")
-	ErrNoKeys = errors.New("no keys in store")
+	ErrLocked        = errors.New("account is locked")
+	ErrNoKeys        = errors.New("no keys in store")
+	ErrAccountExists = errors.New("account already exists")
+	ErrSignerManaged = errors.New("not supported with external signer")
 )
 
 type Account struct {
@@ -48,6 +53,12 @@ type Manager struct {
 	keyStore crypto.KeyStore
 	unlocked map[common.Address]*unlocked
 	mutex    sync.RWMutex
+
+	wallets    *walletStore // hierarchical deterministic wallets, nil if disabled
+	walletLock sync.RWMutex
+
+	signer     Signer // external signer holding the keys, nil if signing locally
+	signerLock sync.RWMutex
 }
 
 type unlocked struct {
@@ -62,6 +73,18 @@ func NewManager(keyStore crypto.KeyStore) *Manager {
 	}
 }
 
+// Subscribe creates a subscription to the crypto.KeyAddedEvent and
+// crypto.KeyRemovedEvent events, posted when accounts appear in or disappear
+// from the key store and when accounts are derived from HD wallets.
+func (am *Manager) Subscribe() event.Subscription {
+	return am.keyStore.Events().Subscribe(crypto.KeyAddedEvent{}, crypto.KeyRemovedEvent{})
+}
+
+// Close stops watching the key store for changes.
+func (am *Manager) Close() {
+	am.keyStore.Close()
+}
+
 func (am *Manager) HasAccount(addr common.Address) bool {
 	accounts, _ := am.Accounts()
 	for _, acct := range accounts {
@@ -73,10 +96,39 @@ func (am *Manager) HasAccount(addr common.Address) bool {
 }
 
 func (am *Manager) DeleteAccount(address common.Address, auth string) error {
+	if am.externalSigner() != nil {
+		return ErrSignerManaged
+	}
+	am.walletLock.RLock()
+	derived := am.walletOf(address) != nil
+	am.walletLock.RUnlock()
+	if derived {
+		return ErrDerivedAccount
+	}
 	return am.keyStore.DeleteKey(address, auth)
 }
 
+// SetSigner delegates account listing and signing to the given signer instead
+// of the local key store. Passing nil reverts to local signing. Operations on
+// the keys themselves (creating, unlocking, updating, re-encrypting, importing
+// or exporting them) fail with ErrSignerManaged while a signer is set, as the
+// signer manages the keys.
+func (am *Manager) SetSigner(signer Signer) {
+	am.signerLock.Lock()
+	defer am.signerLock.Unlock()
+	am.signer = signer
+}
+
+func (am *Manager) externalSigner() Signer {
+	am.signerLock.RLock()
+	defer am.signerLock.RUnlock()
+	return am.signer
+}
+
 func (am *Manager) Sign(a Account, toSign []byte) (signature []byte, err error) {
+	if signer := am.externalSigner(); signer != nil {
+		return signer.SignHash(a, toSign)
+	}
 	am.mutex.RLock()
 	defer am.mutex.RUnlock()
 	unlockedKey, found := am.unlocked[a.Address]
@@ -87,6 +139,72 @@ func (am *Manager) Sign(a Account, toSign []byte) (signature []byte, err error)
 	return signature, err
 }
 
+// SignTx signs a transaction with the given account. Contrary to signing its
+// hash with Sign, an external signer gets to see the whole transaction.
+func (am *Manager) SignTx(a Account, tx *types.Transaction) (*types.Transaction, error) {
+	if signer := am.externalSigner(); signer != nil {
+		return signer.SignTx(a, tx)
+	}
+	sig, err := am.Sign(a, tx.SigHash().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithSignature(sig)
+}
+
+// SignWithPassphrase signs hash with the key of the account, decrypting it
+// for this single signature only. Unlocked keys are left untouched.
+func (am *Manager) SignWithPassphrase(a Account, passphrase string, hash []byte) ([]byte, error) {
+	if signer := am.externalSigner(); signer != nil {
+		return signer.SignHash(a, hash)
+	}
+	key, err := am.getKey(a.Address, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key.PrivateKey)
+	return crypto.Sign(hash, key.PrivateKey)
+}
+
+// SignTxWithPassphrase signs a transaction, decrypting the key of the account
+// for this single signature only.
+func (am *Manager) SignTxWithPassphrase(a Account, passphrase string, tx *types.Transaction) (*types.Transaction, error) {
+	if signer := am.externalSigner(); signer != nil {
+		return signer.SignTx(a, tx)
+	}
+	sig, err := am.SignWithPassphrase(a, passphrase, tx.SigHash().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithSignature(sig)
+}
+
+// TextHash computes the hash signed for a message by personal_sign:
+//
+//	sha3("\x19Krypton Signed Message:\n" + len(data) + data)
+//
+// The prefix keeps signed messages from being valid transaction signatures.
+func TextHash(data []byte) []byte {
+	msg := fmt.Sprintf("\x19Krypton Signed Message:\n%d%s", len(data), data)
+	return crypto.Sha3([]byte(msg))
+}
+
+// Lock removes the private key of an unlocked account from memory. Locking an
+// account that isn't unlocked does nothing.
+func (am *Manager) Lock(addr common.Address) error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	if u, found := am.unlocked[addr]; found {
+		if u.abort != nil {
+			close(u.abort)
+		}
+		zeroKey(u.PrivateKey)
+		delete(am.unlocked, addr)
+	}
+	return nil
+}
+
 // Unlock unlocks the given account indefinitely.
 func (am *Manager) Unlock(addr common.Address, keyAuth string) error {
 	return am.TimedUnlock(addr, keyAuth, 0)
@@ -99,7 +217,10 @@ func (am *Manager) Unlock(addr common.Address, keyAuth string) error {
 // If the accout is already unlocked, TimedUnlock extends or shortens
 // the active unlock timeout.
 func (am *Manager) TimedUnlock(addr common.Address, keyAuth string, timeout time.Duration) error {
-	key, err := am.keyStore.GetKey(addr, keyAuth)
+	if am.externalSigner() != nil {
+		return ErrSignerManaged
+	}
+	key, err := am.getKey(addr, keyAuth)
 	if err != nil {
 		return err
 	}
@@ -145,6 +266,9 @@ func (am *Manager) expire(addr common.Address, u *unlocked, timeout time.Duratio
 }
 
 func (am *Manager) NewAccount(auth string) (Account, error) {
+	if am.externalSigner() != nil {
+		return Account{}, ErrSignerManaged
+	}
 	key, err := am.keyStore.GenerateNewKey(crand.Reader, auth)
 	if err != nil {
 		return Account{}, err
@@ -167,19 +291,26 @@ func (am *Manager) AddressByIndex(index int) (addr string, err error) {
 }
 
 func (am *Manager) Accounts() ([]Account, error) {
+	if signer := am.externalSigner(); signer != nil {
+		return signer.Accounts()
+	}
+	derived := am.walletAccounts()
 	addresses, err := am.keyStore.GetKeyAddresses()
 	if os.IsNotExist(err) {
+		if len(derived) > 0 {
+			return derived, nil
+		}
 		return nil, ErrNoKeys
 	} else if err != nil {
 		return nil, err
 	}
-	accounts := make([]Account, len(addresses))
+	accounts := make([]Account, len(addresses), len(addresses)+len(derived))
 	for i, addr := range addresses {
 		accounts[i] = Account{
 			Address: addr,
 		}
 	}
-	return accounts, err
+	return append(accounts, derived...), err
 }
 
 // zeroKey zeroes a private key in memory.
@@ -193,7 +324,10 @@ func zeroKey(k *ecdsa.PrivateKey) {
 // USE WITH CAUTION = this will save an unencrypted private key on disk
 // no cli or js interface
 func (am *Manager) Export(path string, addr common.Address, keyAuth string) error {
-	key, err := am.keyStore.GetKey(addr, keyAuth)
+	if am.externalSigner() != nil {
+		return ErrSignerManaged
+	}
+	key, err := am.getKey(addr, keyAuth)
 	if err != nil {
 		return err
 	}
@@ -205,14 +339,52 @@ func (am *Manager) Import(path string, keyAuth string) (Account, error) {
 	if err != nil {
 		return Account{}, err
 	}
-	key := crypto.NewKeyFromECDSA(privateKeyECDSA)
-	if err = am.keyStore.StoreKey(key, keyAuth); err != nil {
+	return am.ImportECDSA(privateKeyECDSA, keyAuth)
+}
+
+// ImportECDSA stores the given private key in the key store, encrypted with
+// keyAuth.
+func (am *Manager) ImportECDSA(priv *ecdsa.PrivateKey, keyAuth string) (Account, error) {
+	if am.externalSigner() != nil {
+		return Account{}, ErrSignerManaged
+	}
+	key := crypto.NewKeyFromECDSA(priv)
+	if am.HasAccount(key.Address) {
+		return Account{}, ErrAccountExists
+	}
+	if err := am.keyStore.StoreKey(key, keyAuth); err != nil {
 		return Account{}, err
 	}
 	return Account{Address: key.Address}, nil
 }
 
 func (am *Manager) Update(addr common.Address, authFrom, authTo string) (err error) {
+	if am.externalSigner() != nil {
+		return ErrSignerManaged
+	}
+	// Derived accounts share the passphrase of their wallet, which is
+	// re-encrypted without holding the wallet lock
+	am.walletLock.RLock()
+	store, wallet := am.wallets, am.walletOf(addr)
+	var encrypted json.RawMessage
+	if wallet != nil {
+		encrypted = wallet.crypto
+	}
+	am.walletLock.RUnlock()
+
+	if wallet != nil {
+		if encrypted, err = store.reencrypt(encrypted, authFrom, authTo); err != nil {
+			return err
+		}
+		am.walletLock.Lock()
+		defer am.walletLock.Unlock()
+
+		if am.wallets == nil {
+			return ErrNoWalletStore
+		}
+		return am.wallets.update(wallet.id, encrypted)
+	}
+
 	var key *crypto.Key
 	key, err = am.keyStore.GetKey(addr, authFrom)
 
@@ -225,7 +397,66 @@ func (am *Manager) Update(addr common.Address, authFrom, authTo string) (err err
 	return
 }
 
+// Reencrypt encrypts the key of an account again using the given KDF
+// parameters, keeping its passphrase. The format the key was stored in before
+// is returned, so callers can report keys that used a legacy format.
+func (am *Manager) Reencrypt(addr common.Address, auth string, kdf crypto.KDFParams) (crypto.KeyFileFormat, error) {
+	if am.externalSigner() != nil {
+		return crypto.KeyFileFormat{}, ErrSignerManaged
+	}
+	am.walletLock.RLock()
+	derived := am.walletOf(addr) != nil
+	am.walletLock.RUnlock()
+	if derived {
+		return crypto.KeyFileFormat{}, ErrDerivedAccount
+	}
+	return am.keyStore.Reencrypt(addr, auth, kdf)
+}
+
+// ReencryptResult is the outcome of re-encrypting a single key.
+type ReencryptResult struct {
+	Address common.Address
+	Format  crypto.KeyFileFormat // format before re-encryption
+	Err     error
+}
+
+// ReencryptAll re-encrypts every key of the key store using the given KDF
+// parameters. Each key is tried with all passphrases in turn; keys that none
+// of them decrypt are reported with the last error and left untouched.
+func (am *Manager) ReencryptAll(auths []string, kdf crypto.KDFParams) ([]ReencryptResult, error) {
+	if am.externalSigner() != nil {
+		return nil, ErrSignerManaged
+	}
+	if err := kdf.Validate(); err != nil {
+		return nil, err
+	}
+	addrs, err := am.keyStore.GetKeyAddresses()
+	if os.IsNotExist(err) {
+		return nil, ErrNoKeys
+	} else if err != nil {
+		return nil, err
+	}
+	results := make([]ReencryptResult, len(addrs))
+	for i, addr := range addrs {
+		results[i] = am.reencryptWithAny(addr, auths, kdf)
+	}
+	return results, nil
+}
+
+func (am *Manager) reencryptWithAny(addr common.Address, auths []string, kdf crypto.KDFParams) ReencryptResult {
+	res := ReencryptResult{Address: addr, Err: errors.New("no passphrase given")}
+	for _, auth := range auths {
+		if res.Format, res.Err = am.Reencrypt(addr, auth, kdf); res.Err == nil {
+			break
+		}
+	}
+	return res
+}
+
 func (am *Manager) ImportPreSaleKey(keyJSON []byte, password string) (acc Account, err error) {
+	if am.externalSigner() != nil {
+		return Account{}, ErrSignerManaged
+	}
 	var key *crypto.Key
 	key, err = crypto.ImportPreSaleKey(am.keyStore, keyJSON, password)
 	if err != nil {
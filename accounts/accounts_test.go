@@ -141,6 +141,130 @@ func TestSignRace(t *testing.T) {
 	t.Errorf("Account did not lock within the timeout")
 }
 
+func TestSignWithPassphrase(t *testing.T) {
+	dir, ks := tmpKeyStore(t, func(dir string) crypto.KeyStore {
+		return crypto.NewKeyStorePassphrase(dir, crypto.LightScryptN, crypto.LightScryptP)
+	})
+	defer os.RemoveAll(dir)
+
+	am := NewManager(ks)
+	a1, err := am.NewAccount("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := am.SignWithPassphrase(a1, "bar", testSigData); err == nil {
+		t.Fatal("Signing with the wrong passphrase should've failed")
+	}
+	sig, err := am.SignWithPassphrase(a1, "foo", testSigData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := crypto.SigToPub(testSigData, sig)
+	if err != nil || crypto.PubkeyToAddress(*pub) != a1.Address {
+		t.Errorf("Signature doesn't recover to the signer, got %v", err)
+	}
+	// The account must not stay unlocked
+	if _, err := am.Sign(a1, testSigData); err != ErrLocked {
+		t.Fatal("Signing should've failed with ErrLocked after passphrase signing, got ", err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	dir, ks := tmpKeyStore(t, crypto.NewKeyStorePlain)
+	defer os.RemoveAll(dir)
+
+	am := NewManager(ks)
+	a1, err := am.NewAccount("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := am.TimedUnlock(a1.Address, "", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := am.Lock(a1.Address); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := am.Sign(a1, testSigData); err != ErrLocked {
+		t.Fatal("Signing should've failed with ErrLocked after locking, got ", err)
+	}
+	// Locking a locked account is fine
+	if err := am.Lock(a1.Address); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportECDSA(t *testing.T) {
+	dir, ks := tmpKeyStore(t, crypto.NewKeyStorePlain)
+	defer os.RemoveAll(dir)
+
+	am := NewManager(ks)
+	key, _ := crypto.GenerateKey()
+	acc, err := am.ImportECDSA(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Address != crypto.PubkeyToAddress(key.PublicKey) || !am.HasAccount(acc.Address) {
+		t.Fatalf("Imported account mismatch: %x", acc.Address)
+	}
+	if _, err := am.ImportECDSA(key, ""); err != ErrAccountExists {
+		t.Fatalf("Reimport error mismatch: have %v, want %v", err, ErrAccountExists)
+	}
+}
+
+func TestReencryptAll(t *testing.T) {
+	dir, ks := tmpKeyStore(t, func(dir string) crypto.KeyStore {
+		return crypto.NewKeyStorePassphrase(dir, crypto.LightScryptN, crypto.LightScryptP)
+	})
+	defer os.RemoveAll(dir)
+
+	am := NewManager(ks)
+	a1, _ := am.NewAccount("foo")
+	a2, _ := am.NewAccount("bar")
+	a3, _ := am.NewAccount("baz")
+
+	kdf := crypto.PBKDF2KDF(1024)
+	results, err := am.ReencryptAll([]string{"bar", "foo"}, kdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Result count mismatch: have %d, want 3", len(results))
+	}
+	for _, res := range results {
+		switch res.Address {
+		case a1.Address, a2.Address:
+			if res.Err != nil {
+				t.Errorf("Failed to re-encrypt %x: %v", res.Address, res.Err)
+			}
+			if res.Format.KDF.KDF != "scrypt" || res.Format.Legacy() {
+				t.Errorf("Previous format mismatch for %x: %v", res.Address, res.Format)
+			}
+		case a3.Address:
+			if res.Err == nil {
+				t.Errorf("Re-encrypted %x without its passphrase", res.Address)
+			}
+		}
+	}
+	// The passphrases still unlock the re-encrypted keys
+	if err := am.Unlock(a1.Address, "foo"); err != nil {
+		t.Errorf("Failed to unlock re-encrypted account: %v", err)
+	}
+	if err := am.Unlock(a3.Address, "baz"); err != nil {
+		t.Errorf("Failed to unlock untouched account: %v", err)
+	}
+	if format, err := am.Reencrypt(a2.Address, "bar", kdf); err != nil || format.KDF != kdf {
+		t.Errorf("Re-encrypted format mismatch: have %v (%v), want %v", format, err, kdf)
+	}
+}
+
+func TestTextHash(t *testing.T) {
+	hash := TextHash([]byte("Hello Joe"))
+	want := crypto.Sha3([]byte("\x19Krypton Signed Message:\n9Hello Joe"))
+	if string(hash) != string(want) {
+		t.Errorf("Hash mismatch: have %x, want %x", hash, want)
+	}
+}
+
 func tmpKeyStore(t *testing.T, new func(string) crypto.KeyStore) (string, crypto.KeyStore) {
 	d, err := ioutil.TempDir("", "kr-keystore-test")
 	if err != nil {
@@ -0,0 +1,118 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package hd
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/krypton/go-krypton/crypto"
+)
+
+// ErrInvalidKey is returned in the astronomically unlikely case that a
+// derivation yields an invalid private key. BIP-32 mandates skipping to the
+// next index.
+var ErrInvalidKey = errors.New("derived key is invalid")
+
+// masterSecret is the HMAC key used to derive the master key from a seed.
+var masterSecret = []byte("Bitcoin seed")
+
+// ExtendedKey is a private key extended with the chain code needed to derive
+// its children.
+type ExtendedKey struct {
+	key       []byte // 32 byte private key
+	chainCode []byte // 32 byte chain code
+}
+
+// NewMasterKey derives the root of the key hierarchy from a seed.
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	mac := hmac.New(sha512.New, masterSecret)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	if !validKey(new(big.Int).SetBytes(sum[:32])) {
+		return nil, ErrInvalidKey
+	}
+	return &ExtendedKey{key: sum[:32], chainCode: sum[32:]}, nil
+}
+
+// Child derives the child key at the given index. Indexes of at least
+// HardenedOffset derive hardened children.
+func (self *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	// Hardened children hash the private key, normal ones the public key
+	data := make([]byte, 0, 37)
+	if index >= HardenedOffset {
+		data = append(append(data, 0), self.key...)
+	} else {
+		data = append(data, self.publicKey()...)
+	}
+	data = append(data, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[len(data)-4:], index)
+
+	mac := hmac.New(sha512.New, self.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	// The child key is the parent key tweaked by the left half of the hash
+	n := crypto.S256().Params().N
+	tweak := new(big.Int).SetBytes(sum[:32])
+	if tweak.Cmp(n) >= 0 {
+		return nil, ErrInvalidKey
+	}
+	key := tweak.Add(tweak, new(big.Int).SetBytes(self.key))
+	key.Mod(key, n)
+	if !validKey(key) {
+		return nil, ErrInvalidKey
+	}
+	return &ExtendedKey{key: padBytes(key.Bytes(), 32), chainCode: sum[32:]}, nil
+}
+
+// Derive derives the descendant key at the given path.
+func (self *ExtendedKey) Derive(path DerivationPath) (*ExtendedKey, error) {
+	key := self
+	for _, index := range path {
+		var err error
+		if key, err = key.Child(index); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// PrivateKey returns the private key as an ECDSA key.
+func (self *ExtendedKey) PrivateKey() *ecdsa.PrivateKey {
+	return crypto.ToECDSA(self.key)
+}
+
+// publicKey returns the public key in the 33 byte compressed encoding.
+func (self *ExtendedKey) publicKey() []byte {
+	x, y := crypto.S256().ScalarBaseMult(self.key)
+
+	pub := make([]byte, 33)
+	pub[0] = 2 + byte(y.Bit(0))
+	copy(pub[1:], padBytes(x.Bytes(), 32))
+	return pub
+}
+
+// validKey checks whether a number is a valid secp256k1 private key.
+func validKey(key *big.Int) bool {
+	return key.Sign() > 0 && key.Cmp(crypto.S256().Params().N) < 0
+}
@@ -0,0 +1,140 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package hd implements hierarchical deterministic keys: BIP-39 mnemonic
+// sentences, BIP-32 key derivation on the secp256k1 curve and BIP-44
+// derivation paths.
+package hd
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/krypton/go-krypton/crypto/randentropy"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// DefaultEntropyBits is the entropy of generated mnemonics, which results
+	// in 24 words.
+	DefaultEntropyBits = 256
+
+	seedIterations = 2048 // PBKDF2 rounds stretching a mnemonic into a seed
+	seedLength     = 64   // Length of the seed in bytes
+)
+
+var (
+	ErrEntropyLength    = errors.New("entropy must be 128-256 bits and a multiple of 32")
+	ErrMnemonicLength   = errors.New("mnemonic must have 12, 15, 18, 21 or 24 words")
+	ErrMnemonicChecksum = errors.New("mnemonic checksum mismatch")
+)
+
+// wordIndex maps the words of the word list to their index.
+var wordIndex = make(map[string]int, len(englishWords))
+
+func init() {
+	for i, word := range englishWords {
+		wordIndex[word] = i
+	}
+}
+
+// NewEntropy generates random entropy of the given bit size for a mnemonic.
+func NewEntropy(bits int) ([]byte, error) {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return nil, ErrEntropyLength
+	}
+	return randentropy.GetEntropyCSPRNG(bits / 8), nil
+}
+
+// NewMnemonic encodes entropy as a mnemonic sentence. Every word carries 11
+// bits: the entropy followed by a checksum of one bit per 32 bits of entropy.
+func NewMnemonic(entropy []byte) (string, error) {
+	bits := len(entropy) * 8
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", ErrEntropyLength
+	}
+	data, words := withChecksum(entropy), (bits+bits/32)/11
+
+	mask := big.NewInt(2047)
+	sentence := make([]string, words)
+	for i := words - 1; i >= 0; i-- {
+		sentence[i] = englishWords[new(big.Int).And(data, mask).Int64()]
+		data.Rsh(data, 11)
+	}
+	return strings.Join(sentence, " "), nil
+}
+
+// MnemonicToEntropy decodes a mnemonic sentence, verifying its checksum.
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, ErrMnemonicLength
+	}
+	data := new(big.Int)
+	for _, word := range words {
+		index, ok := wordIndex[strings.ToLower(word)]
+		if !ok {
+			return nil, fmt.Errorf("unknown mnemonic word %q", word)
+		}
+		data.Lsh(data, 11)
+		data.Or(data, big.NewInt(int64(index)))
+	}
+	// Split off the checksum and compare against the recomputed one
+	checksumBits := uint(len(words) / 3)
+	entropy := padBytes(new(big.Int).Rsh(data, checksumBits).Bytes(), int(checksumBits)*4)
+	if withChecksum(entropy).Cmp(data) != 0 {
+		return nil, ErrMnemonicChecksum
+	}
+	return entropy, nil
+}
+
+// ValidateMnemonic checks whether a sentence is a well formed mnemonic.
+func ValidateMnemonic(mnemonic string) bool {
+	_, err := MnemonicToEntropy(mnemonic)
+	return err == nil
+}
+
+// NewSeed stretches a mnemonic and an optional passphrase into the 64 byte
+// seed the master key is derived from. The mnemonic is not validated, and
+// Unicode normalization is not performed as the English word list is ASCII.
+func NewSeed(mnemonic, passphrase string) []byte {
+	sentence := strings.Join(strings.Fields(mnemonic), " ")
+	return pbkdf2.Key([]byte(sentence), []byte("mnemonic"+passphrase), seedIterations, seedLength, sha512.New)
+}
+
+// withChecksum returns the entropy followed by its checksum bits as a number.
+func withChecksum(entropy []byte) *big.Int {
+	checksumBits := uint(len(entropy) / 4)
+	hash := sha256.Sum256(entropy)
+
+	data := new(big.Int).SetBytes(entropy)
+	data.Lsh(data, checksumBits)
+	return data.Or(data, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+}
+
+// padBytes left pads a big endian number with zeroes to the given length.
+func padBytes(b []byte, length int) []byte {
+	if len(b) >= length {
+		return b
+	}
+	padded := make([]byte, length)
+	copy(padded[length-len(b):], b)
+	return padded
+}
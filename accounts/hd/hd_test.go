@@ -0,0 +1,182 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package hd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/krypton/go-krypton/crypto"
+)
+
+// Test vectors from the BIP-39 reference implementation.
+var mnemonicTests = []struct {
+	entropy, mnemonic, seed string
+}{
+	{
+		entropy:  "00000000000000000000000000000000",
+		mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		seed:     "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+	},
+	{
+		entropy:  "7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		mnemonic: "legal winner thank year wave sausage worth useful legal winner thank yellow",
+		seed:     "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+	},
+	{
+		entropy:  "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+		seed:     "dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad",
+	},
+}
+
+func TestMnemonic(t *testing.T) {
+	for i, tt := range mnemonicTests {
+		entropy, _ := hex.DecodeString(tt.entropy)
+		mnemonic, err := NewMnemonic(entropy)
+		if err != nil {
+			t.Fatalf("test %d: failed to create mnemonic: %v", i, err)
+		}
+		if mnemonic != tt.mnemonic {
+			t.Errorf("test %d: mnemonic mismatch: have %q, want %q", i, mnemonic, tt.mnemonic)
+		}
+		decoded, err := MnemonicToEntropy(mnemonic)
+		if err != nil {
+			t.Fatalf("test %d: failed to decode mnemonic: %v", i, err)
+		}
+		if !bytes.Equal(decoded, entropy) {
+			t.Errorf("test %d: entropy mismatch: have %x, want %x", i, decoded, entropy)
+		}
+		if seed := hex.EncodeToString(NewSeed(mnemonic, "TREZOR")); seed != tt.seed {
+			t.Errorf("test %d: seed mismatch: have %s, want %s", i, seed, tt.seed)
+		}
+	}
+}
+
+func TestMnemonicErrors(t *testing.T) {
+	tests := []struct {
+		mnemonic string
+		err      error
+	}{
+		{"abandon abandon abandon", ErrMnemonicLength},
+		{"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon", ErrMnemonicChecksum},
+		{"legal winner thank year wave sausage worth useful legal winner thank zoo", ErrMnemonicChecksum},
+	}
+	for i, tt := range tests {
+		if _, err := MnemonicToEntropy(tt.mnemonic); err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	if _, err := MnemonicToEntropy("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon krypton"); err == nil {
+		t.Errorf("expected error for unknown word")
+	}
+	if _, err := NewMnemonic(make([]byte, 15)); err != ErrEntropyLength {
+		t.Errorf("entropy length error mismatch: have %v, want %v", err, ErrEntropyLength)
+	}
+}
+
+func TestNewEntropy(t *testing.T) {
+	entropy, err := NewEntropy(DefaultEntropyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mnemonic, err := NewMnemonic(entropy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ValidateMnemonic(mnemonic) {
+		t.Errorf("generated mnemonic is invalid: %q", mnemonic)
+	}
+	if _, err := NewEntropy(100); err != ErrEntropyLength {
+		t.Errorf("entropy length error mismatch: have %v, want %v", err, ErrEntropyLength)
+	}
+}
+
+// Test vector 1 from the BIP-32 specification.
+func TestDerive(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path, key string
+	}{
+		{"m", "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35"},
+		{"m/0'", "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+		{"m/0'/1", "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+		{"m/0'/1/2'", "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca"},
+		{"m/0h/1/2h/2", "0f479245fb19a38a1954c5c7c0ebab2f9bdfd96a17563ef28a6a4b1a2a764ef4"},
+		{"m/0'/1/2'/2/1000000000", "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8"},
+	}
+	for i, tt := range tests {
+		key := master
+		if tt.path != "m" {
+			path, err := ParseDerivationPath(tt.path)
+			if err != nil {
+				t.Fatalf("test %d: failed to parse path: %v", i, err)
+			}
+			if key, err = master.Derive(path); err != nil {
+				t.Fatalf("test %d: failed to derive: %v", i, err)
+			}
+		}
+		if have := hex.EncodeToString(crypto.FromECDSA(key.PrivateKey())); have != tt.key {
+			t.Errorf("test %d: key mismatch for %s: have %s, want %s", i, tt.path, have, tt.key)
+		}
+	}
+}
+
+func TestDerivationPath(t *testing.T) {
+	tests := []struct {
+		input  string
+		path   DerivationPath
+		output string
+	}{
+		{"m/44'/60'/0'/0", DefaultBasePath, "m/44'/60'/0'/0"},
+		{"44h/60h/0h/0/5", DerivationPath{44 + HardenedOffset, 60 + HardenedOffset, HardenedOffset, 0, 5}, "m/44'/60'/0'/0/5"},
+		{"m/2147483647'", DerivationPath{0xffffffff}, "m/2147483647'"},
+		{"m", nil, ""},
+		{"m/", nil, ""},
+		{"m/-1", nil, ""},
+		{"m/2147483648", nil, ""},
+		{"m/1/x'", nil, ""},
+	}
+	for i, tt := range tests {
+		path, err := ParseDerivationPath(tt.input)
+		if tt.path == nil {
+			if err == nil {
+				t.Errorf("test %d: expected error for %q, got %v", i, tt.input, path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: failed to parse %q: %v", i, tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(path, tt.path) {
+			t.Errorf("test %d: path mismatch: have %v, want %v", i, path, tt.path)
+		}
+		if path.String() != tt.output {
+			t.Errorf("test %d: string mismatch: have %s, want %s", i, path, tt.output)
+		}
+	}
+	if child := DefaultBasePath.Child(3); child.String() != "m/44'/60'/0'/0/3" || len(DefaultBasePath) != 4 {
+		t.Errorf("child path mismatch: %v", child)
+	}
+}
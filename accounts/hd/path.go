@@ -0,0 +1,83 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package hd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// HardenedOffset is added to the index of hardened children, which are
+// derived from the private key of their parent.
+const HardenedOffset = 0x80000000
+
+// DefaultBasePath is the BIP-44 path under which the accounts of a wallet are
+// derived, m/purpose'/coin_type'/account'/change. Account i is derived at
+// DefaultBasePath/i. The coin type is the one registered for Ethereum, whose
+// key and address formats krypton shares.
+var DefaultBasePath = DerivationPath{44 + HardenedOffset, 60 + HardenedOffset, 0 + HardenedOffset, 0}
+
+// DerivationPath is the list of child indexes leading from the master key to
+// a derived key.
+type DerivationPath []uint32
+
+// ParseDerivationPath parses a path in the m/44'/60'/0'/0 notation. Hardened
+// indexes are marked with an apostrophe or an h suffix, the leading m is
+// optional.
+func ParseDerivationPath(path string) (DerivationPath, error) {
+	components := strings.Split(strings.TrimSpace(path), "/")
+	if len(components) > 0 && components[0] == "m" {
+		components = components[1:]
+	}
+	if len(components) == 0 || (len(components) == 1 && components[0] == "") {
+		return nil, fmt.Errorf("empty derivation path %q", path)
+	}
+	result := make(DerivationPath, len(components))
+	for i, component := range components {
+		var offset uint32
+		if strings.HasSuffix(component, "'") || strings.HasSuffix(component, "h") {
+			offset, component = HardenedOffset, component[:len(component)-1]
+		}
+		index, err := strconv.ParseUint(component, 10, 32)
+		if err != nil || index >= HardenedOffset {
+			return nil, fmt.Errorf("invalid index %q in derivation path %q", components[i], path)
+		}
+		result[i] = uint32(index) + offset
+	}
+	return result, nil
+}
+
+// Child returns the path extended by the given index.
+func (path DerivationPath) Child(index uint32) DerivationPath {
+	child := make(DerivationPath, len(path), len(path)+1)
+	copy(child, path)
+	return append(child, index)
+}
+
+// String formats the path in the m/44'/60'/0'/0 notation.
+func (path DerivationPath) String() string {
+	result := "m"
+	for _, index := range path {
+		if index >= HardenedOffset {
+			result += fmt.Sprintf("/%d'", index-HardenedOffset)
+		} else {
+			result += fmt.Sprintf("/%d", index)
+		}
+	}
+	return result
+}
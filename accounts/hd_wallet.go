@@ -0,0 +1,504 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the hierarchical deterministic wallets of the account manager. A
+// wallet stores the seed of a BIP-39 mnemonic, encrypted like the v3 key
+// files, along with the addresses of the accounts derived from it so far.
+// Account i of a wallet is derived at the BIP-44 path <base path>/i.
+
+package accounts
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/krypton/go-krypton/accounts/hd"
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/crypto"
+	"github.com/pborman/uuid"
+)
+
+const walletVersion = 1
+
+var (
+	ErrNoWalletStore   = errors.New("no wallet store configured")
+	ErrUnknownWallet   = errors.New("unknown wallet")
+	ErrDerivedAccount  = errors.New("account belongs to a hierarchical deterministic wallet")
+	errWalletDuplicate = errors.New("wallet already exists")
+)
+
+// Wallet describes a hierarchical deterministic wallet.
+type Wallet struct {
+	Id       string
+	BasePath string
+	Accounts []Account
+}
+
+// walletJSON is the on disk format of a wallet.
+type walletJSON struct {
+	Id        string          `json:"id"`
+	BasePath  string          `json:"basepath"`
+	Addresses []string        `json:"addresses"`
+	Crypto    json.RawMessage `json:"crypto"`
+	Version   int             `json:"version"`
+}
+
+// hdWallet is a loaded wallet. The seed is only available encrypted.
+type hdWallet struct {
+	id        string
+	basePath  hd.DerivationPath
+	addresses []common.Address
+	crypto    json.RawMessage
+}
+
+// walletStore keeps the wallets of a directory, one file per wallet.
+type walletStore struct {
+	dir     string
+	scryptN int
+	scryptP int
+	wallets map[string]*hdWallet
+}
+
+func newWalletStore(dir string, scryptN, scryptP int) (*walletStore, error) {
+	store := &walletStore{
+		dir:     dir,
+		scryptN: scryptN,
+		scryptP: scryptP,
+		wallets: make(map[string]*hdWallet),
+	}
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return store, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		wallet, err := loadWallet(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("invalid wallet %s: %v", file.Name(), err)
+		}
+		store.wallets[wallet.id] = wallet
+	}
+	return store, nil
+}
+
+func loadWallet(path string) (*hdWallet, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var enc walletJSON
+	if err := json.Unmarshal(blob, &enc); err != nil {
+		return nil, err
+	}
+	if enc.Version != walletVersion {
+		return nil, fmt.Errorf("version not supported: %v", enc.Version)
+	}
+	basePath, err := hd.ParseDerivationPath(enc.BasePath)
+	if err != nil {
+		return nil, err
+	}
+	wallet := &hdWallet{id: enc.Id, basePath: basePath, crypto: enc.Crypto}
+	for _, addr := range enc.Addresses {
+		wallet.addresses = append(wallet.addresses, common.HexToAddress(addr))
+	}
+	return wallet, nil
+}
+
+// path returns the file a wallet is stored in.
+func (self *walletStore) path(id string) string {
+	return filepath.Join(self.dir, id+".json")
+}
+
+// store writes a wallet to disk, replacing any previous version.
+func (self *walletStore) store(wallet *hdWallet) error {
+	enc := walletJSON{
+		Id:       wallet.id,
+		BasePath: wallet.basePath.String(),
+		Crypto:   wallet.crypto,
+		Version:  walletVersion,
+	}
+	for _, addr := range wallet.addresses {
+		enc.Addresses = append(enc.Addresses, hex.EncodeToString(addr[:]))
+	}
+	blob, err := json.Marshal(enc)
+	if err != nil {
+		return err
+	}
+	// read, write and dir search for user
+	if err := os.MkdirAll(self.dir, 0700); err != nil {
+		return err
+	}
+	// write to a temporary file first so a crash can't corrupt the wallet
+	path := self.path(wallet.id)
+	if err := ioutil.WriteFile(path+".tmp", blob, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// create encrypts the seed of a mnemonic into a new wallet and derives its
+// first account. It only reads the immutable encryption parameters of the
+// store, so it's safe to call without the wallet lock; see add.
+func (self *walletStore) create(mnemonic string, basePath hd.DerivationPath, auth string) (*hdWallet, error) {
+	seed := hd.NewSeed(mnemonic, "")
+	defer zeroBytes(seed)
+
+	master, err := hd.NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	first, err := deriveKey(master, basePath, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(first.PrivateKey)
+
+	encrypted, err := crypto.EncryptDataV3(seed, auth, self.scryptN, self.scryptP)
+	if err != nil {
+		return nil, err
+	}
+	wallet := &hdWallet{
+		id:        uuid.NewRandom().String(),
+		basePath:  basePath,
+		addresses: []common.Address{first.Address},
+		crypto:    encrypted,
+	}
+	return wallet, nil
+}
+
+// add stores a created wallet, unless its accounts duplicate a known wallet.
+func (self *walletStore) add(wallet *hdWallet) error {
+	for _, known := range self.wallets {
+		if len(known.addresses) > 0 && known.addresses[0] == wallet.addresses[0] && known.basePath.String() == wallet.basePath.String() {
+			return errWalletDuplicate
+		}
+	}
+	if err := self.store(wallet); err != nil {
+		return err
+	}
+	self.wallets[wallet.id] = wallet
+	return nil
+}
+
+// seed returns the encrypted seed of a wallet.
+func (self *walletStore) seed(id string) (json.RawMessage, error) {
+	wallet, ok := self.wallets[id]
+	if !ok {
+		return nil, ErrUnknownWallet
+	}
+	return wallet.crypto, nil
+}
+
+// derive derives the next account of a wallet from its decrypted master key.
+func (self *walletStore) derive(id string, master *hd.ExtendedKey) (common.Address, error) {
+	wallet, ok := self.wallets[id]
+	if !ok {
+		return common.Address{}, ErrUnknownWallet
+	}
+	// Skip the (practically impossible) invalid children as BIP-32 mandates
+	for index := uint32(len(wallet.addresses)); index < hd.HardenedOffset; index++ {
+		key, err := deriveKey(master, wallet.basePath, index)
+		if err == hd.ErrInvalidKey {
+			wallet.addresses = append(wallet.addresses, common.Address{})
+			continue
+		} else if err != nil {
+			return common.Address{}, err
+		}
+		zeroKey(key.PrivateKey)
+
+		wallet.addresses = append(wallet.addresses, key.Address)
+		if err := self.store(wallet); err != nil {
+			wallet.addresses = wallet.addresses[:index]
+			return common.Address{}, err
+		}
+		return key.Address, nil
+	}
+	return common.Address{}, errors.New("wallet exhausted")
+}
+
+// find returns the wallet an address was derived from and its index.
+func (self *walletStore) find(addr common.Address) (*hdWallet, int) {
+	for _, wallet := range self.wallets {
+		for i, derived := range wallet.addresses {
+			if derived == addr {
+				return wallet, i
+			}
+		}
+	}
+	return nil, -1
+}
+
+// getKey decrypts the private key of a derived account.
+func (self *walletStore) getKey(addr common.Address, auth string) (*crypto.Key, error) {
+	wallet, index := self.find(addr)
+	if wallet == nil {
+		return nil, ErrUnknownWallet
+	}
+	master, err := wallet.master(auth)
+	if err != nil {
+		return nil, err
+	}
+	return deriveKey(master, wallet.basePath, uint32(index))
+}
+
+// reencrypt encrypts a wallet seed again with a new passphrase. Like create, it
+// is safe to call without the wallet lock.
+func (self *walletStore) reencrypt(encrypted json.RawMessage, authFrom, authTo string) (json.RawMessage, error) {
+	seed, err := crypto.DecryptDataV3(encrypted, authFrom)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(seed)
+
+	return crypto.EncryptDataV3(seed, authTo, self.scryptN, self.scryptP)
+}
+
+// update replaces the encrypted seed of a wallet.
+func (self *walletStore) update(id string, encrypted json.RawMessage) error {
+	wallet, ok := self.wallets[id]
+	if !ok {
+		return ErrUnknownWallet
+	}
+	updated := *wallet
+	updated.crypto = encrypted
+	if err := self.store(&updated); err != nil {
+		return err
+	}
+	wallet.crypto = encrypted
+	return nil
+}
+
+// list returns the descriptions of all wallets, ordered by id.
+func (self *walletStore) list() []Wallet {
+	wallets := make([]Wallet, 0, len(self.wallets))
+	for _, wallet := range self.wallets {
+		wallets = append(wallets, wallet.info())
+	}
+	sort.Sort(walletsById(wallets))
+	return wallets
+}
+
+// master decrypts the seed of the wallet and derives the master key.
+func (self *hdWallet) master(auth string) (*hd.ExtendedKey, error) {
+	return masterKey(self.crypto, auth)
+}
+
+func (self *hdWallet) info() Wallet {
+	wallet := Wallet{Id: self.id, BasePath: self.basePath.String()}
+	for _, addr := range self.addresses {
+		if addr != (common.Address{}) {
+			wallet.Accounts = append(wallet.Accounts, Account{Address: addr})
+		}
+	}
+	return wallet
+}
+
+type walletsById []Wallet
+
+func (s walletsById) Len() int           { return len(s) }
+func (s walletsById) Less(i, j int) bool { return s[i].Id < s[j].Id }
+func (s walletsById) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// masterKey decrypts a wallet seed and derives the master key.
+func masterKey(encrypted json.RawMessage, auth string) (*hd.ExtendedKey, error) {
+	seed, err := crypto.DecryptDataV3(encrypted, auth)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(seed)
+
+	return hd.NewMasterKey(seed)
+}
+
+// deriveKey derives the key of account index below the base path.
+func deriveKey(master *hd.ExtendedKey, basePath hd.DerivationPath, index uint32) (*crypto.Key, error) {
+	child, err := master.Derive(basePath.Child(index))
+	if err != nil {
+		return nil, err
+	}
+	return crypto.NewKeyFromECDSA(child.PrivateKey()), nil
+}
+
+// zeroBytes zeroes a byte slice in memory.
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// SetWalletStore enables hierarchical deterministic wallets, which are stored
+// in the given directory encrypted with the given scrypt parameters.
+func (am *Manager) SetWalletStore(dir string, scryptN, scryptP int) error {
+	store, err := newWalletStore(dir, scryptN, scryptP)
+	if err != nil {
+		return err
+	}
+	am.walletLock.Lock()
+	am.wallets = store
+	am.walletLock.Unlock()
+	return nil
+}
+
+// NewWallet generates a new mnemonic and stores its wallet, protected by the
+// passphrase. The mnemonic is returned for the user to write down, it is the
+// only way to restore the wallet if the passphrase or the files are lost.
+func (am *Manager) NewWallet(basePath hd.DerivationPath, auth string) (string, Wallet, error) {
+	entropy, err := hd.NewEntropy(hd.DefaultEntropyBits)
+	if err != nil {
+		return "", Wallet{}, err
+	}
+	mnemonic, err := hd.NewMnemonic(entropy)
+	if err != nil {
+		return "", Wallet{}, err
+	}
+	wallet, err := am.ImportMnemonic(mnemonic, basePath, auth)
+	return mnemonic, wallet, err
+}
+
+// ImportMnemonic restores the wallet of a mnemonic, protected by the
+// passphrase. Only the first account is derived, further ones need to be
+// derived with DeriveAccount.
+func (am *Manager) ImportMnemonic(mnemonic string, basePath hd.DerivationPath, auth string) (Wallet, error) {
+	if am.externalSigner() != nil {
+		return Wallet{}, ErrSignerManaged
+	}
+	if !hd.ValidateMnemonic(mnemonic) {
+		return Wallet{}, errors.New("invalid mnemonic")
+	}
+	if basePath == nil {
+		basePath = hd.DefaultBasePath
+	}
+	// Encrypt the seed without the lock, only adding the wallet holds it
+	am.walletLock.RLock()
+	store := am.wallets
+	am.walletLock.RUnlock()
+	if store == nil {
+		return Wallet{}, ErrNoWalletStore
+	}
+	wallet, err := store.create(mnemonic, basePath, auth)
+	if err != nil {
+		return Wallet{}, err
+	}
+	am.walletLock.Lock()
+	if am.wallets == nil {
+		am.walletLock.Unlock()
+		return Wallet{}, ErrNoWalletStore
+	}
+	if err := am.wallets.add(wallet); err != nil {
+		am.walletLock.Unlock()
+		return Wallet{}, err
+	}
+	info, path := wallet.info(), am.wallets.path(wallet.id)
+	am.walletLock.Unlock()
+
+	am.postAdded(info.Accounts[0].Address, path)
+	return info, nil
+}
+
+// DeriveAccount derives the next account of a wallet.
+func (am *Manager) DeriveAccount(id string, auth string) (Account, error) {
+	if am.externalSigner() != nil {
+		return Account{}, ErrSignerManaged
+	}
+	// Decrypt the seed without the lock, only deriving and storing the account
+	// holds it
+	am.walletLock.RLock()
+	if am.wallets == nil {
+		am.walletLock.RUnlock()
+		return Account{}, ErrNoWalletStore
+	}
+	encrypted, err := am.wallets.seed(id)
+	am.walletLock.RUnlock()
+	if err != nil {
+		return Account{}, err
+	}
+	master, err := masterKey(encrypted, auth)
+	if err != nil {
+		return Account{}, err
+	}
+	am.walletLock.Lock()
+	if am.wallets == nil {
+		am.walletLock.Unlock()
+		return Account{}, ErrNoWalletStore
+	}
+	addr, err := am.wallets.derive(id, master)
+	path := am.wallets.path(id)
+	am.walletLock.Unlock()
+	if err != nil {
+		return Account{}, err
+	}
+	am.postAdded(addr, path)
+	return Account{Address: addr}, nil
+}
+
+// postAdded announces a derived account to the subscribers of the manager.
+func (am *Manager) postAdded(addr common.Address, path string) {
+	am.keyStore.Events().Post(crypto.KeyAddedEvent{Address: addr, Path: path})
+}
+
+// Wallets returns the hierarchical deterministic wallets and their derived
+// accounts.
+func (am *Manager) Wallets() []Wallet {
+	am.walletLock.RLock()
+	defer am.walletLock.RUnlock()
+
+	if am.wallets == nil {
+		return nil
+	}
+	return am.wallets.list()
+}
+
+// walletAccounts returns the accounts derived from all wallets.
+func (am *Manager) walletAccounts() []Account {
+	var accounts []Account
+	for _, wallet := range am.Wallets() {
+		accounts = append(accounts, wallet.Accounts...)
+	}
+	return accounts
+}
+
+// walletOf returns the wallet an address was derived from, if any. The caller
+// must hold the wallet lock.
+func (am *Manager) walletOf(addr common.Address) *hdWallet {
+	if am.wallets == nil {
+		return nil
+	}
+	wallet, _ := am.wallets.find(addr)
+	return wallet
+}
+
+// getKey retrieves the private key of an account, either from the key store
+// or derived from the wallet it belongs to.
+func (am *Manager) getKey(addr common.Address, auth string) (*crypto.Key, error) {
+	am.walletLock.RLock()
+	defer am.walletLock.RUnlock()
+
+	if am.walletOf(addr) != nil {
+		return am.wallets.getKey(addr, auth)
+	}
+	return am.keyStore.GetKey(addr, auth)
+}
@@ -0,0 +1,181 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package accounts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/crypto"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func tmpWalletManager(t *testing.T) (string, *Manager) {
+	dir, ks := tmpKeyStore(t, crypto.NewKeyStorePlain)
+	am := NewManager(ks)
+	if err := am.SetWalletStore(filepath.Join(dir, "wallets"), crypto.LightScryptN, crypto.LightScryptP); err != nil {
+		t.Fatal(err)
+	}
+	return dir, am
+}
+
+func TestImportMnemonic(t *testing.T) {
+	dir, am := tmpWalletManager(t)
+	defer os.RemoveAll(dir)
+
+	wallet, err := am.ImportMnemonic(testMnemonic, nil, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first account of the standard test mnemonic, derived at m/44'/60'/0'/0/0
+	want := common.HexToAddress("0x9858EfFD232B4033E47d90003D41EC34EcaEda94")
+	if len(wallet.Accounts) != 1 || wallet.Accounts[0].Address != want {
+		t.Fatalf("first account mismatch: have %v, want %x", wallet.Accounts, want)
+	}
+	if wallet.BasePath != "m/44'/60'/0'/0" {
+		t.Errorf("base path mismatch: have %s", wallet.BasePath)
+	}
+	if _, err := am.ImportMnemonic(testMnemonic, nil, "bar"); err != errWalletDuplicate {
+		t.Errorf("duplicate import error mismatch: have %v, want %v", err, errWalletDuplicate)
+	}
+	if _, err := am.ImportMnemonic("abandon abandon abandon", nil, "foo"); err == nil {
+		t.Errorf("expected error for invalid mnemonic")
+	}
+}
+
+func TestDeriveAccount(t *testing.T) {
+	dir, am := tmpWalletManager(t)
+	defer os.RemoveAll(dir)
+
+	mnemonic, wallet, err := am.NewWallet(nil, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := am.DeriveAccount(wallet.Id, "bar"); err == nil {
+		t.Fatalf("derived account with wrong passphrase")
+	}
+	if _, err := am.DeriveAccount("missing", "foo"); err != ErrUnknownWallet {
+		t.Fatalf("unknown wallet error mismatch: have %v, want %v", err, ErrUnknownWallet)
+	}
+	second, err := am.DeriveAccount(wallet.Id, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts, err := am.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 2 || accounts[0] != wallet.Accounts[0] || accounts[1] != second {
+		t.Fatalf("account list mismatch: %v", accounts)
+	}
+	// Derived accounts are unlocked with the wallet passphrase and can sign
+	if err := am.Unlock(second.Address, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := am.Sign(second, testSigData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := crypto.SigToPub(testSigData, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr := crypto.PubkeyToAddress(*pub); addr != second.Address {
+		t.Errorf("signer mismatch: have %x, want %x", addr, second.Address)
+	}
+	if err := am.DeleteAccount(second.Address, "foo"); err != ErrDerivedAccount {
+		t.Errorf("delete error mismatch: have %v, want %v", err, ErrDerivedAccount)
+	}
+	// Changing the passphrase applies to the whole wallet
+	if err := am.Update(second.Address, "foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if err := am.Unlock(wallet.Accounts[0].Address, "bar"); err != nil {
+		t.Errorf("failed to unlock with new passphrase: %v", err)
+	}
+	// The wallet survives a restart and matches the mnemonic
+	restarted := NewManager(am.keyStore)
+	if err := restarted.SetWalletStore(filepath.Join(dir, "wallets"), crypto.LightScryptN, crypto.LightScryptP); err != nil {
+		t.Fatal(err)
+	}
+	wallets := restarted.Wallets()
+	if len(wallets) != 1 || len(wallets[0].Accounts) != 2 || wallets[0].Accounts[1] != second {
+		t.Fatalf("reloaded wallets mismatch: %v", wallets)
+	}
+	if _, err := restarted.ImportMnemonic(mnemonic, nil, "baz"); err != errWalletDuplicate {
+		t.Errorf("reimport error mismatch: have %v, want %v", err, errWalletDuplicate)
+	}
+}
+
+// Tests that accounts derived concurrently, while decrypting the wallet seed in
+// parallel, are all distinct and stored.
+func TestDeriveAccountConcurrently(t *testing.T) {
+	dir, am := tmpWalletManager(t)
+	defer os.RemoveAll(dir)
+
+	_, wallet, err := am.NewWallet(nil, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const derivations = 8
+	errc := make(chan error, derivations)
+	for i := 0; i < derivations; i++ {
+		go func() {
+			_, err := am.DeriveAccount(wallet.Id, "foo")
+			errc <- err
+		}()
+	}
+	for i := 0; i < derivations; i++ {
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+	wallets := am.Wallets()
+	if len(wallets) != 1 || len(wallets[0].Accounts) != derivations+1 {
+		t.Fatalf("derived accounts mismatch: %v", wallets)
+	}
+	seen := make(map[common.Address]bool)
+	for _, account := range wallets[0].Accounts {
+		if seen[account.Address] {
+			t.Errorf("account %x derived twice", account.Address)
+		}
+		seen[account.Address] = true
+	}
+	restarted := NewManager(am.keyStore)
+	if err := restarted.SetWalletStore(filepath.Join(dir, "wallets"), crypto.LightScryptN, crypto.LightScryptP); err != nil {
+		t.Fatal(err)
+	}
+	if wallets := restarted.Wallets(); len(wallets) != 1 || len(wallets[0].Accounts) != derivations+1 {
+		t.Errorf("reloaded accounts mismatch: %v", wallets)
+	}
+}
+
+func TestNoWalletStore(t *testing.T) {
+	dir, ks := tmpKeyStore(t, crypto.NewKeyStorePlain)
+	defer os.RemoveAll(dir)
+
+	am := NewManager(ks)
+	if _, _, err := am.NewWallet(nil, "foo"); err != ErrNoWalletStore {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrNoWalletStore)
+	}
+	if wallets := am.Wallets(); len(wallets) != 0 {
+		t.Errorf("unexpected wallets: %v", wallets)
+	}
+}
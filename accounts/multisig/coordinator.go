@@ -0,0 +1,483 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package multisig coordinates the confirmations of multisig wallet calls
+// over whisper.
+//
+// A proposer publishes a proposed call of a wallet contract to the whisper
+// identities of its co-signers, encrypted to each of them and signed with the
+// identity of the proposer. The co-signers review the call decoded with the
+// wallet ABI they configured locally and confirm it by signing the prefixed
+// proposal hash (see SigHash) with one of their accounts. The signatures are
+// sent back to the proposer, which submits the call once the threshold of
+// confirmations is met.
+package multisig
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/krypton/go-krypton/accounts"
+	"github.com/krypton/go-krypton/accounts/abi"
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/crypto"
+	"github.com/krypton/go-krypton/logger"
+	"github.com/krypton/go-krypton/logger/glog"
+	"github.com/krypton/go-krypton/whisper"
+)
+
+// Topic is the whisper topic proposals and confirmations are sent with.
+var Topic = whisper.NewTopicFromString("multisig")
+
+const (
+	proposalTTL  = 10 * time.Minute // Lifetime of proposals, both as envelopes and tracked
+	maxProposals = 256              // Maximum number of proposals tracked at once
+)
+
+var (
+	ErrUnknownProposal  = errors.New("unknown proposal")
+	ErrNotSigner        = errors.New("account is not a signer of the proposal")
+	ErrUnknownIdentity  = errors.New("unknown whisper identity")
+	ErrNoCosigners      = errors.New("no co-signer identities given")
+	ErrNotOwn           = errors.New("proposal not made by this node")
+	ErrThreshold        = errors.New("threshold of confirmations not met")
+	ErrSubmitted        = errors.New("proposal already submitted")
+	ErrTooManyProposals = errors.New("too many pending proposals")
+)
+
+// SubmitFunc submits a proposal that met its threshold, given the signatures
+// of the confirming signers ordered like the signer list. It returns the hash
+// of the submitted transaction.
+type SubmitFunc func(p *Proposal, sigs [][]byte) (common.Hash, error)
+
+// Status is the state of a proposal known to the coordinator.
+type Status struct {
+	Hash      common.Hash
+	Proposal  *Proposal
+	Call      *Call            // Decoded call, nil without a wallet ABI or if it can't be decoded
+	Own       bool             // Whether this node proposed it and collects its confirmations
+	Confirmed []common.Address // Signers whose confirmations are known
+	Expires   time.Time        // Time the proposal is dropped unless submitted before
+	Error     error            // Reason of a failed submission
+}
+
+// proposal is a tracked proposal.
+type proposal struct {
+	proposal  *Proposal
+	hash      common.Hash
+	identity  *ecdsa.PublicKey // Local identity that proposed or received it
+	proposer  *ecdsa.PublicKey // Identity of the proposer, confirmations are sent to
+	confirmed map[common.Address][]byte
+	expires   time.Time // Time the proposal is dropped
+
+	submitting bool  // Whether a submission is in progress
+	submitted  bool  // Whether the proposal was submitted successfully
+	err        error // Error of the last submission attempt
+}
+
+// own reports whether the proposal was made by this node.
+func (p *proposal) own() bool {
+	return p.identity == p.proposer
+}
+
+// Coordinator tracks the proposals sent to or made by the whisper identities
+// of a node. Received proposals are only tracked if one of their signers is a
+// local account or if they were sent by a configured co-signer. Proposals are
+// dropped once submitted or after proposalTTL.
+type Coordinator struct {
+	shh       *whisper.Whisper
+	am        *accounts.Manager
+	submit    SubmitFunc
+	watch     int
+	wallet    *abi.ABI            // Trusted ABI of the wallets, nil if calls aren't decoded
+	cosigners map[string]struct{} // Whisper identities trusted to propose, by public key
+
+	proposals map[common.Hash]*proposal
+	lock      sync.RWMutex
+}
+
+// New creates a coordinator serving the identities of the whisper node,
+// signing confirmations with the accounts of am and submitting proposals that
+// met their threshold with submit.
+func New(shh *whisper.Whisper, am *accounts.Manager, submit SubmitFunc) *Coordinator {
+	c := &Coordinator{
+		shh:       shh,
+		am:        am,
+		submit:    submit,
+		cosigners: make(map[string]struct{}),
+		proposals: make(map[common.Hash]*proposal),
+	}
+	c.watch = shh.Watch(whisper.Filter{
+		Topics: [][]whisper.Topic{{Topic}},
+		Fn:     c.handle,
+	})
+	return c
+}
+
+// SetWalletABI configures the ABI proposed calls are decoded with for review.
+// The ABI is not part of the proposals, so co-signers decode calls with an
+// ABI they trust instead of one supplied by the proposer.
+func (self *Coordinator) SetWalletABI(wallet abi.ABI) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.wallet = &wallet
+}
+
+// SetCosigners configures the whisper identities whose proposals are tracked
+// even if none of their signers is a local account.
+func (self *Coordinator) SetCosigners(cosigners []*ecdsa.PublicKey) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.cosigners = make(map[string]struct{})
+	for _, cosigner := range cosigners {
+		self.cosigners[string(crypto.FromECDSAPub(cosigner))] = struct{}{}
+	}
+}
+
+// Stop stops processing messages.
+func (self *Coordinator) Stop() {
+	self.shh.Unwatch(self.watch)
+}
+
+// Propose sends a proposal to the co-signer identities, signed with the given
+// local identity. Confirmations are collected from the replies, as well as
+// from the local accounts confirming with Confirm.
+func (self *Coordinator) Propose(identity *ecdsa.PublicKey, p *Proposal, cosigners []*ecdsa.PublicKey) (common.Hash, error) {
+	if err := p.Validate(); err != nil {
+		return common.Hash{}, err
+	}
+	if len(cosigners) == 0 {
+		return common.Hash{}, ErrNoCosigners
+	}
+	key := self.shh.GetIdentity(identity)
+	if key == nil {
+		return common.Hash{}, ErrUnknownIdentity
+	}
+	payload, err := encodeMessage(proposalMsg, p)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	hash := p.Hash()
+
+	self.lock.Lock()
+	self.expire()
+	if _, ok := self.proposals[hash]; ok {
+		self.lock.Unlock()
+		return common.Hash{}, fmt.Errorf("proposal %x already known", hash[:4])
+	}
+	if len(self.proposals) >= maxProposals {
+		self.lock.Unlock()
+		return common.Hash{}, ErrTooManyProposals
+	}
+	self.proposals[hash] = &proposal{
+		proposal:  p,
+		hash:      hash,
+		identity:  &key.PublicKey,
+		proposer:  &key.PublicKey,
+		confirmed: make(map[common.Address][]byte),
+		expires:   time.Now().Add(proposalTTL),
+	}
+	self.lock.Unlock()
+
+	for _, cosigner := range cosigners {
+		if err := self.send(key, cosigner, payload); err != nil {
+			return hash, err
+		}
+	}
+	glog.V(logger.Info).Infof("Proposed multisig call %x of wallet %x to %d co-signers", hash[:4], p.Wallet, len(cosigners))
+	return hash, nil
+}
+
+// Confirm signs the proposal with the account of a signer, which has to be
+// unlocked, and passes the confirmation on to the proposer. The account signs
+// SigHash of the proposal hash, never the plain hash.
+func (self *Coordinator) Confirm(hash common.Hash, signer common.Address) error {
+	p, ok := self.get(hash)
+	if !ok {
+		return ErrUnknownProposal
+	}
+	if p.proposal.signerIndex(signer) < 0 {
+		return ErrNotSigner
+	}
+	sig, err := self.am.Sign(accounts.Account{Address: signer}, SigHash(hash))
+	if err != nil {
+		return err
+	}
+	conf := &Confirmation{Proposal: hash, Signer: signer, Signature: sig}
+	if p.own() {
+		return self.confirmed(p, conf)
+	}
+	key := self.shh.GetIdentity(p.identity)
+	if key == nil {
+		return ErrUnknownIdentity
+	}
+	payload, err := encodeMessage(confirmationMsg, conf)
+	if err != nil {
+		return err
+	}
+	if err := self.send(key, p.proposer, payload); err != nil {
+		return err
+	}
+	self.lock.Lock()
+	p.confirmed[signer] = sig
+	self.lock.Unlock()
+	return nil
+}
+
+// Proposals returns the state of all known proposals.
+func (self *Coordinator) Proposals() []*Status {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.expire()
+
+	statuses := make([]*Status, 0, len(self.proposals))
+	for _, p := range self.proposals {
+		statuses = append(statuses, self.status(p))
+	}
+	return statuses
+}
+
+// Proposal returns the state of a proposal.
+func (self *Coordinator) Proposal(hash common.Hash) (*Status, error) {
+	p, ok := self.get(hash)
+	if !ok {
+		return nil, ErrUnknownProposal
+	}
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	return self.status(p), nil
+}
+
+// get retrieves a tracked proposal that didn't expire yet.
+func (self *Coordinator) get(hash common.Hash) (*proposal, bool) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	p, ok := self.proposals[hash]
+	if !ok || time.Now().After(p.expires) {
+		return nil, false
+	}
+	return p, true
+}
+
+// status assembles the state of a proposal. The caller must hold the lock.
+func (self *Coordinator) status(p *proposal) *Status {
+	status := &Status{
+		Hash:     p.hash,
+		Proposal: p.proposal,
+		Own:      p.own(),
+		Expires:  p.expires,
+		Error:    p.err,
+	}
+	if self.wallet != nil {
+		status.Call, _ = p.proposal.Call(*self.wallet)
+	}
+	for _, signer := range p.proposal.Signers {
+		if _, ok := p.confirmed[signer]; ok {
+			status.Confirmed = append(status.Confirmed, signer)
+		}
+	}
+	return status
+}
+
+// send posts a payload signed by one identity and encrypted to another.
+func (self *Coordinator) send(from *ecdsa.PrivateKey, to *ecdsa.PublicKey, payload []byte) error {
+	// Seal with at least the work the local node requires to relay the message
+	envelope, err := whisper.NewMessage(payload).Wrap(self.shh.MinPoW(), whisper.Options{
+		From:   from,
+		To:     to,
+		TTL:    proposalTTL,
+		Topics: []whisper.Topic{Topic},
+	})
+	if err != nil {
+		return err
+	}
+	return self.shh.Send(envelope)
+}
+
+// handle processes a message received on the multisig topic. Only messages
+// encrypted to a local identity and signed by their sender are accepted.
+func (self *Coordinator) handle(msg *whisper.Message) {
+	sender := msg.Recover()
+	if msg.To == nil || sender == nil {
+		return
+	}
+	decoded, err := decodeMessage(msg.Payload)
+	if err != nil {
+		glog.V(logger.Debug).Infof("Dropping invalid multisig message from %x: %v", crypto.FromECDSAPub(sender)[1:9], err)
+		return
+	}
+	switch m := decoded.(type) {
+	case *Proposal:
+		hash := m.Hash()
+		if !self.involved(m, sender) {
+			glog.V(logger.Debug).Infof("Dropping multisig proposal %x not involving this node", hash[:4])
+			return
+		}
+		self.lock.Lock()
+		defer self.lock.Unlock()
+
+		self.expire()
+		if _, ok := self.proposals[hash]; ok {
+			return
+		}
+		if len(self.proposals) >= maxProposals {
+			glog.V(logger.Debug).Infof("Dropping multisig proposal %x: %v", hash[:4], ErrTooManyProposals)
+			return
+		}
+		// Track the proposal as long as its envelope lives, within our own limit
+		expires := msg.Sent.Add(msg.TTL)
+		if limit := time.Now().Add(proposalTTL); expires.After(limit) {
+			expires = limit
+		}
+		self.proposals[hash] = &proposal{
+			proposal:  m,
+			hash:      hash,
+			identity:  msg.To,
+			proposer:  sender,
+			confirmed: make(map[common.Address][]byte),
+			expires:   expires,
+		}
+		glog.V(logger.Info).Infof("Received multisig proposal %x for wallet %x", hash[:4], m.Wallet)
+
+	case *Confirmation:
+		p, ok := self.get(m.Proposal)
+
+		// Only the proposer collects confirmations, sent to its identity
+		if !ok || !p.own() || string(crypto.FromECDSAPub(p.identity)) != string(crypto.FromECDSAPub(msg.To)) {
+			return
+		}
+		if err := self.confirmed(p, m); err != nil {
+			glog.V(logger.Debug).Infof("Dropping multisig confirmation of %x: %v", m.Proposal[:4], err)
+		}
+	}
+}
+
+// involved reports whether a received proposal concerns this node, i.e. if one
+// of its signers is a local account or its sender a configured co-signer.
+func (self *Coordinator) involved(p *Proposal, sender *ecdsa.PublicKey) bool {
+	self.lock.RLock()
+	_, trusted := self.cosigners[string(crypto.FromECDSAPub(sender))]
+	self.lock.RUnlock()
+
+	if trusted {
+		return true
+	}
+	for _, signer := range p.Signers {
+		if self.am.HasAccount(signer) {
+			return true
+		}
+	}
+	return false
+}
+
+// expire drops the proposals whose lifetime passed. The caller must hold the
+// lock.
+func (self *Coordinator) expire() {
+	now := time.Now()
+	for hash, p := range self.proposals {
+		if now.After(p.expires) {
+			delete(self.proposals, hash)
+		}
+	}
+}
+
+// confirmed records the confirmation of an own proposal, submitting it if the
+// threshold of confirmations is met and it wasn't submitted yet.
+func (self *Coordinator) confirmed(p *proposal, conf *Confirmation) error {
+	if p.proposal.signerIndex(conf.Signer) < 0 {
+		return ErrNotSigner
+	}
+	if err := conf.verify(); err != nil {
+		return err
+	}
+	self.lock.Lock()
+	if _, ok := p.confirmed[conf.Signer]; ok {
+		self.lock.Unlock()
+		return nil
+	}
+	p.confirmed[conf.Signer] = conf.Signature
+	self.lock.Unlock()
+
+	switch _, err := self.trySubmit(p); err {
+	case ErrThreshold, ErrSubmitted:
+		return nil
+	default:
+		return err
+	}
+}
+
+// Submit submits an own proposal whose threshold of confirmations is met,
+// e.g. to retry after a failed submission. It returns the hash of the
+// submitting transaction.
+func (self *Coordinator) Submit(hash common.Hash) (common.Hash, error) {
+	p, ok := self.get(hash)
+	if !ok {
+		return common.Hash{}, ErrUnknownProposal
+	}
+	if !p.own() {
+		return common.Hash{}, ErrNotOwn
+	}
+	return self.trySubmit(p)
+}
+
+// trySubmit submits the proposal with the signatures of the first threshold
+// confirming signers, unless it lacks confirmations or is already submitted.
+// Successfully submitted proposals are dropped.
+func (self *Coordinator) trySubmit(p *proposal) (common.Hash, error) {
+	self.lock.Lock()
+	if p.submitting || p.submitted {
+		self.lock.Unlock()
+		return common.Hash{}, ErrSubmitted
+	}
+	if uint64(len(p.confirmed)) < p.proposal.Threshold {
+		self.lock.Unlock()
+		return common.Hash{}, ErrThreshold
+	}
+	sigs := make([][]byte, 0, p.proposal.Threshold)
+	for _, signer := range p.proposal.Signers {
+		if sig, ok := p.confirmed[signer]; ok && uint64(len(sigs)) < p.proposal.Threshold {
+			sigs = append(sigs, sig)
+		}
+	}
+	p.submitting = true
+	self.lock.Unlock()
+
+	// Submit outside of the lock
+	tx, err := self.submit(p.proposal, sigs)
+
+	self.lock.Lock()
+	p.submitting, p.err = false, err
+	if err == nil {
+		p.submitted = true
+		delete(self.proposals, p.hash)
+	}
+	self.lock.Unlock()
+
+	if err != nil {
+		glog.V(logger.Error).Infof("Failed to submit multisig proposal %x: %v", p.hash[:4], err)
+		return common.Hash{}, err
+	}
+	glog.V(logger.Info).Infof("Submitted multisig proposal %x in transaction %x", p.hash[:4], tx[:4])
+	return tx, nil
+}
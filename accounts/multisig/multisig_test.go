@@ -0,0 +1,366 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package multisig
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krypton/go-krypton/accounts"
+	"github.com/krypton/go-krypton/accounts/abi"
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/crypto"
+	"github.com/krypton/go-krypton/p2p"
+	"github.com/krypton/go-krypton/p2p/simulations"
+	"github.com/krypton/go-krypton/whisper"
+)
+
+const testABI = `[
+	{ "name" : "execute", "const" : false, "inputs" : [
+		{ "name" : "to", "type" : "address" },
+		{ "name" : "amount", "type" : "uint256" }
+	] }
+]`
+
+var (
+	testWallet    = common.HexToAddress("0x289d485d9771714cce91d3393d764e1311907acc")
+	testRecipient = common.HexToAddress("0xf466859ead1932d743d622cb74fc058882e8648a")
+)
+
+// testNode is a co-signer running a coordinator on a simulated whisper node.
+type testNode struct {
+	coordinator *Coordinator
+	identity    *ecdsa.PrivateKey
+	account     accounts.Account
+}
+
+// startTestNodes runs n connected whisper nodes, each with a coordinator and
+// an unlocked account.
+func startTestNodes(t *testing.T, n int, submit SubmitFunc) ([]*testNode, func()) {
+	dir, err := ioutil.TempDir("", "kr-multisig-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	whispers := make([]*whisper.Whisper, n)
+	network, err := simulations.New(simulations.Config{
+		Nodes: n,
+		Protocols: func(i int) []p2p.Protocol {
+			whispers[i] = whisper.New()
+			whispers[i].Start()
+			return whispers[i].Protocols()
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create network: %v", err)
+	}
+	if err := network.ConnectTopology(simulations.Full); err != nil {
+		t.Fatalf("failed to connect network: %v", err)
+	}
+	wallet, err := abi.JSON(strings.NewReader(testABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := make([]*testNode, n)
+	for i := range nodes {
+		am := accounts.NewManager(crypto.NewKeyStorePlain(filepath.Join(dir, fmt.Sprint(i))))
+		acc, err := am.NewAccount("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := am.Unlock(acc.Address, ""); err != nil {
+			t.Fatal(err)
+		}
+		nodes[i] = &testNode{
+			coordinator: New(whispers[i], am, submit),
+			identity:    whispers[i].NewIdentity(),
+			account:     acc,
+		}
+		nodes[i].coordinator.SetWalletABI(wallet)
+	}
+	return nodes, func() {
+		for i, node := range nodes {
+			node.coordinator.Stop()
+			whispers[i].Stop()
+		}
+		network.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+func newTestProposal(t *testing.T, nodes []*testNode, threshold uint64) *Proposal {
+	wallet, err := abi.JSON(strings.NewReader(testABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := wallet.Pack("execute", testRecipient, big.NewInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Proposal{
+		Wallet:    testWallet,
+		Value:     new(big.Int),
+		Gas:       big.NewInt(200000),
+		GasPrice:  common.Shannon,
+		Data:      data,
+		Threshold: threshold,
+		Proposer:  nodes[0].account.Address,
+	}
+	for _, node := range nodes {
+		p.Signers = append(p.Signers, node.account.Address)
+	}
+	return p
+}
+
+// waitProposal waits until the coordinator received the proposal.
+func waitProposal(t *testing.T, c *Coordinator, hash common.Hash) *Status {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if status, err := c.Proposal(hash); err == nil {
+			return status
+		}
+	}
+	t.Fatalf("proposal %x not received", hash[:4])
+	return nil
+}
+
+func TestProposalValidation(t *testing.T) {
+	signer := common.HexToAddress("0x01")
+	tests := []struct {
+		signers   []common.Address
+		threshold uint64
+		err       error
+	}{
+		{[]common.Address{signer}, 1, nil},
+		{nil, 1, errNoSigners},
+		{[]common.Address{signer}, 0, errInvalidThreshold},
+		{[]common.Address{signer}, 2, errInvalidThreshold},
+		{[]common.Address{signer, signer}, 1, errDuplicateSigner},
+	}
+	for i, tt := range tests {
+		p := &Proposal{Value: new(big.Int), Gas: new(big.Int), GasPrice: new(big.Int), Signers: tt.signers, Threshold: tt.threshold}
+		if err := p.Validate(); err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	if err := new(Proposal).Validate(); err != errMissingQuantity {
+		t.Errorf("error mismatch: have %v, want %v", err, errMissingQuantity)
+	}
+}
+
+func TestCoordination(t *testing.T) {
+	submitted := make(chan [][]byte, 1)
+	submit := func(p *Proposal, sigs [][]byte) (common.Hash, error) {
+		submitted <- sigs
+		return p.Transaction(0, sigs).Hash(), nil
+	}
+	nodes, teardown := startTestNodes(t, 3, submit)
+	defer teardown()
+
+	// Propose a 2 of 3 call to both co-signers
+	p := newTestProposal(t, nodes, 2)
+	hash, err := nodes[0].coordinator.Propose(&nodes[0].identity.PublicKey, p, []*ecdsa.PublicKey{
+		&nodes[1].identity.PublicKey, &nodes[2].identity.PublicKey,
+	})
+	if err != nil {
+		t.Fatalf("failed to propose: %v", err)
+	}
+	if hash != p.Hash() {
+		t.Errorf("proposal hash mismatch: have %x, want %x", hash, p.Hash())
+	}
+	// The co-signers review the decoded call and confirm
+	for _, node := range nodes[1:] {
+		status := waitProposal(t, node.coordinator, hash)
+		if status.Own {
+			t.Errorf("received proposal marked as own")
+		}
+		if status.Call == nil || status.Call.Method != "execute(address,uint256)" {
+			t.Fatalf("decoded call mismatch: %v", status.Call)
+		}
+		if to := status.Call.Args[0].Value; to != testRecipient {
+			t.Errorf("decoded recipient mismatch: have %v, want %x", to, testRecipient)
+		}
+		if err := node.coordinator.Confirm(hash, node.account.Address); err != nil {
+			t.Fatalf("failed to confirm: %v", err)
+		}
+	}
+	// The proposer submits the call with both signatures in signer order
+	var sigs [][]byte
+	select {
+	case sigs = <-submitted:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("proposal not submitted")
+	}
+	if len(sigs) != 2 {
+		t.Fatalf("signature count mismatch: have %d, want 2", len(sigs))
+	}
+	for i, sig := range sigs {
+		pub, err := crypto.SigToPub(SigHash(hash), sig)
+		if err != nil || crypto.PubkeyToAddress(*pub) != nodes[i+1].account.Address {
+			t.Errorf("signature %d not made by signer %x", i, nodes[i+1].account.Address)
+		}
+	}
+	// Submitted proposals are dropped and further confirmations don't submit again
+	if _, err := nodes[0].coordinator.Proposal(hash); err != ErrUnknownProposal {
+		t.Errorf("submitted proposal lookup error mismatch: have %v, want %v", err, ErrUnknownProposal)
+	}
+	if err := nodes[0].coordinator.Confirm(hash, nodes[0].account.Address); err != ErrUnknownProposal {
+		t.Errorf("submitted proposal confirmation error mismatch: have %v, want %v", err, ErrUnknownProposal)
+	}
+	select {
+	case <-submitted:
+		t.Errorf("proposal submitted twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestConfirmRejections(t *testing.T) {
+	nodes, teardown := startTestNodes(t, 2, func(p *Proposal, sigs [][]byte) (common.Hash, error) {
+		return common.Hash{}, nil
+	})
+	defer teardown()
+
+	// The second node isn't a signer, but trusts the proposer as a co-signer
+	nodes[1].coordinator.SetCosigners([]*ecdsa.PublicKey{&nodes[0].identity.PublicKey})
+
+	p := newTestProposal(t, nodes[:1], 1)
+	hash, err := nodes[0].coordinator.Propose(&nodes[0].identity.PublicKey, p, []*ecdsa.PublicKey{&nodes[1].identity.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitProposal(t, nodes[1].coordinator, hash)
+
+	if err := nodes[1].coordinator.Confirm(hash, nodes[1].account.Address); err != ErrNotSigner {
+		t.Errorf("non-signer confirmation error mismatch: have %v, want %v", err, ErrNotSigner)
+	}
+	if err := nodes[1].coordinator.Confirm(common.Hash{1}, nodes[1].account.Address); err != ErrUnknownProposal {
+		t.Errorf("unknown proposal error mismatch: have %v, want %v", err, ErrUnknownProposal)
+	}
+	unknown := &nodes[1].identity.PublicKey
+	if _, err := nodes[0].coordinator.Propose(unknown, newTestProposal(t, nodes, 1), []*ecdsa.PublicKey{unknown}); err != ErrUnknownIdentity {
+		t.Errorf("unknown identity error mismatch: have %v, want %v", err, ErrUnknownIdentity)
+	}
+}
+
+func TestSubmitRetry(t *testing.T) {
+	var (
+		fail      = true
+		submitted = make(chan [][]byte, 1)
+	)
+	submit := func(p *Proposal, sigs [][]byte) (common.Hash, error) {
+		if fail {
+			return common.Hash{}, errors.New("submission failed")
+		}
+		submitted <- sigs
+		return p.Transaction(0, sigs).Hash(), nil
+	}
+	nodes, teardown := startTestNodes(t, 2, submit)
+	defer teardown()
+
+	nodes[1].coordinator.SetCosigners([]*ecdsa.PublicKey{&nodes[0].identity.PublicKey})
+
+	// Confirming the own 1 of 1 proposal fails to submit it
+	p := newTestProposal(t, nodes[:1], 1)
+	hash, err := nodes[0].coordinator.Propose(&nodes[0].identity.PublicKey, p, []*ecdsa.PublicKey{&nodes[1].identity.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nodes[0].coordinator.Submit(hash); err != ErrThreshold {
+		t.Errorf("early submission error mismatch: have %v, want %v", err, ErrThreshold)
+	}
+	if err := nodes[0].coordinator.Confirm(hash, nodes[0].account.Address); err == nil {
+		t.Fatalf("failed submission not reported")
+	}
+	if status, err := nodes[0].coordinator.Proposal(hash); err != nil {
+		t.Errorf("failed submission dropped the proposal: %v", err)
+	} else if status.Error == nil {
+		t.Errorf("failed submission error not recorded")
+	}
+	// Retrying submits it once
+	fail = false
+	tx, err := nodes[0].coordinator.Submit(hash)
+	if err != nil {
+		t.Fatalf("failed to retry submission: %v", err)
+	}
+	sigs := <-submitted
+	if tx != p.Transaction(0, sigs).Hash() {
+		t.Errorf("submitted transaction mismatch: have %x, want %x", tx, p.Transaction(0, sigs).Hash())
+	}
+	// Submitted proposals are dropped
+	if _, err := nodes[0].coordinator.Submit(hash); err != ErrUnknownProposal {
+		t.Errorf("repeated submission error mismatch: have %v, want %v", err, ErrUnknownProposal)
+	}
+	// Only the proposer submits
+	waitProposal(t, nodes[1].coordinator, hash)
+	if _, err := nodes[1].coordinator.Submit(hash); err != ErrNotOwn {
+		t.Errorf("foreign submission error mismatch: have %v, want %v", err, ErrNotOwn)
+	}
+}
+
+// Tests that only proposals involving the node are tracked, for a limited time
+// and up to a limited number.
+func TestProposalTracking(t *testing.T) {
+	nodes, teardown := startTestNodes(t, 3, func(p *Proposal, sigs [][]byte) (common.Hash, error) {
+		return common.Hash{}, nil
+	})
+	defer teardown()
+
+	// Propose a call signed by the second node only, sent to both others
+	p := newTestProposal(t, nodes[:2], 1)
+	hash, err := nodes[0].coordinator.Propose(&nodes[0].identity.PublicKey, p, []*ecdsa.PublicKey{
+		&nodes[1].identity.PublicKey, &nodes[2].identity.PublicKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The signer tracks it, the uninvolved third node doesn't
+	waitProposal(t, nodes[1].coordinator, hash)
+	time.Sleep(100 * time.Millisecond)
+	if _, err := nodes[2].coordinator.Proposal(hash); err != ErrUnknownProposal {
+		t.Errorf("uninvolved node tracked the proposal: %v", err)
+	}
+	// Expired proposals are dropped
+	c := nodes[1].coordinator
+	c.lock.Lock()
+	c.proposals[hash].expires = time.Now().Add(-time.Second)
+	c.lock.Unlock()
+
+	if _, err := c.Proposal(hash); err != ErrUnknownProposal {
+		t.Errorf("expired proposal lookup error mismatch: have %v, want %v", err, ErrUnknownProposal)
+	}
+	if statuses := c.Proposals(); len(statuses) != 0 {
+		t.Errorf("expired proposal listed")
+	}
+	// New proposals are rejected once the limit is reached
+	c = nodes[0].coordinator
+	c.lock.Lock()
+	for i := len(c.proposals); i < maxProposals; i++ {
+		c.proposals[common.Hash{byte(i), byte(i >> 8), 1}] = &proposal{expires: time.Now().Add(time.Hour)}
+	}
+	c.lock.Unlock()
+
+	p.Nonce = 1
+	if _, err := c.Propose(&nodes[0].identity.PublicKey, p, []*ecdsa.PublicKey{&nodes[1].identity.PublicKey}); err != ErrTooManyProposals {
+		t.Errorf("proposal limit error mismatch: have %v, want %v", err, ErrTooManyProposals)
+	}
+}
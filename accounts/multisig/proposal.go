@@ -0,0 +1,204 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package multisig
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/krypton/go-krypton/accounts/abi"
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/core/types"
+	"github.com/krypton/go-krypton/crypto"
+	"github.com/krypton/go-krypton/rlp"
+)
+
+const (
+	proposalMsg     = 0x00 // Payload code of proposals
+	confirmationMsg = 0x01 // Payload code of confirmations
+)
+
+var (
+	errNoSigners        = errors.New("proposal has no signers")
+	errInvalidThreshold = errors.New("threshold must be between 1 and the number of signers")
+	errDuplicateSigner  = errors.New("duplicate signer")
+	errMissingQuantity  = errors.New("proposal lacks value, gas or gas price")
+	errUnknownMessage   = errors.New("unknown message code")
+)
+
+// Proposal is a call of a multisig wallet contract proposed to its co-signers.
+// All fields are covered by the hash the co-signers sign, so the submitted
+// transaction is exactly the one they reviewed, apart from its nonce.
+type Proposal struct {
+	Wallet    common.Address   // Multisig wallet contract the call is submitted to
+	Nonce     uint64           // Distinguishes repeated proposals of the same call
+	Value     *big.Int         // Value sent along with the call
+	Gas       *big.Int         // Gas limit of the submitted transaction
+	GasPrice  *big.Int         // Gas price of the submitted transaction
+	Data      []byte           // ABI encoded call of the wallet
+	Signers   []common.Address // Accounts whose confirmations count
+	Threshold uint64           // Number of confirmations required for submission
+	Proposer  common.Address   // Account submitting the transaction
+}
+
+// Hash returns the hash identifying the proposal.
+func (p *Proposal) Hash() common.Hash {
+	enc, _ := rlp.EncodeToBytes([]interface{}{
+		p.Wallet, p.Nonce, p.Value, p.Gas, p.GasPrice, p.Data, p.Signers, p.Threshold, p.Proposer,
+	})
+	return common.BytesToHash(crypto.Sha3(enc))
+}
+
+// SigHash returns the hash the co-signers sign to confirm the proposal with
+// the given hash. Like the hash of personal_sign messages it is prefixed, so
+// confirmations can't be replayed as signatures of anything else:
+//
+//	sha3("\x19Krypton Multisig Proposal:\n32" + hash)
+func SigHash(hash common.Hash) []byte {
+	return crypto.Sha3([]byte("\x19Krypton Multisig Proposal:\n32"), hash[:])
+}
+
+// Validate checks the consistency of the proposal.
+func (p *Proposal) Validate() error {
+	if p.Value == nil || p.Gas == nil || p.GasPrice == nil {
+		return errMissingQuantity
+	}
+	if len(p.Signers) == 0 {
+		return errNoSigners
+	}
+	if p.Threshold == 0 || p.Threshold > uint64(len(p.Signers)) {
+		return errInvalidThreshold
+	}
+	for i, signer := range p.Signers {
+		if p.signerIndex(signer) != i {
+			return errDuplicateSigner
+		}
+	}
+	return nil
+}
+
+// signerIndex returns the position of the account in the signer list, or -1.
+func (p *Proposal) signerIndex(addr common.Address) int {
+	for i, signer := range p.Signers {
+		if signer == addr {
+			return i
+		}
+	}
+	return -1
+}
+
+// Call decodes the proposed call using the ABI of the wallet.
+func (p *Proposal) Call(wallet abi.ABI) (*Call, error) {
+	method, args, err := wallet.UnpackInput(p.Data)
+	if err != nil {
+		return nil, err
+	}
+	call := &Call{Method: method.String()}
+	for i, input := range method.Inputs {
+		call.Args = append(call.Args, CallArg{Name: input.Name, Type: input.Type.String(), Value: args[i]})
+	}
+	return call, nil
+}
+
+// Transaction assembles the transaction submitting the proposal. The collected
+// signatures are appended to the call data in the order of the signer list,
+// for the wallet contract to check them.
+func (p *Proposal) Transaction(nonce uint64, sigs [][]byte) *types.Transaction {
+	data := common.CopyBytes(p.Data)
+	for _, sig := range sigs {
+		data = append(data, sig...)
+	}
+	return types.NewTransaction(nonce, p.Wallet, p.Value, p.Gas, p.GasPrice, data)
+}
+
+// Call is a decoded contract call.
+type Call struct {
+	Method string // Signature of the called method
+	Args   []CallArg
+}
+
+// CallArg is a decoded argument of a call.
+type CallArg struct {
+	Name  string
+	Type  string
+	Value interface{}
+}
+
+func (c *Call) String() string {
+	args := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		switch v := arg.Value.(type) {
+		case common.Address:
+			args[i] = fmt.Sprintf("%s=%x", arg.Name, v)
+		default:
+			args[i] = fmt.Sprintf("%s=%v", arg.Name, v)
+		}
+	}
+	return fmt.Sprintf("%s [%s]", c.Method, strings.Join(args, ", "))
+}
+
+// Confirmation is the signature of a co-signer on the hash of a proposal.
+type Confirmation struct {
+	Proposal  common.Hash
+	Signer    common.Address
+	Signature []byte
+}
+
+// verify checks that the confirmation was signed by its signer.
+func (c *Confirmation) verify() error {
+	pub, err := crypto.SigToPub(SigHash(c.Proposal), c.Signature)
+	if err != nil {
+		return err
+	}
+	if crypto.PubkeyToAddress(*pub) != c.Signer {
+		return fmt.Errorf("confirmation not signed by %x", c.Signer)
+	}
+	return nil
+}
+
+// encodeMessage assembles a whisper payload of a proposal or confirmation.
+func encodeMessage(code byte, msg interface{}) ([]byte, error) {
+	enc, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{code}, enc...), nil
+}
+
+// decodeMessage parses a whisper payload into a proposal or confirmation.
+func decodeMessage(payload []byte) (interface{}, error) {
+	if len(payload) == 0 {
+		return nil, errUnknownMessage
+	}
+	switch payload[0] {
+	case proposalMsg:
+		p := new(Proposal)
+		if err := rlp.DecodeBytes(payload[1:], p); err != nil {
+			return nil, err
+		}
+		return p, p.Validate()
+	case confirmationMsg:
+		c := new(Confirmation)
+		if err := rlp.DecodeBytes(payload[1:], c); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	return nil, errUnknownMessage
+}
@@ -0,0 +1,33 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package accounts
+
+import "github.com/krypton/go-krypton/core/types"
+
+// Signer holds keys and signs on behalf of the account manager. Setting one
+// on a Manager moves account listing and signing out of the local key store.
+type Signer interface {
+	// Accounts lists the accounts the signer is willing to sign for.
+	Accounts() ([]Account, error)
+
+	// SignHash signs an arbitrary hash.
+	SignHash(a Account, hash []byte) ([]byte, error)
+
+	// SignTx signs a transaction, giving the signer the chance to review all
+	// of its fields before approving it.
+	SignTx(a Account, tx *types.Transaction) (*types.Transaction, error)
+}
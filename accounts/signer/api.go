@@ -0,0 +1,197 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package signer implements external signing of transactions: the reference
+// signer, a JSON-RPC service signing with the keys of an account manager and
+// approving requests according to a set of rules, and the Client nodes use to
+// delegate signing to it.
+package signer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/krypton/go-krypton/accounts"
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/core/types"
+	"github.com/krypton/go-krypton/logger"
+	"github.com/krypton/go-krypton/logger/glog"
+	"github.com/krypton/go-krypton/rlp"
+	"github.com/krypton/go-krypton/rpc/shared"
+)
+
+const (
+	SignerApiName    = "signer"
+	SignerApiVersion = "1.0"
+)
+
+var (
+	// mapping between methods and handlers
+	signerMapping = map[string]signerhandler{
+		"signer_accounts":        (*SignerApi).Accounts,
+		"signer_sign":            (*SignerApi).Sign,
+		"signer_signTransaction": (*SignerApi).SignTransaction,
+	}
+)
+
+// signer callback handler
+type signerhandler func(*SignerApi, *shared.Request) (interface{}, error)
+
+// ConfirmFunc asks for the approval of a request not covered by the rules.
+type ConfirmFunc func(request string) bool
+
+// SignerApi serves the signing requests of nodes.
+type SignerApi struct {
+	am      *accounts.Manager
+	rules   *Rules
+	confirm ConfirmFunc
+	methods map[string]signerhandler
+}
+
+// NewSignerApi creates a signer using the keys of the account manager.
+// Requests rejected by the rules are passed to confirm, or refused if it is nil.
+func NewSignerApi(am *accounts.Manager, rules *Rules, confirm ConfirmFunc) *SignerApi {
+	return &SignerApi{
+		am:      am,
+		rules:   rules,
+		confirm: confirm,
+		methods: signerMapping,
+	}
+}
+
+// collection with supported methods
+func (self *SignerApi) Methods() []string {
+	methods := make([]string, 0, len(self.methods))
+	for k := range self.methods {
+		methods = append(methods, k)
+	}
+	return methods
+}
+
+// Execute given request
+func (self *SignerApi) Execute(req *shared.Request) (interface{}, error) {
+	if callback, ok := self.methods[req.Method]; ok {
+		return callback(self, req)
+	}
+	return nil, shared.NewNotImplementedError(req.Method)
+}
+
+func (self *SignerApi) Name() string {
+	return SignerApiName
+}
+
+func (self *SignerApi) ApiVersion() string {
+	return SignerApiVersion
+}
+
+// Accounts lists the accounts offered by the signer.
+func (self *SignerApi) Accounts(req *shared.Request) (interface{}, error) {
+	accs, err := self.am.Accounts()
+	if err != nil && err != accounts.ErrNoKeys {
+		return nil, err
+	}
+	addrs := []string{}
+	for _, acc := range accs {
+		if self.rules.HasAccount(acc.Address) {
+			addrs = append(addrs, acc.Address.Hex())
+		}
+	}
+	return addrs, nil
+}
+
+// SignTransaction signs a transaction if it is approved, returning the RLP
+// encoding of the signed transaction.
+func (self *SignerApi) SignTransaction(req *shared.Request) (interface{}, error) {
+	var params []*TxRequest
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+	if len(params) < 1 || params[0] == nil {
+		return nil, shared.NewInsufficientParamsError(len(params), 1)
+	}
+	if !common.IsHexAddress(params[0].From) {
+		return nil, shared.NewValidationError("from", "not a valid address")
+	}
+	from := common.HexToAddress(params[0].From)
+	tx, err := params[0].Transaction()
+	if err != nil {
+		return nil, shared.NewValidationError("transaction", err.Error())
+	}
+	if err := self.approve(describeTx(from, tx), self.rules.CheckTx(from, tx)); err != nil {
+		return nil, err
+	}
+	signed, err := self.am.SignTx(accounts.Account{Address: from}, tx)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := rlp.EncodeToBytes(signed)
+	if err != nil {
+		return nil, err
+	}
+	return common.ToHex(enc), nil
+}
+
+// Sign signs a plain hash if it is approved.
+func (self *SignerApi) Sign(req *shared.Request) (interface{}, error) {
+	var params []string
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+	if len(params) < 2 {
+		return nil, shared.NewInsufficientParamsError(len(params), 2)
+	}
+	if !common.IsHexAddress(params[0]) {
+		return nil, shared.NewValidationError("address", "not a valid address")
+	}
+	from := common.HexToAddress(params[0])
+	hash := common.FromHex(params[1])
+	if len(hash) != len(common.Hash{}) {
+		return nil, shared.NewValidationError("hash", "must be 32 bytes")
+	}
+	desc := fmt.Sprintf("sign hash %x with %x", hash, from)
+	if err := self.approve(desc, self.rules.CheckHash(from)); err != nil {
+		return nil, err
+	}
+	sig, err := self.am.Sign(accounts.Account{Address: from}, hash)
+	if err != nil {
+		return nil, err
+	}
+	return common.ToHex(sig), nil
+}
+
+// approve decides on a request given the verdict of the rules, asking for
+// confirmation if they reject it.
+func (self *SignerApi) approve(desc string, verdict error) error {
+	if verdict == nil {
+		glog.V(logger.Info).Infof("Approved by rules: %s", desc)
+		return nil
+	}
+	if self.confirm != nil && self.confirm(fmt.Sprintf("%s (%v)", desc, verdict)) {
+		glog.V(logger.Info).Infof("Approved by user: %s", desc)
+		return nil
+	}
+	glog.V(logger.Info).Infof("Rejected: %s: %v", desc, verdict)
+	return fmt.Errorf("request rejected: %v", verdict)
+}
+
+func describeTx(from common.Address, tx *types.Transaction) string {
+	to := "contract creation"
+	if tx.To() != nil {
+		to = fmt.Sprintf("to %x", *tx.To())
+	}
+	return fmt.Sprintf("transaction from %x %s: nonce %d, value %v, gas %v, gas price %v, data %d bytes",
+		from, to, tx.Nonce(), tx.Value(), tx.Gas(), tx.GasPrice(), len(tx.Data()))
+}
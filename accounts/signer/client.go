@@ -0,0 +1,173 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package signer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/krypton/go-krypton/accounts"
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/core/types"
+	"github.com/krypton/go-krypton/crypto"
+	"github.com/krypton/go-krypton/rlp"
+	"github.com/krypton/go-krypton/rpc/client"
+)
+
+// SignTimeout is the time the signer is given to answer a signing request,
+// which may include a user reviewing it.
+var SignTimeout = 5 * time.Minute
+
+var errSignerMismatch = errors.New("signer returned a mismatching signature")
+
+// TxRequest is the transaction of a signing request as sent to the signer. Quantities are hex encoded, To is empty for contract creations.
+type TxRequest struct {
+	From     string `json:"from"`
+	To       string `json:"to,omitempty"`
+	Nonce    string `json:"nonce"`
+	Gas      string `json:"gas"`
+	GasPrice string `json:"gasPrice"`
+	Value    string `json:"value"`
+	Data     string `json:"data"`
+}
+
+// NewTxRequest assembles the signing request of a transaction.
+func NewTxRequest(from common.Address, tx *types.Transaction) *TxRequest {
+	req := &TxRequest{
+		From:     from.Hex(),
+		Nonce:    common.ToHex(new(big.Int).SetUint64(tx.Nonce()).Bytes()),
+		Gas:      common.ToHex(tx.Gas().Bytes()),
+		GasPrice: common.ToHex(tx.GasPrice().Bytes()),
+		Value:    common.ToHex(tx.Value().Bytes()),
+		Data:     "0x" + common.Bytes2Hex(tx.Data()),
+	}
+	if to := tx.To(); to != nil {
+		req.To = to.Hex()
+	}
+	return req
+}
+
+// Transaction reassembles the unsigned transaction of the request.
+func (req *TxRequest) Transaction() (*types.Transaction, error) {
+	nonce, ok := new(big.Int).SetString(strip0x(req.Nonce), 16)
+	if !ok || nonce.BitLen() > 64 {
+		return nil, fmt.Errorf("invalid nonce %q", req.Nonce)
+	}
+	var quantities [3]*big.Int
+	for i, field := range []string{req.Gas, req.GasPrice, req.Value} {
+		if quantities[i], ok = new(big.Int).SetString(strip0x(field), 16); !ok || quantities[i].Sign() < 0 {
+			return nil, fmt.Errorf("invalid quantity %q", field)
+		}
+	}
+	data := common.FromHex(req.Data)
+	if req.To == "" {
+		return types.NewContractCreation(nonce.Uint64(), quantities[2], quantities[0], quantities[1], data), nil
+	}
+	if !common.IsHexAddress(req.To) {
+		return nil, fmt.Errorf("invalid recipient %q", req.To)
+	}
+	return types.NewTransaction(nonce.Uint64(), common.HexToAddress(req.To), quantities[2], quantities[0], quantities[1], data), nil
+}
+
+func strip0x(s string) string {
+	if len(s) > 1 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		return s[2:]
+	}
+	return s
+}
+
+// Client is an accounts.Signer talking to a signer running in a separate
+// process through its JSON-RPC interface (usually an IPC socket), such as the
+// one served by SignerApi. The signer serves the methods
+//
+//	signer_accounts                 returning the hex addresses it signs for
+//	signer_signTransaction(tx)      returning the RLP of the signed transaction
+//	signer_sign(address, hash)      returning the signature of the hash
+//
+// where tx is a TxRequest. Responses are verified, so a misbehaving signer
+// can't get a different transaction signed in the name of the node.
+type Client struct {
+	endpoint string
+	client   *client.Client
+}
+
+// NewClient connects to the signer listening on the given endpoint.
+func NewClient(endpoint string) (*Client, error) {
+	c, err := client.Dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{endpoint: endpoint, client: c}, nil
+}
+
+// Close disconnects from the signer.
+func (s *Client) Close() {
+	s.client.Close()
+}
+
+func (s *Client) String() string {
+	return "signer " + s.endpoint
+}
+
+func (s *Client) Accounts() ([]accounts.Account, error) {
+	var addrs []string
+	if err := s.client.Call(&addrs, "signer_accounts"); err != nil {
+		return nil, err
+	}
+	accs := make([]accounts.Account, 0, len(addrs))
+	for _, addr := range addrs {
+		if !common.IsHexAddress(addr) {
+			return nil, fmt.Errorf("%v: invalid account %q", s, addr)
+		}
+		accs = append(accs, accounts.Account{Address: common.HexToAddress(addr)})
+	}
+	return accs, nil
+}
+
+func (s *Client) SignHash(a accounts.Account, hash []byte) ([]byte, error) {
+	var res string
+	if err := s.client.CallTimeout(SignTimeout, &res, "signer_sign", a.Address.Hex(), common.ToHex(hash)); err != nil {
+		return nil, err
+	}
+	sig := common.FromHex(res)
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil || crypto.PubkeyToAddress(*pub) != a.Address {
+		return nil, errSignerMismatch
+	}
+	return sig, nil
+}
+
+func (s *Client) SignTx(a accounts.Account, tx *types.Transaction) (*types.Transaction, error) {
+	var res string
+	if err := s.client.CallTimeout(SignTimeout, &res, "signer_signTransaction", NewTxRequest(a.Address, tx)); err != nil {
+		return nil, err
+	}
+	signed := new(types.Transaction)
+	if err := rlp.Decode(bytes.NewReader(common.FromHex(res)), signed); err != nil {
+		return nil, fmt.Errorf("%v: invalid signed transaction: %v", s, err)
+	}
+	if signed.SigHash() != tx.SigHash() {
+		return nil, errSignerMismatch
+	}
+	if from, err := signed.From(); err != nil || from != a.Address {
+		return nil, errSignerMismatch
+	}
+	return signed, nil
+}
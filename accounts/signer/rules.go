@@ -0,0 +1,206 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package signer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/core/types"
+)
+
+var (
+	errNoMatchingRule = errors.New("no rule approves the transaction")
+	errHashSigning    = errors.New("signing of plain hashes is not allowed")
+	errUnknownAccount = errors.New("account not offered by the signer")
+)
+
+// Rules decide which requests the signer approves automatically.
+type Rules struct {
+	Accounts     []common.Address // accounts offered to clients, all if empty
+	Transactions []TxRule         // a transaction is approved if any of the rules matches
+	SignHashes   bool             // whether hashes of unknown content may be signed
+}
+
+// TxRule matches the transactions within its limits.
+type TxRule struct {
+	From        *common.Address  // sender, any if nil
+	To          []common.Address // recipients, any if empty
+	AllowCreate bool             // whether contracts may be created
+	AllowData   bool             // whether transactions may carry data
+	MaxValue    *big.Int         // value limit, unlimited if nil
+	MaxGas      *big.Int         // gas limit, unlimited if nil
+	MaxGasPrice *big.Int         // gas price limit, unlimited if nil
+}
+
+// rulesJSON is the file format of the rules. Quantities are decimal or 0x
+// prefixed hex strings.
+//
+//	{
+//	  "accounts": ["0x..."],
+//	  "transactions": [
+//	    {"from": "0x...", "to": ["0x..."], "maxValue": "1000000000000000000"}
+//	  ],
+//	  "signHashes": false
+//	}
+type rulesJSON struct {
+	Accounts     []string     `json:"accounts"`
+	Transactions []txRuleJSON `json:"transactions"`
+	SignHashes   bool         `json:"signHashes"`
+}
+
+type txRuleJSON struct {
+	From        string   `json:"from"`
+	To          []string `json:"to"`
+	AllowCreate bool     `json:"allowCreate"`
+	AllowData   bool     `json:"allowData"`
+	MaxValue    string   `json:"maxValue"`
+	MaxGas      string   `json:"maxGas"`
+	MaxGasPrice string   `json:"maxGasPrice"`
+}
+
+// LoadRules reads the rules from a JSON file.
+func LoadRules(path string) (*Rules, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRules(data)
+}
+
+// ParseRules decodes JSON encoded rules.
+func ParseRules(data []byte) (*Rules, error) {
+	var enc rulesJSON
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return nil, err
+	}
+	rules := &Rules{SignHashes: enc.SignHashes}
+	for _, addr := range enc.Accounts {
+		if !common.IsHexAddress(addr) {
+			return nil, fmt.Errorf("invalid account %q", addr)
+		}
+		rules.Accounts = append(rules.Accounts, common.HexToAddress(addr))
+	}
+	for i, r := range enc.Transactions {
+		rule := TxRule{AllowCreate: r.AllowCreate, AllowData: r.AllowData}
+		if r.From != "" {
+			if !common.IsHexAddress(r.From) {
+				return nil, fmt.Errorf("rule %d: invalid sender %q", i, r.From)
+			}
+			from := common.HexToAddress(r.From)
+			rule.From = &from
+		}
+		for _, to := range r.To {
+			if !common.IsHexAddress(to) {
+				return nil, fmt.Errorf("rule %d: invalid recipient %q", i, to)
+			}
+			rule.To = append(rule.To, common.HexToAddress(to))
+		}
+		var err error
+		if rule.MaxValue, err = parseLimit(r.MaxValue); err != nil {
+			return nil, fmt.Errorf("rule %d: maxValue: %v", i, err)
+		}
+		if rule.MaxGas, err = parseLimit(r.MaxGas); err != nil {
+			return nil, fmt.Errorf("rule %d: maxGas: %v", i, err)
+		}
+		if rule.MaxGasPrice, err = parseLimit(r.MaxGasPrice); err != nil {
+			return nil, fmt.Errorf("rule %d: maxGasPrice: %v", i, err)
+		}
+		rules.Transactions = append(rules.Transactions, rule)
+	}
+	return rules, nil
+}
+
+func parseLimit(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	n, ok := new(big.Int).SetString(s, 0)
+	if !ok || n.Sign() < 0 {
+		return nil, fmt.Errorf("invalid quantity %q", s)
+	}
+	return n, nil
+}
+
+// HasAccount checks whether the account is offered to clients.
+func (r *Rules) HasAccount(addr common.Address) bool {
+	return len(r.Accounts) == 0 || containsAddress(r.Accounts, addr)
+}
+
+// CheckTx returns nil if the transaction is approved by the rules, or the
+// reason for rejecting it otherwise.
+func (r *Rules) CheckTx(from common.Address, tx *types.Transaction) error {
+	if !r.HasAccount(from) {
+		return errUnknownAccount
+	}
+	for i := range r.Transactions {
+		if r.Transactions[i].matches(from, tx) {
+			return nil
+		}
+	}
+	return errNoMatchingRule
+}
+
+// CheckHash returns nil if signing a plain hash is approved by the rules, or
+// the reason for rejecting it otherwise.
+func (r *Rules) CheckHash(from common.Address) error {
+	if !r.HasAccount(from) {
+		return errUnknownAccount
+	}
+	if !r.SignHashes {
+		return errHashSigning
+	}
+	return nil
+}
+
+func (rule *TxRule) matches(from common.Address, tx *types.Transaction) bool {
+	if rule.From != nil && *rule.From != from {
+		return false
+	}
+	if to := tx.To(); to == nil {
+		if !rule.AllowCreate {
+			return false
+		}
+	} else {
+		if len(tx.Data()) > 0 && !rule.AllowData {
+			return false
+		}
+		if len(rule.To) > 0 && !containsAddress(rule.To, *to) {
+			return false
+		}
+	}
+	return withinLimit(tx.Value(), rule.MaxValue) &&
+		withinLimit(tx.Gas(), rule.MaxGas) &&
+		withinLimit(tx.GasPrice(), rule.MaxGasPrice)
+}
+
+func containsAddress(addrs []common.Address, addr common.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func withinLimit(n, limit *big.Int) bool {
+	return limit == nil || n.Cmp(limit) <= 0
+}
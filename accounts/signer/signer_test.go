@@ -0,0 +1,239 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package signer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/krypton/go-krypton/accounts"
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/core/types"
+	"github.com/krypton/go-krypton/crypto"
+	"github.com/krypton/go-krypton/rpc/codec"
+	"github.com/krypton/go-krypton/rpc/comms"
+	"github.com/krypton/go-krypton/rpc/shared"
+)
+
+var (
+	testRecipient = common.HexToAddress("0x289d485d9771714cce91d3393d764e1311907acc")
+	testOther     = common.HexToAddress("0xf466859ead1932d743d622cb74fc058882e8648a")
+)
+
+func TestRules(t *testing.T) {
+	from := common.HexToAddress("0x7ef5a6135f1fd6a02593eedc869c6d41d934aef8")
+	rules, err := ParseRules([]byte(fmt.Sprintf(`{
+		"transactions": [
+			{"from": "%x", "to": ["%x"], "maxValue": "1000", "maxGasPrice": "0x10"},
+			{"to": ["%x"], "allowCreate": true, "allowData": true, "maxGas": "100000"}
+		]
+	}`, from, testRecipient, testOther)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		from common.Address
+		tx   *types.Transaction
+		err  error
+	}{
+		{from, types.NewTransaction(0, testRecipient, big.NewInt(1000), big.NewInt(21000), big.NewInt(16), nil), nil},
+		{from, types.NewTransaction(0, testRecipient, big.NewInt(1001), big.NewInt(21000), big.NewInt(16), nil), errNoMatchingRule},
+		{from, types.NewTransaction(0, testRecipient, big.NewInt(1000), big.NewInt(21000), big.NewInt(17), nil), errNoMatchingRule},
+		{from, types.NewTransaction(0, testOther, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil), nil},
+		{from, types.NewTransaction(0, testOther, big.NewInt(1), big.NewInt(200000), big.NewInt(1), nil), errNoMatchingRule},
+		{testOther, types.NewTransaction(0, testRecipient, big.NewInt(1000), big.NewInt(200000), big.NewInt(1), nil), errNoMatchingRule},
+		{from, types.NewTransaction(0, testRecipient, big.NewInt(1), big.NewInt(21000), big.NewInt(1), []byte{1}), errNoMatchingRule},
+		{from, types.NewTransaction(0, testOther, big.NewInt(1), big.NewInt(21000), big.NewInt(1), []byte{1}), nil},
+		{from, types.NewContractCreation(0, big.NewInt(0), big.NewInt(100000), big.NewInt(1), []byte{1}), nil},
+	}
+	for i, tt := range tests {
+		if err := rules.CheckTx(tt.from, tt.tx); err != tt.err {
+			t.Errorf("test %d: verdict mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	if err := rules.CheckHash(from); err != errHashSigning {
+		t.Errorf("hash signing verdict mismatch: have %v, want %v", err, errHashSigning)
+	}
+	// Restricting the accounts rejects everything for the others
+	rules.Accounts = []common.Address{testOther}
+	if err := rules.CheckTx(from, tests[0].tx); err != errUnknownAccount {
+		t.Errorf("unknown account verdict mismatch: have %v, want %v", err, errUnknownAccount)
+	}
+
+	for _, invalid := range []string{
+		`{"accounts": ["0x1234"]}`,
+		`{"transactions": [{"to": ["foo"]}]}`,
+		`{"transactions": [{"maxValue": "-1"}]}`,
+		`{"transactions": [{"maxGas": "lots"}]}`,
+	} {
+		if _, err := ParseRules([]byte(invalid)); err == nil {
+			t.Errorf("expected error for %s", invalid)
+		}
+	}
+}
+
+func TestTxRequest(t *testing.T) {
+	txs := []*types.Transaction{
+		types.NewTransaction(3, testRecipient, big.NewInt(0), big.NewInt(21000), big.NewInt(1), nil),
+		types.NewTransaction(1<<40, testRecipient, common.Krypton, big.NewInt(90000), common.Shannon, []byte{1, 2, 3}),
+		types.NewContractCreation(0, big.NewInt(5), big.NewInt(500000), big.NewInt(1), []byte{0x60, 0x60}),
+	}
+	for i, tx := range txs {
+		decoded, err := NewTxRequest(testOther, tx).Transaction()
+		if err != nil {
+			t.Fatalf("test %d: failed to decode: %v", i, err)
+		}
+		if decoded.SigHash() != tx.SigHash() {
+			t.Errorf("test %d: transaction mismatch: have %v, want %v", i, decoded, tx)
+		}
+	}
+}
+
+// startTestSigner serves a signer for the unlocked account of a new key store,
+// connecting a node side account manager to it.
+func startTestSigner(t *testing.T, rules *Rules, confirm ConfirmFunc) (accounts.Account, *accounts.Manager, func()) {
+	dir, err := ioutil.TempDir("", "kr-signer-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	am := accounts.NewManager(crypto.NewKeyStorePlain(filepath.Join(dir, "keystore")))
+	acc, err := am.NewAccount("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := am.Unlock(acc.Address, ""); err != nil {
+		t.Fatal(err)
+	}
+	api := NewSignerApi(am, rules, confirm)
+	endpoint := filepath.Join(dir, "signer.ipc")
+	initializer := func(conn net.Conn) (comms.Stopper, shared.KryptonApi, error) {
+		return testStopper{}, api, nil
+	}
+	if err := comms.StartIpc(comms.IpcConfig{Endpoint: endpoint}, codec.JSON, initializer); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := accounts.NewManager(crypto.NewKeyStorePlain(filepath.Join(dir, "node")))
+	node.SetSigner(client)
+
+	return acc, node, func() {
+		client.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+type testStopper struct{}
+
+func (testStopper) Stop() {}
+
+func TestClientSigning(t *testing.T) {
+	rules := &Rules{Transactions: []TxRule{{To: []common.Address{testRecipient}, MaxValue: common.Krypton}}}
+	acc, node, teardown := startTestSigner(t, rules, nil)
+	defer teardown()
+
+	accs, err := node.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accs) != 1 || accs[0] != acc {
+		t.Fatalf("account list mismatch: have %v, want [%v]", accs, acc)
+	}
+	// Transactions within the rules are signed
+	tx := types.NewTransaction(1, testRecipient, common.Krypton, big.NewInt(21000), common.Shannon, nil)
+	signed, err := node.SignTx(acc, tx)
+	if err != nil {
+		t.Fatalf("failed to sign approved transaction: %v", err)
+	}
+	if from, err := signed.From(); err != nil || from != acc.Address {
+		t.Errorf("sender mismatch: have %x (%v), want %x", from, err, acc.Address)
+	}
+	if signed.SigHash() != tx.SigHash() {
+		t.Errorf("signed transaction differs from the request")
+	}
+	// Others are rejected, as are plain hashes
+	tx = types.NewTransaction(1, testOther, common.Krypton, big.NewInt(21000), common.Shannon, nil)
+	if _, err := node.SignTx(acc, tx); err == nil {
+		t.Errorf("transaction to unapproved recipient signed")
+	}
+	if _, err := node.Sign(acc, tx.SigHash().Bytes()); err == nil {
+		t.Errorf("plain hash signed")
+	}
+	if _, err := node.SignTx(accounts.Account{Address: testOther}, tx); err == nil {
+		t.Errorf("transaction of unknown account signed")
+	}
+	// Keys are managed by the signer, not the node
+	if err := node.Unlock(acc.Address, ""); err != accounts.ErrSignerManaged {
+		t.Errorf("unlock error mismatch: have %v, want %v", err, accounts.ErrSignerManaged)
+	}
+	if _, err := node.NewAccount(""); err != accounts.ErrSignerManaged {
+		t.Errorf("account creation error mismatch: have %v, want %v", err, accounts.ErrSignerManaged)
+	}
+	if err := node.Update(acc.Address, "", "new"); err != accounts.ErrSignerManaged {
+		t.Errorf("update error mismatch: have %v, want %v", err, accounts.ErrSignerManaged)
+	}
+	if _, err := node.Reencrypt(acc.Address, "", crypto.ScryptKDF(crypto.LightScryptN, crypto.LightScryptP)); err != accounts.ErrSignerManaged {
+		t.Errorf("re-encryption error mismatch: have %v, want %v", err, accounts.ErrSignerManaged)
+	}
+	if _, err := node.ReencryptAll([]string{""}, crypto.ScryptKDF(crypto.LightScryptN, crypto.LightScryptP)); err != accounts.ErrSignerManaged {
+		t.Errorf("bulk re-encryption error mismatch: have %v, want %v", err, accounts.ErrSignerManaged)
+	}
+	if _, err := node.ImportPreSaleKey([]byte("{}"), ""); err != accounts.ErrSignerManaged {
+		t.Errorf("presale import error mismatch: have %v, want %v", err, accounts.ErrSignerManaged)
+	}
+	dir, err := ioutil.TempDir("", "signer-export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := node.Export(filepath.Join(dir, "exported"), acc.Address, ""); err != accounts.ErrSignerManaged {
+		t.Errorf("export error mismatch: have %v, want %v", err, accounts.ErrSignerManaged)
+	}
+}
+
+func TestClientConfirm(t *testing.T) {
+	var asked []string
+	confirm := func(request string) bool {
+		asked = append(asked, request)
+		return true
+	}
+	acc, node, teardown := startTestSigner(t, new(Rules), confirm)
+	defer teardown()
+
+	tx := types.NewContractCreation(0, big.NewInt(0), big.NewInt(100000), common.Shannon, []byte{0x60})
+	if _, err := node.SignTx(acc, tx); err != nil {
+		t.Fatalf("failed to sign confirmed transaction: %v", err)
+	}
+	hash := tx.SigHash().Bytes()
+	sig, err := node.Sign(acc, hash)
+	if err != nil {
+		t.Fatalf("failed to sign confirmed hash: %v", err)
+	}
+	if pub, err := crypto.SigToPub(hash, sig); err != nil || crypto.PubkeyToAddress(*pub) != acc.Address {
+		t.Errorf("hash signature mismatch")
+	}
+	if len(asked) != 2 {
+		t.Errorf("confirmation count mismatch: have %d, want 2", len(asked))
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of go-krypton.
+//
+// go-krypton is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-krypton is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-krypton. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/krypton/go-krypton/accounts"
+	"github.com/krypton/go-krypton/cmd/utils"
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/crypto"
+)
+
+var (
+	reencryptKDFFlag = cli.StringFlag{
+		Name:  "kdf",
+		Value: "scrypt",
+		Usage: "Key derivation function of the re-encrypted keys (scrypt or pbkdf2)",
+	}
+	reencryptScryptNFlag = cli.IntFlag{
+		Name:  "scrypt-n",
+		Value: crypto.StandardScryptN,
+		Usage: "scrypt CPU/memory cost parameter",
+	}
+	reencryptScryptPFlag = cli.IntFlag{
+		Name:  "scrypt-p",
+		Value: crypto.StandardScryptP,
+		Usage: "scrypt parallelization parameter",
+	}
+	reencryptPBKDF2CFlag = cli.IntFlag{
+		Name:  "pbkdf2-c",
+		Value: crypto.StandardPBKDF2C,
+		Usage: "pbkdf2 iteration count",
+	}
+	accountReencryptCommand = cli.Command{
+		Action: accountReencrypt,
+		Name:   "reencrypt",
+		Usage:  "re-encrypt keys with new key derivation parameters",
+		Flags:  []cli.Flag{reencryptKDFFlag, reencryptScryptNFlag, reencryptScryptPFlag, reencryptPBKDF2CFlag},
+		Description: `
+
+    krypton account reencrypt [--kdf scrypt|pbkdf2] [<address> ...]
+
+Encrypts the given accounts, or all keys of the key store if none are given,
+again using new key derivation parameters. The passphrases stay the same. Keys
+of legacy formats are upgraded to the newest format and reported.
+
+Every re-encrypted key is checked to decrypt to the original key before it
+replaces the key file, so an interrupted run leaves either the old or the new
+file in place.
+
+For non-interactive use the passphrases can be specified with the --password
+flag. Each key is tried with all passphrases in the file:
+
+    krypton --password <passwordfile> account reencrypt --kdf pbkdf2
+					`,
+	}
+)
+
+func accountReencrypt(ctx *cli.Context) {
+	var kdf crypto.KDFParams
+	switch name := ctx.String(reencryptKDFFlag.Name); name {
+	case "scrypt":
+		kdf = crypto.ScryptKDF(ctx.Int(reencryptScryptNFlag.Name), ctx.Int(reencryptScryptPFlag.Name))
+	case "pbkdf2":
+		kdf = crypto.PBKDF2KDF(ctx.Int(reencryptPBKDF2CFlag.Name))
+	default:
+		utils.Fatalf("Unknown key derivation function %q", name)
+	}
+	if err := kdf.Validate(); err != nil {
+		utils.Fatalf("Invalid key derivation parameters: %v", err)
+	}
+	am := utils.MakeAccountManager(ctx)
+
+	var results []accounts.ReencryptResult
+	if ctx.Args().Present() {
+		for i, arg := range ctx.Args() {
+			addr, auth, _ := unlockAccount(ctx, am, arg, i, nil)
+			res := accounts.ReencryptResult{Address: common.HexToAddress(addr)}
+			res.Format, res.Err = am.Reencrypt(res.Address, auth, kdf)
+			results = append(results, res)
+		}
+	} else {
+		var err error
+		if results, err = am.ReencryptAll(reencryptPassphrases(ctx), kdf); err != nil {
+			utils.Fatalf("Could not re-encrypt the keys: %v", err)
+		}
+	}
+
+	var failed, legacy int
+	for _, res := range results {
+		switch {
+		case res.Err != nil:
+			failed++
+			fmt.Printf("%x: failed: %v\n", res.Address, res.Err)
+		case res.Format.Legacy():
+			legacy++
+			fmt.Printf("%x: upgraded from legacy format %v to %v\n", res.Address, res.Format, kdf)
+		default:
+			fmt.Printf("%x: re-encrypted from %v to %v\n", res.Address, res.Format, kdf)
+		}
+	}
+	fmt.Printf("Re-encrypted %d of %d keys, %d of them in legacy formats\n", len(results)-failed, len(results), legacy)
+	if failed > 0 {
+		utils.Fatalf("Could not re-encrypt %d keys", failed)
+	}
+}
+
+// reencryptPassphrases returns the passphrases to try on every key: the lines
+// of the password file, or a single prompted one.
+func reencryptPassphrases(ctx *cli.Context) []string {
+	passfile := ctx.GlobalString(utils.PasswordFileFlag.Name)
+	if passfile == "" {
+		auth, err := utils.PromptPassword("Passphrase: ", true)
+		if err != nil {
+			utils.Fatalf("%v", err)
+		}
+		return []string{auth}
+	}
+	passbytes, err := ioutil.ReadFile(passfile)
+	if err != nil {
+		utils.Fatalf("Unable to read password file '%s': %v", passfile, err)
+	}
+	return strings.Split(strings.TrimRight(string(passbytes), "\r\n"), "\n")
+}
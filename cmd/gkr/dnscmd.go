@@ -0,0 +1,132 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of go-krypton.
+//
+// go-krypton is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-krypton is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-krypton. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/codegangsta/cli"
+	"github.com/krypton/go-krypton/cmd/utils"
+	"github.com/krypton/go-krypton/crypto"
+	"github.com/krypton/go-krypton/p2p/discover"
+	"github.com/krypton/go-krypton/p2p/dnsdisc"
+)
+
+var (
+	dnsSeqFlag = cli.IntFlag{
+		Name:  "seq",
+		Value: 1,
+		Usage: "Sequence number of the tree, increase on every update",
+	}
+	dnsCommand = cli.Command{
+		Name:  "dns",
+		Usage: "publish node lists in DNS",
+		Description: `
+Node lists can be published as signed trees of DNS TXT records, which nodes
+started with --dnsdisc <url> resolve, verify and dial peers from.
+`,
+		Subcommands: []cli.Command{
+			{
+				Action: dnsSign,
+				Name:   "sign",
+				Usage:  "sign a node list into DNS TXT records",
+				Flags:  []cli.Flag{dnsSeqFlag},
+				Description: `
+
+    gkr dns sign [--seq <n>] <domain> <keyfile> <nodes.json>
+
+Creates a tree of the enode URLs listed in the JSON file (same format as the
+static-nodes.json file), signed with the hex encoded private key in keyfile.
+Prints the URL of the tree and the TXT records to publish under the domain.
+`,
+			},
+			{
+				Action: dnsSync,
+				Name:   "sync",
+				Usage:  "resolve and verify a tree published in DNS",
+				Description: `
+
+    gkr dns sync <url>
+
+Resolves the tree of the URL (enodetree://<key>@<domain>), verifies it and
+prints the contained enode URLs.
+`,
+			},
+		},
+	}
+)
+
+// dnsTree is the output of the sign command.
+type dnsTree struct {
+	URL     string            `json:"url"`
+	Seq     uint              `json:"seq"`
+	Records map[string]string `json:"records"`
+}
+
+func dnsSign(ctx *cli.Context) {
+	if len(ctx.Args()) != 3 {
+		utils.Fatalf("Usage: gkr dns sign [--seq <n>] <domain> <keyfile> <nodes.json>")
+	}
+	domain, keyfile, nodefile := ctx.Args()[0], ctx.Args()[1], ctx.Args()[2]
+
+	key, err := crypto.LoadECDSA(keyfile)
+	if err != nil {
+		utils.Fatalf("Failed to load signing key: %v", err)
+	}
+	blob, err := ioutil.ReadFile(nodefile)
+	if err != nil {
+		utils.Fatalf("Failed to read node list: %v", err)
+	}
+	var urls []string
+	if err := json.Unmarshal(blob, &urls); err != nil {
+		utils.Fatalf("Failed to decode node list: %v", err)
+	}
+	nodes := make([]*discover.Node, 0, len(urls))
+	for _, url := range urls {
+		node, err := discover.ParseNode(url)
+		if err != nil {
+			utils.Fatalf("Invalid node URL %s: %v", url, err)
+		}
+		nodes = append(nodes, node)
+	}
+	tree, err := dnsdisc.MakeTree(uint(ctx.Int(dnsSeqFlag.Name)), nodes)
+	if err != nil {
+		utils.Fatalf("Failed to create tree: %v", err)
+	}
+	url, err := tree.Sign(key, domain)
+	if err != nil {
+		utils.Fatalf("Failed to sign tree: %v", err)
+	}
+	out, _ := json.MarshalIndent(&dnsTree{URL: url, Seq: tree.Seq(), Records: tree.ToTXT(domain)}, "", "  ")
+	fmt.Println(string(out))
+}
+
+func dnsSync(ctx *cli.Context) {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("Usage: gkr dns sync <url>")
+	}
+	tree, err := dnsdisc.NewClient(dnsdisc.DefaultResolver).SyncTree(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to sync tree: %v", err)
+	}
+	fmt.Printf("Tree sequence number: %d\n", tree.Seq())
+	for _, node := range tree.Nodes() {
+		fmt.Println(node)
+	}
+}
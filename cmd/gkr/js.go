@@ -18,6 +18,7 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"os"
@@ -28,6 +29,7 @@ import (
 
 	"sort"
 
+	"github.com/codegangsta/cli"
 	"github.com/krypton/go-krypton/cmd/utils"
 	"github.com/krypton/go-krypton/common"
 	"github.com/krypton/go-krypton/common/natspec"
@@ -84,34 +86,32 @@ type jsre struct {
 	atexit     func()
 	corsDomain string
 	client     comms.KryptonClient
+	jsonOutput bool                          // Print batch results as raw JSON
+	docs       map[string][]shared.MethodDoc // Documentation of the available API's
 	prompter
 }
 
 var (
-	loadedModulesMethods map[string][]string
+	loadedModulesDocs map[string][]shared.MethodDoc
 )
 
 func keywordCompleter(line string) []string {
 	results := make([]string, 0)
 
 	if strings.Contains(line, ".") {
-		elements := strings.Split(line, ".")
-		if len(elements) == 2 {
-			module := elements[0]
-			partialMethod := elements[1]
-			if methods, found := loadedModulesMethods[module]; found {
-				for _, method := range methods {
-					if strings.HasPrefix(method, partialMethod) { // e.g. debug.se
-						results = append(results, module+"."+method)
-					}
-				}
+		elements := strings.SplitN(line, ".", 2)
+		module := elements[0]
+		partialMethod := elements[1]
+		for _, doc := range loadedModulesDocs[module] {
+			if strings.HasPrefix(doc.Name, partialMethod) { // e.g. debug.se
+				results = append(results, completion(module, doc))
 			}
 		}
 	} else {
-		for module, methods := range loadedModulesMethods {
+		for module, docs := range loadedModulesDocs {
 			if line == module { // user typed in full module name, show all methods
-				for _, method := range methods {
-					results = append(results, module+"."+method)
+				for _, doc := range docs {
+					results = append(results, completion(module, doc))
 				}
 			} else if strings.HasPrefix(module, line) { // partial method name, e.g. admi
 				results = append(results, module)
@@ -121,13 +121,49 @@ func keywordCompleter(line string) []string {
 	return results
 }
 
+// completion returns the text a completed method or property is replaced with,
+// methods include the opening parenthesis of the call.
+func completion(module string, doc shared.MethodDoc) string {
+	if doc.Property {
+		return module + "." + doc.Name
+	}
+	return module + "." + doc.Name + "("
+}
+
+// argumentHint returns the signature of the method whose call is being opened
+// at the end of line, e.g. "kr.getBalance(".
+func argumentHint(line string) (string, bool) {
+	if !strings.HasSuffix(line, "(") {
+		return "", false
+	}
+	elements := strings.SplitN(strings.TrimSuffix(line, "("), ".", 2)
+	if len(elements) != 2 {
+		return "", false
+	}
+	for _, doc := range loadedModulesDocs[elements[0]] {
+		if !doc.Property && doc.Name == elements[1] {
+			return doc.Signature(elements[0]), true
+		}
+	}
+	return "", false
+}
+
 func apiWordCompleter(line string, pos int) (head string, completions []string, tail string) {
 	if len(line) == 0 || pos == 0 {
 		return "", nil, ""
 	}
 
+	// an opened call is completed with the argument list, which leaves the line
+	// untouched but prints the method signature on the second tab
+	end := pos
+	if line[pos-1] == '(' {
+		if end = pos - 1; end == 0 {
+			return "", nil, ""
+		}
+	}
+
 	i := 0
-	for i = pos - 1; i > 0; i-- {
+	for i = end - 1; i > 0; i-- {
 		if line[i] == '.' || (line[i] >= 'a' && line[i] <= 'z') || (line[i] >= 'A' && line[i] <= 'Z') {
 			continue
 		}
@@ -140,10 +176,13 @@ func apiWordCompleter(line string, pos int) (head string, completions []string,
 
 	begin := line[:i]
 	keyword := line[i:pos]
-	end := line[pos:]
+	rest := line[pos:]
 
+	if hint, ok := argumentHint(keyword); ok {
+		return begin, []string{keyword, hint}, rest
+	}
 	completionWords := keywordCompleter(keyword)
-	return begin, completionWords, end
+	return begin, completionWords, rest
 }
 
 func newLightweightJSRE(docRoot string, client comms.KryptonClient, datadir string, interactive bool) *jsre {
@@ -218,26 +257,137 @@ func newJSRE(krypton *kr.Krypton, docRoot, corsDomain string, client comms.Krypt
 }
 
 func (self *jsre) loadAutoCompletion() {
-	if modules, err := self.supportedApis(); err == nil {
-		loadedModulesMethods = make(map[string][]string)
-		for module, _ := range modules {
-			loadedModulesMethods[module] = api.AutoCompletion[module]
+	loadedModulesDocs = self.docs
+}
+
+// loadDocs retrieves the documentation of the API's offered by the node. Nodes
+// which don't serve it are documented from the local definition of their API's.
+func (self *jsre) loadDocs(apiNames []string) {
+	self.docs = make(map[string][]shared.MethodDoc)
+
+	if err := self.client.Send(&shared.Request{Id: 1, Jsonrpc: shared.JsonRpcVersion, Method: "methodDocs"}); err == nil {
+		if res, err := self.client.Recv(); err == nil {
+			if res, ok := res.(*shared.SuccessResponse); ok {
+				if blob, err := json.Marshal(res.Result); err == nil {
+					if err := json.Unmarshal(blob, &self.docs); err == nil && len(self.docs) > 0 {
+						return
+					}
+				}
+			}
+		}
+	}
+	for _, name := range apiNames {
+		if docs := api.Docs(name); docs != nil {
+			self.docs[name] = docs
+		}
+	}
+	self.docs[shared.Web3ApiName] = api.Web3_Docs
+}
+
+// help prints the documentation of the method, property or module given as name
+// (e.g. "kr.getBalance") or as value (e.g. kr.getBalance). Without arguments the
+// documented modules are listed.
+func (self *jsre) help(call otto.FunctionCall) otto.Value {
+	if call.Argument(0).IsUndefined() {
+		fmt.Println("Documented modules:", strings.Join(self.documentedModules(), ", "))
+		fmt.Println("Use help(module) or help(module.method) for details.")
+		return otto.UndefinedValue()
+	}
+	if module, docs := self.findDocs(call.Otto, call.Argument(0)); docs != nil {
+		for _, doc := range docs {
+			fmt.Println(doc.Signature(module))
+			fmt.Println("    " + doc.Description)
 		}
+	} else {
+		fmt.Println("No documentation available.")
 	}
+	return otto.UndefinedValue()
 }
 
-func (self *jsre) batch(statement string) {
-	err := self.re.EvalAndPrettyPrint(statement)
+// documentedModules returns the sorted names of the modules with documentation.
+func (self *jsre) documentedModules() []string {
+	modules := make([]string, 0, len(self.docs))
+	for module, _ := range self.docs {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	return modules
+}
 
+// findDocs returns the documentation matching the help argument, which is either
+// the name of a module or method, or the module or method itself.
+func (self *jsre) findDocs(vm *otto.Otto, arg otto.Value) (string, []shared.MethodDoc) {
+	if arg.IsString() {
+		name := arg.String()
+		if docs, ok := self.docs[name]; ok {
+			return name, docs
+		}
+		if elements := strings.SplitN(name, ".", 2); len(elements) == 2 {
+			for _, doc := range self.docs[elements[0]] {
+				if doc.Name == elements[1] {
+					return elements[0], []shared.MethodDoc{doc}
+				}
+			}
+		}
+		return "", nil
+	}
+	if !arg.IsObject() {
+		return "", nil
+	}
+	// Resolve the documented modules and methods, comparing them to the argument
+	// by identity.
+	same, err := vm.Run("(function (a, b) { return a === b; })")
 	if err != nil {
-		fmt.Printf("error: %v", err)
+		return "", nil
+	}
+	identical := func(expr string) bool {
+		v, err := vm.Run(expr)
+		if err != nil {
+			return false
+		}
+		eq, err := same.Call(otto.UndefinedValue(), arg, v)
+		if err != nil {
+			return false
+		}
+		b, _ := eq.ToBoolean()
+		return b
+	}
+	for _, module := range self.documentedModules() {
+		if identical(module) {
+			return module, self.docs[module]
+		}
+		for _, doc := range self.docs[module] {
+			if !doc.Property && identical(module+"."+doc.Name) {
+				return module, []shared.MethodDoc{doc}
+			}
+		}
+	}
+	return "", nil
+}
+
+// configure applies the scripting related command line flags.
+func (self *jsre) configure(ctx *cli.Context) {
+	self.jsonOutput = ctx.GlobalBool(utils.JSONOutputFlag.Name)
+	self.re.SetScriptTimeout(ctx.GlobalDuration(utils.JSTimeoutFlag.Name))
+}
+
+// batch evaluates a single statement and prints its result.
+func (self *jsre) batch(statement string) error {
+	var err error
+	if self.jsonOutput {
+		err = self.re.EvalAndPrintJSON(statement)
+	} else {
+		err = self.re.EvalAndPrettyPrint(statement)
 	}
 
 	if self.atexit != nil {
 		self.atexit()
 	}
 
-	self.re.Stop(false)
+	if stopErr := self.re.Stop(false); err == nil {
+		err = stopErr
+	}
+	return err
 }
 
 // show summary of current gkr instance
@@ -329,6 +479,9 @@ func (js *jsre) apiBindings(f xkr.Frontend) error {
 		utils.Fatalf("Error setting namespaces: %v", err)
 	}
 
+	js.loadDocs(apiNames)
+	js.re.Set("help", js.help)
+
 	js.re.Run(`var GlobalRegistrar = kr.contract(` + registrar.GlobalRegistrarAbi + `);   registrar = GlobalRegistrar.at("` + registrar.GlobalRegistrarAddr + `");`)
 	return nil
 }
@@ -367,16 +520,37 @@ func (self *jsre) UnlockAccount(addr []byte) bool {
 	}
 }
 
-func (self *jsre) exec(filename string) error {
-	if err := self.re.Exec(filename); err != nil {
-		self.re.Stop(false)
-		return fmt.Errorf("Javascript Error: %v", err)
+// exec runs the given script files in order and waits for their pending
+// callbacks. Execution stops at the first failing script.
+func (self *jsre) exec(files ...string) error {
+	for _, file := range files {
+		if err := self.re.Exec(file); err != nil {
+			self.re.Stop(false)
+			return err
+		}
 	}
-	self.re.Stop(true)
-	return nil
+	return self.re.Stop(true)
+}
+
+// scriptExitCode reports the failure of a JavaScript run on stderr and returns
+// the exit status the process should terminate with.
+func scriptExitCode(err error) int {
+	switch err := err.(type) {
+	case nil:
+		return 0
+	case *re.ExitError:
+		return err.Code
+	case *otto.Error:
+		fmt.Fprintln(os.Stderr, err.String())
+	default:
+		fmt.Fprintln(os.Stderr, "Javascript Error:", err)
+	}
+	return 1
 }
 
-func (self *jsre) interactive() {
+// interactive runs the read-eval-print loop until the input is closed or exit
+// is called. In the latter case an ExitError is returned.
+func (self *jsre) interactive() error {
 	// Read input lines.
 	prompt := make(chan string)
 	inputln := make(chan string)
@@ -410,10 +584,10 @@ func (self *jsre) interactive() {
 		select {
 		case <-sig:
 			fmt.Println("caught interrupt, exiting")
-			return
+			return nil
 		case input, ok := <-inputln:
 			if !ok || indentCount <= 0 && exit.MatchString(input) {
-				return
+				return nil
 			}
 			if onlyws.MatchString(input) {
 				continue
@@ -424,7 +598,9 @@ func (self *jsre) interactive() {
 				if mustLogInHistory(str) {
 					self.AppendHistory(str[:len(str)-1])
 				}
-				self.parseInput(str)
+				if err := self.parseInput(str); err != nil {
+					return err
+				}
 				str = ""
 			}
 		}
@@ -447,7 +623,9 @@ func (self *jsre) withHistory(datadir string, op func(*os.File)) {
 	hist.Close()
 }
 
-func (self *jsre) parseInput(code string) {
+// parseInput evaluates a statement entered at the prompt, printing its result.
+// Only a call to exit is reported as an error, all others are printed.
+func (self *jsre) parseInput(code string) (exitErr error) {
 	defer func() {
 		if r := recover(); r != nil {
 			fmt.Println("[native] error", r)
@@ -456,11 +634,13 @@ func (self *jsre) parseInput(code string) {
 	if err := self.re.EvalAndPrettyPrint(code); err != nil {
 		if ottoErr, ok := err.(*otto.Error); ok {
 			fmt.Println(ottoErr.String())
+		} else if _, ok := err.(*re.ExitError); ok {
+			return err
 		} else {
 			fmt.Println(err)
 		}
-		return
 	}
+	return nil
 }
 
 var indentCount = 0
@@ -22,9 +22,11 @@ import (
 	"math/big"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"runtime"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -35,11 +37,17 @@ import (
 	"github.com/krypton/go-krypton/common/natspec"
 	"github.com/krypton/go-krypton/common/registrar"
 	"github.com/krypton/go-krypton/core"
+	"github.com/krypton/go-krypton/core/types"
 	"github.com/krypton/go-krypton/crypto"
 	"github.com/krypton/go-krypton/kr"
 	"github.com/krypton/go-krypton/krdb"
+	"github.com/krypton/go-krypton/params"
+	"github.com/krypton/go-krypton/rpc/api"
 	"github.com/krypton/go-krypton/rpc/codec"
 	"github.com/krypton/go-krypton/rpc/comms"
+	"github.com/krypton/go-krypton/rpc/shared"
+	"github.com/krypton/krash"
+	"github.com/robertkrimen/otto"
 )
 
 const (
@@ -208,6 +216,47 @@ func TestBlockChain(t *testing.T) {
 	checkEvalJSON(t, repl, `debug.dumpBlock(kr.blockNumber)`, beforeExport)
 }
 
+// Tests that blocks rejected by the block validation after passing their seal
+// check are stored and can be traced.
+func TestTraceBadBlock(t *testing.T) {
+	tmp, repl, krypton := testJKrRE(t)
+	if err := krypton.Start(); err != nil {
+		t.Fatalf("error starting krypton: %v", err)
+	}
+	defer krypton.Stop()
+	defer os.RemoveAll(tmp)
+
+	// Generate a block with a transaction but a wrong transaction root
+	key, _ := crypto.HexToECDSA(testKey)
+	chain, _ := core.GenerateChain(krypton.BlockChain().Genesis(), krypton.ChainDb(), 1, func(i int, gen *core.BlockGen) {
+		tx, _ := types.NewTransaction(gen.TxNonce(common.HexToAddress(testAddress)), common.Address{1}, big.NewInt(1000), params.TxGas, nil, nil).SignECDSA(key)
+		gen.AddTx(tx)
+	})
+	header := chain[0].Header()
+	header.TxHash = common.Hash{1}
+	block := types.NewBlockWithHeader(header).WithBody(chain[0].Transactions(), nil)
+
+	// Seal it properly, so it only fails the block validation
+	pow, err := krash.NewForTesting()
+	if err != nil {
+		t.Fatalf("failed to create proof of work: %v", err)
+	}
+	pow.Turbo(true)
+	nonce, mixDigest := pow.Search(block, nil, 0)
+	block = block.WithMiningResult(nonce, common.BytesToHash(mixDigest))
+
+	if _, err := krypton.BlockChain().InsertChain(types.Blocks{block}); err == nil {
+		t.Fatalf("block with invalid transaction root accepted")
+	}
+	val, err := repl.re.Run(`JSON.stringify(debug.traceBadBlock("` + block.Hash().Hex() + `"))`)
+	if err != nil {
+		t.Fatalf("failed to trace bad block: %v", err)
+	}
+	if want := `"transactionHash":"` + block.Transactions()[0].Hash().Hex() + `"`; !strings.Contains(val.String(), want) {
+		t.Errorf("trace mismatch: have %s, want transaction %s", val.String(), want)
+	}
+}
+
 func TestMining(t *testing.T) {
 	tmp, repl, krypton := testJKrRE(t)
 	if err := krypton.Start(); err != nil {
@@ -272,6 +321,162 @@ func TestSignature(t *testing.T) {
 	}
 }
 
+func TestEstimateGas(t *testing.T) {
+	tmp, repl, krypton := testJKrRE(t)
+	if err := krypton.Start(); err != nil {
+		t.Errorf("error starting krypton: %v", err)
+		return
+	}
+	defer krypton.Stop()
+	defer os.RemoveAll(tmp)
+
+	// A plain value transfer needs exactly the intrinsic transaction gas
+	checkEvalJSON(t, repl, `kr.estimateGas({from: "`+testAddress+`", to: "`+testAddress+`", value: 1})`, `21000`)
+
+	// Code hitting an invalid opcode fails regardless of the gas allowance
+	if _, err := repl.re.Run(`kr.estimateGas({from: "` + testAddress + `", data: "0x6000fe"})`); err == nil {
+		t.Errorf("expected estimation of always failing code to fail")
+	}
+}
+
+// Tests that transactions signed concurrently get distinct nonces, and that the
+// nonces of transactions rejected by the pool are handed out again to fill the
+// gaps they left.
+func TestConcurrentTransact(t *testing.T) {
+	tmp, repl, krypton := testJKrRE(t)
+	if err := krypton.Start(); err != nil {
+		t.Fatalf("error starting krypton: %v", err)
+	}
+	defer krypton.Stop()
+	defer os.RemoveAll(tmp)
+
+	const txs = 8
+	errc := make(chan error, 2*txs)
+	for i := 0; i < 2*txs; i++ {
+		value := "1"
+		if i%2 == 1 {
+			value = testBalance // exceeds the balance with the gas included
+		}
+		go func() {
+			_, err := repl.xkr.TransactWithPassphrase("", testAddress, testAddress, "", value, "", "", "")
+			errc <- err
+		}()
+	}
+	failed := 0
+	for i := 0; i < 2*txs; i++ {
+		if err := <-errc; err != nil {
+			failed++
+		}
+	}
+	if failed != txs {
+		t.Fatalf("failed transaction count mismatch: have %d, want %d", failed, txs)
+	}
+	for i := 0; i < txs; i++ {
+		if _, err := repl.xkr.TransactWithPassphrase("", testAddress, testAddress, "", "1", "", "", ""); err != nil {
+			t.Fatalf("transaction %d failed: %v", i, err)
+		}
+	}
+	pending := krypton.TxPool().GetTransactions()
+	if len(pending) != 2*txs {
+		t.Fatalf("pending transaction count mismatch: have %d, want %d", len(pending), 2*txs)
+	}
+	nonces := make(map[uint64]bool)
+	for _, tx := range pending {
+		nonces[tx.Nonce()] = true
+	}
+	for nonce := uint64(0); nonce < 2*txs; nonce++ {
+		if !nonces[nonce] {
+			t.Errorf("nonce %d missing from pending transactions", nonce)
+		}
+	}
+}
+
+func TestScriptExitStatus(t *testing.T) {
+	tmp, repl, krypton := testJKrRE(t)
+	if err := krypton.Start(); err != nil {
+		t.Errorf("error starting krypton: %v", err)
+		return
+	}
+	defer krypton.Stop()
+	defer os.RemoveAll(tmp)
+
+	scripts := map[string]string{
+		"ok.js":    `var balance = kr.getBalance("` + testAddress + `");`,
+		"exit.js":  `if (kr.accounts.length > 0) { exit(7); }`,
+		"never.js": `admin.neverReached = true;`,
+	}
+	for name, code := range scripts {
+		if err := ioutil.WriteFile(filepath.Join(tmp, name), []byte(code), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := repl.exec(filepath.Join(tmp, "ok.js"), filepath.Join(tmp, "exit.js"), filepath.Join(tmp, "never.js"))
+	if code := scriptExitCode(err); code != 7 {
+		t.Errorf("exit status mismatch: have %d, want 7", code)
+	}
+}
+
+func TestHelp(t *testing.T) {
+	tmp, repl, krypton := testJKrRE(t)
+	if err := krypton.Start(); err != nil {
+		t.Errorf("error starting krypton: %v", err)
+		return
+	}
+	defer krypton.Stop()
+	defer os.RemoveAll(tmp)
+
+	// Expose the documentation lookup of help() to check what it resolves
+	repl.re.Set("lookup", func(call otto.FunctionCall) otto.Value {
+		module, docs := repl.findDocs(call.Otto, call.Argument(0))
+		if len(docs) == 0 {
+			return otto.NullValue()
+		}
+		v, _ := call.Otto.ToValue(fmt.Sprintf("%s:%d", docs[0].Signature(module), len(docs)))
+		return v
+	})
+	tests := map[string]string{
+		`kr.getBalance`:       `"kr.getBalance(address, block?):1"`,
+		`"kr.getBalance"`:     `"kr.getBalance(address, block?):1"`,
+		`"kr.blockNumber"`:    `"kr.blockNumber:1"`,
+		`admin.addPeer`:       `"admin.addPeer(url):1"`,
+		`kr.compile.solidity`: `"kr.compile.solidity(source):1"`,
+		`txpool`:              `"txpool.status:1"`,
+		`function () {}`:      `null`,
+		`"kr.noSuchMethod"`:   `null`,
+	}
+	for expr, want := range tests {
+		checkEvalJSON(t, repl, "lookup("+expr+")", want)
+	}
+	if _, err := repl.re.Run("help(kr.getBalance)"); err != nil {
+		t.Errorf("help failed: %v", err)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	loadedModulesDocs = map[string][]shared.MethodDoc{"kr": api.Kr_Docs}
+	defer func() { loadedModulesDocs = nil }()
+
+	tests := []struct {
+		line        string
+		head, tail  string
+		completions []string
+	}{
+		{"x = kr.getBal", "x = ", "", []string{"kr.getBalance("}},
+		{"kr.blockNum", "", "", []string{"kr.blockNumber"}},
+		{"kr.compile.so", "", "", []string{"kr.compile.solidity("}},
+		{"k", "", "", []string{"kr"}},
+		{"kr.getBalance(", "", "", []string{"kr.getBalance(", "kr.getBalance(address, block?)"}},
+		{"(", "", "", nil},
+	}
+	for _, tt := range tests {
+		head, completions, tail := apiWordCompleter(tt.line, len(tt.line))
+		if head != tt.head || tail != tt.tail || !reflect.DeepEqual(completions, tt.completions) {
+			t.Errorf("completion mismatch for %q: have (%q, %q, %q), want (%q, %q, %q)",
+				tt.line, head, completions, tail, tt.head, tt.completions, tt.tail)
+		}
+	}
+}
+
 func TestContract(t *testing.T) {
 	t.Skip("contract testing is implemented with mining in krash test mode. This takes about 7seconds to run. Unskip and run on demand")
 	coinbase := common.HexToAddress(testAddress)
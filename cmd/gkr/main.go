@@ -90,6 +90,7 @@ recover <hex> recovers by hash
 		removedbCommand,
 		dumpCommand,
 		monitorCommand,
+		dnsCommand,
 		{
 			Action: makedag,
 			Name:   "makedag",
@@ -235,6 +236,7 @@ Note that account update has the a side effect that the order of your accounts
 changes.
 					`,
 				},
+				accountReencryptCommand,
 				{
 					Action: accountImport,
 					Name:   "import",
@@ -291,6 +293,10 @@ This command allows to open a console on a running gkr node.
 			Description: `
 The JavaScript VM exposes a node admin interface as well as the Ðapp
 JavaScript API. See https://github.com/krypton/go-krypton/wiki/Javascipt-Console
+
+The files are executed in order, stopping at the first failure. Scripts may end
+the process with a specific exit status by calling exit(n). Uncaught exceptions
+and timeouts (see --jstimeout) exit with status 1.
 `,
 		},
 	}
@@ -298,8 +304,10 @@ JavaScript API. See https://github.com/krypton/go-krypton/wiki/Javascipt-Console
 		utils.IdentityFlag,
 		utils.UnlockedAccountFlag,
 		utils.PasswordFileFlag,
+		utils.ExternalSignerFlag,
 		utils.GenesisFileFlag,
 		utils.BootnodesFlag,
+		utils.DNSDiscoveryFlag,
 		utils.DataDirFlag,
 		utils.BlockchainVersionFlag,
 		utils.OlympicFlag,
@@ -329,7 +337,13 @@ JavaScript API. See https://github.com/krypton/go-krypton/wiki/Javascipt-Console
 		utils.IPCApiFlag,
 		utils.IPCPathFlag,
 		utils.ExecFlag,
+		utils.JSONOutputFlag,
+		utils.JSTimeoutFlag,
 		utils.WhisperEnabledFlag,
+		utils.WhisperMailServerFlag,
+		utils.WhisperMinPoWFlag,
+		utils.MultisigABIFlag,
+		utils.MultisigCosignersFlag,
 		utils.DevModeFlag,
 		utils.TestNetFlag,
 		utils.VMDebugFlag,
@@ -338,6 +352,8 @@ JavaScript API. See https://github.com/krypton/go-krypton/wiki/Javascipt-Console
 		utils.VMEnableJitFlag,
 		utils.NetworkIdFlag,
 		utils.RPCCORSDomainFlag,
+		utils.RPCMaxBatchSizeFlag,
+		utils.RPCTimeoutFlag,
 		utils.VerbosityFlag,
 		utils.BacktraceAtFlag,
 		utils.LogVModuleFlag,
@@ -352,6 +368,10 @@ JavaScript API. See https://github.com/krypton/go-krypton/wiki/Javascipt-Console
 		utils.GpobaseStepDownFlag,
 		utils.GpobaseStepUpFlag,
 		utils.GpobaseCorrectionFactorFlag,
+		utils.GpoOracleFlag,
+		utils.GpoBlocksFlag,
+		utils.GpoSamplesFlag,
+		utils.GpoPercentileFlag,
 		utils.ExtraDataFlag,
 	}
 	app.Before = func(ctx *cli.Context) error {
@@ -440,12 +460,16 @@ func attach(ctx *cli.Context) {
 		ctx.GlobalString(utils.DataDirFlag.Name),
 		true,
 	)
+	repl.configure(ctx)
 
 	if ctx.GlobalString(utils.ExecFlag.Name) != "" {
-		repl.batch(ctx.GlobalString(utils.ExecFlag.Name))
+		err = repl.batch(ctx.GlobalString(utils.ExecFlag.Name))
 	} else {
 		repl.welcome()
-		repl.interactive()
+		err = repl.interactive()
+	}
+	if code := scriptExitCode(err); code != 0 {
+		os.Exit(code)
 	}
 }
 
@@ -469,16 +493,21 @@ func console(ctx *cli.Context) {
 		true,
 		nil,
 	)
+	repl.configure(ctx)
 
 	if ctx.GlobalString(utils.ExecFlag.Name) != "" {
-		repl.batch(ctx.GlobalString(utils.ExecFlag.Name))
+		err = repl.batch(ctx.GlobalString(utils.ExecFlag.Name))
 	} else {
 		repl.welcome()
-		repl.interactive()
+		err = repl.interactive()
 	}
 
 	krypton.Stop()
 	krypton.WaitForShutdown()
+
+	if code := scriptExitCode(err); code != 0 {
+		os.Exit(code)
+	}
 }
 
 func execJSFiles(ctx *cli.Context) {
@@ -498,12 +527,15 @@ func execJSFiles(ctx *cli.Context) {
 		false,
 		nil,
 	)
-	for _, file := range ctx.Args() {
-		repl.exec(file)
-	}
+	repl.configure(ctx)
+	err = repl.exec(ctx.Args()...)
 
 	krypton.Stop()
 	krypton.WaitForShutdown()
+
+	if code := scriptExitCode(err); code != 0 {
+		os.Exit(code)
+	}
 }
 
 func unlockAccount(ctx *cli.Context, am *accounts.Manager, addr string, i int, inputpassphrases []string) (addrHex, auth string, passphrases []string) {
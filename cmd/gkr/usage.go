@@ -79,6 +79,7 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.UnlockedAccountFlag,
 			utils.PasswordFileFlag,
+			utils.ExternalSignerFlag,
 		},
 	},
 	{
@@ -92,14 +93,19 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.IPCApiFlag,
 			utils.IPCPathFlag,
 			utils.RPCCORSDomainFlag,
+			utils.RPCMaxBatchSizeFlag,
+			utils.RPCTimeoutFlag,
 			utils.JSpathFlag,
 			utils.ExecFlag,
+			utils.JSONOutputFlag,
+			utils.JSTimeoutFlag,
 		},
 	},
 	{
 		Name: "NETWORKING",
 		Flags: []cli.Flag{
 			utils.BootnodesFlag,
+			utils.DNSDiscoveryFlag,
 			utils.ListenPortFlag,
 			utils.MaxPeersFlag,
 			utils.MaxPendingPeersFlag,
@@ -130,6 +136,10 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.GpobaseStepDownFlag,
 			utils.GpobaseStepUpFlag,
 			utils.GpobaseCorrectionFactorFlag,
+			utils.GpoOracleFlag,
+			utils.GpoBlocksFlag,
+			utils.GpoSamplesFlag,
+			utils.GpoPercentileFlag,
 		},
 	},
 	{
@@ -157,6 +167,10 @@ var AppHelpFlagGroups = []flagGroup{
 		Name: "EXPERIMENTAL",
 		Flags: []cli.Flag{
 			utils.WhisperEnabledFlag,
+			utils.WhisperMailServerFlag,
+			utils.WhisperMinPoWFlag,
+			utils.MultisigABIFlag,
+			utils.MultisigCosignersFlag,
 			utils.NatspecEnabledFlag,
 		},
 	},
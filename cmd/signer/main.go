@@ -0,0 +1,143 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of go-krypton.
+//
+// go-krypton is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-krypton is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-krypton. If not, see <http://www.gnu.org/licenses/>.
+
+// signer is the reference external signer for gkr --signer. It holds the keys
+// of a key store and signs the requests of connected nodes, approving them
+// according to a rules file and optionally asking on the console otherwise.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/krypton/go-krypton/accounts"
+	"github.com/krypton/go-krypton/accounts/signer"
+	"github.com/krypton/go-krypton/cmd/utils"
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/crypto"
+	"github.com/krypton/go-krypton/logger/glog"
+	"github.com/krypton/go-krypton/rpc/codec"
+	"github.com/krypton/go-krypton/rpc/comms"
+	"github.com/krypton/go-krypton/rpc/shared"
+)
+
+func main() {
+	var (
+		keyDir      = flag.String("keystore", filepath.Join(common.DefaultDataDir(), "keystore"), "key store directory")
+		ipcPath     = flag.String("ipcpath", filepath.Join(common.DefaultDataDir(), "signer.ipc"), "IPC endpoint to listen on")
+		rulesFile   = flag.String("rules", "", "JSON file with the rules for approving requests (rejects all if unset)")
+		unlock      = flag.String("unlock", "", "comma separated addresses of the accounts to unlock")
+		passFile    = flag.String("password", "", "file with the passphrases of the unlocked accounts, one per line")
+		interactive = flag.Bool("interactive", false, "ask on the console for requests not approved by the rules")
+		lightKDF    = flag.Bool("lightkdf", false, "the key store uses the light scrypt parameters")
+		verbosity   = flag.Int("verbosity", 3, "log verbosity (0-6)")
+	)
+	flag.Parse()
+	glog.SetToStderr(true)
+	glog.SetV(*verbosity)
+
+	rules := new(signer.Rules)
+	if *rulesFile != "" {
+		var err error
+		if rules, err = signer.LoadRules(*rulesFile); err != nil {
+			log.Fatalf("-rules: %v", err)
+		}
+	}
+	scryptN, scryptP := crypto.StandardScryptN, crypto.StandardScryptP
+	if *lightKDF {
+		scryptN, scryptP = crypto.LightScryptN, crypto.LightScryptP
+	}
+	am := accounts.NewManager(crypto.NewKeyStorePassphrase(*keyDir, scryptN, scryptP))
+	defer am.Close()
+	if *unlock != "" {
+		unlockAccounts(am, strings.Split(*unlock, ","), *passFile)
+	}
+
+	var confirm signer.ConfirmFunc
+	if *interactive {
+		var lock sync.Mutex
+		confirm = func(request string) bool {
+			lock.Lock()
+			defer lock.Unlock()
+			ok, _ := utils.PromptConfirm("Approve " + request + "?")
+			return ok
+		}
+	}
+	api := signer.NewSignerApi(am, rules, confirm)
+	config := comms.IpcConfig{
+		Endpoint:       *ipcPath,
+		RequestTimeout: signer.SignTimeout,
+	}
+	initializer := func(conn net.Conn) (comms.Stopper, shared.KryptonApi, error) {
+		return nopStopper{}, api, nil
+	}
+	if err := comms.StartIpc(config, codec.JSON, initializer); err != nil {
+		log.Fatalf("-ipcpath: %v", err)
+	}
+	fmt.Printf("Signer listening on %s\n", *ipcPath)
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt)
+	<-sigc
+	os.Remove(*ipcPath)
+}
+
+// unlockAccounts unlocks the given accounts for the lifetime of the signer,
+// reading the passphrases from the password file or prompting for them.
+func unlockAccounts(am *accounts.Manager, addrs []string, passFile string) {
+	var passwords []string
+	if passFile != "" {
+		content, err := ioutil.ReadFile(passFile)
+		if err != nil {
+			log.Fatalf("-password: %v", err)
+		}
+		passwords = strings.Split(strings.TrimRight(string(content), "\r\n"), "\n")
+	}
+	for i, addr := range addrs {
+		addr = strings.TrimSpace(addr)
+		if !common.IsHexAddress(addr) {
+			log.Fatalf("-unlock: invalid address %q", addr)
+		}
+		var password string
+		switch {
+		case len(passwords) == 0:
+			var err error
+			if password, err = utils.PromptPassword(fmt.Sprintf("Passphrase for %s: ", addr), true); err != nil {
+				log.Fatal(err)
+			}
+		case i < len(passwords):
+			password = passwords[i]
+		default:
+			// the last passphrase applies to the remaining accounts
+			password = passwords[len(passwords)-1]
+		}
+		if err := am.Unlock(common.HexToAddress(addr), strings.TrimRight(password, "\r\n")); err != nil {
+			log.Fatalf("Could not unlock %s: %v", addr, err)
+		}
+	}
+}
+
+type nopStopper struct{}
+
+func (nopStopper) Stop() {}
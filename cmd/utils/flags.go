@@ -28,10 +28,13 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 
 	"github.com/codegangsta/cli"
 	"github.com/krypton/krash"
 	"github.com/krypton/go-krypton/accounts"
+	"github.com/krypton/go-krypton/accounts/abi"
+	"github.com/krypton/go-krypton/accounts/signer"
 	"github.com/krypton/go-krypton/common"
 	"github.com/krypton/go-krypton/core"
 	"github.com/krypton/go-krypton/core/vm"
@@ -49,6 +52,7 @@ import (
 	"github.com/krypton/go-krypton/rpc/comms"
 	"github.com/krypton/go-krypton/rpc/shared"
 	"github.com/krypton/go-krypton/rpc/useragent"
+	"github.com/krypton/go-krypton/whisper"
 	"github.com/krypton/go-krypton/xkr"
 )
 
@@ -200,6 +204,11 @@ var (
 		Usage: "Password file to use with options/subcommands needing a pass phrase",
 		Value: "",
 	}
+	ExternalSignerFlag = cli.StringFlag{
+		Name:  "signer",
+		Usage: "IPC endpoint of an external signer holding the account keys (keystore is not used)",
+		Value: "",
+	}
 
 	// vm flags
 	VMDebugFlag = cli.BoolFlag{
@@ -280,6 +289,16 @@ var (
 		Usage: "API's offered over the HTTP-RPC interface",
 		Value: comms.DefaultHttpRpcApis,
 	}
+	RPCMaxBatchSizeFlag = cli.IntFlag{
+		Name:  "rpcbatchlimit",
+		Usage: "Maximum number of requests accepted in a single RPC batch (HTTP and IPC)",
+		Value: comms.DefaultMaxBatchSize,
+	}
+	RPCTimeoutFlag = cli.DurationFlag{
+		Name:  "rpctimeout",
+		Usage: "Maximum time to wait for a single RPC request before replying with a timeout error (HTTP and IPC, interactive methods exempt)",
+		Value: comms.DefaultRequestTimeout,
+	}
 	IPCDisabledFlag = cli.BoolFlag{
 		Name:  "ipcdisable",
 		Usage: "Disable the IPC-RPC server",
@@ -298,6 +317,14 @@ var (
 		Name:  "exec",
 		Usage: "Execute JavaScript statement (only in combination with console/attach)",
 	}
+	JSONOutputFlag = cli.BoolFlag{
+		Name:  "json",
+		Usage: "Print the result of --exec as raw JSON instead of pretty-printing it",
+	}
+	JSTimeoutFlag = cli.DurationFlag{
+		Name:  "jstimeout",
+		Usage: "Maximum execution time of each JavaScript file run by js or loadScript (0 = unlimited)",
+	}
 	// Network Settings
 	MaxPeersFlag = cli.IntFlag{
 		Name:  "maxpeers",
@@ -319,6 +346,11 @@ var (
 		Usage: "Space-separated enode URLs for P2P discovery bootstrap",
 		Value: "",
 	}
+	DNSDiscoveryFlag = cli.StringFlag{
+		Name:  "dnsdisc",
+		Usage: "Space-separated URLs of signed DNS node trees (enodetree://<key>@<domain>) to dial peers from",
+		Value: "",
+	}
 	NodeKeyFileFlag = cli.StringFlag{
 		Name:  "nodekey",
 		Usage: "P2P node key file",
@@ -340,6 +372,23 @@ var (
 		Name:  "shh",
 		Usage: "Enable Whisper",
 	}
+	WhisperMailServerFlag = cli.BoolFlag{
+		Name:  "shhmailserver",
+		Usage: "Archive Whisper envelopes to serve them to peers that were offline",
+	}
+	WhisperMinPoWFlag = cli.StringFlag{
+		Name:  "shhminpow",
+		Usage: "Minimum proof of work (per byte and second of TTL) of accepted Whisper envelopes",
+		Value: strconv.FormatFloat(whisper.DefaultMinPoW, 'g', -1, 64),
+	}
+	MultisigABIFlag = cli.StringFlag{
+		Name:  "multisigabi",
+		Usage: "JSON ABI file of the multisig wallet contract, decoding proposed calls for review",
+	}
+	MultisigCosignersFlag = cli.StringFlag{
+		Name:  "multisigcosigners",
+		Usage: "Comma separated whisper identities (hex public keys) whose multisig proposals are tracked even if not signed by local accounts",
+	}
 	// ATM the url is left to the user and deployment to
 	JSpathFlag = cli.StringFlag{
 		Name:  "jspath",
@@ -383,6 +432,26 @@ var (
 		Usage: "Suggested gas price base correction factor (%)",
 		Value: 110,
 	}
+	GpoOracleFlag = cli.StringFlag{
+		Name:  "gpooracle",
+		Usage: "Gas price oracle to use (heuristic, percentile)",
+		Value: kr.GpoHeuristic,
+	}
+	GpoBlocksFlag = cli.IntFlag{
+		Name:  "gpoblocks",
+		Usage: "Number of recent blocks sampled by the percentile gas price oracle",
+		Value: 20,
+	}
+	GpoSamplesFlag = cli.IntFlag{
+		Name:  "gposamples",
+		Usage: "Number of lowest gas prices sampled from each block by the percentile oracle",
+		Value: 3,
+	}
+	GpoPercentileFlag = cli.IntFlag{
+		Name:  "gpopercentile",
+		Usage: "Percentile of the sampled gas prices suggested by the percentile oracle",
+		Value: 60,
+	}
 )
 
 // MakeNAT creates a port mapper from set command line flags.
@@ -394,6 +463,68 @@ func MakeNAT(ctx *cli.Context) nat.Interface {
 	return natif
 }
 
+// MakeWhisperMinPoW parses the minimum whisper proof of work from set command
+// line flags, nil if the flag isn't set.
+func MakeWhisperMinPoW(ctx *cli.Context) *float64 {
+	if !ctx.GlobalIsSet(WhisperMinPoWFlag.Name) {
+		return nil
+	}
+	pow, err := strconv.ParseFloat(ctx.GlobalString(WhisperMinPoWFlag.Name), 64)
+	if err != nil || pow < 0 {
+		Fatalf("Option %s: invalid proof of work %q", WhisperMinPoWFlag.Name, ctx.GlobalString(WhisperMinPoWFlag.Name))
+	}
+	return &pow
+}
+
+// MakeMultisigABI loads the trusted ABI of multisig wallets from set command
+// line flags, nil if none is configured.
+func MakeMultisigABI(ctx *cli.Context) *abi.ABI {
+	path := ctx.GlobalString(MultisigABIFlag.Name)
+	if path == "" {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		Fatalf("Option %s: %v", MultisigABIFlag.Name, err)
+	}
+	defer file.Close()
+
+	wallet, err := abi.JSON(file)
+	if err != nil {
+		Fatalf("Option %s: invalid ABI: %v", MultisigABIFlag.Name, err)
+	}
+	return &wallet
+}
+
+// MakeMultisigCosigners parses the whisper identities of the trusted multisig
+// co-signers from set command line flags.
+func MakeMultisigCosigners(ctx *cli.Context) []*ecdsa.PublicKey {
+	var cosigners []*ecdsa.PublicKey
+	for _, id := range strings.Split(ctx.GlobalString(MultisigCosignersFlag.Name), ",") {
+		if id = strings.TrimSpace(id); id == "" {
+			continue
+		}
+		pub := crypto.ToECDSAPub(common.FromHex(id))
+		if pub == nil || pub.X == nil {
+			Fatalf("Option %s: invalid whisper identity %q", MultisigCosignersFlag.Name, id)
+		}
+		cosigners = append(cosigners, pub)
+	}
+	return cosigners
+}
+
+// MakeGasPriceOracle returns the name of the gas price oracle selected by the
+// command line flags.
+func MakeGasPriceOracle(ctx *cli.Context) string {
+	switch oracle := ctx.GlobalString(GpoOracleFlag.Name); oracle {
+	case kr.GpoHeuristic, kr.GpoPercentile:
+		return oracle
+	default:
+		Fatalf("Option %s: unknown gas price oracle %q", GpoOracleFlag.Name, oracle)
+	}
+	return ""
+}
+
 // MakeNodeKey creates a node key from set command line flags.
 func MakeNodeKey(ctx *cli.Context) (key *ecdsa.PrivateKey) {
 	hex, file := ctx.GlobalString(NodeKeyHexFlag.Name), ctx.GlobalString(NodeKeyFileFlag.Name)
@@ -450,8 +581,14 @@ func MakeKrConfig(clientID, version string, ctx *cli.Context) *kr.Config {
 		Discovery:               !ctx.GlobalBool(NoDiscoverFlag.Name),
 		NodeKey:                 MakeNodeKey(ctx),
 		Shh:                     ctx.GlobalBool(WhisperEnabledFlag.Name),
+		ShhMailServer:           ctx.GlobalBool(WhisperMailServerFlag.Name),
+		ShhMinPoW:               MakeWhisperMinPoW(ctx),
+		ShhKeyStore:             MakeWhisperKeyStore(ctx),
+		MultisigABI:             MakeMultisigABI(ctx),
+		MultisigCosigners:       MakeMultisigCosigners(ctx),
 		Dial:                    true,
 		BootNodes:               ctx.GlobalString(BootnodesFlag.Name),
+		DNSDiscovery:            ctx.GlobalString(DNSDiscoveryFlag.Name),
 		GasPrice:                common.String2Big(ctx.GlobalString(GasPriceFlag.Name)),
 		GpoMinGasPrice:          common.String2Big(ctx.GlobalString(GpoMinGasPriceFlag.Name)),
 		GpoMaxGasPrice:          common.String2Big(ctx.GlobalString(GpoMaxGasPriceFlag.Name)),
@@ -459,6 +596,10 @@ func MakeKrConfig(clientID, version string, ctx *cli.Context) *kr.Config {
 		GpobaseStepDown:         ctx.GlobalInt(GpobaseStepDownFlag.Name),
 		GpobaseStepUp:           ctx.GlobalInt(GpobaseStepUpFlag.Name),
 		GpobaseCorrectionFactor: ctx.GlobalInt(GpobaseCorrectionFactorFlag.Name),
+		GpoOracle:               MakeGasPriceOracle(ctx),
+		GpoBlocks:               ctx.GlobalInt(GpoBlocksFlag.Name),
+		GpoSamples:              ctx.GlobalInt(GpoSamplesFlag.Name),
+		GpoPercentile:           ctx.GlobalInt(GpoPercentileFlag.Name),
 		SolcPath:                ctx.GlobalString(SolcPathFlag.Name),
 		AutoDAG:                 ctx.GlobalBool(AutoDAGFlag.Name) || ctx.GlobalBool(MiningEnabledFlag.Name),
 	}
@@ -562,18 +703,51 @@ func MakeChain(ctx *cli.Context) (chain *core.BlockChain, chainDb krdb.Database)
 
 // MakeChain creates an account manager from set command line flags.
 func MakeAccountManager(ctx *cli.Context) *accounts.Manager {
+	am := accounts.NewManager(makeKeyStore(ctx, "keystore"))
+
+	scryptN, scryptP := makeScryptParams(ctx)
+	if err := am.SetWalletStore(makeKeyStoreDir(ctx, "wallets"), scryptN, scryptP); err != nil {
+		Fatalf("Could not load HD wallets: %v", err)
+	}
+	if endpoint := ctx.GlobalString(ExternalSignerFlag.Name); endpoint != "" {
+		client, err := signer.NewClient(endpoint)
+		if err != nil {
+			Fatalf("Could not connect to external signer: %v", err)
+		}
+		am.SetSigner(client)
+	}
+	return am
+}
+
+// MakeWhisperKeyStore creates the key store of the whisper identities from set
+// command line flags.
+func MakeWhisperKeyStore(ctx *cli.Context) crypto.KeyStore {
+	return makeKeyStore(ctx, "shhkeys")
+}
+
+// makeKeyStore creates a passphrase protected key store in the given directory
+// of the data directory.
+func makeKeyStore(ctx *cli.Context, dir string) crypto.KeyStore {
+	scryptN, scryptP := makeScryptParams(ctx)
+	return crypto.NewKeyStorePassphrase(makeKeyStoreDir(ctx, dir), scryptN, scryptP)
+}
+
+// makeKeyStoreDir returns the path of a key directory in the data directory.
+func makeKeyStoreDir(ctx *cli.Context, dir string) string {
 	dataDir := MustDataDir(ctx)
 	if ctx.GlobalBool(TestNetFlag.Name) {
 		dataDir += "/testnet"
 	}
-	scryptN := crypto.StandardScryptN
-	scryptP := crypto.StandardScryptP
+	return filepath.Join(dataDir, dir)
+}
+
+// makeScryptParams returns the key encryption parameters selected by the
+// command line flags.
+func makeScryptParams(ctx *cli.Context) (int, int) {
 	if ctx.GlobalBool(LightKDFFlag.Name) {
-		scryptN = crypto.LightScryptN
-		scryptP = crypto.LightScryptP
+		return crypto.LightScryptN, crypto.LightScryptP
 	}
-	ks := crypto.NewKeyStorePassphrase(filepath.Join(dataDir, "keystore"), scryptN, scryptP)
-	return accounts.NewManager(ks)
+	return crypto.StandardScryptN, crypto.StandardScryptP
 }
 
 // MustDataDir retrieves the currently requested data directory, terminating if
@@ -607,7 +781,9 @@ func IpcSocketPath(ctx *cli.Context) (ipcpath string) {
 
 func StartIPC(kr *kr.Krypton, ctx *cli.Context) error {
 	config := comms.IpcConfig{
-		Endpoint: IpcSocketPath(ctx),
+		Endpoint:       IpcSocketPath(ctx),
+		MaxBatchSize:   ctx.GlobalInt(RPCMaxBatchSizeFlag.Name),
+		RequestTimeout: ctx.GlobalDuration(RPCTimeoutFlag.Name),
 	}
 
 	initializer := func(conn net.Conn) (comms.Stopper, shared.KryptonApi, error) {
@@ -625,9 +801,11 @@ func StartIPC(kr *kr.Krypton, ctx *cli.Context) error {
 
 func StartRPC(kr *kr.Krypton, ctx *cli.Context) error {
 	config := comms.HttpConfig{
-		ListenAddress: ctx.GlobalString(RPCListenAddrFlag.Name),
-		ListenPort:    uint(ctx.GlobalInt(RPCPortFlag.Name)),
-		CorsDomain:    ctx.GlobalString(RPCCORSDomainFlag.Name),
+		ListenAddress:  ctx.GlobalString(RPCListenAddrFlag.Name),
+		ListenPort:     uint(ctx.GlobalInt(RPCPortFlag.Name)),
+		CorsDomain:     ctx.GlobalString(RPCCORSDomainFlag.Name),
+		MaxBatchSize:   ctx.GlobalInt(RPCMaxBatchSizeFlag.Name),
+		RequestTimeout: ctx.GlobalDuration(RPCTimeoutFlag.Name),
 	}
 
 	xkr := xkr.New(kr, nil)
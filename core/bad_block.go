@@ -21,14 +21,154 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"sync"
 
 	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/core/state"
 	"github.com/krypton/go-krypton/core/types"
+	"github.com/krypton/go-krypton/krdb"
 	"github.com/krypton/go-krypton/logger"
 	"github.com/krypton/go-krypton/logger/glog"
 	"github.com/krypton/go-krypton/rlp"
 )
 
+// BadBlockLimit is the number of most recently rejected blocks kept in the
+// database for debugging.
+const BadBlockLimit = 10
+
+var (
+	badBlocksKey   = []byte("BadBlocks")
+	badBlockPrefix = []byte("bad-block-")
+
+	badBlockLock sync.Mutex // Serialises updates of the bad block list
+)
+
+// BadBlock is a block rejected during import, stored along with the reason of
+// the rejection and, if the block got that far, the receipts and the state
+// changes produced by processing it locally.
+type BadBlock struct {
+	Block     *types.Block
+	Error     string
+	Receipts  types.Receipts
+	StateDiff []byte // JSON dump of the accounts modified by the block, if processed
+}
+
+// storageBadBlock is the RLP representation of a stored bad block.
+type storageBadBlock struct {
+	Block     *types.Block
+	Error     string
+	Receipts  []*types.ReceiptForStorage
+	StateDiff []byte
+}
+
+// NewBadBlock assembles the debug record of a rejected block. The receipts and
+// the state the block was processed on are optional.
+func NewBadBlock(block *types.Block, receipts types.Receipts, statedb *state.StateDB, err error) *BadBlock {
+	bad := &BadBlock{Block: block, Error: err.Error(), Receipts: receipts}
+	if statedb != nil {
+		statedb.IntermediateRoot()
+		bad.StateDiff, _ = json.Marshal(statedb.RawDumpDirty())
+	}
+	return bad
+}
+
+// WriteBadBlock stores a rejected block in the database, dropping the oldest
+// stored one if more than BadBlockLimit are kept.
+func WriteBadBlock(db krdb.Database, bad *BadBlock) error {
+	badBlockLock.Lock()
+	defer badBlockLock.Unlock()
+
+	// Store the record itself
+	storage := storageBadBlock{
+		Block:     bad.Block,
+		Error:     bad.Error,
+		Receipts:  make([]*types.ReceiptForStorage, len(bad.Receipts)),
+		StateDiff: bad.StateDiff,
+	}
+	for i, receipt := range bad.Receipts {
+		storage.Receipts[i] = (*types.ReceiptForStorage)(receipt)
+	}
+	data, err := rlp.EncodeToBytes(storage)
+	if err != nil {
+		return err
+	}
+	hash := bad.Block.Hash()
+	if err := db.Put(append(badBlockPrefix, hash[:]...), data); err != nil {
+		return err
+	}
+	// Move the block to the end of the list and drop the oldest ones
+	hashes := []common.Hash{}
+	for _, old := range getBadBlockHashes(db) {
+		if old != hash {
+			hashes = append(hashes, old)
+		}
+	}
+	hashes = append(hashes, hash)
+	for len(hashes) > BadBlockLimit {
+		db.Delete(append(badBlockPrefix, hashes[0][:]...))
+		hashes = hashes[1:]
+	}
+	data, err = rlp.EncodeToBytes(hashes)
+	if err != nil {
+		return err
+	}
+	if err := db.Put(badBlocksKey, data); err != nil {
+		return err
+	}
+	glog.V(logger.Debug).Infof("stored bad block #%v [%x…]", bad.Block.Number(), hash[:4])
+	return nil
+}
+
+// GetBadBlock retrieves a stored rejected block by its hash, or nil if it is
+// not stored.
+func GetBadBlock(db krdb.Database, hash common.Hash) *BadBlock {
+	data, _ := db.Get(append(badBlockPrefix, hash[:]...))
+	if len(data) == 0 {
+		return nil
+	}
+	var storage storageBadBlock
+	if err := rlp.DecodeBytes(data, &storage); err != nil {
+		glog.V(logger.Error).Infof("invalid bad block RLP for hash %x: %v", hash, err)
+		return nil
+	}
+	bad := &BadBlock{Block: storage.Block, Error: storage.Error, StateDiff: storage.StateDiff}
+	if len(storage.Receipts) > 0 {
+		bad.Receipts = make(types.Receipts, len(storage.Receipts))
+		for i, receipt := range storage.Receipts {
+			bad.Receipts[i] = (*types.Receipt)(receipt)
+		}
+	}
+	return bad
+}
+
+// GetBadBlocks retrieves the stored rejected blocks, most recent first.
+func GetBadBlocks(db krdb.Database) []*BadBlock {
+	hashes := getBadBlockHashes(db)
+
+	blocks := make([]*BadBlock, 0, len(hashes))
+	for i := len(hashes) - 1; i >= 0; i-- {
+		if bad := GetBadBlock(db, hashes[i]); bad != nil {
+			blocks = append(blocks, bad)
+		}
+	}
+	return blocks
+}
+
+// getBadBlockHashes retrieves the hashes of the stored rejected blocks, in the
+// order they were rejected.
+func getBadBlockHashes(db krdb.Database) []common.Hash {
+	data, _ := db.Get(badBlocksKey)
+	if len(data) == 0 {
+		return nil
+	}
+	var hashes []common.Hash
+	if err := rlp.DecodeBytes(data, &hashes); err != nil {
+		glog.V(logger.Error).Infof("invalid bad block list RLP: %v", err)
+		return nil
+	}
+	return hashes
+}
+
 // DisabledBadBlockReporting can be set to prevent blocks being reported.
 var DisableBadBlockReporting = true
 
@@ -0,0 +1,152 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/core/state"
+	"github.com/krypton/go-krypton/core/types"
+	"github.com/krypton/go-krypton/crypto"
+	"github.com/krypton/go-krypton/event"
+	"github.com/krypton/go-krypton/krdb"
+	"github.com/krypton/go-krypton/params"
+)
+
+// Tests that bad blocks are stored with their debug data and that only the
+// most recent ones are kept.
+func TestBadBlockStorage(t *testing.T) {
+	db, _ := krdb.NewMemDatabase()
+
+	if blocks := GetBadBlocks(db); len(blocks) != 0 {
+		t.Fatalf("non existent bad blocks returned: %v", blocks)
+	}
+	blocks := make([]*types.Block, BadBlockLimit+2)
+	for i := range blocks {
+		blocks[i] = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(int64(i)), Extra: []byte("bad block")})
+	}
+	receipt := types.NewReceipt(common.Hash{1}.Bytes(), big.NewInt(21000))
+	receipt.TxHash = common.Hash{2}
+	receipt.GasUsed = big.NewInt(21000)
+
+	for i, block := range blocks {
+		bad := &BadBlock{Block: block, Error: "invalid", Receipts: types.Receipts{receipt}, StateDiff: []byte("{}")}
+		if err := WriteBadBlock(db, bad); err != nil {
+			t.Fatalf("block %d: failed to store bad block: %v", i, err)
+		}
+	}
+	// Only the most recent blocks are kept, most recent first
+	stored := GetBadBlocks(db)
+	if len(stored) != BadBlockLimit {
+		t.Fatalf("stored bad block count mismatch: have %d, want %d", len(stored), BadBlockLimit)
+	}
+	for i, bad := range stored {
+		want := blocks[len(blocks)-1-i]
+		if bad.Block.Hash() != want.Hash() {
+			t.Errorf("bad block %d: hash mismatch: have %x, want %x", i, bad.Block.Hash(), want.Hash())
+		}
+		if bad.Error != "invalid" || string(bad.StateDiff) != "{}" {
+			t.Errorf("bad block %d: debug data mismatch: have %q, %q", i, bad.Error, bad.StateDiff)
+		}
+		if len(bad.Receipts) != 1 || bad.Receipts[0].TxHash != receipt.TxHash || bad.Receipts[0].GasUsed.Cmp(receipt.GasUsed) != 0 {
+			t.Errorf("bad block %d: receipts mismatch: have %v, want %v", i, bad.Receipts, receipt)
+		}
+	}
+	for _, block := range blocks[:2] {
+		if GetBadBlock(db, block.Hash()) != nil {
+			t.Errorf("dropped bad block #%v still stored", block.Number())
+		}
+	}
+	// Rejecting a stored block again moves it to the front
+	if err := WriteBadBlock(db, &BadBlock{Block: blocks[2], Error: "invalid again"}); err != nil {
+		t.Fatalf("failed to store bad block: %v", err)
+	}
+	stored = GetBadBlocks(db)
+	if len(stored) != BadBlockLimit || stored[0].Block.Hash() != blocks[2].Hash() || stored[0].Error != "invalid again" {
+		t.Errorf("re-rejected block not moved to the front")
+	}
+}
+
+// Tests that blocks failing state validation on import are stored along with
+// the receipts and state changes of their processing.
+func TestBadBlockReporting(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		db, _   = krdb.NewMemDatabase()
+	)
+	genesis := WriteGenesisBlockForTesting(db, GenesisAccount{Address: address, Balance: big.NewInt(1000000)})
+	blockchain, _ := NewBlockChain(db, FakePow{}, new(event.TypeMux))
+
+	chain, _ := GenerateChain(genesis, db, 1, func(i int, gen *BlockGen) {
+		tx, _ := types.NewTransaction(gen.TxNonce(address), common.Address{1}, big.NewInt(1000), params.TxGas, nil, nil).SignECDSA(key)
+		gen.AddTx(tx)
+	})
+	header := chain[0].Header()
+	header.Root = common.Hash{1}
+	block := types.NewBlockWithHeader(header).WithBody(chain[0].Transactions(), chain[0].Uncles())
+
+	if _, err := blockchain.InsertChain(types.Blocks{block}); err == nil {
+		t.Fatalf("invalid state root accepted")
+	}
+	bad := blockchain.GetBadBlock(block.Hash())
+	if bad == nil {
+		t.Fatalf("rejected block not stored")
+	}
+	if bad.Error == "" || len(bad.Receipts) != 1 || bad.Receipts[0].TxHash != block.Transactions()[0].Hash() {
+		t.Errorf("debug data mismatch: error %q, receipts %v", bad.Error, bad.Receipts)
+	}
+	var diff state.World
+	if err := json.Unmarshal(bad.StateDiff, &diff); err != nil {
+		t.Fatalf("failed to decode state diff: %v", err)
+	}
+	if account, ok := diff.Accounts[common.Bytes2Hex(common.Address{1}.Bytes())]; !ok || account.Balance != "1000" {
+		t.Errorf("recipient state change mismatch: have %v, want balance 1000", account)
+	}
+	if blocks := blockchain.BadBlocks(); len(blocks) != 1 || blocks[0].Block.Hash() != block.Hash() {
+		t.Errorf("bad block list mismatch: have %v", blocks)
+	}
+	// Blocks not even processed are stored without processing results
+	if err := WriteBadBlock(db, NewBadBlock(chain[0], nil, nil, errors.New("unprocessed"))); err != nil {
+		t.Fatalf("failed to store bad block: %v", err)
+	}
+	if bad := blockchain.GetBadBlock(chain[0].Hash()); bad == nil || len(bad.Receipts) != 0 || len(bad.StateDiff) != 0 {
+		t.Errorf("unprocessed block stored with processing results: %v", bad)
+	}
+}
+
+// Tests that blocks failing their seal check are not stored, so they can't
+// evict the blocks rejected by their state processing.
+func TestBadBlockSealNotStored(t *testing.T) {
+	db, _ := krdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db)
+	blockchain, _ := NewBlockChain(db, FakePow{}, new(event.TypeMux))
+
+	chain, _ := GenerateChain(genesis, db, 1, nil)
+	blockchain.pow = failPow{chain[0].NumberU64()}
+
+	if _, err := blockchain.InsertChain(chain); !IsBlockNonceErr(err) {
+		t.Fatalf("error mismatch: have %v, want nonce error", err)
+	}
+	if blocks := blockchain.BadBlocks(); len(blocks) != 0 {
+		t.Errorf("block with invalid seal stored: %v", blocks)
+	}
+}
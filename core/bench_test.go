@@ -29,6 +29,7 @@ import (
 	"github.com/krypton/go-krypton/krdb"
 	"github.com/krypton/go-krypton/event"
 	"github.com/krypton/go-krypton/params"
+	"github.com/krypton/go-krypton/rlp"
 )
 
 func BenchmarkInsertChain_empty_memdb(b *testing.B) {
@@ -68,6 +69,13 @@ func BenchmarkInsertChain_ring1000_diskdb(b *testing.B) {
 	benchInsertChain(b, true, genTxRing(1000))
 }
 
+func BenchmarkRecoverSenders_serial(b *testing.B) {
+	benchRecoverSenders(b, false)
+}
+func BenchmarkRecoverSenders_parallel(b *testing.B) {
+	benchRecoverSenders(b, true)
+}
+
 var (
 	// This is the content of the genesis block used by the benchmarks.
 	benchRootKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
@@ -165,6 +173,10 @@ func benchInsertChain(b *testing.B, disk bool, gen func(int, *BlockGen)) {
 	genesis := WriteGenesisBlockForTesting(db, GenesisAccount{benchRootAddr, benchRootFunds})
 	chain, _ := GenerateChain(genesis, db, b.N, gen)
 
+	// Drop the senders cached while generating the chain, blocks
+	// arriving from the network don't have them either.
+	chain = reencodeBlocks(b, chain)
+
 	// Time the insertion of the new chain.
 	// State and blocks are stored in the same DB.
 	evmux := new(event.TypeMux)
@@ -176,3 +188,37 @@ func benchInsertChain(b *testing.B, disk bool, gen func(int, *BlockGen)) {
 		b.Fatalf("insert error (block %d): %v\n", i, err)
 	}
 }
+
+// reencodeBlocks round-trips the blocks through RLP, returning copies without
+// any cached values.
+func reencodeBlocks(b *testing.B, blocks []*types.Block) []*types.Block {
+	decoded := make([]*types.Block, len(blocks))
+	for i, block := range blocks {
+		enc, err := rlp.EncodeToBytes(block)
+		if err != nil {
+			b.Fatalf("cannot encode block %d: %v", i, err)
+		}
+		decoded[i] = new(types.Block)
+		if err := rlp.DecodeBytes(enc, decoded[i]); err != nil {
+			b.Fatalf("cannot decode block %d: %v", i, err)
+		}
+	}
+	return decoded
+}
+
+func benchRecoverSenders(b *testing.B, parallel bool) {
+	// Sign b.N transactions, leaving their senders uncached.
+	txs := make([]*types.Transaction, b.N)
+	for i := range txs {
+		key := ringKeys[i%len(ringKeys)]
+		txs[i], _ = types.NewTransaction(0, common.Address{}, big.NewInt(1), params.TxGas, nil, nil).SignECDSA(key)
+	}
+	b.ResetTimer()
+	if parallel {
+		RecoverSenders(txs)
+	} else {
+		for _, tx := range txs {
+			tx.From()
+		}
+	}
+}
@@ -797,67 +797,21 @@ func (self *BlockChain) InsertHeaderChain(chain []*types.Header, checkFreq int)
 	}
 	verify[len(verify)-1] = true // Last should always be verified to avoid junk
 
-	// Create the header verification task queue and worker functions
-	tasks := make(chan int, len(chain))
-	for i := 0; i < len(chain); i++ {
-		tasks <- i
-	}
-	close(tasks)
-
-	errs, failed := make([]error, len(tasks)), int32(0)
-	process := func(worker int) {
-		for index := range tasks {
-			header, hash := chain[index], chain[index].Hash()
+	// Verify all headers concurrently before importing any of them
+	abort, results := self.verifyHeaders(chain, verify, self.Validator().ValidateHeader)
+	defer close(abort)
 
-			// Short circuit insertion if shutting down or processing failed
-			if atomic.LoadInt32(&self.procInterrupt) == 1 {
-				return
-			}
-			if atomic.LoadInt32(&failed) > 0 {
-				return
-			}
-			// Short circuit if the header is bad or already known
-			if BadHashes[hash] {
-				errs[index] = BadHashError(hash)
-				atomic.AddInt32(&failed, 1)
-				return
-			}
-			if self.HasHeader(hash) {
-				continue
-			}
-			// Verify that the header honors the chain parameters
-			checkPow := verify[index]
-
-			var err error
-			if index == 0 {
-				err = self.Validator().ValidateHeader(header, self.GetHeader(header.ParentHash), checkPow)
-			} else {
-				err = self.Validator().ValidateHeader(header, chain[index-1], checkPow)
-			}
-			if err != nil {
-				errs[index] = err
-				atomic.AddInt32(&failed, 1)
-				return
-			}
+	for i, header := range chain {
+		// Short circuit insertion if shutting down
+		if atomic.LoadInt32(&self.procInterrupt) == 1 {
+			glog.V(logger.Debug).Infoln("premature abort during header chain verification")
+			return 0, nil
 		}
-	}
-	// Start as many worker threads as goroutines allowed
-	pending := new(sync.WaitGroup)
-	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
-		pending.Add(1)
-		go func(id int) {
-			defer pending.Done()
-			process(id)
-		}(i)
-	}
-	pending.Wait()
-
-	// If anything failed, report
-	if failed > 0 {
-		for i, err := range errs {
-			if err != nil {
-				return i, err
-			}
+		if hash := header.Hash(); BadHashes[hash] {
+			return i, BadHashError(hash)
+		}
+		if err := <-results; err != nil {
+			return i, err
 		}
 	}
 	// All headers passed verification, import them into the database
@@ -1102,12 +1056,19 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 		coalescedLogs vm.Logs
 		tstart        = time.Now()
 
-		nonceChecked = make([]bool, len(chain))
+		headers = make([]*types.Header, len(chain))
+		seals   = make([]bool, len(chain))
 	)
+	for i, block := range chain {
+		headers[i], seals[i] = block.Header(), true
+	}
+	// Start the parallel header verifier.
+	headerAbort, headerResults := self.verifyHeaders(headers, seals, self.validateBlockHeader)
+	defer close(headerAbort)
 
-	// Start the parallel nonce verifier.
-	nonceAbort, nonceResults := verifyNoncesFromBlocks(self.pow, chain)
-	defer close(nonceAbort)
+	// Start recovering the transaction senders ahead of processing.
+	senderAbort, _ := recoverSendersFromBlocks(chain)
+	defer close(senderAbort)
 
 	txcount := 0
 	for i, block := range chain {
@@ -1117,22 +1078,20 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 		}
 
 		bstart := time.Now()
-		// Wait for block i's nonce to be verified before processing
-		// its state transition.
-		for !nonceChecked[i] {
-			r := <-nonceResults
-			nonceChecked[r.index] = true
-			if !r.valid {
-				block := chain[r.index]
-				return r.index, &BlockNonceErr{Hash: block.Hash(), Number: block.Number(), Nonce: block.Nonce()}
-			}
-		}
 
 		if BadHashes[block.Hash()] {
 			err := BadHashError(block.Hash())
-			reportBlock(block, err)
+			self.reportBlock(block, false, nil, nil, err)
 			return i, err
 		}
+		// Wait for block i's header to be verified before processing
+		// its state transition. Future blocks and unknown parents are
+		// left to the block validation, as they may be queued.
+		headerErr := <-headerResults
+		if headerErr != nil && headerErr != BlockFutureErr && !IsParentErr(headerErr) {
+			self.reportBlock(block, false, nil, nil, headerErr)
+			return i, headerErr
+		}
 		// Stage 1 validation of the block using the chain's validator
 		// interface.
 		err := self.Validator().ValidateBlock(block)
@@ -1162,7 +1121,7 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 				continue
 			}
 
-			reportBlock(block, err)
+			self.reportBlock(block, headerErr == nil, nil, nil, err)
 
 			return i, err
 		}
@@ -1171,19 +1130,19 @@ func (self *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 		// error if it fails.
 		statedb, err := state.New(self.GetBlock(block.ParentHash()).Root(), self.chainDb)
 		if err != nil {
-			reportBlock(block, err)
+			self.reportBlock(block, true, nil, nil, err)
 			return i, err
 		}
 		// Process block using the parent state as reference point.
 		receipts, logs, usedGas, err := self.processor.Process(block, statedb)
 		if err != nil {
-			reportBlock(block, err)
+			self.reportBlock(block, true, nil, statedb, err)
 			return i, err
 		}
 		// Validate the state using the default validator
 		err = self.Validator().ValidateState(block, self.GetBlock(block.ParentHash()), statedb, receipts, usedGas)
 		if err != nil {
-			reportBlock(block, err)
+			self.reportBlock(block, true, receipts, statedb, err)
 			return i, err
 		}
 		// Write state changes to database
@@ -1369,12 +1328,32 @@ func (self *BlockChain) update() {
 }
 
 // reportBlock reports the given block and error using the canonical block
-// reporting tool. Reporting the block to the service is handled in a separate
-// goroutine.
-func reportBlock(block *types.Block, err error) {
+// reporting tool. Blocks that passed their header and seal verification are
+// also stored in the database for debugging, along with the receipts and state
+// changes of their processing if they got that far (statedb given). Blocks
+// with invalid headers or seals are not, as they are cheap to forge and would
+// evict the stored blocks of real consensus failures. Reporting the block to
+// the service is handled in a separate goroutine.
+func (self *BlockChain) reportBlock(block *types.Block, verified bool, receipts types.Receipts, statedb *state.StateDB, err error) {
 	if glog.V(logger.Error) {
 		glog.Errorf("Bad block #%v (%s)\n", block.Number(), block.Hash().Hex())
 		glog.Errorf("    %v", err)
 	}
+	if verified {
+		if err := WriteBadBlock(self.chainDb, NewBadBlock(block, receipts, statedb, err)); err != nil {
+			glog.V(logger.Error).Infof("failed to store bad block #%v: %v", block.Number(), err)
+		}
+	}
 	go ReportBlock(block, err)
 }
+
+// BadBlocks returns the most recently rejected blocks stored for debugging,
+// most recent first.
+func (self *BlockChain) BadBlocks() []*BadBlock {
+	return GetBadBlocks(self.chainDb)
+}
+
+// GetBadBlock returns a rejected block stored for debugging by its hash.
+func (self *BlockChain) GetBadBlock(hash common.Hash) *BadBlock {
+	return GetBadBlock(self.chainDb, hash)
+}
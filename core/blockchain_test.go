@@ -142,12 +142,12 @@ func testBlockChainImport(chain types.Blocks, blockchain *BlockChain) error {
 		}
 		receipts, _, usedGas, err := blockchain.Processor().Process(block, statedb)
 		if err != nil {
-			reportBlock(block, err)
+			blockchain.reportBlock(block, true, nil, statedb, err)
 			return err
 		}
 		err = blockchain.Validator().ValidateState(block, blockchain.GetBlock(block.ParentHash()), statedb, receipts, usedGas)
 		if err != nil {
-			reportBlock(block, err)
+			blockchain.reportBlock(block, true, receipts, statedb, err)
 			return err
 		}
 		blockchain.mu.Lock()
@@ -0,0 +1,118 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/krypton/go-krypton/core/types"
+)
+
+// headerValidationFn checks a header against its parent, including its seal
+// if requested.
+type headerValidationFn func(header, parent *types.Header, seal bool) error
+
+// verifyHeaders starts a concurrent verification of a contiguous batch of
+// headers, validating each header against its parent, and the seals of the
+// headers flagged in seals. The parent of the first header is looked up in the
+// chain.
+//
+// It returns a quit channel to abort the operations and a results channel
+// delivering the verification result of every header in the order of the
+// batch. Callers close the quit channel once done, at the latest on the first
+// failure they don't tolerate.
+func (self *BlockChain) verifyHeaders(headers []*types.Header, seals []bool, validate headerValidationFn) (chan<- struct{}, <-chan error) {
+	abort := make(chan struct{})
+	results := make(chan error, len(headers)) // Buffered to make sure the collector stops
+	if len(headers) == 0 {
+		close(results)
+		return abort, results
+	}
+	// Spawn as many workers as allowed threads
+	workers := runtime.GOMAXPROCS(0)
+	if len(headers) < workers {
+		workers = len(headers)
+	}
+	errs := make([]error, len(headers))
+	tasks := make(chan int, workers)
+	verified := make(chan int, len(headers)) // Buffered to make sure all workers stop
+	for i := 0; i < workers; i++ {
+		go func() {
+			for index := range tasks {
+				errs[index] = self.verifyHeader(validate, headers, index, seals[index])
+				verified <- index
+			}
+		}()
+	}
+	// Feed header indices to the workers until done or aborted
+	go func() {
+		defer close(tasks)
+
+		for i := range headers {
+			select {
+			case tasks <- i:
+			case <-abort:
+				return
+			}
+		}
+	}()
+	// Deliver the results in order as the verifications complete
+	go func() {
+		defer close(results)
+
+		checked := make([]bool, len(headers))
+		for next := 0; next < len(headers); {
+			select {
+			case index := <-verified:
+				checked[index] = true
+				for ; next < len(headers) && checked[next]; next++ {
+					results <- errs[next]
+				}
+			case <-abort:
+				return
+			}
+		}
+	}()
+	return abort, results
+}
+
+// verifyHeader verifies a single header of a batch against its parent, which
+// is the previous header of the batch, or looked up in the chain for the first.
+func (self *BlockChain) verifyHeader(validate headerValidationFn, headers []*types.Header, index int, seal bool) error {
+	header := headers[index]
+
+	var parent *types.Header
+	if index == 0 {
+		parent = self.GetHeader(header.ParentHash)
+	} else {
+		parent = headers[index-1]
+		if hash := parent.Hash(); header.ParentHash != hash {
+			return fmt.Errorf("non contiguous chain: #%d [%x…] is not the parent of #%d [%x…]", parent.Number, hash[:4], header.Number, header.Hash().Bytes()[:4])
+		}
+	}
+	return validate(header, parent, seal)
+}
+
+// validateBlockHeader checks the seal and header of a block to be imported,
+// leaving the validation of the block body to the chain's validator.
+func (self *BlockChain) validateBlockHeader(header, parent *types.Header, seal bool) error {
+	if seal && !self.pow.Verify(types.NewBlockWithHeader(header)) {
+		return &BlockNonceErr{Number: header.Number, Hash: header.Hash(), Nonce: header.Nonce.Uint64()}
+	}
+	return self.Validator().ValidateHeader(header, parent, false)
+}
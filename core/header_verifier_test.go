@@ -0,0 +1,151 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/krypton/go-krypton/core/types"
+	"github.com/krypton/go-krypton/krdb"
+	"github.com/krypton/go-krypton/pow"
+)
+
+// failPow is a non-validating proof of work implementation, that returns true
+// from Verify for all but one block.
+type failPow struct {
+	failing uint64
+}
+
+func (pow failPow) Search(pow.Block, <-chan struct{}, int) (uint64, []byte) {
+	return 0, nil
+}
+func (pow failPow) Verify(block pow.Block) bool { return block.NumberU64() != pow.failing }
+func (pow failPow) GetHashrate() int64          { return 0 }
+func (pow failPow) Turbo(bool)                  {}
+
+// delayedPow is a non-validating proof of work implementation, that returns true
+// from Verify for all blocks, but delays them the configured amount of time.
+type delayedPow struct {
+	delay time.Duration
+}
+
+func (pow delayedPow) Search(pow.Block, <-chan struct{}, int) (uint64, []byte) {
+	return 0, nil
+}
+func (pow delayedPow) Verify(block pow.Block) bool { time.Sleep(pow.delay); return true }
+func (pow delayedPow) GetHashrate() int64          { return 0 }
+func (pow delayedPow) Turbo(bool)                  {}
+
+// newVerifierTestChain creates a chain with only a genesis block and headers of
+// blocks extending it.
+func newVerifierTestChain(n int) (*BlockChain, []*types.Header) {
+	db, _ := krdb.NewMemDatabase()
+	genesis, _ := WriteTestNetGenesisBlock(db, 0)
+	blocks, _ := GenerateChain(genesis, db, n, nil)
+
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	return chm(genesis, db), headers
+}
+
+// Tests that header verification results are delivered in order, whatever
+// order the workers finish in, and that the first failures are reported.
+func TestVerifyHeaders1(t *testing.T)  { testVerifyHeaders(t, 1) }
+func TestVerifyHeaders8(t *testing.T)  { testVerifyHeaders(t, 8) }
+func TestVerifyHeaders32(t *testing.T) { testVerifyHeaders(t, 32) }
+
+func testVerifyHeaders(t *testing.T, threads int) {
+	old := runtime.GOMAXPROCS(threads)
+	defer runtime.GOMAXPROCS(old)
+
+	tests := []struct {
+		pow    failPow
+		broken bool // whether to swap two headers, breaking the chain
+		fail   int  // index of the first failure, -1 for none
+	}{
+		{failPow{0}, false, -1},
+		{failPow{6}, false, 5},
+		{failPow{0}, true, 12},
+		{failPow{11}, true, 10},
+	}
+	for i, tt := range tests {
+		bc, headers := newVerifierTestChain(16)
+		bc.pow = tt.pow
+		if tt.broken {
+			headers[12], headers[13] = headers[13], headers[12]
+		}
+		seals := make([]bool, len(headers))
+		for j := range seals {
+			seals[j] = true
+		}
+		abort, results := bc.verifyHeaders(headers, seals, bc.validateBlockHeader)
+
+		for j := range headers {
+			var err error
+			select {
+			case err = <-results:
+			case <-time.After(time.Second):
+				t.Fatalf("test %d: header %d: verification timeout", i, j)
+			}
+			if j < tt.fail || tt.fail < 0 {
+				if err != nil {
+					t.Errorf("test %d: header %d: unexpected failure: %v", i, j, err)
+				}
+				continue
+			}
+			if err == nil {
+				t.Errorf("test %d: header %d: failure not reported", i, j)
+			}
+			break
+		}
+		close(abort)
+	}
+}
+
+// Tests that aborting a verification stops the delivery of results.
+func TestVerifyHeadersAbort(t *testing.T) {
+	bc, headers := newVerifierTestChain(64)
+	bc.pow = delayedPow{10 * time.Millisecond}
+
+	abort, results := bc.verifyHeaders(headers, make([]bool, len(headers)), func(header, parent *types.Header, seal bool) error {
+		bc.pow.Verify(types.NewBlockWithHeader(header))
+		return nil
+	})
+	<-results
+	close(abort)
+
+	count := 1
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-results:
+			if !ok {
+				if count == len(headers) {
+					t.Errorf("all headers verified despite abort")
+				}
+				return
+			}
+			count++
+		case <-timeout:
+			t.Fatalf("results not closed after abort")
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/krypton/go-krypton/core/types"
+)
+
+// RecoverSenders recovers the senders of a batch of transactions concurrently,
+// caching them in the transactions, and waits until all are done. Invalid
+// signatures are left to be reported when the sender is requested.
+func RecoverSenders(txs []*types.Transaction) {
+	_, done := recoverSenders(txs)
+	<-done
+}
+
+// recoverSendersFromBlocks starts a concurrent sender recovery of the
+// transactions in the blocks, in block order, returning a quit channel to
+// abort the operations and a channel closed when done.
+func recoverSendersFromBlocks(blocks []*types.Block) (chan<- struct{}, <-chan struct{}) {
+	var txs []*types.Transaction
+	for _, block := range blocks {
+		txs = append(txs, block.Transactions()...)
+	}
+	return recoverSenders(txs)
+}
+
+// recoverSenders starts a concurrent sender recovery, returning a quit channel
+// to abort the operations and a channel closed when all workers are done.
+// Transactions are handed out in order, so that the recovered senders stay
+// ahead of a sequential consumer.
+func recoverSenders(txs []*types.Transaction) (chan<- struct{}, <-chan struct{}) {
+	abort, done := make(chan struct{}), make(chan struct{})
+	if len(txs) == 0 {
+		close(done)
+		return abort, done
+	}
+	// Spawn as many workers as allowed threads
+	workers := runtime.GOMAXPROCS(0)
+	if len(txs) < workers {
+		workers = len(txs)
+	}
+	var pend sync.WaitGroup
+	pend.Add(workers)
+
+	tasks := make(chan int, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer pend.Done()
+			for index := range tasks {
+				txs[index].From()
+			}
+		}()
+	}
+	// Feed transaction indices to the workers until done or aborted
+	go func() {
+		defer close(done)
+		defer pend.Wait()
+		defer close(tasks)
+
+		for i := range txs {
+			select {
+			case tasks <- i:
+			case <-abort:
+				return
+			}
+		}
+	}()
+	return abort, done
+}
@@ -0,0 +1,53 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/core/types"
+	"github.com/krypton/go-krypton/crypto"
+	"github.com/krypton/go-krypton/params"
+)
+
+// Tests that concurrently recovered senders are cached in the transactions and
+// that invalid signatures are still reported on access.
+func TestRecoverSenders(t *testing.T) {
+	signed := make([]*types.Transaction, 64)
+	addrs := make([]common.Address, len(signed))
+	for i := range signed {
+		key, _ := crypto.GenerateKey()
+		addrs[i] = crypto.PubkeyToAddress(key.PublicKey)
+		signed[i], _ = types.NewTransaction(uint64(i), common.Address{}, big.NewInt(1), params.TxGas, nil, nil).SignECDSA(key)
+	}
+	invalid := types.NewTransaction(0, common.Address{}, big.NewInt(1), params.TxGas, nil, nil)
+	txs := append(signed, invalid)
+
+	RecoverSenders(txs)
+	for i, tx := range signed {
+		if from, err := tx.From(); err != nil || from != addrs[i] {
+			t.Errorf("tx %d: sender mismatch: have %x (%v), want %x", i, from, err, addrs[i])
+		}
+	}
+	if _, err := invalid.From(); err == nil {
+		t.Errorf("invalid signature not reported")
+	}
+	// Recovering nothing must not block
+	RecoverSenders(nil)
+}
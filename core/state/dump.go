@@ -29,6 +29,7 @@ type Account struct {
 	Root     string            `json:"root"`
 	CodeHash string            `json:"codeHash"`
 	Storage  map[string]string `json:"storage"`
+	Deleted  bool              `json:"deleted,omitempty"`
 }
 
 type World struct {
@@ -59,6 +60,29 @@ func (self *StateDB) RawDump() World {
 	return world
 }
 
+// RawDumpDirty dumps the accounts modified since the state was loaded or last
+// committed, along with the storage slots accessed while modifying them.
+// Accounts marked for deletion are flagged as deleted.
+func (self *StateDB) RawDumpDirty() World {
+	world := World{
+		Root:     common.Bytes2Hex(self.trie.Root()),
+		Accounts: make(map[string]Account),
+	}
+	for _, stateObject := range self.stateObjects {
+		if !stateObject.dirty {
+			continue
+		}
+		account := Account{Balance: stateObject.balance.String(), Nonce: stateObject.nonce, Root: common.Bytes2Hex(stateObject.Root()), CodeHash: common.Bytes2Hex(stateObject.codeHash), Deleted: stateObject.remove}
+		account.Storage = make(map[string]string)
+
+		for key, value := range stateObject.storage {
+			account.Storage[common.Bytes2Hex([]byte(key))] = common.Bytes2Hex(value[:])
+		}
+		world.Accounts[common.Bytes2Hex(stateObject.Address().Bytes())] = account
+	}
+	return world
+}
+
 func (self *StateDB) Dump() []byte {
 	json, err := json.MarshalIndent(self.RawDump(), "", "    ")
 	if err != nil {
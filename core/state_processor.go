@@ -62,7 +62,7 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB) (ty
 // ApplyTransactions returns the generated receipts and vm logs during the
 // execution of the state transition phase.
 func ApplyTransaction(bc *BlockChain, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *big.Int) (*types.Receipt, vm.Logs, *big.Int, error) {
-	_, gas, err := ApplyMessage(NewEnv(statedb, bc, tx, header), tx, gp)
+	_, gas, _, err := ApplyMessage(NewEnv(statedb, bc, tx, header), tx, gp)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -94,7 +94,13 @@ func IntrinsicGas(data []byte) *big.Int {
 	return igas
 }
 
-func ApplyMessage(env vm.Environment, msg Message, gp *GasPool) ([]byte, *big.Int, error) {
+// ApplyMessage computes the new state by applying the given message against the
+// old state within the environment.
+//
+// ApplyMessage returns the bytes returned by any EVM execution (if it took
+// place), the gas used (which includes gas refunds), whether the execution
+// failed inside the VM and an error if the message itself was invalid.
+func ApplyMessage(env vm.Environment, msg Message, gp *GasPool) ([]byte, *big.Int, bool, error) {
 	var st = StateTransition{
 		gp:         gp,
 		env:        env,
@@ -191,7 +197,7 @@ func (self *StateTransition) preCheck() (err error) {
 	return nil
 }
 
-func (self *StateTransition) transitionDb() (ret []byte, usedGas *big.Int, err error) {
+func (self *StateTransition) transitionDb() (ret []byte, usedGas *big.Int, failed bool, err error) {
 	if err = self.preCheck(); err != nil {
 		return
 	}
@@ -201,7 +207,7 @@ func (self *StateTransition) transitionDb() (ret []byte, usedGas *big.Int, err e
 
 	// Pay intrinsic gas
 	if err = self.useGas(IntrinsicGas(self.data)); err != nil {
-		return nil, nil, InvalidTxError(err)
+		return nil, nil, false, InvalidTxError(err)
 	}
 
 	vmenv := self.env
@@ -227,11 +233,12 @@ func (self *StateTransition) transitionDb() (ret []byte, usedGas *big.Int, err e
 	}
 
 	if err != nil && IsValueTransferErr(err) {
-		return nil, nil, InvalidTxError(err)
+		return nil, nil, false, InvalidTxError(err)
 	}
 
 	// We aren't interested in errors here. Errors returned by the VM are non-consensus errors and therefor shouldn't bubble up
 	if err != nil {
+		failed = true
 		err = nil
 	}
 
@@ -242,7 +249,7 @@ func (self *StateTransition) transitionDb() (ret []byte, usedGas *big.Int, err e
 	self.refundGas()
 	self.state.AddBalance(self.env.Coinbase(), new(big.Int).Mul(self.gasUsed(), self.gasPrice))
 
-	return ret, self.gasUsed(), err
+	return ret, self.gasUsed(), failed, err
 }
 
 func (self *StateTransition) refundGas() {
@@ -0,0 +1,358 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package crypto
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/event"
+	"github.com/krypton/go-krypton/logger"
+	"github.com/krypton/go-krypton/logger/glog"
+)
+
+const (
+	// keyCachePollInterval is the minimum time between two rescans of a key
+	// directory that can't be watched for changes.
+	keyCachePollInterval = 2 * time.Second
+
+	// keyCacheWatchDelay is the time changes are collected for after the
+	// watcher reported one, so a burst of changes triggers a single rescan.
+	keyCacheWatchDelay = 100 * time.Millisecond
+
+	// keyCacheMissInterval is the minimum time between two rescans triggered
+	// by lookups of addresses not in the cache.
+	keyCacheMissInterval = 1 * time.Second
+
+	// maxKeyFileSize is the size above which files are not parsed for keys.
+	maxKeyFileSize = 64 * 1024
+)
+
+// KeyAddedEvent is posted when a key file for a new address appears in a key
+// directory.
+type KeyAddedEvent struct {
+	Address common.Address
+	Path    string
+}
+
+// KeyRemovedEvent is posted when the last key file of an address disappears
+// from a key directory.
+type KeyRemovedEvent struct {
+	Address common.Address
+	Path    string
+}
+
+// AmbiguousKeyError is returned for operations needing a single key file if
+// there are multiple files for the address.
+type AmbiguousKeyError struct {
+	Address common.Address
+	Paths   []string
+}
+
+func (err *AmbiguousKeyError) Error() string {
+	return fmt.Sprintf("multiple key files for address %x: %s", err.Address, strings.Join(err.Paths, ", "))
+}
+
+// keyCache is an in-memory index of the key files of a directory, mapping the
+// addresses to the files holding their keys. It is kept up to date by a
+// directory watcher, or by rescanning the directory at most once per
+// keyCachePollInterval where watching is not supported.
+type keyCache struct {
+	keydir string
+	mux    *event.TypeMux
+
+	reloadLock sync.Mutex // serializes rescans
+	postLock   sync.Mutex // taken before releasing reloadLock, so events are posted in order
+
+	lock     sync.Mutex
+	order    []common.Address            // addresses in the order of their first key file name
+	files    map[common.Address][]string // key files of every address, sorted by name
+	err      error                       // error of the last scan
+	scanned  time.Time                   // time of the last scan, zero if never scanned
+	watching bool                        // whether a watcher keeps the cache up to date
+	closed   chan struct{}               // closed to stop the watcher
+}
+
+func newKeyCache(keydir string) *keyCache {
+	return &keyCache{
+		keydir: keydir,
+		mux:    new(event.TypeMux),
+		files:  make(map[common.Address][]string),
+		closed: make(chan struct{}),
+	}
+}
+
+// addresses returns the addresses of all keys in the directory, in the order
+// of their key file names (i.e. creation time for standard file names).
+func (kc *keyCache) addresses() ([]common.Address, error) {
+	kc.maybeReload()
+
+	kc.lock.Lock()
+	defer kc.lock.Unlock()
+
+	if kc.err != nil {
+		return nil, kc.err
+	}
+	addrs := make([]common.Address, len(kc.order))
+	copy(addrs, kc.order)
+	return addrs, nil
+}
+
+// find returns the key file of an address. If there are multiple files for the
+// address, the one with the latest name is returned, mirroring the key file
+// naming convention which begins with the creation time.
+func (kc *keyCache) find(addr common.Address) (string, error) {
+	kc.maybeReload()
+
+	kc.lock.Lock()
+	files := kc.files[addr]
+	rescan := len(files) == 0 && time.Since(kc.scanned) >= keyCacheMissInterval
+	kc.lock.Unlock()
+
+	// Files added behind our back might not have been noticed yet. Rescan for
+	// them, but not for every lookup of an unknown address.
+	if rescan {
+		kc.reload()
+
+		kc.lock.Lock()
+		files = kc.files[addr]
+		kc.lock.Unlock()
+	}
+	if len(files) == 0 {
+		return "", &os.PathError{Op: "find", Path: filepath.Join(kc.keydir, hex.EncodeToString(addr[:])), Err: os.ErrNotExist}
+	}
+	return files[len(files)-1], nil
+}
+
+// findUnique returns the key file of an address, failing with an
+// AmbiguousKeyError if there are multiple files for it.
+func (kc *keyCache) findUnique(addr common.Address) (string, error) {
+	path, err := kc.find(addr)
+	if err != nil {
+		return "", err
+	}
+	kc.lock.Lock()
+	defer kc.lock.Unlock()
+
+	if files := kc.files[addr]; len(files) > 1 {
+		return "", &AmbiguousKeyError{Address: addr, Paths: append([]string(nil), files...)}
+	}
+	return path, nil
+}
+
+// maybeReload rescans the directory if it isn't watched and wasn't scanned
+// recently.
+func (kc *keyCache) maybeReload() {
+	kc.lock.Lock()
+	stale := !kc.watching && time.Since(kc.scanned) >= keyCachePollInterval
+	kc.lock.Unlock()
+
+	if stale {
+		kc.reload()
+	}
+}
+
+// reload rescans the directory, posting events for the addresses that
+// appeared or disappeared since the last scan.
+func (kc *keyCache) reload() {
+	kc.reloadLock.Lock()
+
+	// Try to (re)start watching first, so no change after the scan is missed
+	kc.lock.Lock()
+	if !kc.watching && !kc.isClosed() {
+		if err := kc.watch(); err == nil {
+			kc.watching = true
+		} else {
+			glog.V(logger.Detail).Infof("Polling key directory %s: %v", kc.keydir, err)
+		}
+	}
+	kc.lock.Unlock()
+
+	order, files, err := scanKeyDir(kc.keydir)
+
+	kc.lock.Lock()
+	first := kc.scanned.IsZero()
+	var added []KeyAddedEvent
+	var removed []KeyRemovedEvent
+	if !first {
+		for _, addr := range order {
+			if _, ok := kc.files[addr]; !ok {
+				added = append(added, KeyAddedEvent{addr, files[addr][0]})
+			}
+		}
+		for _, addr := range kc.order {
+			if _, ok := files[addr]; !ok {
+				removed = append(removed, KeyRemovedEvent{addr, kc.files[addr][0]})
+			}
+		}
+	}
+	for _, addr := range order {
+		if paths := files[addr]; len(paths) > 1 && len(paths) != len(kc.files[addr]) {
+			glog.V(logger.Warn).Infof("Multiple key files for address %x: %s", addr, strings.Join(paths, ", "))
+		}
+	}
+	kc.order, kc.files, kc.err, kc.scanned = order, files, err, time.Now()
+	kc.lock.Unlock()
+
+	// Post the events without holding the locks as delivery blocks. The next
+	// rescan may start meanwhile, but posts its events after these.
+	kc.postLock.Lock()
+	kc.reloadLock.Unlock()
+	defer kc.postLock.Unlock()
+
+	for _, ev := range added {
+		kc.mux.Post(ev)
+	}
+	for _, ev := range removed {
+		kc.mux.Post(ev)
+	}
+}
+
+// changed is called by the watcher when the directory was modified. The
+// directory is rescanned after a short delay, collecting further changes.
+func (kc *keyCache) changed(trigger <-chan struct{}) {
+	timer := time.NewTimer(keyCacheWatchDelay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-trigger:
+		case <-timer.C:
+			kc.reload()
+			return
+		case <-kc.closed:
+			return
+		}
+	}
+}
+
+// stopWatching marks the directory as no longer watched, e.g. because it was
+// deleted. The cache falls back to polling until the watch can be restarted.
+func (kc *keyCache) stopWatching() {
+	kc.lock.Lock()
+	kc.watching = false
+	kc.lock.Unlock()
+}
+
+// close stops the watcher, releasing its inotify instance.
+func (kc *keyCache) close() {
+	kc.lock.Lock()
+	defer kc.lock.Unlock()
+
+	if !kc.isClosed() {
+		close(kc.closed)
+	}
+}
+
+// isClosed checks whether the cache was closed. The caller must hold the lock.
+func (kc *keyCache) isClosed() bool {
+	select {
+	case <-kc.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// scanKeyDir lists the key files of a directory. Standard key files end with
+// the hex address of their key, other files are parsed for their address.
+// Legacy keys stored as <address>/<address> are supported as well.
+func scanKeyDir(keydir string) ([]common.Address, map[common.Address][]string, error) {
+	files := make(map[common.Address][]string)
+
+	fileInfos, err := ioutil.ReadDir(keydir)
+	if err != nil {
+		return nil, files, err
+	}
+	var order []common.Address
+	for _, fi := range fileInfos {
+		path := filepath.Join(keydir, fi.Name())
+		if skipKeyFile(fi) {
+			continue
+		}
+		if fi.IsDir() {
+			// Only legacy key directories are of interest
+			path = filepath.Join(path, fi.Name())
+			if _, ok := addressFromFileName(fi.Name()); !ok || len(fi.Name()) != 40 {
+				continue
+			}
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+		}
+		addr, ok := addressFromFileName(fi.Name())
+		if !ok {
+			if fi.Size() > maxKeyFileSize {
+				continue
+			}
+			if addr, ok = addressFromFile(path); !ok {
+				continue
+			}
+		}
+		if len(files[addr]) == 0 {
+			order = append(order, addr)
+		}
+		files[addr] = append(files[addr], path)
+	}
+	return order, files, nil
+}
+
+// skipKeyFile ignores hidden files, editor backups and temporary files.
+func skipKeyFile(fi os.FileInfo) bool {
+	name := fi.Name()
+	return strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") || strings.HasSuffix(name, ".tmp")
+}
+
+// addressFromFileName extracts the address of a file named according to the
+// key file naming convention.
+func addressFromFileName(name string) (common.Address, bool) {
+	if len(name) < 40 {
+		return common.Address{}, false
+	}
+	addr, err := hex.DecodeString(name[len(name)-40:])
+	if err != nil {
+		return common.Address{}, false
+	}
+	return common.BytesToAddress(addr), true
+}
+
+// addressFromFile reads the address field of a JSON key file.
+func addressFromFile(path string) (common.Address, bool) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return common.Address{}, false
+	}
+	var key struct {
+		Address string `json:"address"`
+	}
+	if err := json.Unmarshal(content, &key); err != nil {
+		return common.Address{}, false
+	}
+	addr, err := hex.DecodeString(strings.TrimPrefix(key.Address, "0x"))
+	if err != nil || len(addr) != len(common.Address{}) {
+		return common.Address{}, false
+	}
+	return common.BytesToAddress(addr), true
+}
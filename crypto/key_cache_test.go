@@ -0,0 +1,214 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package crypto
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/crypto/randentropy"
+)
+
+func tmpKeyDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "kr-keycache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestScanKeyDir(t *testing.T) {
+	dir := tmpKeyDir(t)
+	defer os.RemoveAll(dir)
+
+	var (
+		a = common.HexToAddress("0x7ef5a6135f1fd6a02593eedc869c6d41d934aef8")
+		b = common.HexToAddress("0xf466859ead1932d743d622cb74fc058882e8648a")
+		c = common.HexToAddress("0x289d485d9771714cce91d3393d764e1311907acc")
+	)
+	files := map[string]string{
+		"UTC--2015-11-01T00-00-00.000000000Z--" + hex.EncodeToString(a[:]): "{}",
+		"UTC--2015-11-02T00-00-00.000000000Z--" + hex.EncodeToString(b[:]): "{}",
+		"UTC--2015-11-03T00-00-00.000000000Z--" + hex.EncodeToString(a[:]): "{}",
+		"mykey.json":   fmt.Sprintf(`{"address": "%x"}`, c),
+		"notes.txt":    "not a key",
+		".hidden":      fmt.Sprintf(`{"address": "%x"}`, b),
+		"mykey.json~":  fmt.Sprintf(`{"address": "%x"}`, b),
+		"mykey.tmp":    fmt.Sprintf(`{"address": "%x"}`, b),
+		"invalid.json": `{"address": "0x1234"}`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	order, found, err := scanKeyDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []common.Address{a, b, c}; !reflect.DeepEqual(order, want) {
+		t.Errorf("order mismatch: have %x, want %x", order, want)
+	}
+	if len(found[a]) != 2 || len(found[b]) != 1 || found[c][0] != filepath.Join(dir, "mykey.json") {
+		t.Errorf("files mismatch: %v", found)
+	}
+}
+
+func TestKeyCacheDuplicates(t *testing.T) {
+	dir := tmpKeyDir(t)
+	defer os.RemoveAll(dir)
+
+	ks := NewKeyStorePlain(dir)
+	key, err := ks.GenerateNewKey(randentropy.Reader, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Copy the key file, making the address ambiguous
+	path, _ := ks.(*keyStorePlain).cache.find(key.Address)
+	content, _ := ioutil.ReadFile(path)
+	if err := ioutil.WriteFile(filepath.Join(dir, "copy--"+hex.EncodeToString(key.Address[:])), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	ks.(*keyStorePlain).cache.reload()
+
+	addrs, err := ks.GetKeyAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0] != key.Address {
+		t.Errorf("addresses mismatch: have %x, want [%x]", addrs, key.Address)
+	}
+	if _, err := ks.GetKey(key.Address, ""); err != nil {
+		t.Errorf("failed to get ambiguous key: %v", err)
+	}
+	if err, ok := ks.DeleteKey(key.Address, "").(*AmbiguousKeyError); !ok || len(err.Paths) != 2 {
+		t.Errorf("expected ambiguous key error, got %v", err)
+	}
+	// Cleaning up resolves the ambiguity
+	if err := ks.Cleanup(key.Address); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.DeleteKey(key.Address, ""); err != nil {
+		t.Errorf("failed to delete key: %v", err)
+	}
+	if addrs, _ := ks.GetKeyAddresses(); len(addrs) != 0 {
+		t.Errorf("addresses left after delete: %x", addrs)
+	}
+}
+
+func TestKeyCacheEvents(t *testing.T) {
+	dir := tmpKeyDir(t)
+	defer os.RemoveAll(dir)
+
+	ks := NewKeyStorePlain(dir)
+	defer ks.Close()
+
+	if _, err := ks.GenerateNewKey(randentropy.Reader, ""); err != nil {
+		t.Fatal(err)
+	}
+	sub := ks.Events().Subscribe(KeyAddedEvent{}, KeyRemovedEvent{})
+	defer sub.Unsubscribe()
+
+	// Add a key file behind the back of the key store
+	key := NewKey(randentropy.Reader)
+	content, _ := key.MarshalJSON()
+	path := filepath.Join(dir, "external.json")
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Accessing the store picks it up in case the directory isn't watched
+	go ks.GetKeyAddresses()
+	select {
+	case ev := <-sub.Chan():
+		added, ok := ev.Data.(KeyAddedEvent)
+		if !ok || added.Address != key.Address || added.Path != path {
+			t.Errorf("unexpected event: %#v", ev.Data)
+		}
+	case <-time.After(keyCachePollInterval + time.Second):
+		t.Fatalf("no event for added key")
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	go ks.GetKeyAddresses()
+	select {
+	case ev := <-sub.Chan():
+		if removed, ok := ev.Data.(KeyRemovedEvent); !ok || removed.Address != key.Address {
+			t.Errorf("unexpected event: %#v", ev.Data)
+		}
+	case <-time.After(keyCachePollInterval + time.Second):
+		t.Fatalf("no event for removed key")
+	}
+}
+
+func TestKeyCacheMissingDir(t *testing.T) {
+	dir := tmpKeyDir(t)
+	defer os.RemoveAll(dir)
+
+	ks := NewKeyStorePlain(filepath.Join(dir, "keystore"))
+	if _, err := ks.GetKeyAddresses(); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+	// Creating the directory with the first key must make the cache pick it up
+	key, err := ks.GenerateNewKey(randentropy.Reader, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs, err := ks.GetKeyAddresses()
+	if err != nil || len(addrs) != 1 || addrs[0] != key.Address {
+		t.Errorf("addresses mismatch: have %x (%v), want [%x]", addrs, err, key.Address)
+	}
+}
+
+func TestKeyCacheClose(t *testing.T) {
+	dir := tmpKeyDir(t)
+	defer os.RemoveAll(dir)
+
+	ks := NewKeyStorePlain(dir)
+	cache := ks.(*keyStorePlain).cache
+	if _, err := ks.GetKeyAddresses(); err != nil {
+		t.Fatal(err)
+	}
+	// Closing the store must stop the watcher, if any
+	ks.Close()
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		cache.lock.Lock()
+		watching := cache.watching
+		cache.lock.Unlock()
+		if !watching {
+			break
+		}
+		if time.Since(start) > time.Second {
+			t.Fatalf("key directory still watched after close")
+		}
+	}
+	// Lookups keep working by polling the directory
+	key, err := ks.GenerateNewKey(randentropy.Reader, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addrs, err := ks.GetKeyAddresses(); err != nil || len(addrs) != 1 || addrs[0] != key.Address {
+		t.Errorf("addresses mismatch: have %x (%v), want [%x]", addrs, err, key.Address)
+	}
+}
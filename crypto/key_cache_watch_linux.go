@@ -0,0 +1,92 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build linux
+
+package crypto
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/krypton/go-krypton/logger"
+	"github.com/krypton/go-krypton/logger/glog"
+)
+
+// keyDirEvents are the inotify events signalling a change of the key files.
+const keyDirEvents = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_CLOSE_WRITE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// watch starts watching the key directory with inotify. The caller must hold
+// the lock.
+func (kc *keyCache) watch() error {
+	fd, err := syscall.InotifyInit()
+	if err != nil {
+		return err
+	}
+	wd, err := syscall.InotifyAddWatch(fd, kc.keydir, keyDirEvents)
+	if err != nil {
+		syscall.Close(fd)
+		return err
+	}
+	// Removing the watch on close wakes up the blocked reader
+	go func() {
+		<-kc.closed
+		syscall.InotifyRmWatch(fd, uint32(wd))
+	}()
+	go kc.watchLoop(fd)
+	return nil
+}
+
+// watchLoop reads inotify events until the directory is gone or the watch is
+// removed, triggering rescans on changes.
+func (kc *keyCache) watchLoop(fd int) {
+	defer syscall.Close(fd)
+	defer kc.stopWatching()
+
+	var (
+		buf     [syscall.SizeofInotifyEvent * 64]byte
+		pending chan struct{} // collects the changes of the next rescan
+	)
+	for {
+		n, err := syscall.Read(fd, buf[:])
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || n <= 0 {
+			glog.V(logger.Debug).Infof("Key directory watcher of %s failed: %v", kc.keydir, err)
+			return
+		}
+		gone := false
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			if ev.Mask&(syscall.IN_IGNORED|syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0 {
+				gone = true
+			}
+			offset += syscall.SizeofInotifyEvent + int(ev.Len)
+		}
+		// Add the change to the pending rescan, or schedule a new one
+		select {
+		case pending <- struct{}{}:
+		default:
+			pending = make(chan struct{})
+			go kc.changed(pending)
+		}
+		if gone {
+			return
+		}
+	}
+}
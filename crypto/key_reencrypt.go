@@ -0,0 +1,173 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/krypton/go-krypton/common"
+	"github.com/pborman/uuid"
+)
+
+var errPlainKeyStore = errors.New("keys of a plain key store are not encrypted")
+
+// KeyFileFormat describes how a key file is encrypted.
+type KeyFileFormat struct {
+	Version int
+	KDF     KDFParams
+}
+
+// Legacy reports whether the key file predates the current key file version.
+func (f KeyFileFormat) Legacy() bool {
+	return f.Version < version
+}
+
+func (f KeyFileFormat) String() string {
+	return fmt.Sprintf("v%d %v", f.Version, f.KDF)
+}
+
+// ReadKeyFileFormat determines the format of an encrypted key file.
+func ReadKeyFileFormat(keyJSON []byte) (KeyFileFormat, error) {
+	var k struct {
+		Version interface{} `json:"version"`
+		Crypto  cryptoJSON  `json:"crypto"`
+	}
+	if err := json.Unmarshal(keyJSON, &k); err != nil {
+		return KeyFileFormat{}, err
+	}
+	var format KeyFileFormat
+	switch v := k.Version.(type) {
+	case float64:
+		format.Version = int(v)
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return KeyFileFormat{}, fmt.Errorf("invalid key file version %q", v)
+		}
+		format.Version = n
+	default:
+		return KeyFileFormat{}, errors.New("key file has no version")
+	}
+	format.KDF.KDF = k.Crypto.KDF
+	switch params := k.Crypto.KDFParams; k.Crypto.KDF {
+	case "scrypt":
+		if n, ok := params["n"].(float64); ok {
+			format.KDF.ScryptN = int(n)
+		}
+		if p, ok := params["p"].(float64); ok {
+			format.KDF.ScryptP = int(p)
+		}
+	case "pbkdf2":
+		if c, ok := params["c"].(float64); ok {
+			format.KDF.PBKDF2C = int(c)
+		}
+	}
+	return format, nil
+}
+
+// ReencryptKeyFile decrypts the key file at path with auth and encrypts it
+// again using the given KDF parameters, upgrading legacy key files to the
+// current version. The result is verified to decrypt to the same key before
+// it atomically replaces the original file. The format of the original file is
+// returned, even if re-encryption fails.
+func ReencryptKeyFile(path, auth string, kdf KDFParams) (KeyFileFormat, error) {
+	if err := kdf.Validate(); err != nil {
+		return KeyFileFormat{}, err
+	}
+	keyJSON, err := ioutil.ReadFile(path)
+	if err != nil {
+		return KeyFileFormat{}, err
+	}
+	format, err := ReadKeyFileFormat(keyJSON)
+	if err != nil {
+		return format, err
+	}
+	keyBytes, keyId, err := decryptKeyJSON(keyJSON, auth)
+	if err != nil {
+		return format, err
+	}
+	key := NewKeyFromECDSA(ToECDSA(keyBytes))
+	if id := uuid.UUID(keyId); id != nil {
+		key.Id = id
+	}
+	// Refuse to touch files whose key doesn't match their address
+	var stored struct {
+		Address string `json:"address"`
+	}
+	json.Unmarshal(keyJSON, &stored)
+	if stored.Address != "" && !strings.EqualFold(strings.TrimPrefix(stored.Address, "0x"), hex.EncodeToString(key.Address[:])) {
+		return format, fmt.Errorf("key file address %s doesn't match its key %x", stored.Address, key.Address)
+	}
+	newJSON, err := encryptKey(key, auth, kdf)
+	if err != nil {
+		return format, err
+	}
+	if check, _, err := decryptKeyJSON(newJSON, auth); err != nil || !bytes.Equal(check, keyBytes) {
+		return format, fmt.Errorf("verification of re-encrypted key failed: %v", err)
+	}
+	return format, replaceFile(path, newJSON)
+}
+
+// replaceFile atomically replaces the content of a file by renaming a
+// temporary file written next to it over it.
+func replaceFile(path string, content []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	f.Close()
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Reencrypt re-encrypts the key file of the address with new KDF parameters,
+// see ReencryptKeyFile. Addresses with multiple key files are refused.
+func (ks keyStorePassphrase) Reencrypt(keyAddr common.Address, auth string, kdf KDFParams) (KeyFileFormat, error) {
+	path, err := ks.cache.findUnique(keyAddr)
+	if err != nil {
+		return KeyFileFormat{}, err
+	}
+	format, err := ReencryptKeyFile(path, auth, kdf)
+	ks.cache.reload()
+	return format, err
+}
+
+func (ks keyStorePlain) Reencrypt(keyAddr common.Address, auth string, kdf KDFParams) (KeyFileFormat, error) {
+	return KeyFileFormat{}, errPlainKeyStore
+}
@@ -38,6 +38,7 @@ import (
 
 	"github.com/krypton/go-krypton/common"
 	"github.com/krypton/go-krypton/crypto/randentropy"
+	"github.com/krypton/go-krypton/event"
 	"github.com/pborman/uuid"
 	"golang.org/x/crypto/pbkdf2"
 	"golang.org/x/crypto/scrypt"
@@ -56,18 +57,70 @@ const (
 
 	scryptR     = 8
 	scryptDKLen = 32
+
+	// StandardPBKDF2C is the PBKDF2-HMAC-SHA256 iteration count of the Web3
+	// Secret Storage examples, for tools that can't handle scrypt.
+	StandardPBKDF2C = 1 << 18
 )
 
+// KDFParams selects the key derivation function deriving the encryption key
+// of a key file from its passphrase, along with its cost parameters.
+type KDFParams struct {
+	KDF     string // "scrypt" or "pbkdf2"
+	ScryptN int
+	ScryptP int
+	PBKDF2C int // iterations of PBKDF2-HMAC-SHA256
+}
+
+// ScryptKDF returns the parameters of scrypt with the given N and P.
+func ScryptKDF(n, p int) KDFParams {
+	return KDFParams{KDF: "scrypt", ScryptN: n, ScryptP: p}
+}
+
+// PBKDF2KDF returns the parameters of PBKDF2-HMAC-SHA256 with c iterations.
+func PBKDF2KDF(c int) KDFParams {
+	return KDFParams{KDF: "pbkdf2", PBKDF2C: c}
+}
+
+func (kdf KDFParams) String() string {
+	switch kdf.KDF {
+	case "scrypt":
+		return fmt.Sprintf("scrypt(n=%d, r=%d, p=%d)", kdf.ScryptN, scryptR, kdf.ScryptP)
+	case "pbkdf2":
+		return fmt.Sprintf("pbkdf2(c=%d, prf=hmac-sha256)", kdf.PBKDF2C)
+	}
+	return kdf.KDF
+}
+
+// Validate checks whether keys can be encrypted with the parameters.
+func (kdf KDFParams) Validate() error {
+	switch kdf.KDF {
+	case "scrypt":
+		if kdf.ScryptN <= 1 || kdf.ScryptN&(kdf.ScryptN-1) != 0 {
+			return fmt.Errorf("scrypt N must be a power of two > 1, got %d", kdf.ScryptN)
+		}
+		if kdf.ScryptP <= 0 {
+			return fmt.Errorf("scrypt P must be positive, got %d", kdf.ScryptP)
+		}
+	case "pbkdf2":
+		if kdf.PBKDF2C <= 0 {
+			return fmt.Errorf("pbkdf2 iteration count must be positive, got %d", kdf.PBKDF2C)
+		}
+	default:
+		return fmt.Errorf("unsupported KDF %q", kdf.KDF)
+	}
+	return nil
+}
+
 type keyStorePassphrase struct {
 	keysDirPath string
+	cache       *keyCache
 	scryptN     int
 	scryptP     int
-	scryptR     int
-	scryptDKLen int
 }
 
 func NewKeyStorePassphrase(path string, scryptN int, scryptP int) KeyStore {
-	return &keyStorePassphrase{path, scryptN, scryptP, scryptR, scryptDKLen}
+	return &keyStorePassphrase{path, newKeyCache(path), scryptN, scryptP}
 }
 
 func (ks keyStorePassphrase) GenerateNewKey(rand io.Reader, auth string) (key *Key, err error) {
@@ -75,7 +128,7 @@ func (ks keyStorePassphrase) GenerateNewKey(rand io.Reader, auth string) (key *K
 }
 
 func (ks keyStorePassphrase) GetKey(keyAddr common.Address, auth string) (key *Key, err error) {
-	keyBytes, keyId, err := decryptKeyFromFile(ks.keysDirPath, keyAddr, auth)
+	keyBytes, keyId, err := decryptKeyFromFile(ks.cache, keyAddr, auth)
 	if err == nil {
 		key = &Key{
 			Id:         uuid.UUID(keyId),
@@ -87,93 +140,156 @@ func (ks keyStorePassphrase) GetKey(keyAddr common.Address, auth string) (key *K
 }
 
 func (ks keyStorePassphrase) Cleanup(keyAddr common.Address) (err error) {
-	return cleanup(ks.keysDirPath, keyAddr)
+	return cleanup(ks.cache, keyAddr)
+}
+
+func (ks keyStorePassphrase) Events() *event.TypeMux {
+	return ks.cache.mux
+}
+
+func (ks keyStorePassphrase) Close() {
+	ks.cache.close()
 }
 
 func (ks keyStorePassphrase) GetKeyAddresses() (addresses []common.Address, err error) {
-	return getKeyAddresses(ks.keysDirPath)
+	return ks.cache.addresses()
 }
 
 func (ks keyStorePassphrase) StoreKey(key *Key, auth string) (err error) {
-	authArray := []byte(auth)
-	salt := randentropy.GetEntropyCSPRNG(32)
-	derivedKey, err := scrypt.Key(authArray, salt, ks.scryptN, ks.scryptR, ks.scryptP, ks.scryptDKLen)
+	keyJSON, err := encryptKey(key, auth, ScryptKDF(ks.scryptN, ks.scryptP))
 	if err != nil {
 		return err
 	}
+
+	if err := writeKeyFile(key.Address, ks.keysDirPath, keyJSON); err != nil {
+		return err
+	}
+	ks.cache.reload()
+	return nil
+}
+
+// encryptKey returns the v3 key file of the key, encrypted with auth.
+func encryptKey(key *Key, auth string, kdf KDFParams) ([]byte, error) {
+	cryptoStruct, err := encryptDataV3(FromECDSA(key.PrivateKey), auth, kdf)
+	if err != nil {
+		return nil, err
+	}
+	encryptedKeyJSONV3 := encryptedKeyJSONV3{
+		hex.EncodeToString(key.Address[:]),
+		cryptoStruct,
+		key.Id.String(),
+		version,
+	}
+	return json.Marshal(encryptedKeyJSONV3)
+}
+
+// EncryptDataV3 encrypts arbitrary data with a passphrase the same way the
+// private keys of v3 key files are encrypted, returning the JSON encoding of
+// the crypto section.
+func EncryptDataV3(data []byte, auth string, scryptN, scryptP int) ([]byte, error) {
+	cryptoStruct, err := encryptDataV3(data, auth, ScryptKDF(scryptN, scryptP))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(cryptoStruct)
+}
+
+// DecryptDataV3 decrypts the JSON encoded crypto section created by
+// EncryptDataV3 or found in v3 key files.
+func DecryptDataV3(cryptoSection []byte, auth string) ([]byte, error) {
+	var cryptoStruct cryptoJSON
+	if err := json.Unmarshal(cryptoSection, &cryptoStruct); err != nil {
+		return nil, err
+	}
+	return decryptDataV3(cryptoStruct, auth)
+}
+
+func encryptDataV3(data []byte, auth string, kdf KDFParams) (cryptoJSON, error) {
+	if err := kdf.Validate(); err != nil {
+		return cryptoJSON{}, err
+	}
+	authArray := []byte(auth)
+	salt := randentropy.GetEntropyCSPRNG(32)
+
+	var (
+		derivedKey []byte
+		err        error
+	)
+	kdfParamsJSON := map[string]interface{}{
+		"dklen": scryptDKLen,
+		"salt":  hex.EncodeToString(salt),
+	}
+	if kdf.KDF == "pbkdf2" {
+		derivedKey = pbkdf2.Key(authArray, salt, kdf.PBKDF2C, scryptDKLen, sha256.New)
+		kdfParamsJSON["c"] = kdf.PBKDF2C
+		kdfParamsJSON["prf"] = "hmac-sha256"
+	} else {
+		derivedKey, err = scrypt.Key(authArray, salt, kdf.ScryptN, scryptR, kdf.ScryptP, scryptDKLen)
+		if err != nil {
+			return cryptoJSON{}, err
+		}
+		kdfParamsJSON["n"] = kdf.ScryptN
+		kdfParamsJSON["r"] = scryptR
+		kdfParamsJSON["p"] = kdf.ScryptP
+	}
 	encryptKey := derivedKey[:16]
-	keyBytes := FromECDSA(key.PrivateKey)
 
 	iv := randentropy.GetEntropyCSPRNG(aes.BlockSize) // 16
-	cipherText, err := aesCTRXOR(encryptKey, keyBytes, iv)
+	cipherText, err := aesCTRXOR(encryptKey, data, iv)
 	if err != nil {
-		return err
+		return cryptoJSON{}, err
 	}
 
 	mac := Sha3(derivedKey[16:32], cipherText)
 
-	scryptParamsJSON := make(map[string]interface{}, 5)
-	scryptParamsJSON["n"] = ks.scryptN
-	scryptParamsJSON["r"] = ks.scryptR
-	scryptParamsJSON["p"] = ks.scryptP
-	scryptParamsJSON["dklen"] = ks.scryptDKLen
-	scryptParamsJSON["salt"] = hex.EncodeToString(salt)
-
 	cipherParamsJSON := cipherparamsJSON{
 		IV: hex.EncodeToString(iv),
 	}
 
-	cryptoStruct := cryptoJSON{
+	return cryptoJSON{
 		Cipher:       "aes-128-ctr",
 		CipherText:   hex.EncodeToString(cipherText),
 		CipherParams: cipherParamsJSON,
-		KDF:          "scrypt",
-		KDFParams:    scryptParamsJSON,
+		KDF:          kdf.KDF,
+		KDFParams:    kdfParamsJSON,
 		MAC:          hex.EncodeToString(mac),
-	}
-	encryptedKeyJSONV3 := encryptedKeyJSONV3{
-		hex.EncodeToString(key.Address[:]),
-		cryptoStruct,
-		key.Id.String(),
-		version,
-	}
-	keyJSON, err := json.Marshal(encryptedKeyJSONV3)
-	if err != nil {
-		return err
-	}
-
-	return writeKeyFile(key.Address, ks.keysDirPath, keyJSON)
+	}, nil
 }
 
 func (ks keyStorePassphrase) DeleteKey(keyAddr common.Address, auth string) (err error) {
 	// only delete if correct passphrase is given
-	_, _, err = decryptKeyFromFile(ks.keysDirPath, keyAddr, auth)
+	_, _, err = decryptKeyFromFile(ks.cache, keyAddr, auth)
 	if err != nil {
 		return err
 	}
 
-	return deleteKey(ks.keysDirPath, keyAddr)
+	return deleteKey(ks.cache, keyAddr)
 }
 
-func decryptKeyFromFile(keysDirPath string, keyAddr common.Address, auth string) (keyBytes []byte, keyId []byte, err error) {
-	m := make(map[string]interface{})
-	err = getKey(keysDirPath, keyAddr, &m)
+func decryptKeyFromFile(cache *keyCache, keyAddr common.Address, auth string) (keyBytes []byte, keyId []byte, err error) {
+	keyJSON, err := getKeyFile(cache, keyAddr)
 	if err != nil {
-		return
+		return nil, nil, err
 	}
+	return decryptKeyJSON(keyJSON, auth)
+}
 
+// decryptKeyJSON decrypts the private key of a v1 or v3 key file.
+func decryptKeyJSON(keyJSON []byte, auth string) (keyBytes []byte, keyId []byte, err error) {
+	m := make(map[string]interface{})
+	if err = json.Unmarshal(keyJSON, &m); err != nil {
+		return
+	}
 	v := reflect.ValueOf(m["version"])
 	if v.Kind() == reflect.String && v.String() == "1" {
 		k := new(encryptedKeyJSONV1)
-		err = getKey(keysDirPath, keyAddr, &k)
-		if err != nil {
+		if err = json.Unmarshal(keyJSON, k); err != nil {
 			return
 		}
 		return decryptKeyV1(k, auth)
 	} else {
 		k := new(encryptedKeyJSONV3)
-		err = getKey(keysDirPath, keyAddr, &k)
-		if err != nil {
+		if err = json.Unmarshal(keyJSON, k); err != nil {
 			return
 		}
 		return decryptKeyV3(k, auth)
@@ -185,41 +301,45 @@ func decryptKeyV3(keyProtected *encryptedKeyJSONV3, auth string) (keyBytes []byt
 		return nil, nil, fmt.Errorf("Version not supported: %v", keyProtected.Version)
 	}
 
-	if keyProtected.Crypto.Cipher != "aes-128-ctr" {
-		return nil, nil, fmt.Errorf("Cipher not supported: %v", keyProtected.Crypto.Cipher)
-	}
-
 	keyId = uuid.Parse(keyProtected.Id)
-	mac, err := hex.DecodeString(keyProtected.Crypto.MAC)
+	plainText, err := decryptDataV3(keyProtected.Crypto, auth)
 	if err != nil {
 		return nil, nil, err
 	}
+	return plainText, keyId, err
+}
 
-	iv, err := hex.DecodeString(keyProtected.Crypto.CipherParams.IV)
+func decryptDataV3(cryptoJson cryptoJSON, auth string) ([]byte, error) {
+	if cryptoJson.Cipher != "aes-128-ctr" {
+		return nil, fmt.Errorf("Cipher not supported: %v", cryptoJson.Cipher)
+	}
+
+	mac, err := hex.DecodeString(cryptoJson.MAC)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	cipherText, err := hex.DecodeString(keyProtected.Crypto.CipherText)
+	iv, err := hex.DecodeString(cryptoJson.CipherParams.IV)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	derivedKey, err := getKDFKey(keyProtected.Crypto, auth)
+	cipherText, err := hex.DecodeString(cryptoJson.CipherText)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+
+	derivedKey, err := getKDFKey(cryptoJson, auth)
+	if err != nil {
+		return nil, err
 	}
 
 	calculatedMAC := Sha3(derivedKey[16:32], cipherText)
 	if !bytes.Equal(calculatedMAC, mac) {
-		return nil, nil, errors.New("Decryption failed: MAC mismatch")
+		return nil, errors.New("Decryption failed: MAC mismatch")
 	}
 
-	plainText, err := aesCTRXOR(derivedKey[:16], cipherText, iv)
-	if err != nil {
-		return nil, nil, err
-	}
-	return plainText, keyId, err
+	return aesCTRXOR(derivedKey[:16], cipherText, iv)
 }
 
 func decryptKeyV1(keyProtected *encryptedKeyJSONV1, auth string) (keyBytes []byte, keyId []byte, err error) {
@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/event"
 )
 
 type KeyStore interface {
@@ -37,14 +38,26 @@ type KeyStore interface {
 	StoreKey(*Key, string) error                 // store key optionally using auth string
 	DeleteKey(common.Address, string) error      // delete key by addr and auth string
 	Cleanup(keyAddr common.Address) (err error)
+
+	// Reencrypt encrypts the key of the address again with new KDF parameters,
+	// returning the format the key was stored in before.
+	Reencrypt(keyAddr common.Address, auth string, kdf KDFParams) (KeyFileFormat, error)
+
+	// Events returns the mux KeyAddedEvent and KeyRemovedEvent are posted on
+	// when keys appear in or disappear from the store.
+	Events() *event.TypeMux
+
+	// Close stops watching the key directory for changes.
+	Close()
 }
 
 type keyStorePlain struct {
 	keysDirPath string
+	cache       *keyCache
 }
 
 func NewKeyStorePlain(path string) KeyStore {
-	return &keyStorePlain{path}
+	return &keyStorePlain{path, newKeyCache(path)}
 }
 
 func (ks keyStorePlain) GenerateNewKey(rand io.Reader, auth string) (key *Key, err error) {
@@ -64,12 +77,12 @@ func GenerateNewKeyDefault(ks KeyStore, rand io.Reader, auth string) (key *Key,
 
 func (ks keyStorePlain) GetKey(keyAddr common.Address, auth string) (key *Key, err error) {
 	key = new(Key)
-	err = getKey(ks.keysDirPath, keyAddr, key)
+	err = getKey(ks.cache, keyAddr, key)
 	return
 }
 
-func getKey(keysDirPath string, keyAddr common.Address, content interface{}) (err error) {
-	fileContent, err := getKeyFile(keysDirPath, keyAddr)
+func getKey(cache *keyCache, keyAddr common.Address, content interface{}) (err error) {
+	fileContent, err := getKeyFile(cache, keyAddr)
 	if err != nil {
 		return
 	}
@@ -77,11 +90,19 @@ func getKey(keysDirPath string, keyAddr common.Address, content interface{}) (er
 }
 
 func (ks keyStorePlain) GetKeyAddresses() (addresses []common.Address, err error) {
-	return getKeyAddresses(ks.keysDirPath)
+	return ks.cache.addresses()
 }
 
 func (ks keyStorePlain) Cleanup(keyAddr common.Address) (err error) {
-	return cleanup(ks.keysDirPath, keyAddr)
+	return cleanup(ks.cache, keyAddr)
+}
+
+func (ks keyStorePlain) Events() *event.TypeMux {
+	return ks.cache.mux
+}
+
+func (ks keyStorePlain) Close() {
+	ks.cache.close()
 }
 
 func (ks keyStorePlain) StoreKey(key *Key, auth string) (err error) {
@@ -90,73 +111,55 @@ func (ks keyStorePlain) StoreKey(key *Key, auth string) (err error) {
 		return
 	}
 	err = writeKeyFile(key.Address, ks.keysDirPath, keyJSON)
+	ks.cache.reload()
 	return
 }
 
 func (ks keyStorePlain) DeleteKey(keyAddr common.Address, auth string) (err error) {
-	return deleteKey(ks.keysDirPath, keyAddr)
+	return deleteKey(ks.cache, keyAddr)
 }
 
-func deleteKey(keysDirPath string, keyAddr common.Address) (err error) {
-	var path string
-	path, err = getKeyFilePath(keysDirPath, keyAddr)
-	if err == nil {
-		addrHex := hex.EncodeToString(keyAddr[:])
-		if path == filepath.Join(keysDirPath, addrHex, addrHex) {
-			path = filepath.Join(keysDirPath, addrHex)
-		}
-		err = os.RemoveAll(path)
+// deleteKey removes the key file of an address. Keys with multiple files are
+// not deleted as it's unclear which one is meant.
+func deleteKey(cache *keyCache, keyAddr common.Address) (err error) {
+	path, err := cache.findUnique(keyAddr)
+	if err != nil {
+		return err
 	}
-	return
-}
-
-func getKeyFilePath(keysDirPath string, keyAddr common.Address) (keyFilePath string, err error) {
 	addrHex := hex.EncodeToString(keyAddr[:])
-	matches, err := filepath.Glob(filepath.Join(keysDirPath, fmt.Sprintf("*--%s", addrHex)))
-	if len(matches) > 0 {
-		if err == nil {
-			keyFilePath = matches[len(matches)-1]
-		}
-		return
+	if path == filepath.Join(cache.keydir, addrHex, addrHex) {
+		path = filepath.Join(cache.keydir, addrHex)
 	}
-	keyFilePath = filepath.Join(keysDirPath, addrHex, addrHex)
-	_, err = os.Stat(keyFilePath)
-	return
+	err = os.RemoveAll(path)
+	cache.reload()
+	return err
 }
 
-func cleanup(keysDirPath string, keyAddr common.Address) (err error) {
-	fileInfos, err := ioutil.ReadDir(keysDirPath)
-	if err != nil {
-		return
-	}
-	var paths []string
-	account := hex.EncodeToString(keyAddr[:])
-	for _, fileInfo := range fileInfos {
-		path := filepath.Join(keysDirPath, fileInfo.Name())
-		if len(path) >= 40 {
-			addr := path[len(path)-40 : len(path)]
-			if addr == account {
-				if path == filepath.Join(keysDirPath, addr, addr) {
-					path = filepath.Join(keysDirPath, addr)
-				}
-				paths = append(paths, path)
-			}
+// cleanup removes all but the latest key file of an address.
+func cleanup(cache *keyCache, keyAddr common.Address) (err error) {
+	cache.reload()
+
+	cache.lock.Lock()
+	paths := append([]string(nil), cache.files[keyAddr]...)
+	cache.lock.Unlock()
+
+	addrHex := hex.EncodeToString(keyAddr[:])
+	for i := 0; err == nil && i < len(paths)-1; i++ {
+		path := paths[i]
+		if path == filepath.Join(cache.keydir, addrHex, addrHex) {
+			path = filepath.Join(cache.keydir, addrHex)
 		}
+		err = os.RemoveAll(path)
 	}
 	if len(paths) > 1 {
-		for i := 0; err == nil && i < len(paths)-1; i++ {
-			err = os.RemoveAll(paths[i])
-			if err != nil {
-				break
-			}
-		}
+		cache.reload()
 	}
 	return
 }
 
-func getKeyFile(keysDirPath string, keyAddr common.Address) (fileContent []byte, err error) {
+func getKeyFile(cache *keyCache, keyAddr common.Address) (fileContent []byte, err error) {
 	var keyFilePath string
-	keyFilePath, err = getKeyFilePath(keysDirPath, keyAddr)
+	keyFilePath, err = cache.find(keyAddr)
 	if err == nil {
 		fileContent, err = ioutil.ReadFile(keyFilePath)
 	}
@@ -191,21 +194,3 @@ func toISO8601(t time.Time) string {
 	}
 	return fmt.Sprintf("%04d-%02d-%02dT%02d-%02d-%02d.%09d%s", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), tz)
 }
-
-func getKeyAddresses(keysDirPath string) (addresses []common.Address, err error) {
-	fileInfos, err := ioutil.ReadDir(keysDirPath)
-	if err != nil {
-		return nil, err
-	}
-	for _, fileInfo := range fileInfos {
-		filename := fileInfo.Name()
-		if len(filename) >= 40 {
-			addr := filename[len(filename)-40 : len(filename)]
-			address, err := hex.DecodeString(addr)
-			if err == nil {
-				addresses = append(addresses, common.BytesToAddress(address))
-			}
-		}
-	}
-	return addresses, err
-}
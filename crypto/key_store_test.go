@@ -19,6 +19,9 @@ package crypto
 import (
 	"encoding/hex"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -231,3 +234,58 @@ func TestKeyForDirectICAP(t *testing.T) {
 		t.Errorf("Expected first address byte to be zero, have: %s", key.Address.Hex())
 	}
 }
+
+func TestReencryptKeyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kr-keystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Upgrade the v1 test key to a pbkdf2 encrypted v3 key
+	addr := common.HexToAddress("cb61d5a9c4896fb9658090b597ef0e7be6f7b67e")
+	keyJSON, err := ioutil.ReadFile("tests/v1/cb61d5a9c4896fb9658090b597ef0e7be6f7b67e/cb61d5a9c4896fb9658090b597ef0e7be6f7b67e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "key")
+	if err := ioutil.WriteFile(path, keyJSON, 0600); err != nil {
+		t.Fatal(err)
+	}
+	kdf := PBKDF2KDF(1024)
+	if _, err := ReencryptKeyFile(path, "wrong", kdf); err == nil {
+		t.Fatal("re-encrypted with wrong passphrase")
+	}
+	format, err := ReencryptKeyFile(path, "g", kdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format.Version != 1 || !format.Legacy() {
+		t.Errorf("previous format mismatch: %v", format)
+	}
+	newJSON, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format, err = ReadKeyFileFormat(newJSON); err != nil || format.Legacy() || format.KDF != kdf {
+		t.Errorf("new format mismatch: have %v (%v), want v%d %v", format, err, version, kdf)
+	}
+	ks := NewKeyStorePassphrase(dir, LightScryptN, LightScryptP)
+	k, err := ks.GetKey(addr, "g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(FromECDSA(k.PrivateKey)) != "d1b1178d3529626a1a93e073f65028370d14c7eb0936eb42abef05db6f37ad7d" {
+		t.Errorf("re-encrypted key mismatch")
+	}
+	// And back to scrypt through the key store
+	if _, err := ks.Reencrypt(addr, "g", ScryptKDF(LightScryptN, LightScryptP)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.GetKey(addr, "g"); err != nil {
+		t.Errorf("failed to decrypt key after second re-encryption: %v", err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("key directory has %d files, want 1", len(files))
+	}
+}
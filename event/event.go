@@ -66,7 +66,7 @@ func (mux *TypeMux) Subscribe(types ...interface{}) Subscription {
 	mux.mutex.Lock()
 	defer mux.mutex.Unlock()
 	if mux.stopped {
-		close(sub.postC)
+		sub.closewait()
 	} else {
 		if mux.subm == nil {
 			mux.subm = make(map[reflect.Type][]*muxsub)
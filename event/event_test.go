@@ -54,6 +54,8 @@ func TestMuxErrorAfterStop(t *testing.T) {
 	if err := mux.Post(testEvent(0)); err != ErrMuxClosed {
 		t.Errorf("Post error mismatch, got: %s, expected: %s", err, ErrMuxClosed)
 	}
+	// Unsubscribing must not close the channel a second time
+	sub.Unsubscribe()
 }
 
 func TestUnsubscribeUnblockPost(t *testing.T) {
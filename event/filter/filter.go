@@ -17,7 +17,10 @@
 // Package filter implements event filters.
 package filter
 
-import "reflect"
+import (
+	"reflect"
+	"sync"
+)
 
 type Filter interface {
 	Compare(Filter) bool
@@ -32,6 +35,7 @@ type FilterEvent struct {
 type Filters struct {
 	id       int
 	watchers map[int]Filter
+	lock     sync.RWMutex // Protects the watchers, which are modified from other goroutines
 	ch       chan FilterEvent
 
 	quit chan struct{}
@@ -58,6 +62,9 @@ func (self *Filters) Notify(filter Filter, data interface{}) {
 }
 
 func (self *Filters) Install(watcher Filter) int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
 	self.watchers[self.id] = watcher
 	self.id++
 
@@ -65,6 +72,9 @@ func (self *Filters) Install(watcher Filter) int {
 }
 
 func (self *Filters) Uninstall(id int) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
 	delete(self.watchers, id)
 }
 
@@ -75,7 +85,15 @@ out:
 		case <-self.quit:
 			break out
 		case event := <-self.ch:
+			// Trigger without holding the lock, handlers may (un)install filters
+			self.lock.RLock()
+			watchers := make([]Filter, 0, len(self.watchers))
 			for _, watcher := range self.watchers {
+				watchers = append(watchers, watcher)
+			}
+			self.lock.RUnlock()
+
+			for _, watcher := range watchers {
 				if reflect.TypeOf(watcher) == reflect.TypeOf(event.filter) {
 					if watcher.Compare(event.filter) {
 						watcher.Trigger(event.data)
@@ -91,5 +109,8 @@ func (self *Filters) Match(a, b Filter) bool {
 }
 
 func (self *Filters) Get(i int) Filter {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
 	return self.watchers[i]
 }
@@ -18,8 +18,10 @@
 package jsre
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -40,8 +42,35 @@ type JSRE struct {
 	evalQueue     chan *evalReq
 	stopEventLoop chan bool
 	loopWg        sync.WaitGroup
+	closed        chan struct{} // Closed when the event loop terminates
+	loopErr       error         // Error that terminated the event loop, if any
+
+	scriptTimeout time.Duration // Maximum execution time of a single script, 0 if unlimited
+	scriptDirs    []string      // Directories of the scripts being executed, used by loadScript
+}
+
+// ExitError is returned when the running code terminated the runtime by calling
+// the exit function.
+type ExitError struct {
+	Code int
+}
+
+func (err *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", err.Code)
+}
+
+// TimeoutError is returned when a script ran longer than the configured limit.
+type TimeoutError struct {
+	File    string
+	Timeout time.Duration
+}
+
+func (err *TimeoutError) Error() string {
+	return fmt.Sprintf("script %s timed out after %v", err.File, err.Timeout)
 }
 
+var errStopped = errors.New("JS runtime stopped")
+
 // jsTimer is a single timer instance with a callback function
 type jsTimer struct {
 	timer    *time.Timer
@@ -62,14 +91,41 @@ func New(assetPath string) *JSRE {
 		assetPath:     assetPath,
 		evalQueue:     make(chan *evalReq),
 		stopEventLoop: make(chan bool),
+		closed:        make(chan struct{}),
 	}
 	re.loopWg.Add(1)
 	go re.runEventLoop()
 	re.Set("loadScript", re.loadScript)
 	re.Set("inspect", prettyPrintJS)
+	re.Set("exit", exit)
 	return re
 }
 
+// exit terminates the running code, making the runtime report an ExitError
+// with the given status code (0 if omitted).
+func exit(call otto.FunctionCall) otto.Value {
+	code, _ := call.Argument(0).ToInteger()
+	panic(&ExitError{Code: int(code)})
+}
+
+// catchExit runs fn, converting the control flow panics raised by exit calls and
+// script timeouts into errors. These can't be caught by JS exception handlers.
+func catchExit(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch r := r.(type) {
+			case *ExitError:
+				err = r
+			case *TimeoutError:
+				err = r
+			default:
+				panic(r)
+			}
+		}
+	}()
+	return fn()
+}
+
 // This function runs the main event loop from a goroutine that is started
 // when JSRE is created. Use Stop() before exiting to properly stop it.
 // The event loop processes vm access requests from the evalQueue in a
@@ -150,11 +206,21 @@ loop:
 				arguments = make([]interface{}, 1)
 			}
 			arguments[0] = timer.call.ArgumentList[0]
-			_, err := vm.Call(`Function.call.call`, nil, arguments...)
+			err := catchExit(func() error {
+				_, err := vm.Call(`Function.call.call`, nil, arguments...)
+				return err
+			})
+			if exitErr, ok := err.(*ExitError); ok {
+				self.loopErr = exitErr
+				break loop
+			}
 			if err != nil {
 				fmt.Println("js error:", err, arguments)
+				if self.loopErr == nil {
+					self.loopErr = err
+				}
 			}
-		
+
 			_, inreg := registry[timer] // when clearInterval is called from within the callback don't reset it
 			if timer.interval && inreg {
 				timer.timer.Reset(timer.duration)
@@ -182,32 +248,101 @@ loop:
 		timer.timer.Stop()
 		delete(registry, timer)
 	}
+	close(self.closed)
 
 	self.loopWg.Done()
 }
 
-// do schedules the given function on the event loop.
-func (self *JSRE) do(fn func(*otto.Otto)) {
+// do schedules the given function on the event loop. It returns false if the
+// event loop already terminated and fn was not run.
+func (self *JSRE) do(fn func(*otto.Otto)) bool {
 	done := make(chan bool)
 	req := &evalReq{fn, done}
-	self.evalQueue <- req
-	<-done
+	select {
+	case self.evalQueue <- req:
+		<-done
+		return true
+	case <-self.closed:
+		return false
+	}
 }
 
-// stops the event loop before exit, optionally waits for all timers to expire
-func (self *JSRE) Stop(waitForCallbacks bool) {
-	self.stopEventLoop <- waitForCallbacks
+// Stop stops the event loop before exit, optionally waiting for all timers to
+// expire. It returns the first error raised by a timer callback, which is an
+// ExitError if a callback called exit.
+func (self *JSRE) Stop(waitForCallbacks bool) error {
+	select {
+	case self.stopEventLoop <- waitForCallbacks:
+	case <-self.closed:
+	}
 	self.loopWg.Wait()
+	return self.loopErr
+}
+
+// SetScriptTimeout limits the execution time of each script run by Exec or
+// loadScript. Code that keeps running after the deadline is aborted with a
+// TimeoutError. Zero disables the limit.
+func (self *JSRE) SetScriptTimeout(timeout time.Duration) {
+	self.do(func(vm *otto.Otto) {
+		self.scriptTimeout = timeout
+		if timeout > 0 && vm.Interrupt == nil {
+			vm.Interrupt = make(chan func(), 1)
+		}
+	})
+}
+
+// runScript runs the source of file, enforcing the script timeout and making
+// the file's directory the base for relative loadScript paths. It must be
+// called on the event loop.
+func (self *JSRE) runScript(vm *otto.Otto, file string, src interface{}) (otto.Value, error) {
+	self.scriptDirs = append(self.scriptDirs, filepath.Dir(file))
+	defer func() { self.scriptDirs = self.scriptDirs[:len(self.scriptDirs)-1] }()
+
+	if self.scriptTimeout > 0 {
+		var (
+			timeout    = self.scriptTimeout
+			interrupts = vm.Interrupt
+			finished   = make(chan struct{})
+			running    = true
+		)
+		defer func() {
+			running = false
+			close(finished)
+		}()
+		timer := time.AfterFunc(timeout, func() {
+			// The interrupt is evaluated on the event loop, so running can't
+			// change concurrently. Late interrupts are ignored.
+			interrupt := func() {
+				if running {
+					panic(&TimeoutError{File: file, Timeout: timeout})
+				}
+			}
+			select {
+			case interrupts <- interrupt:
+			case <-finished:
+			}
+		})
+		defer timer.Stop()
+	}
+	return compileAndRun(vm, file, src)
 }
 
 // Exec(file) loads and runs the contents of a file
 // if a relative path is given, the jsre's assetPath is used
 func (self *JSRE) Exec(file string) error {
-	code, err := ioutil.ReadFile(common.AbsolutePath(self.assetPath, file))
+	file = common.AbsolutePath(self.assetPath, file)
+	code, err := ioutil.ReadFile(file)
 	if err != nil {
 		return err
 	}
-	self.do(func(vm *otto.Otto) { _, err = vm.Run(code) })
+	if !self.do(func(vm *otto.Otto) {
+		err = catchExit(func() error {
+			_, err := self.runScript(vm, file, code)
+			return err
+		})
+	}) {
+		return errStopped
+	}
 	return err
 }
 
@@ -219,62 +354,120 @@ func (self *JSRE) Bind(name string, v interface{}) error {
 
 // Run runs a piece of JS code.
 func (self *JSRE) Run(code string) (v otto.Value, err error) {
-	self.do(func(vm *otto.Otto) { v, err = vm.Run(code) })
+	if !self.do(func(vm *otto.Otto) {
+		err = catchExit(func() (err error) {
+			v, err = vm.Run(code)
+			return err
+		})
+	}) {
+		return otto.Value{}, errStopped
+	}
 	return v, err
 }
 
 // Get returns the value of a variable in the JS environment.
 func (self *JSRE) Get(ns string) (v otto.Value, err error) {
-	self.do(func(vm *otto.Otto) { v, err = vm.Get(ns) })
+	if !self.do(func(vm *otto.Otto) { v, err = vm.Get(ns) }) {
+		return otto.Value{}, errStopped
+	}
 	return v, err
 }
 
 // Set assigns value v to a variable in the JS environment.
 func (self *JSRE) Set(ns string, v interface{}) (err error) {
-	self.do(func(vm *otto.Otto) { err = vm.Set(ns, v) })
+	if !self.do(func(vm *otto.Otto) { err = vm.Set(ns, v) }) {
+		return errStopped
+	}
 	return err
 }
 
-// loadScript executes a JS script from inside the currently executing JS code.
+// loadScript executes a JS script from inside the currently executing JS code
+// and returns its evaluation result. Relative paths are resolved against the
+// directory of the calling script, or the assetPath outside of scripts. Failures
+// are thrown as JS exceptions.
 func (self *JSRE) loadScript(call otto.FunctionCall) otto.Value {
 	file, err := call.Argument(0).ToString()
 	if err != nil {
-		// TODO: throw exception
-		return otto.FalseValue()
+		throwJSException(call.Otto, err.Error())
 	}
-	file = common.AbsolutePath(self.assetPath, file)
+	base := self.assetPath
+	if len(self.scriptDirs) > 0 {
+		base = self.scriptDirs[len(self.scriptDirs)-1]
+	}
+	file = common.AbsolutePath(base, file)
 	source, err := ioutil.ReadFile(file)
 	if err != nil {
-		// TODO: throw exception
-		return otto.FalseValue()
+		throwJSException(call.Otto, fmt.Sprintf("could not load script %s: %v", file, err))
 	}
-	if _, err := compileAndRun(call.Otto, file, source); err != nil {
-		// TODO: throw exception
-		fmt.Println("err:", err)
-		return otto.FalseValue()
+	value, err := self.runScript(call.Otto, file, source)
+	if err != nil {
+		throwJSException(call.Otto, fmt.Sprintf("error in script %s: %v", file, err))
 	}
-	// TODO: return evaluation result
-	return otto.TrueValue()
+	return value
+}
+
+// throwJSException raises a JS Error with the given message in the calling code.
+func throwJSException(vm *otto.Otto, msg string) {
+	exception, _ := vm.Call("new Error", nil, msg)
+	panic(exception)
 }
 
 // EvalAndPrettyPrint evaluates code and pretty prints the result to
 // standard output.
 func (self *JSRE) EvalAndPrettyPrint(code string) (err error) {
-	self.do(func(vm *otto.Otto) {
-		var val otto.Value
-		val, err = vm.Run(code)
-		if err != nil {
-			return
-		}
+	return self.evalAndPrint(code, func(vm *otto.Otto, val otto.Value) error {
 		prettyPrint(vm, val)
 		fmt.Println()
+		return nil
+	})
+}
+
+// EvalAndPrintJSON evaluates code and prints the result to standard output as
+// raw JSON, suitable for processing by other programs. Undefined results are
+// not printed.
+func (self *JSRE) EvalAndPrintJSON(code string) (err error) {
+	return self.evalAndPrint(code, func(vm *otto.Otto, val otto.Value) error {
+		if val.IsUndefined() {
+			return nil
+		}
+		out, err := vm.Call("JSON.stringify", nil, val)
+		if err != nil {
+			return err
+		}
+		if out.IsUndefined() { // functions have no JSON encoding
+			out, _ = vm.ToValue(nil)
+		}
+		str, _ := out.ToString()
+		fmt.Println(str)
+		return nil
 	})
+}
+
+func (self *JSRE) evalAndPrint(code string, print func(*otto.Otto, otto.Value) error) (err error) {
+	if !self.do(func(vm *otto.Otto) {
+		err = catchExit(func() error {
+			val, err := vm.Run(code)
+			if err != nil {
+				return err
+			}
+			return print(vm, val)
+		})
+	}) {
+		return errStopped
+	}
 	return err
 }
 
 // Compile compiles and then runs a piece of JS code.
 func (self *JSRE) Compile(filename string, src interface{}) (err error) {
-	self.do(func(vm *otto.Otto) { _, err = compileAndRun(vm, filename, src) })
+	if !self.do(func(vm *otto.Otto) {
+		err = catchExit(func() error {
+			_, err := compileAndRun(vm, filename, src)
+			return err
+		})
+	}) {
+		return errStopped
+	}
 	return err
 }
 
@@ -135,3 +135,77 @@ func TestLoadScript(t *testing.T) {
 	}
 	jsre.Stop(false)
 }
+
+func TestLoadScriptRelative(t *testing.T) {
+	jsre, dir := newWithTestJS(t, `loadScript("lib/a.js")`)
+	defer os.RemoveAll(dir)
+	defer jsre.Stop(false)
+
+	os.MkdirAll(path.Join(dir, "lib"), os.ModePerm)
+	ioutil.WriteFile(path.Join(dir, "lib", "a.js"), []byte(`loadScript("b.js")`), os.ModePerm)
+	ioutil.WriteFile(path.Join(dir, "lib", "b.js"), []byte(`msg = "nested"; 42`), os.ModePerm)
+
+	if err := jsre.Exec("test.js"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if val, _ := jsre.Run("msg"); val.String() != "nested" {
+		t.Errorf("expected 'nested', got %v", val)
+	}
+	// The evaluation result is returned, missing files are thrown
+	if val, _ := jsre.Run(`loadScript("lib/b.js")`); val.String() != "42" {
+		t.Errorf("expected 42, got %v", val)
+	}
+	if val, _ := jsre.Run(`try { loadScript("missing.js"); "loaded" } catch (e) { "thrown" }`); val.String() != "thrown" {
+		t.Errorf("expected missing script to throw, got %v", val)
+	}
+}
+
+func TestExit(t *testing.T) {
+	jsre, dir := newWithTestJS(t, `try { exit(3) } catch (e) {}; msg = "continued"`)
+	defer os.RemoveAll(dir)
+
+	err := jsre.Exec("test.js")
+	if exitErr, ok := err.(*ExitError); !ok || exitErr.Code != 3 {
+		t.Fatalf("expected exit status 3, got %v", err)
+	}
+	if val, _ := jsre.Run("typeof msg"); val.String() != "undefined" {
+		t.Errorf("script continued after exit")
+	}
+	jsre.Stop(false)
+}
+
+func TestExitInCallback(t *testing.T) {
+	jsre, dir := newWithTestJS(t, `setTimeout(function() { exit(2) }, 1); setInterval(function() {}, 1)`)
+	defer os.RemoveAll(dir)
+
+	if err := jsre.Exec("test.js"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	err := jsre.Stop(true)
+	if exitErr, ok := err.(*ExitError); !ok || exitErr.Code != 2 {
+		t.Fatalf("expected exit status 2, got %v", err)
+	}
+	if _, err := jsre.Run("1"); err == nil {
+		t.Errorf("expected error from stopped runtime")
+	}
+}
+
+func TestScriptTimeout(t *testing.T) {
+	jsre, dir := newWithTestJS(t, `while (true) {}`)
+	defer os.RemoveAll(dir)
+	defer jsre.Stop(false)
+
+	jsre.SetScriptTimeout(50 * time.Millisecond)
+	if _, ok := jsre.Exec("test.js").(*TimeoutError); !ok {
+		t.Fatalf("expected script timeout")
+	}
+	// Scripts finishing in time must not be affected by earlier deadlines
+	ioutil.WriteFile(path.Join(dir, "fast.js"), []byte(`msg = "fast"`), os.ModePerm)
+	if err := jsre.Exec("fast.js"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := jsre.Run(`for (var i = 0; i < 1000; i++) {}; msg`); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
@@ -3359,6 +3359,7 @@ var getOptions = function (options) {
     return {
         topics: options.topics,
         to: options.to,
+        symKeyID: options.symKeyID,
         address: options.address,
         fromBlock: formatters.inputBlockNumberFormatter(options.fromBlock),
         toBlock: formatters.inputBlockNumberFormatter(options.toBlock)
@@ -33,6 +33,8 @@ import (
 
 	"github.com/krypton/krash"
 	"github.com/krypton/go-krypton/accounts"
+	"github.com/krypton/go-krypton/accounts/abi"
+	"github.com/krypton/go-krypton/accounts/multisig"
 	"github.com/krypton/go-krypton/common"
 	"github.com/krypton/go-krypton/common/compiler"
 	"github.com/krypton/go-krypton/common/httpclient"
@@ -117,13 +119,21 @@ type Config struct {
 	// Space-separated list of discovery node URLs
 	BootNodes string
 
+	// Space-separated list of DNS node tree URLs to dial peers from
+	DNSDiscovery string
+
 	// This key is used to identify the node on the network.
 	// If nil, an ephemeral key is used.
 	NodeKey *ecdsa.PrivateKey
 
-	NAT  nat.Interface
-	Shh  bool
-	Dial bool
+	NAT               nat.Interface
+	Shh               bool
+	ShhMailServer     bool               // Archive whisper envelopes to serve them to offline peers
+	ShhMinPoW         *float64           // Minimum proof of work of accepted whisper envelopes (nil = default)
+	ShhKeyStore       crypto.KeyStore    // Encrypted storage of whisper identities (nil = memory only)
+	MultisigABI       *abi.ABI           // Trusted ABI decoding proposed multisig wallet calls (nil = no decoding)
+	MultisigCosigners []*ecdsa.PublicKey // Whisper identities trusted to propose multisig calls
+	Dial              bool
 
 	Kryptonbase      common.Address
 	GasPrice       *big.Int
@@ -137,6 +147,10 @@ type Config struct {
 	GpobaseStepDown         int
 	GpobaseStepUp           int
 	GpobaseCorrectionFactor int
+	GpoOracle               string // Gas price oracle to use, GpoHeuristic or GpoPercentile
+	GpoBlocks               int    // Number of recent blocks sampled by the percentile oracle
+	GpoSamples              int    // Number of lowest prices sampled from each block
+	GpoPercentile           int    // Percentile of the samples suggested as gas price
 
 	// NewDB is used to create databases.
 	// If nil, the default is to create leveldb databases on disk.
@@ -228,12 +242,16 @@ type Krypton struct {
 	// DB interfaces
 	chainDb krdb.Database // Block chain database
 	dappDb  krdb.Database // Dapp database
+	mailDb  krdb.Database // Whisper mail server archive, nil if not serving mail
+
+	shhKeyStore crypto.KeyStore // Whisper identity store, nil if memory only
 
 	// Handlers
 	txPool          *core.TxPool
 	blockchain      *core.BlockChain
 	accountManager  *accounts.Manager
 	whisper         *whisper.Whisper
+	multisig        *multisig.Coordinator
 	pow             *krash.Krash
 	protocolManager *ProtocolManager
 	SolcPath        string
@@ -245,6 +263,11 @@ type Krypton struct {
 	GpobaseStepDown         int
 	GpobaseStepUp           int
 	GpobaseCorrectionFactor int
+	GpoOracle               string
+	GpoBlocks               int
+	GpoSamples              int
+	GpoPercentile           int
+	gasPrices               *PercentileOracle
 
 	httpclient *httpclient.HTTPClient
 
@@ -380,6 +403,10 @@ func New(config *Config) (*Krypton, error) {
 		GpobaseStepDown:         config.GpobaseStepDown,
 		GpobaseStepUp:           config.GpobaseStepUp,
 		GpobaseCorrectionFactor: config.GpobaseCorrectionFactor,
+		GpoOracle:               config.GpoOracle,
+		GpoBlocks:               config.GpoBlocks,
+		GpoSamples:              config.GpoSamples,
+		GpoPercentile:           config.GpoPercentile,
 		httpclient:              httpclient.New(config.DocRoot),
 	}
 
@@ -402,6 +429,7 @@ func New(config *Config) (*Krypton, error) {
 	}
 	newPool := core.NewTxPool(kr.EventMux(), kr.blockchain.State, kr.blockchain.GasLimit)
 	kr.txPool = newPool
+	kr.gasPrices = NewPercentileOracle(kr)
 
 	if kr.protocolManager, err = NewProtocolManager(config.FastSync, config.NetworkId, kr.eventMux, kr.txPool, kr.pow, kr.blockchain, chainDb); err != nil {
 		return nil, err
@@ -413,6 +441,28 @@ func New(config *Config) (*Krypton, error) {
 	if config.Shh {
 		kr.whisper = whisper.New()
 		kr.shhVersionId = int(kr.whisper.Version())
+
+		if config.ShhMinPoW != nil {
+			kr.whisper.SetMinPoW(*config.ShhMinPoW)
+		}
+		if config.ShhKeyStore != nil {
+			kr.shhKeyStore = config.ShhKeyStore
+			kr.whisper.SetKeyStore(kr.shhKeyStore)
+		}
+
+		if config.ShhMailServer {
+			if kr.mailDb, err = newdb(filepath.Join(config.DataDir, "shhmail")); err != nil {
+				return nil, fmt.Errorf("whisper mail db err: %v", err)
+			}
+			kr.whisper.EnableMailServer(kr.mailDb)
+		}
+		kr.multisig = multisig.New(kr.whisper, kr.accountManager, kr.submitMultisig)
+		if config.MultisigABI != nil {
+			kr.multisig.SetWalletABI(*config.MultisigABI)
+		}
+		if len(config.MultisigCosigners) > 0 {
+			kr.multisig.SetCosigners(config.MultisigCosigners)
+		}
 	}
 
 	netprv, err := config.nodeKey()
@@ -421,7 +471,7 @@ func New(config *Config) (*Krypton, error) {
 	}
 	protocols := append([]p2p.Protocol{}, kr.protocolManager.SubProtocols...)
 	if config.Shh {
-		protocols = append(protocols, kr.whisper.Protocol())
+		protocols = append(protocols, kr.whisper.Protocols()...)
 	}
 	kr.net = &p2p.Server{
 		PrivateKey:      netprv,
@@ -433,6 +483,7 @@ func New(config *Config) (*Krypton, error) {
 		NAT:             config.NAT,
 		NoDial:          !config.Dial,
 		BootstrapNodes:  config.parseBootNodes(),
+		DNSDiscovery:    strings.Fields(config.DNSDiscovery),
 		StaticNodes:     config.parseNodes(staticNodes),
 		TrustedNodes:    config.parseNodes(trustedNodes),
 		NodeDatabase:    nodeDb,
@@ -440,6 +491,10 @@ func New(config *Config) (*Krypton, error) {
 	if len(config.Port) > 0 {
 		kr.net.ListenAddr = ":" + config.Port
 	}
+	if config.Shh {
+		// Whisper peers are rare, search for them specifically
+		kr.net.DiscoveryTopics = []string{kr.whisper.Protocols()[0].Name}
+	}
 
 	vm.Debug = config.VmDebug
 
@@ -484,6 +539,8 @@ func (s *Krypton) AccountManager() *accounts.Manager  { return s.accountManager
 func (s *Krypton) BlockChain() *core.BlockChain       { return s.blockchain }
 func (s *Krypton) TxPool() *core.TxPool               { return s.txPool }
 func (s *Krypton) Whisper() *whisper.Whisper          { return s.whisper }
+func (s *Krypton) Multisig() *multisig.Coordinator    { return s.multisig }
+func (s *Krypton) GasPrices() *PercentileOracle       { return s.gasPrices }
 func (s *Krypton) EventMux() *event.TypeMux           { return s.eventMux }
 func (s *Krypton) ChainDb() krdb.Database            { return s.chainDb }
 func (s *Krypton) DappDb() krdb.Database             { return s.dappDb }
@@ -551,15 +608,37 @@ func (s *Krypton) Stop() {
 	s.txPool.Stop()
 	s.eventMux.Stop()
 	if s.whisper != nil {
+		s.multisig.Stop()
 		s.whisper.Stop()
 	}
 	s.StopAutoDAG()
 
+	s.accountManager.Close()
+	if s.shhKeyStore != nil {
+		s.shhKeyStore.Close()
+	}
 	s.chainDb.Close()
 	s.dappDb.Close()
+	if s.mailDb != nil {
+		s.mailDb.Close()
+	}
 	close(s.shutdownChan)
 }
 
+// submitMultisig sends the transaction of a multisig proposal that met its
+// threshold, signed by the proposer.
+func (s *Krypton) submitMultisig(p *multisig.Proposal, sigs [][]byte) (common.Hash, error) {
+	nonce := s.txPool.State().GetNonce(p.Proposer)
+	tx, err := s.accountManager.SignTx(accounts.Account{Address: p.Proposer}, p.Transaction(nonce, sigs))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := s.txPool.Add(tx); err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
+
 // This function will wait for a shutdown and resumes main thread execution
 func (s *Krypton) WaitForShutdown() {
 	<-s.shutdownChan
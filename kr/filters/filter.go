@@ -36,6 +36,7 @@ type Filter struct {
 	begin, end int64
 	addresses  []common.Address
 	topics     [][]common.Hash
+	quit       <-chan struct{}
 
 	BlockCallback       func(*types.Block, vm.Logs)
 	TransactionCallback func(*types.Transaction)
@@ -67,6 +68,22 @@ func (self *Filter) SetTopics(topics [][]common.Hash) {
 	self.topics = topics
 }
 
+// SetQuit sets a channel which aborts a running Find when closed. The logs found
+// up to that point are returned.
+func (self *Filter) SetQuit(quit <-chan struct{}) {
+	self.quit = quit
+}
+
+// aborted reports whether the filter's quit channel has been closed.
+func (self *Filter) aborted() bool {
+	select {
+	case <-self.quit:
+		return true
+	default:
+		return false
+	}
+}
+
 // Run filters logs with the current parameters set
 func (self *Filter) Find() vm.Logs {
 	latestBlock := core.GetBlock(self.db, core.GetHeadBlockHash(self.db))
@@ -92,7 +109,7 @@ func (self *Filter) mipFind(start, end uint64, depth int) (logs vm.Logs) {
 	level := core.MIPMapLevels[depth]
 	// normalise numerator so we can work in level specific batches and
 	// work with the proper range checks
-	for num := start / level * level; num <= end; num += level {
+	for num := start / level * level; num <= end && !self.aborted(); num += level {
 		// find addresses in bloom filters
 		bloom := core.GetMipmapBloom(self.db, num, level)
 		for _, addr := range self.addresses {
@@ -121,7 +138,7 @@ func (self *Filter) mipFind(start, end uint64, depth int) (logs vm.Logs) {
 func (self *Filter) getLogs(start, end uint64) (logs vm.Logs) {
 	var block *types.Block
 
-	for i := start; i <= end; i++ {
+	for i := start; i <= end && !self.aborted(); i++ {
 		hash := core.GetCanonicalHash(self.db, i)
 		if hash != (common.Hash{}) {
 			block = core.GetBlock(self.db, hash)
@@ -0,0 +1,190 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package kr
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/core/types"
+	"github.com/krypton/go-krypton/logger"
+	"github.com/krypton/go-krypton/logger/glog"
+)
+
+// Names of the gas price oracles selectable with GpoOracle.
+const (
+	GpoHeuristic  = "heuristic"
+	GpoPercentile = "percentile"
+)
+
+const (
+	gpoDefaultBlocks     = 20
+	gpoDefaultSamples    = 3
+	gpoDefaultPercentile = 60
+)
+
+// GasPricer suggests gas prices for new transactions.
+type GasPricer interface {
+	SuggestPrice() *big.Int
+}
+
+// NewGasPricer returns the gas price oracle selected by the GpoOracle setting
+// of the node, defaulting to the heuristic one.
+func NewGasPricer(kr *Krypton) GasPricer {
+	if kr.GpoOracle == GpoPercentile {
+		return kr.gasPrices
+	}
+	return NewGasPriceOracle(kr)
+}
+
+// GasPriceDistribution holds the gas prices sampled from recent blocks.
+type GasPriceDistribution struct {
+	Head   common.Hash // Block the samples were taken at
+	Number uint64      // Number of the head block
+	Blocks int         // Number of blocks sampled
+	Prices []*big.Int  // Sampled prices in ascending order
+}
+
+// Percentile returns the price below which the given percentage of samples
+// lie, or nil if there are no samples.
+func (d *GasPriceDistribution) Percentile(p int) *big.Int {
+	if len(d.Prices) == 0 {
+		return nil
+	}
+	if p < 0 {
+		p = 0
+	} else if p > 100 {
+		p = 100
+	}
+	return new(big.Int).Set(d.Prices[(len(d.Prices)-1)*p/100])
+}
+
+// PercentileOracle recommends gas prices by sampling the cheapest transactions
+// included in each of the recent blocks, and suggesting a percentile of the
+// samples. Transactions of a block's miner are not sampled, as the miner is
+// free to include them at any price. The distribution is recomputed only when
+// the head of the chain changes.
+type PercentileOracle struct {
+	kr         *Krypton
+	blocks     int
+	samples    int
+	percentile int
+
+	lock      sync.Mutex
+	dist      *GasPriceDistribution // distribution at the last seen head
+	lastPrice *big.Int              // last suggestion with samples available
+}
+
+// NewPercentileOracle returns a new percentile based oracle.
+func NewPercentileOracle(kr *Krypton) *PercentileOracle {
+	self := &PercentileOracle{
+		kr:         kr,
+		blocks:     kr.GpoBlocks,
+		samples:    kr.GpoSamples,
+		percentile: kr.GpoPercentile,
+	}
+	if self.blocks <= 0 {
+		self.blocks = gpoDefaultBlocks
+	}
+	if self.samples <= 0 {
+		self.samples = gpoDefaultSamples
+	}
+	if self.percentile <= 0 || self.percentile > 100 {
+		self.percentile = gpoDefaultPercentile
+	}
+	return self
+}
+
+// Distribution returns the prices sampled from the blocks preceding and
+// including the current head.
+func (self *PercentileOracle) Distribution() *GasPriceDistribution {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	return self.distribution()
+}
+
+// distribution returns the cached distribution if the head didn't change,
+// or samples the chain again. The caller must hold the lock.
+func (self *PercentileOracle) distribution() *GasPriceDistribution {
+	chain := self.kr.BlockChain()
+	head := chain.CurrentBlock()
+	if self.dist != nil && self.dist.Head == head.Hash() {
+		return self.dist
+	}
+	dist := &GasPriceDistribution{Head: head.Hash(), Number: head.NumberU64()}
+	for block := head; block != nil && dist.Blocks < self.blocks; block = chain.GetBlock(block.ParentHash()) {
+		dist.Prices = append(dist.Prices, sampleGasPrices(block, self.samples)...)
+		dist.Blocks++
+	}
+	sort.Sort(bigIntSlice(dist.Prices))
+	self.dist = dist
+
+	glog.V(logger.Detail).Infof("Sampled %d gas prices from %d blocks at #%d", len(dist.Prices), dist.Blocks, dist.Number)
+	return dist
+}
+
+// SuggestPrice returns the configured percentile of the sampled prices, capped
+// by the minimum and maximum gas price of the node. The last suggestion is
+// kept while recent blocks contain no samples.
+func (self *PercentileOracle) SuggestPrice() *big.Int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if price := self.distribution().Percentile(self.percentile); price != nil {
+		self.lastPrice = price
+	}
+	price := self.kr.GpoMinGasPrice
+	if self.lastPrice != nil {
+		price = self.lastPrice
+	}
+	if price == nil {
+		price = big.NewInt(gpoDefaultMinGasPrice)
+	}
+	price = new(big.Int).Set(price)
+	if self.kr.GpoMinGasPrice != nil && price.Cmp(self.kr.GpoMinGasPrice) < 0 {
+		price.Set(self.kr.GpoMinGasPrice)
+	} else if self.kr.GpoMaxGasPrice != nil && price.Cmp(self.kr.GpoMaxGasPrice) > 0 {
+		price.Set(self.kr.GpoMaxGasPrice)
+	}
+	return price
+}
+
+// sampleGasPrices returns the n lowest gas prices of the transactions in the
+// block that were not sent by its miner.
+func sampleGasPrices(block *types.Block, n int) []*big.Int {
+	var prices []*big.Int
+	for _, tx := range block.Transactions() {
+		if from, err := tx.From(); err != nil || from == block.Coinbase() {
+			continue
+		}
+		prices = append(prices, tx.GasPrice())
+	}
+	sort.Sort(bigIntSlice(prices))
+	if len(prices) > n {
+		prices = prices[:n]
+	}
+	return prices
+}
+
+type bigIntSlice []*big.Int
+
+func (s bigIntSlice) Len() int           { return len(s) }
+func (s bigIntSlice) Less(i, j int) bool { return s[i].Cmp(s[j]) < 0 }
+func (s bigIntSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,120 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package kr
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/core"
+	"github.com/krypton/go-krypton/core/types"
+	"github.com/krypton/go-krypton/crypto"
+	"github.com/krypton/go-krypton/event"
+	"github.com/krypton/go-krypton/krdb"
+	"github.com/krypton/go-krypton/params"
+)
+
+var (
+	testMinerKey, _  = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+	testMinerAddress = crypto.PubkeyToAddress(testMinerKey.PublicKey)
+)
+
+// newTestGasPriceChain creates a chain whose block i includes transactions
+// paying 100*i+1 to 100*i+4 wei per gas, and a free one by the miner.
+func newTestGasPriceChain(t *testing.T, blocks int) (*core.BlockChain, func(int)) {
+	var (
+		funds = common.Krypton
+		db, _ = krdb.NewMemDatabase()
+	)
+	core.WriteGenesisBlockForTesting(db, core.GenesisAccount{Address: testBankAddress, Balance: funds}, core.GenesisAccount{Address: testMinerAddress, Balance: funds})
+	blockchain, _ := core.NewBlockChain(db, new(core.FakePow), new(event.TypeMux))
+
+	extend := func(n int) {
+		head := blockchain.CurrentBlock()
+		chain, _ := core.GenerateChain(head, db, n, func(i int, gen *core.BlockGen) {
+			gen.SetCoinbase(testMinerAddress)
+			number := int64(head.NumberU64()) + int64(i) + 1
+			for _, price := range []int64{4, 2, 3, 1} {
+				tx, _ := types.NewTransaction(gen.TxNonce(testBankAddress), testMinerAddress, new(big.Int), params.TxGas, big.NewInt(100*number+price), nil).SignECDSA(testBankKey)
+				gen.AddTx(tx)
+			}
+			tx, _ := types.NewTransaction(gen.TxNonce(testMinerAddress), testBankAddress, new(big.Int), params.TxGas, new(big.Int), nil).SignECDSA(testMinerKey)
+			gen.AddTx(tx)
+		})
+		if _, err := blockchain.InsertChain(chain); err != nil {
+			t.Fatalf("failed to insert chain: %v", err)
+		}
+	}
+	extend(blocks)
+	return blockchain, extend
+}
+
+func TestPercentileOracle(t *testing.T) {
+	blockchain, extend := newTestGasPriceChain(t, 5)
+	oracle := NewPercentileOracle(&Krypton{
+		blockchain:    blockchain,
+		GpoBlocks:     3,
+		GpoSamples:    2,
+		GpoPercentile: 50,
+	})
+	// The two cheapest non-miner prices of blocks 3 to 5 are sampled
+	dist := oracle.Distribution()
+	if dist.Number != 5 || dist.Blocks != 3 {
+		t.Fatalf("sampled range mismatch: have head #%d and %d blocks, want #5 and 3", dist.Number, dist.Blocks)
+	}
+	want := []int64{301, 302, 401, 402, 501, 502}
+	if len(dist.Prices) != len(want) {
+		t.Fatalf("sample count mismatch: have %v, want %v", dist.Prices, want)
+	}
+	for i, price := range dist.Prices {
+		if price.Int64() != want[i] {
+			t.Errorf("sample %d mismatch: have %v, want %d", i, price, want[i])
+		}
+	}
+	if price := oracle.SuggestPrice(); price.Int64() != 401 {
+		t.Errorf("suggested price mismatch: have %v, want 401", price)
+	}
+	// The distribution is cached until the head changes
+	if oracle.Distribution() != dist {
+		t.Errorf("distribution recomputed at the same head")
+	}
+	extend(1)
+	if price := oracle.SuggestPrice(); price.Int64() != 501 {
+		t.Errorf("suggested price mismatch after new head: have %v, want 501", price)
+	}
+}
+
+func TestPercentileOracleLimits(t *testing.T) {
+	blockchain, _ := newTestGasPriceChain(t, 2)
+	oracle := NewPercentileOracle(&Krypton{
+		blockchain:     blockchain,
+		GpoMinGasPrice: big.NewInt(1000),
+		GpoPercentile:  100,
+	})
+	// All blocks are sampled, including the empty genesis
+	if dist := oracle.Distribution(); dist.Blocks != 3 || len(dist.Prices) != 6 {
+		t.Errorf("sampled range mismatch: have %d prices from %d blocks, want 6 from 3", len(dist.Prices), dist.Blocks)
+	}
+	if price := oracle.SuggestPrice(); price.Int64() != 1000 {
+		t.Errorf("suggested price mismatch: have %v, want minimum 1000", price)
+	}
+	oracle.kr.GpoMinGasPrice, oracle.kr.GpoMaxGasPrice = nil, big.NewInt(200)
+	if price := oracle.SuggestPrice(); price.Int64() != 200 {
+		t.Errorf("suggested price mismatch: have %v, want maximum 200", price)
+	}
+}
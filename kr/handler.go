@@ -669,6 +669,8 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			}
 			p.MarkTransaction(tx.Hash())
 		}
+		// Recover the senders concurrently before the pool validates them one by one
+		core.RecoverSenders(txs)
 		pm.txpool.AddTransactions(txs)
 
 	default:
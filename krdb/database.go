@@ -30,6 +30,7 @@ import (
 	"github.com/syndtr/goleveldb/leveldb/errors"
 	"github.com/syndtr/goleveldb/leveldb/iterator"
 	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
 
 	gometrics "github.com/rcrowley/go-metrics"
 )
@@ -140,6 +141,10 @@ func (self *LDBDatabase) NewIterator() iterator.Iterator {
 	return self.db.NewIterator(nil, nil)
 }
 
+func (self *LDBDatabase) NewIteratorWithPrefix(prefix []byte) iterator.Iterator {
+	return self.db.NewIterator(util.BytesPrefix(prefix), nil)
+}
+
 func (self *LDBDatabase) Close() {
 	// Stop the metrics collection to avoid internal database races
 	self.quitLock.Lock()
@@ -16,12 +16,18 @@
 
 package krdb
 
+import "github.com/syndtr/goleveldb/leveldb/iterator"
+
 type Database interface {
 	Put(key []byte, value []byte) error
 	Get(key []byte) ([]byte, error)
 	Delete(key []byte) error
 	Close()
 	NewBatch() Batch
+
+	// NewIteratorWithPrefix iterates in key order over the entries whose keys
+	// start with the given prefix.
+	NewIteratorWithPrefix(prefix []byte) iterator.Iterator
 }
 
 type Batch interface {
@@ -19,9 +19,13 @@ package krdb
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/krypton/go-krypton/common"
+	"github.com/syndtr/goleveldb/leveldb/comparer"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/memdb"
 )
 
 /*
@@ -82,6 +86,21 @@ func (db *MemDatabase) GetKeys() []*common.Key {
 }
 */
 
+// NewIteratorWithPrefix iterates over a snapshot of the entries whose keys start
+// with the given prefix.
+func (db *MemDatabase) NewIteratorWithPrefix(prefix []byte) iterator.Iterator {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	snapshot := memdb.New(comparer.DefaultComparer, 0)
+	for key, val := range db.db {
+		if strings.HasPrefix(key, string(prefix)) {
+			snapshot.Put([]byte(key), val)
+		}
+	}
+	return snapshot.NewIterator(nil)
+}
+
 func (db *MemDatabase) Delete(key []byte) error {
 	db.lock.Lock()
 	defer db.lock.Unlock()
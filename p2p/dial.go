@@ -36,6 +36,14 @@ const (
 	// Discovery lookups are throttled and can only run
 	// once every few seconds.
 	lookupInterval = 4 * time.Second
+
+	// DNS node trees are synced rarely, as they change slowly.
+	// Failed syncs are retried sooner.
+	dnsSyncInterval  = 30 * time.Minute
+	dnsRetryInterval = 1 * time.Minute
+
+	// Maximum number of nodes returned by a topic lookup.
+	topicLookupResults = 16
 )
 
 // dialstate schedules dials and discovery lookups.
@@ -53,6 +61,14 @@ type dialstate struct {
 	randomNodes []*discover.Node // filled from Table
 	static      map[discover.NodeID]*discover.Node
 	hist        *dialHistory
+
+	dnsTrees   []string         // URLs of the DNS node trees to dial nodes from
+	dnsRunning bool             // whether a DNS tree sync is in progress
+	dnsNext    time.Time        // time of the next DNS tree sync
+	dnsNodes   []*discover.Node // nodes of the last DNS tree sync
+
+	topics  []discover.Topic // topics searched for dial candidates
+	lookups int              // number of discovery lookups launched
 }
 
 type discoverTable interface {
@@ -61,6 +77,8 @@ type discoverTable interface {
 	Bootstrap([]*discover.Node)
 	Lookup(target discover.NodeID) []*discover.Node
 	ReadRandomNodes([]*discover.Node) int
+	RegisterTopic(topic discover.Topic, stop <-chan struct{})
+	LookupTopic(topic discover.Topic, max int) []*discover.Node
 }
 
 // the dial history remembers recent dials.
@@ -86,13 +104,23 @@ type dialTask struct {
 // Only one discoverTask is active at any time.
 //
 // If bootstrap is true, the task runs Table.Bootstrap,
-// otherwise it performs a random lookup and leaves the
+// otherwise it performs a random lookup (or a lookup of
+// the nodes advertising topic, if set) and leaves the
 // results in the task.
 type discoverTask struct {
 	bootstrap bool
+	topic     discover.Topic
 	results   []*discover.Node
 }
 
+// dnsTask syncs the DNS node trees, leaving the nodes
+// of all successfully synced trees in the task.
+type dnsTask struct {
+	urls    []string
+	results []*discover.Node
+	synced  bool
+}
+
 // A waitExpireTask is generated if there are no other tasks
 // to keep the loop in Server.run ticking.
 type waitExpireTask struct {
@@ -162,6 +190,17 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 			}
 		}
 	}
+	// Create dynamic dials from the nodes of the DNS trees, syncing
+	// them again once they're stale.
+	for i := 0; i < len(s.dnsNodes) && needDynDials > 0; i++ {
+		if addDial(dynDialedConn, s.dnsNodes[i]) {
+			needDynDials--
+		}
+	}
+	if len(s.dnsTrees) > 0 && !s.dnsRunning && !now.Before(s.dnsNext) {
+		s.dnsRunning = true
+		newtasks = append(newtasks, &dnsTask{urls: s.dnsTrees})
+	}
 	// Create dynamic dials from random lookup results, removing tried
 	// items from the result buffer.
 	i := 0
@@ -174,22 +213,45 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 	// Launch a discovery lookup if more candidates are needed. The
 	// first discoverTask bootstraps the table and won't return any
 	// results.
-	if len(s.lookupBuf) < needDynDials && !s.lookupRunning {
+	if s.ntab != nil && len(s.lookupBuf) < needDynDials && !s.lookupRunning {
 		s.lookupRunning = true
-		newtasks = append(newtasks, &discoverTask{bootstrap: !s.bootstrapped})
+		newtasks = append(newtasks, s.newDiscoverTask())
 	}
 
 	// Launch a timer to wait for the next node to expire if all
 	// candidates have been tried and no task is currently active.
 	// This should prevent cases where the dialer logic is not ticked
 	// because there are no pending events.
-	if nRunning == 0 && len(newtasks) == 0 && s.hist.Len() > 0 {
-		t := &waitExpireTask{s.hist.min().exp.Sub(now)}
-		newtasks = append(newtasks, t)
+	if nRunning == 0 && len(newtasks) == 0 {
+		var wait time.Duration
+		if s.hist.Len() > 0 {
+			wait = s.hist.min().exp.Sub(now)
+		}
+		if len(s.dnsTrees) > 0 && !s.dnsRunning && (wait == 0 || s.dnsNext.Sub(now) < wait) {
+			wait = s.dnsNext.Sub(now)
+		}
+		if wait != 0 {
+			newtasks = append(newtasks, &waitExpireTask{wait})
+		}
 	}
 	return newtasks
 }
 
+// newDiscoverTask creates the next discovery task. If topics are
+// configured, every other lookup searches for nodes advertising
+// one of them, cycling through the topics.
+func (s *dialstate) newDiscoverTask() *discoverTask {
+	if !s.bootstrapped {
+		return &discoverTask{bootstrap: true}
+	}
+	t := new(discoverTask)
+	if len(s.topics) > 0 && s.lookups%2 == 1 {
+		t.topic = s.topics[(s.lookups/2)%len(s.topics)]
+	}
+	s.lookups++
+	return t
+}
+
 func (s *dialstate) taskDone(t task, now time.Time) {
 	switch t := t.(type) {
 	case *dialTask:
@@ -201,6 +263,14 @@ func (s *dialstate) taskDone(t task, now time.Time) {
 		}
 		s.lookupRunning = false
 		s.lookupBuf = append(s.lookupBuf, t.results...)
+	case *dnsTask:
+		s.dnsRunning = false
+		if t.synced {
+			s.dnsNodes = t.results
+			s.dnsNext = now.Add(dnsSyncInterval)
+		} else {
+			s.dnsNext = now.Add(dnsRetryInterval)
+		}
 	}
 }
 
@@ -233,6 +303,10 @@ func (t *discoverTask) Do(srv *Server) {
 		time.Sleep(next.Sub(now))
 	}
 	srv.lastLookup = time.Now()
+	if t.topic != "" {
+		t.results = srv.ntab.LookupTopic(t.topic, topicLookupResults)
+		return
+	}
 	var target discover.NodeID
 	rand.Read(target[:])
 	t.results = srv.ntab.Lookup(target)
@@ -241,6 +315,8 @@ func (t *discoverTask) Do(srv *Server) {
 func (t *discoverTask) String() (s string) {
 	if t.bootstrap {
 		s = "discovery bootstrap"
+	} else if t.topic != "" {
+		s = fmt.Sprintf("discovery lookup of topic %q", t.topic)
 	} else {
 		s = "discovery lookup"
 	}
@@ -250,6 +326,22 @@ func (t *discoverTask) String() (s string) {
 	return s
 }
 
+func (t *dnsTask) Do(srv *Server) {
+	for _, url := range t.urls {
+		tree, err := srv.dnsClient.SyncTree(url)
+		if err != nil {
+			glog.V(logger.Debug).Infof("DNS node tree sync failed: %v", err)
+			continue
+		}
+		t.results = append(t.results, tree.Nodes()...)
+		t.synced = true
+	}
+}
+
+func (t *dnsTask) String() string {
+	return fmt.Sprintf("DNS tree sync (%d results)", len(t.results))
+}
+
 func (t waitExpireTask) Do(*Server) {
 	time.Sleep(t.Duration)
 }
@@ -85,6 +85,30 @@ func (t fakeTable) Lookup(target discover.NodeID) []*discover.Node {
 func (t fakeTable) ReadRandomNodes(buf []*discover.Node) int {
 	return copy(buf, t)
 }
+func (t fakeTable) RegisterTopic(topic discover.Topic, stop <-chan struct{}) {}
+func (t fakeTable) LookupTopic(topic discover.Topic, max int) []*discover.Node {
+	return nil
+}
+
+// This test checks that lookups alternate between random and topic lookups.
+func TestDialStateTopicLookups(t *testing.T) {
+	s := newDialState(nil, fakeTable{}, 5)
+	s.topics = []discover.Topic{"shh", "bzz"}
+
+	want := []*discoverTask{
+		{bootstrap: true},
+		{}, {topic: "shh"},
+		{}, {topic: "bzz"},
+		{}, {topic: "shh"},
+	}
+	for i, wt := range want {
+		task := s.newDiscoverTask()
+		if !reflect.DeepEqual(task, wt) {
+			t.Errorf("lookup %d: task mismatch: got %v, want %v", i, task, wt)
+		}
+		s.taskDone(task, time.Now())
+	}
+}
 
 // This test checks that dynamic dials are launched from discovery results.
 func TestDialStateDynDial(t *testing.T) {
@@ -324,6 +348,60 @@ func TestDialStateDynDialFromTable(t *testing.T) {
 }
 
 // This test checks that static dials are launched.
+// This test checks that dynamic dials are launched from the nodes of DNS trees.
+func TestDialStateDNSDial(t *testing.T) {
+	trees := []string{"enodetree://key@nodes.example.org"}
+	state := newDialState(nil, nil, 2)
+	state.dnsTrees = trees
+
+	runDialTest(t, dialtest{
+		init: state,
+		rounds: []round{
+			// A DNS tree sync is launched, but no discovery lookup.
+			{
+				new: []task{&dnsTask{urls: trees}},
+			},
+			// Dynamic dials are launched from the synced nodes.
+			{
+				done: []task{
+					&dnsTask{urls: trees, synced: true, results: []*discover.Node{
+						{ID: uintID(1)},
+						{ID: uintID(2)},
+						{ID: uintID(3)}, // not tried because max dyn dials is 2
+					}},
+				},
+				new: []task{
+					&dialTask{dynDialedConn, &discover.Node{ID: uintID(1)}},
+					&dialTask{dynDialedConn, &discover.Node{ID: uintID(2)}},
+				},
+			},
+			// All dials complete, the dial history expiry is awaited.
+			{
+				peers: []*Peer{
+					{rw: &conn{flags: dynDialedConn, id: uintID(1)}},
+					{rw: &conn{flags: dynDialedConn, id: uintID(2)}},
+				},
+				done: []task{
+					&dialTask{dynDialedConn, &discover.Node{ID: uintID(1)}},
+					&dialTask{dynDialedConn, &discover.Node{ID: uintID(2)}},
+				},
+				new: []task{
+					&waitExpireTask{Duration: 30 * time.Second},
+				},
+			},
+			// The peers disconnect, the remaining synced node is dialed.
+			{
+				done: []task{
+					&waitExpireTask{Duration: 30 * time.Second},
+				},
+				new: []task{
+					&dialTask{dynDialedConn, &discover.Node{ID: uintID(3)}},
+				},
+			},
+		},
+	})
+}
+
 func TestDialStateStaticDial(t *testing.T) {
 	wantStatic := []*discover.Node{
 		{ID: uintID(1)},
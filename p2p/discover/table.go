@@ -83,6 +83,8 @@ type transport interface {
 	ping(NodeID, *net.UDPAddr) error
 	waitping(NodeID) error
 	findnode(toid NodeID, addr *net.UDPAddr, target NodeID) ([]*Node, error)
+	registerTopics(toid NodeID, addr *net.UDPAddr, topics []Topic) error
+	queryTopic(toid NodeID, addr *net.UDPAddr, topic Topic) ([]*Node, error)
 	close()
 }
 
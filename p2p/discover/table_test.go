@@ -159,6 +159,12 @@ func newPingRecorder() *pingRecorder {
 func (t *pingRecorder) findnode(toid NodeID, toaddr *net.UDPAddr, target NodeID) ([]*Node, error) {
 	panic("findnode called on pingRecorder")
 }
+func (t *pingRecorder) registerTopics(toid NodeID, toaddr *net.UDPAddr, topics []Topic) error {
+	panic("registerTopics called on pingRecorder")
+}
+func (t *pingRecorder) queryTopic(toid NodeID, toaddr *net.UDPAddr, topic Topic) ([]*Node, error) {
+	panic("queryTopic called on pingRecorder")
+}
 func (t *pingRecorder) close() {}
 func (t *pingRecorder) waitping(from NodeID) error {
 	return nil // remote always pings
@@ -522,6 +528,12 @@ func (tn *preminedTestnet) findnode(toid NodeID, toaddr *net.UDPAddr, target Nod
 	return result, nil
 }
 
+func (*preminedTestnet) registerTopics(toid NodeID, toaddr *net.UDPAddr, topics []Topic) error {
+	return nil
+}
+func (*preminedTestnet) queryTopic(toid NodeID, toaddr *net.UDPAddr, topic Topic) ([]*Node, error) {
+	return nil, nil
+}
 func (*preminedTestnet) close()                                      {}
 func (*preminedTestnet) waitping(from NodeID) error                  { return nil }
 func (*preminedTestnet) ping(toid NodeID, toaddr *net.UDPAddr) error { return nil }
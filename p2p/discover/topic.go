@@ -0,0 +1,259 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the topic extension of the discovery protocol, which lets nodes
+// advertise the protocols they run and search for nodes running a protocol.
+//
+// Nodes register themselves for a topic at the nodes closest to the hash of
+// the topic, which store the registration for a limited time. Searching nodes
+// perform a lookup of the same target and query the closest nodes for their
+// registrations.
+
+package discover
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/krypton/go-krypton/crypto"
+)
+
+const (
+	maxTopicLength     = 32  // Maximum length of a topic in bytes
+	maxTopicsPerPacket = 8   // Maximum number of topics registered by a single packet
+	maxTopics          = 512 // Maximum number of distinct topics stored
+	maxTopicEntries    = 128 // Maximum number of nodes stored for a single topic
+	maxRegsPerNode     = 16  // Maximum number of topics a single node may be registered for
+
+	topicRegTTL         = 15 * time.Minute // Lifetime of a topic registration
+	topicRegInterval    = 10 * time.Minute // Interval at which our own registrations are renewed
+	minTopicRegInterval = 1 * time.Minute  // Minimum time before a registration may be renewed
+	topicExpireInterval = 1 * time.Minute  // Interval at which expired registrations are dropped
+	topicRegRetryDelay  = 1 * time.Second  // Initial delay before retrying a failed registration
+)
+
+var (
+	errInvalidTopic  = errors.New("invalid topic")
+	errTooManyTopics = errors.New("too many topics")
+	errRegRateLimit  = errors.New("topic registration rate limit exceeded")
+)
+
+// Topic identifies a capability nodes can register for, usually the name of a
+// protocol (e.g. "shh").
+type Topic string
+
+// valid checks whether the topic can be registered and queried.
+func (t Topic) valid() bool {
+	return len(t) > 0 && len(t) <= maxTopicLength
+}
+
+// target returns the lookup target of the topic. Registrations for the topic
+// are stored by the nodes closest to it.
+func (t Topic) target() NodeID {
+	var target NodeID
+	copy(target[:], crypto.Sha3([]byte(t)))
+	return target
+}
+
+// topicEntry is a single registration of a node for a topic.
+type topicEntry struct {
+	node    *Node
+	renewed time.Time // Time of the last registration, used for rate limiting
+	expires time.Time
+}
+
+// topicTable stores the topic registrations of remote nodes.
+type topicTable struct {
+	lock    sync.Mutex
+	topics  map[Topic]map[NodeID]*topicEntry
+	regs    map[NodeID]int // Number of topics each node is registered for
+	expired time.Time      // Time of the last expiration run
+}
+
+func newTopicTable() *topicTable {
+	return &topicTable{
+		topics: make(map[Topic]map[NodeID]*topicEntry),
+		regs:   make(map[NodeID]int),
+	}
+}
+
+// register stores the registration of a node for the given topics. Topics
+// exceeding the storage limits are silently ignored, renewals coming in faster
+// than minTopicRegInterval are rejected.
+func (self *topicTable) register(node *Node, topics []Topic, now time.Time) error {
+	if len(topics) == 0 || len(topics) > maxTopicsPerPacket {
+		return errTooManyTopics
+	}
+	for _, topic := range topics {
+		if !topic.valid() {
+			return errInvalidTopic
+		}
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.expire(now)
+
+	limited := 0
+	for _, topic := range topics {
+		entries := self.topics[topic]
+		if entry, ok := entries[node.ID]; ok {
+			// Known registration, renew it unless it's too early
+			if now.Sub(entry.renewed) < minTopicRegInterval {
+				limited++
+				continue
+			}
+			entry.node, entry.renewed, entry.expires = node, now, now.Add(topicRegTTL)
+			continue
+		}
+		// New registration, store it if within the limits
+		if self.regs[node.ID] >= maxRegsPerNode || len(entries) >= maxTopicEntries {
+			continue
+		}
+		if entries == nil {
+			if len(self.topics) >= maxTopics {
+				continue
+			}
+			entries = make(map[NodeID]*topicEntry)
+			self.topics[topic] = entries
+		}
+		entries[node.ID] = &topicEntry{node: node, renewed: now, expires: now.Add(topicRegTTL)}
+		self.regs[node.ID]++
+	}
+	if limited == len(topics) {
+		return errRegRateLimit
+	}
+	return nil
+}
+
+// query returns at most max randomly chosen nodes registered for a topic.
+func (self *topicTable) query(topic Topic, max int, now time.Time) []*Node {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.expire(now)
+
+	nodes := make([]*Node, 0, len(self.topics[topic]))
+	for _, entry := range self.topics[topic] {
+		if now.Before(entry.expires) {
+			nodes = append(nodes, entry.node)
+		}
+	}
+	// Shuffle the registrations so that all of them get returned eventually
+	for i := len(nodes) - 1; i > 0; i-- {
+		j := randUint(uint32(i + 1))
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+	if len(nodes) > max {
+		nodes = nodes[:max]
+	}
+	return nodes
+}
+
+// expire drops all the expired registrations, at most once per expiration
+// interval. The caller must hold the lock.
+func (self *topicTable) expire(now time.Time) {
+	if now.Sub(self.expired) < topicExpireInterval {
+		return
+	}
+	self.expired = now
+
+	for topic, entries := range self.topics {
+		for id, entry := range entries {
+			if !now.Before(entry.expires) {
+				delete(entries, id)
+				if self.regs[id]--; self.regs[id] == 0 {
+					delete(self.regs, id)
+				}
+			}
+		}
+		if len(entries) == 0 {
+			delete(self.topics, topic)
+		}
+	}
+}
+
+// RegisterTopic advertises the local node under the given topic. The
+// registration is renewed periodically until stop is closed or the table
+// is shut down. Registration rounds that reach no node (e.g. before the
+// table is bootstrapped) are retried with an exponential backoff capped at
+// the minimum renewal interval.
+func (tab *Table) RegisterTopic(topic Topic, stop <-chan struct{}) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	retry := topicRegRetryDelay
+	for {
+		select {
+		case <-timer.C:
+			registered := false
+			for _, n := range tab.Lookup(topic.target()) {
+				if tab.net.registerTopics(n.ID, n.addr(), []Topic{topic}) == nil {
+					registered = true
+				}
+			}
+			if registered {
+				retry = topicRegRetryDelay
+				timer.Reset(topicRegInterval)
+				continue
+			}
+			timer.Reset(retry)
+			if retry *= 2; retry > minTopicRegInterval {
+				retry = minTopicRegInterval
+			}
+		case <-stop:
+			return
+		case <-tab.closed:
+			return
+		}
+	}
+}
+
+// LookupTopic searches the network for nodes registered under the given topic
+// and returns at most max of them.
+func (tab *Table) LookupTopic(topic Topic, max int) []*Node {
+	var (
+		registrars     = tab.Lookup(topic.target())
+		seen           = map[NodeID]bool{tab.self.ID: true}
+		reply          = make(chan []*Node, alpha)
+		pendingQueries = 0
+		result         []*Node
+	)
+	for i := 0; len(result) < max && (i < len(registrars) || pendingQueries > 0); {
+		// ask the next registrars, up to alpha at a time
+		for ; i < len(registrars) && pendingQueries < alpha; i++ {
+			n := registrars[i]
+			pendingQueries++
+			go func() {
+				nodes, _ := tab.net.queryTopic(n.ID, n.addr(), topic)
+				reply <- nodes
+			}()
+		}
+		// wait for the next reply
+		for _, n := range <-reply {
+			if !seen[n.ID] {
+				seen[n.ID] = true
+				result = append(result, n)
+			}
+		}
+		pendingQueries--
+	}
+	if len(result) > max {
+		result = result[:max]
+	}
+	return result
+}
@@ -0,0 +1,142 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/krypton/go-krypton/common"
+)
+
+func topicTestNode(i int) *Node {
+	return newNode(NodeID{byte(i >> 8), byte(i)}, net.IP{10, 0, byte(i >> 8), byte(i)}, 30303, 30303)
+}
+
+func TestTopicTableRegister(t *testing.T) {
+	var (
+		table = newTopicTable()
+		node  = topicTestNode(1)
+		now   = time.Now()
+	)
+	if err := table.register(node, []Topic{"shh"}, now); err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	// Renewals are rate limited, unless new topics are included
+	if err := table.register(node, []Topic{"shh"}, now.Add(time.Second)); err != errRegRateLimit {
+		t.Errorf("early renewal error mismatch: have %v, want %v", err, errRegRateLimit)
+	}
+	if err := table.register(node, []Topic{"shh", "kr"}, now.Add(time.Second)); err != nil {
+		t.Errorf("failed to register new topic: %v", err)
+	}
+	if err := table.register(node, []Topic{"shh"}, now.Add(minTopicRegInterval)); err != nil {
+		t.Errorf("failed to renew registration: %v", err)
+	}
+	// Registrations expire after their lifetime
+	if nodes := table.query("kr", 10, now.Add(topicRegTTL/2)); len(nodes) != 1 || nodes[0] != node {
+		t.Errorf("registration mismatch: have %v, want %v", nodes, node)
+	}
+	if nodes := table.query("kr", 10, now.Add(topicRegTTL+time.Second)); len(nodes) != 0 {
+		t.Errorf("expired registration returned: %v", nodes)
+	}
+	if nodes := table.query("shh", 10, now.Add(topicRegTTL+time.Second)); len(nodes) != 1 {
+		t.Errorf("renewed registration dropped")
+	}
+	if _, ok := table.topics["kr"]; ok {
+		t.Errorf("expired topic not dropped")
+	}
+	if table.regs[node.ID] != 1 {
+		t.Errorf("registration count mismatch: have %d, want 1", table.regs[node.ID])
+	}
+}
+
+func TestTopicTableLimits(t *testing.T) {
+	var (
+		table = newTopicTable()
+		now   = time.Now()
+	)
+	// Topics per packet and topic length are limited
+	if err := table.register(topicTestNode(1), make([]Topic, maxTopicsPerPacket+1), now); err != errTooManyTopics {
+		t.Errorf("oversized packet error mismatch: have %v, want %v", err, errTooManyTopics)
+	}
+	if err := table.register(topicTestNode(1), []Topic{Topic(make([]byte, maxTopicLength+1))}, now); err != errInvalidTopic {
+		t.Errorf("oversized topic error mismatch: have %v, want %v", err, errInvalidTopic)
+	}
+	// Topics per node are limited
+	for i := 0; i < maxRegsPerNode+maxTopicsPerPacket; i += maxTopicsPerPacket {
+		topics := make([]Topic, maxTopicsPerPacket)
+		for j := range topics {
+			topics[j] = Topic(fmt.Sprintf("topic-%d", i+j))
+		}
+		table.register(topicTestNode(1), topics, now)
+	}
+	if table.regs[topicTestNode(1).ID] != maxRegsPerNode {
+		t.Errorf("node registrations mismatch: have %d, want %d", table.regs[topicTestNode(1).ID], maxRegsPerNode)
+	}
+	// Nodes per topic are limited, queries return at most the requested nodes
+	for i := 0; i < maxTopicEntries+10; i++ {
+		table.register(topicTestNode(i+2), []Topic{"shh"}, now)
+	}
+	if len(table.topics["shh"]) != maxTopicEntries {
+		t.Errorf("topic registrations mismatch: have %d, want %d", len(table.topics["shh"]), maxTopicEntries)
+	}
+	if nodes := table.query("shh", maxNeighbors, now); len(nodes) != maxNeighbors {
+		t.Errorf("query result count mismatch: have %d, want %d", len(nodes), maxNeighbors)
+	}
+}
+
+// topicRegRecorder is a test transport reporting registration attempts.
+type topicRegRecorder struct {
+	*preminedTestnet
+	regs chan NodeID
+}
+
+func (t *topicRegRecorder) registerTopics(toid NodeID, toaddr *net.UDPAddr, topics []Topic) error {
+	select {
+	case t.regs <- toid:
+	default:
+	}
+	return nil
+}
+
+// Tests that registrations failing on an empty table are retried shortly
+// instead of waiting for the full renewal interval.
+func TestRegisterTopicRetry(t *testing.T) {
+	var (
+		transport = &topicRegRecorder{lookupTestnet, make(chan NodeID, 1)}
+		self      = nodeAtDistance(common.Hash{}, 0)
+		stop      = make(chan struct{})
+	)
+	tab := newTable(transport, self.ID, &net.UDPAddr{}, "")
+	defer tab.Close()
+	defer close(stop)
+
+	go tab.RegisterTopic("shh", stop)
+	time.Sleep(100 * time.Millisecond)
+
+	tab.mutex.Lock()
+	tab.stuff([]*Node{newNode(lookupTestnet.dists[256][0], net.IP{}, 256, 0)})
+	tab.mutex.Unlock()
+
+	select {
+	case <-transport.regs:
+	case <-time.After(5 * topicRegRetryDelay):
+		t.Fatalf("registration not retried after bootstrap")
+	}
+}
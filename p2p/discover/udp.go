@@ -59,6 +59,9 @@ const (
 	pongPacket
 	findnodePacket
 	neighborsPacket
+	topicRegisterPacket
+	topicQueryPacket
+	topicNodesPacket
 )
 
 // RPC request structures
@@ -92,6 +95,25 @@ type (
 		Expiration uint64
 	}
 
+	// topicRegister asks the recipient to store the sender's
+	// registration for the given topics.
+	topicRegister struct {
+		Topics     []Topic
+		Expiration uint64
+	}
+
+	// topicQuery is a query for nodes registered for a topic.
+	topicQuery struct {
+		Topic      Topic
+		Expiration uint64
+	}
+
+	// reply to topicQuery
+	topicNodes struct {
+		Nodes      []rpcNode
+		Expiration uint64
+	}
+
 	rpcNode struct {
 		IP  net.IP // len 4 for IPv4 or 16 for IPv6
 		UDP uint16 // for discovery protocol
@@ -150,6 +172,8 @@ type udp struct {
 	closing chan struct{}
 	nat     nat.Interface
 
+	topics *topicTable // registrations stored for remote nodes
+
 	*Table
 }
 
@@ -212,6 +236,7 @@ func newUDP(priv *ecdsa.PrivateKey, c conn, natm nat.Interface, nodeDBPath strin
 		closing:    make(chan struct{}),
 		gotreply:   make(chan reply),
 		addpending: make(chan *pending),
+		topics:     newTopicTable(),
 	}
 	realaddr := c.LocalAddr().(*net.UDPAddr)
 	if natm != nil {
@@ -277,6 +302,35 @@ func (t *udp) findnode(toid NodeID, toaddr *net.UDPAddr, target NodeID) ([]*Node
 	return nodes, err
 }
 
+// registerTopics asks the given node to store our registration for the
+// topics. Registrations are not acknowledged.
+func (t *udp) registerTopics(toid NodeID, toaddr *net.UDPAddr, topics []Topic) error {
+	return t.send(toaddr, topicRegisterPacket, topicRegister{
+		Topics:     topics,
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	})
+}
+
+// queryTopic sends a topicQuery request to the given node and waits
+// for the nodes it has stored registrations of.
+func (t *udp) queryTopic(toid NodeID, toaddr *net.UDPAddr, topic Topic) ([]*Node, error) {
+	var nodes []*Node
+	errc := t.pending(toid, topicNodesPacket, func(r interface{}) bool {
+		for _, rn := range r.(*topicNodes).Nodes {
+			if n, valid := nodeFromRPC(rn); valid {
+				nodes = append(nodes, n)
+			}
+		}
+		return true
+	})
+	t.send(toaddr, topicQueryPacket, topicQuery{
+		Topic:      topic,
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	})
+	err := <-errc
+	return nodes, err
+}
+
 // pending adds a reply callback to the pending reply queue.
 // see the documentation of type pending for a detailed explanation.
 func (t *udp) pending(id NodeID, ptype byte, callback func(interface{}) bool) <-chan error {
@@ -510,6 +564,12 @@ func decodePacket(buf []byte) (packet, NodeID, []byte, error) {
 		req = new(findnode)
 	case neighborsPacket:
 		req = new(neighbors)
+	case topicRegisterPacket:
+		req = new(topicRegister)
+	case topicQueryPacket:
+		req = new(topicQuery)
+	case topicNodesPacket:
+		req = new(topicNodes)
 	default:
 		return nil, fromID, hash, fmt.Errorf("unknown type: %d", ptype)
 	}
@@ -585,6 +645,50 @@ func (req *neighbors) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byt
 	return nil
 }
 
+func (req *topicRegister) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	// Only bonded nodes may register, the stored endpoint is the
+	// one verified during bonding.
+	n := t.db.node(fromID)
+	if n == nil {
+		return errUnknownNode
+	}
+	return t.topics.register(n, req.Topics, time.Now())
+}
+
+func (req *topicQuery) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	if t.db.node(fromID) == nil {
+		// No bond exists, see findnode for why we don't reply.
+		return errUnknownNode
+	}
+	if !req.Topic.valid() {
+		return errInvalidTopic
+	}
+	// Always reply, even without registrations, so the querying node
+	// doesn't have to wait for a timeout. A single packet is enough.
+	p := topicNodes{Expiration: uint64(time.Now().Add(expiration).Unix())}
+	for _, n := range t.topics.query(req.Topic, maxNeighbors, time.Now()) {
+		p.Nodes = append(p.Nodes, nodeToRPC(n))
+	}
+	t.send(from, topicNodesPacket, p)
+	return nil
+}
+
+func (req *topicNodes) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	if !t.handleReply(fromID, topicNodesPacket, req) {
+		return errUnsolicitedReply
+	}
+	return nil
+}
+
 func expired(ts uint64) bool {
 	return time.Unix(int64(ts), 0).Before(time.Now())
 }
@@ -125,6 +125,9 @@ func TestUDP_packetErrors(t *testing.T) {
 	test.packetIn(errUnsolicitedReply, pongPacket, &pong{ReplyTok: []byte{}, Expiration: futureExp})
 	test.packetIn(errUnknownNode, findnodePacket, &findnode{Expiration: futureExp})
 	test.packetIn(errUnsolicitedReply, neighborsPacket, &neighbors{Expiration: futureExp})
+	test.packetIn(errUnknownNode, topicRegisterPacket, &topicRegister{Topics: []Topic{"shh"}, Expiration: futureExp})
+	test.packetIn(errUnknownNode, topicQueryPacket, &topicQuery{Topic: "shh", Expiration: futureExp})
+	test.packetIn(errUnsolicitedReply, topicNodesPacket, &topicNodes{Expiration: futureExp})
 }
 
 func TestUDP_pingTimeout(t *testing.T) {
@@ -320,6 +323,76 @@ func TestUDP_findnodeMultiReply(t *testing.T) {
 	}
 }
 
+func TestUDP_topicRegister(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	// registrations are only accepted from bonded nodes.
+	remote := newNode(PubkeyID(&test.remotekey.PublicKey), test.remoteaddr.IP, uint16(test.remoteaddr.Port), 99)
+	test.table.db.updateNode(remote)
+
+	test.packetIn(errTooManyTopics, topicRegisterPacket, &topicRegister{Expiration: futureExp})
+	test.packetIn(errInvalidTopic, topicRegisterPacket, &topicRegister{Topics: []Topic{""}, Expiration: futureExp})
+	test.packetIn(nil, topicRegisterPacket, &topicRegister{Topics: []Topic{"shh", "kr"}, Expiration: futureExp})
+	test.packetIn(errRegRateLimit, topicRegisterPacket, &topicRegister{Topics: []Topic{"shh"}, Expiration: futureExp})
+
+	// the registered node is returned for the topic.
+	test.packetIn(nil, topicQueryPacket, &topicQuery{Topic: "shh", Expiration: futureExp})
+	test.waitPacketOut(func(p *topicNodes) {
+		if len(p.Nodes) != 1 || p.Nodes[0].ID != remote.ID || p.Nodes[0].TCP != remote.TCP {
+			t.Errorf("wrong registrations: got %v, want %v", p.Nodes, remote)
+		}
+	})
+	// unknown topics are answered with an empty reply.
+	test.packetIn(nil, topicQueryPacket, &topicQuery{Topic: "bzz", Expiration: futureExp})
+	test.waitPacketOut(func(p *topicNodes) {
+		if len(p.Nodes) != 0 {
+			t.Errorf("unexpected registrations: %v", p.Nodes)
+		}
+	})
+	test.packetIn(errInvalidTopic, topicQueryPacket, &topicQuery{Topic: Topic(make([]byte, maxTopicLength+1)), Expiration: futureExp})
+}
+
+func TestUDP_queryTopic(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	// queue a pending topic query
+	resultc, errc := make(chan []*Node), make(chan error)
+	go func() {
+		rid := PubkeyID(&test.remotekey.PublicKey)
+		ns, err := test.udp.queryTopic(rid, test.remoteaddr, "shh")
+		if err != nil {
+			errc <- err
+		} else {
+			resultc <- ns
+		}
+	}()
+	test.waitPacketOut(func(p *topicQuery) {
+		if p.Topic != "shh" {
+			t.Errorf("wrong topic: got %q, want %q", p.Topic, "shh")
+		}
+	})
+
+	// reply with a valid and an invalid node.
+	list := []*Node{
+		MustParseNode("enode://ba85011c70bcc5c04d8607d3a0ed29aa6179c092cbdda10d5d32684fb33ed01bd94f588ca8f91ac48318087dcb02eaf36773a7a453f0eedd6742af668097b29c@10.0.1.16:30303?discport=30304"),
+	}
+	rpclist := []rpcNode{nodeToRPC(list[0]), {IP: net.IPv4zero, UDP: 1, TCP: 1}}
+	test.packetIn(nil, topicNodesPacket, &topicNodes{Expiration: futureExp, Nodes: rpclist})
+
+	select {
+	case result := <-resultc:
+		if !reflect.DeepEqual(result, list) {
+			t.Errorf("topic nodes mismatch:\n  got:  %v\n  want: %v", result, list)
+		}
+	case err := <-errc:
+		t.Errorf("queryTopic error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Error("queryTopic did not return within 5 seconds")
+	}
+}
+
 func TestUDP_successfulPing(t *testing.T) {
 	test := newUDPTest(t)
 	added := make(chan *Node, 1)
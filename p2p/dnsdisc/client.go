@@ -0,0 +1,153 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// maxTreeEntries is the maximum number of entries a resolved tree may contain,
+// protecting against malicious trees of unbounded size.
+const maxTreeEntries = 10000
+
+// Resolver retrieves the TXT records of a domain.
+type Resolver interface {
+	LookupTXT(domain string) ([]string, error)
+}
+
+// DefaultResolver resolves TXT records via the DNS configuration of the system.
+var DefaultResolver Resolver = systemResolver{}
+
+type systemResolver struct{}
+
+func (systemResolver) LookupTXT(domain string) ([]string, error) {
+	return net.LookupTXT(domain)
+}
+
+// Client resolves and verifies node trees published in DNS. Tree entries are
+// content addressed, so the entries of previously synced trees are reused when
+// a tree is synced again, only resolving the changed parts.
+type Client struct {
+	resolver Resolver
+
+	trees map[string]*Tree // Last synced tree of each URL
+	lock  sync.Mutex       // Serializes syncs and guards the synced trees
+}
+
+// NewClient creates a tree resolver on top of a DNS resolver.
+func NewClient(resolver Resolver) *Client {
+	return &Client{
+		resolver: resolver,
+		trees:    make(map[string]*Tree),
+	}
+}
+
+// SyncTree resolves the tree referenced by the URL, verifying the signature of
+// its root and the hashes of all its entries. Roots with a lower sequence number
+// than the tree last synced from the URL are rejected as stale or replayed.
+func (self *Client) SyncTree(url string) (*Tree, error) {
+	pubkey, domain, err := ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tree URL %q: %v", url, err)
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	root, err := self.resolveRoot(domain, pubkey)
+	if err != nil {
+		return nil, err
+	}
+	if prev, ok := self.trees[url]; ok && root.seq < prev.root.seq {
+		return nil, fmt.Errorf("%s: %v (%d < %d)", domain, errStaleRoot, root.seq, prev.root.seq)
+	}
+	// Walk the tree, resolving all entries reachable from the root
+	tree := &Tree{root: root, entries: make(map[string]entry)}
+	queue := []string{root.hash}
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+
+		if _, ok := tree.entries[hash]; ok {
+			continue
+		}
+		if len(tree.entries) >= maxTreeEntries {
+			return nil, fmt.Errorf("tree at %s exceeds %d entries", domain, maxTreeEntries)
+		}
+		e, err := self.resolveEntry(domain, hash)
+		if err != nil {
+			return nil, err
+		}
+		tree.entries[hash] = e
+		if branch, ok := e.(*branchEntry); ok {
+			queue = append(queue, branch.children...)
+		}
+	}
+	self.trees[url] = tree
+	return tree, nil
+}
+
+// resolveRoot retrieves the root record of the tree at a domain and verifies
+// that it was signed by the given key.
+func (self *Client) resolveRoot(domain string, pubkey *ecdsa.PublicKey) (*rootEntry, error) {
+	records, err := self.resolver.LookupTXT(domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		root, err := parseRoot(record)
+		if err == errNoRoot {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", domain, err)
+		}
+		if !root.verify(pubkey) {
+			return nil, fmt.Errorf("%s: %v", domain, errInvalidSig)
+		}
+		return root, nil
+	}
+	return nil, fmt.Errorf("%s: %v", domain, errNoRoot)
+}
+
+// resolveEntry retrieves the tree entry with the given hash, verifying that the
+// record content matches the hash.
+func (self *Client) resolveEntry(domain, hash string) (entry, error) {
+	for _, tree := range self.trees {
+		if e, ok := tree.entries[hash]; ok {
+			return e, nil
+		}
+	}
+	name := hash + "." + domain
+	records, err := self.resolver.LookupTXT(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if hashRecord(record) != hash {
+			continue
+		}
+		e, err := parseEntry(record)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		return e, nil
+	}
+	return nil, fmt.Errorf("%s: no record matching the hash", name)
+}
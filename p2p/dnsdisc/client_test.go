@@ -0,0 +1,202 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/krypton/go-krypton/crypto"
+	"github.com/krypton/go-krypton/p2p/discover"
+)
+
+// stubResolver serves TXT records from memory, counting the lookups.
+type stubResolver struct {
+	records map[string]string
+	lookups int
+}
+
+func (r *stubResolver) LookupTXT(domain string) ([]string, error) {
+	r.lookups++
+	if record, ok := r.records[domain]; ok {
+		return []string{record}, nil
+	}
+	return nil, fmt.Errorf("no such host: %s", domain)
+}
+
+// testNodes creates a batch of nodes with random ids.
+func testNodes(t *testing.T, n int) []*discover.Node {
+	nodes := make([]*discover.Node, n)
+	for i := range nodes {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		nodes[i] = &discover.Node{
+			ID:  discover.PubkeyID(&key.PublicKey),
+			IP:  net.IP{10, 0, byte(i >> 8), byte(i)},
+			TCP: 30303,
+			UDP: 30303,
+		}
+	}
+	return nodes
+}
+
+// publish creates a signed tree of the nodes and serves it from a stub resolver.
+func publish(t *testing.T, seq uint, nodes []*discover.Node, domain string) (string, *stubResolver) {
+	key, _ := crypto.GenerateKey()
+	tree, err := MakeTree(seq, nodes)
+	if err != nil {
+		t.Fatalf("failed to create tree: %v", err)
+	}
+	url, err := tree.Sign(key, domain)
+	if err != nil {
+		t.Fatalf("failed to sign tree: %v", err)
+	}
+	return url, &stubResolver{records: tree.ToTXT(domain)}
+}
+
+func TestSyncTree(t *testing.T) {
+	for _, n := range []int{0, 1, maxBranchItems, 200} {
+		nodes := testNodes(t, n)
+		url, resolver := publish(t, 3, nodes, "nodes.example.org")
+
+		tree, err := NewClient(resolver).SyncTree(url)
+		if err != nil {
+			t.Fatalf("%d nodes: failed to sync tree: %v", n, err)
+		}
+		if tree.Seq() != 3 {
+			t.Errorf("%d nodes: sequence number mismatch: have %d, want %d", n, tree.Seq(), 3)
+		}
+		want := append([]*discover.Node{}, nodes...)
+		sort.Sort(nodesByID(want))
+		if have := tree.Nodes(); len(have) != len(want) || (len(want) > 0 && !reflect.DeepEqual(nodeURLs(have), nodeURLs(want))) {
+			t.Errorf("%d nodes: node list mismatch: have %v, want %v", n, nodeURLs(have), nodeURLs(want))
+		}
+	}
+}
+
+func TestSyncTreeCache(t *testing.T) {
+	url, resolver := publish(t, 1, testNodes(t, 50), "nodes.example.org")
+	client := NewClient(resolver)
+
+	if _, err := client.SyncTree(url); err != nil {
+		t.Fatalf("failed to sync tree: %v", err)
+	}
+	if resolver.lookups != len(resolver.records) {
+		t.Errorf("first sync lookups mismatch: have %d, want %d", resolver.lookups, len(resolver.records))
+	}
+	// Resyncing an unchanged tree must only resolve the root
+	resolver.lookups = 0
+	if _, err := client.SyncTree(url); err != nil {
+		t.Fatalf("failed to resync tree: %v", err)
+	}
+	if resolver.lookups != 1 {
+		t.Errorf("resync lookups mismatch: have %d, want %d", resolver.lookups, 1)
+	}
+}
+
+func TestSyncTreeInvalid(t *testing.T) {
+	domain := "nodes.example.org"
+
+	// A root signed by a different key must be rejected
+	url, resolver := publish(t, 1, testNodes(t, 5), domain)
+	other, _ := publish(t, 1, testNodes(t, 1), domain)
+	if _, err := NewClient(resolver).SyncTree(other); err == nil || !strings.Contains(err.Error(), errInvalidSig.Error()) {
+		t.Errorf("foreign signature error mismatch: have %v, want %v", err, errInvalidSig)
+	}
+	// Tampered entries must be rejected
+	for name, record := range resolver.records {
+		if strings.HasPrefix(record, leafPrefix) {
+			resolver.records[name] = strings.Replace(record, "30303", "30304", 1)
+			break
+		}
+	}
+	if _, err := NewClient(resolver).SyncTree(url); err == nil {
+		t.Errorf("tampered tree accepted")
+	}
+	// Missing roots must be reported
+	delete(resolver.records, domain)
+	if _, err := NewClient(resolver).SyncTree(url); err == nil {
+		t.Errorf("tree without root accepted")
+	}
+}
+
+// Tests that roots older than the last synced one are rejected.
+func TestSyncTreeStale(t *testing.T) {
+	domain := "nodes.example.org"
+	key, _ := crypto.GenerateKey()
+	resolver := &stubResolver{}
+
+	sign := func(seq uint) string {
+		tree, err := MakeTree(seq, testNodes(t, 3))
+		if err != nil {
+			t.Fatalf("failed to create tree: %v", err)
+		}
+		url, err := tree.Sign(key, domain)
+		if err != nil {
+			t.Fatalf("failed to sign tree: %v", err)
+		}
+		resolver.records = tree.ToTXT(domain)
+		return url
+	}
+	client := NewClient(resolver)
+	if _, err := client.SyncTree(sign(5)); err != nil {
+		t.Fatalf("failed to sync tree: %v", err)
+	}
+	if _, err := client.SyncTree(sign(4)); err == nil || !strings.Contains(err.Error(), errStaleRoot.Error()) {
+		t.Errorf("stale root error mismatch: have %v, want %v", err, errStaleRoot)
+	}
+	if tree, err := client.SyncTree(sign(6)); err != nil || tree.Seq() != 6 {
+		t.Errorf("newer tree not synced: %v", err)
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	id := discover.PubkeyID(&key.PublicKey)
+
+	pubkey, domain, err := ParseURL(fmt.Sprintf("enodetree://%s@nodes.example.org", id))
+	if err != nil {
+		t.Fatalf("failed to parse valid URL: %v", err)
+	}
+	if discover.PubkeyID(pubkey) != id || domain != "nodes.example.org" {
+		t.Errorf("parsed URL mismatch: have %x@%s, want %x@%s", discover.PubkeyID(pubkey), domain, id, "nodes.example.org")
+	}
+	for _, url := range []string{
+		fmt.Sprintf("enode://%s@nodes.example.org", id),
+		"enodetree://nodes.example.org",
+		"enodetree://1234@nodes.example.org",
+		fmt.Sprintf("enodetree://%s@", id),
+	} {
+		if _, _, err := ParseURL(url); err == nil {
+			t.Errorf("invalid URL %q accepted", url)
+		}
+	}
+}
+
+func nodeURLs(nodes []*discover.Node) []string {
+	urls := make([]string, len(nodes))
+	for i, node := range nodes {
+		urls[i] = node.String()
+	}
+	return urls
+}
@@ -0,0 +1,315 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package dnsdisc implements node discovery via DNS.
+//
+// A list of nodes is published as a Merkle tree of TXT records under a domain.
+// The root record at the domain itself references the hash of the topmost tree
+// entry and is signed by the publisher. Every other entry is stored at the
+// subdomain named after the hash of its own content, so that resolving clients
+// can verify the whole tree against the signed root:
+//
+//   example.org           enodetree-root=v1 e=<hash> seq=<n> sig=<signature>
+//   <hash>.example.org    enodetree-branch=<hash>,<hash>,...
+//   <hash>.example.org    enode://<node id>@<ip>:<port>
+//
+// Trees are referenced by URLs of the form enodetree://<public key>@<domain>,
+// where the public key is the hex encoded key of the publisher.
+package dnsdisc
+
+import (
+	"crypto/ecdsa"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/krypton/go-krypton/crypto"
+	"github.com/krypton/go-krypton/p2p/discover"
+)
+
+const (
+	rootPrefix   = "enodetree-root=v1"
+	branchPrefix = "enodetree-branch="
+	leafPrefix   = "enode://"
+	urlScheme    = "enodetree"
+
+	hashLength     = 16 // Number of hash bytes naming an entry (base32 encoded)
+	maxBranchItems = 8  // Maximum number of children of a branch, to fit a 255 byte TXT string
+)
+
+var (
+	errUnknownEntry = errors.New("unknown entry type")
+	errInvalidSig   = errors.New("invalid root signature")
+	errNoRoot       = errors.New("no root record found")
+	errStaleRoot    = errors.New("root sequence number older than the synced tree")
+)
+
+// entry is a single TXT record of a tree.
+type entry interface {
+	String() string
+}
+
+// rootEntry is the signed record at the top of a tree.
+type rootEntry struct {
+	hash string // Hash of the topmost branch or leaf
+	seq  uint   // Sequence number, increased on every update
+	sig  []byte // Signature of the publisher over the record without sig
+}
+
+// branchEntry references the hashes of further entries.
+type branchEntry struct {
+	children []string
+}
+
+// leafEntry holds a single node.
+type leafEntry struct {
+	node *discover.Node
+}
+
+func (e *rootEntry) signedPart() string {
+	return fmt.Sprintf("%s e=%s seq=%d", rootPrefix, e.hash, e.seq)
+}
+
+func (e *rootEntry) String() string {
+	return fmt.Sprintf("%s sig=%s", e.signedPart(), hex.EncodeToString(e.sig))
+}
+
+// verify checks that the root was signed by the given public key.
+func (e *rootEntry) verify(pubkey *ecdsa.PublicKey) bool {
+	pub, err := crypto.SigToPub(crypto.Sha3([]byte(e.signedPart())), e.sig)
+	if err != nil {
+		return false
+	}
+	return discover.PubkeyID(pub) == discover.PubkeyID(pubkey)
+}
+
+func (e *branchEntry) String() string {
+	return branchPrefix + strings.Join(e.children, ",")
+}
+
+func (e *leafEntry) String() string {
+	return e.node.String()
+}
+
+// hashEntry computes the subdomain label an entry is stored under.
+func hashEntry(e entry) string {
+	return hashRecord(e.String())
+}
+
+// hashRecord computes the subdomain label a TXT record is stored under.
+func hashRecord(record string) string {
+	hash := crypto.Sha3([]byte(record))[:hashLength]
+	return strings.TrimRight(base32.StdEncoding.EncodeToString(hash), "=")
+}
+
+// parseRoot decodes the root record of a tree.
+func parseRoot(record string) (*rootEntry, error) {
+	if !strings.HasPrefix(record, rootPrefix+" ") {
+		return nil, errNoRoot
+	}
+	root := new(rootEntry)
+	for _, field := range strings.Fields(record[len(rootPrefix):]) {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid root field %q", field)
+		}
+		var err error
+		switch kv[0] {
+		case "e":
+			root.hash = kv[1]
+		case "seq":
+			var seq uint64
+			seq, err = strconv.ParseUint(kv[1], 10, 32)
+			root.seq = uint(seq)
+		case "sig":
+			root.sig, err = hex.DecodeString(kv[1])
+		default:
+			err = errors.New("unknown field")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid root field %q: %v", field, err)
+		}
+	}
+	if root.hash == "" || len(root.sig) != 65 {
+		return nil, fmt.Errorf("incomplete root record %q", record)
+	}
+	return root, nil
+}
+
+// parseEntry decodes a branch or leaf record of a tree.
+func parseEntry(record string) (entry, error) {
+	switch {
+	case strings.HasPrefix(record, branchPrefix):
+		children := strings.Split(record[len(branchPrefix):], ",")
+		if len(children) == 1 && children[0] == "" {
+			children = nil
+		}
+		for _, child := range children {
+			if _, err := base32.StdEncoding.DecodeString(child + "======"); err != nil || len(child) == 0 {
+				return nil, fmt.Errorf("invalid child hash %q", child)
+			}
+		}
+		return &branchEntry{children: children}, nil
+
+	case strings.HasPrefix(record, leafPrefix):
+		node, err := discover.ParseNode(record)
+		if err != nil {
+			return nil, err
+		}
+		return &leafEntry{node: node}, nil
+
+	default:
+		return nil, errUnknownEntry
+	}
+}
+
+// ParseURL decodes a tree URL into the public key of its publisher and the
+// domain the tree is published under.
+func ParseURL(rawurl string) (*ecdsa.PublicKey, string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, "", err
+	}
+	if u.Scheme != urlScheme {
+		return nil, "", fmt.Errorf("invalid URL scheme, want %q", urlScheme)
+	}
+	if u.User == nil {
+		return nil, "", errors.New("missing public key")
+	}
+	id, err := discover.HexID(u.User.String())
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid public key: %v", err)
+	}
+	pubkey, err := id.Pubkey()
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid public key: %v", err)
+	}
+	if u.Host == "" {
+		return nil, "", errors.New("missing domain")
+	}
+	return pubkey, u.Host, nil
+}
+
+// Tree is a signed Merkle tree of nodes, as published in DNS.
+type Tree struct {
+	root    *rootEntry
+	entries map[string]entry
+}
+
+// MakeTree creates an unsigned tree holding the given nodes. The sequence number
+// must be increased on every update of a published tree.
+func MakeTree(seq uint, nodes []*discover.Node) (*Tree, error) {
+	// Sort the nodes to make the tree deterministic
+	leaves := make([]entry, len(nodes))
+	for i, node := range nodes {
+		if node.IP == nil || node.IP.IsUnspecified() {
+			return nil, fmt.Errorf("node %x has no IP address", node.ID[:8])
+		}
+		leaves[i] = &leafEntry{node: node}
+	}
+	sort.Sort(byNodeID(leaves))
+
+	tree := &Tree{entries: make(map[string]entry)}
+	top := tree.build(leaves)
+	tree.entries[hashEntry(top)] = top
+	tree.root = &rootEntry{hash: hashEntry(top), seq: seq}
+
+	return tree, nil
+}
+
+// build recursively groups the entries into branches, returning the topmost one.
+func (self *Tree) build(entries []entry) entry {
+	if len(entries) == 1 {
+		return entries[0]
+	}
+	if len(entries) <= maxBranchItems {
+		branch := &branchEntry{}
+		for _, e := range entries {
+			hash := hashEntry(e)
+			self.entries[hash] = e
+			branch.children = append(branch.children, hash)
+		}
+		return branch
+	}
+	// Too many entries for a single branch, group them into subtrees
+	var subtrees []entry
+	for len(entries) > 0 {
+		size := maxBranchItems
+		if size > len(entries) {
+			size = len(entries)
+		}
+		subtrees = append(subtrees, self.build(entries[:size]))
+		entries = entries[size:]
+	}
+	return self.build(subtrees)
+}
+
+// Sign signs the tree with the key of the publisher and returns the URL of the
+// tree when published under the given domain.
+func (self *Tree) Sign(key *ecdsa.PrivateKey, domain string) (string, error) {
+	sig, err := crypto.Sign(crypto.Sha3([]byte(self.root.signedPart())), key)
+	if err != nil {
+		return "", err
+	}
+	self.root.sig = sig
+	return fmt.Sprintf("%s://%s@%s", urlScheme, discover.PubkeyID(&key.PublicKey), domain), nil
+}
+
+// Seq returns the sequence number of the tree.
+func (self *Tree) Seq() uint {
+	return self.root.seq
+}
+
+// Nodes returns all the nodes contained in the tree.
+func (self *Tree) Nodes() []*discover.Node {
+	var nodes []*discover.Node
+	for _, e := range self.entries {
+		if leaf, ok := e.(*leafEntry); ok {
+			nodes = append(nodes, leaf.node)
+		}
+	}
+	sort.Sort(nodesByID(nodes))
+	return nodes
+}
+
+// ToTXT returns the TXT records of the tree when published under the given
+// domain, mapping fully qualified names to record contents.
+func (self *Tree) ToTXT(domain string) map[string]string {
+	records := map[string]string{domain: self.root.String()}
+	for hash, e := range self.entries {
+		records[hash+"."+domain] = e.String()
+	}
+	return records
+}
+
+type byNodeID []entry
+
+func (s byNodeID) Len() int      { return len(s) }
+func (s byNodeID) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byNodeID) Less(i, j int) bool {
+	return s[i].(*leafEntry).node.ID.String() < s[j].(*leafEntry).node.ID.String()
+}
+
+type nodesByID []*discover.Node
+
+func (s nodesByID) Len() int           { return len(s) }
+func (s nodesByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s nodesByID) Less(i, j int) bool { return s[i].ID.String() < s[j].ID.String() }
@@ -20,6 +20,7 @@ package p2p
 
 import (
 	"net"
+	"sync"
 
 	"github.com/krypton/go-krypton/metrics"
 )
@@ -29,6 +30,14 @@ var (
 	ingressTrafficMeter = metrics.NewMeter("p2p/InboundTraffic")
 	egressConnectMeter  = metrics.NewMeter("p2p/OutboundConnects")
 	egressTrafficMeter  = metrics.NewMeter("p2p/OutboundTraffic")
+
+	// Payload sizes of snappy compressed messages before and after compression.
+	// The compression ratio is the rate of the compressed meter divided by the
+	// rate of the raw meter.
+	ingressRawMeter        = metrics.NewMeter("p2p/InboundRawPayload")
+	ingressCompressedMeter = metrics.NewMeter("p2p/InboundCompressedPayload")
+	egressRawMeter         = metrics.NewMeter("p2p/OutboundRawPayload")
+	egressCompressedMeter  = metrics.NewMeter("p2p/OutboundCompressedPayload")
 )
 
 // meteredConn is a wrapper around a network TCP connection that meters both the
@@ -69,3 +78,84 @@ func (c *meteredConn) Write(b []byte) (n int, err error) {
 	egressTrafficMeter.Mark(int64(n))
 	return
 }
+
+// TrafficStats counts the messages and bytes transferred in both directions,
+// either over a whole peer connection or of a single message type. Message
+// sizes are payload sizes, before compression.
+type TrafficStats struct {
+	InPackets  uint64 `json:"inPackets"`
+	InBytes    uint64 `json:"inBytes"`
+	OutPackets uint64 `json:"outPackets"`
+	OutBytes   uint64 `json:"outBytes"`
+}
+
+// bytes returns the total number of bytes exchanged in both directions.
+func (s TrafficStats) bytes() uint64 {
+	return s.InBytes + s.OutBytes
+}
+
+// add accumulates the counters of another traffic stats.
+func (s *TrafficStats) add(other TrafficStats) {
+	s.InPackets += other.InPackets
+	s.InBytes += other.InBytes
+	s.OutPackets += other.OutPackets
+	s.OutBytes += other.OutBytes
+}
+
+// trafficMeter accounts the traffic of a protocol by message code. It is
+// safe for concurrent use.
+type trafficMeter struct {
+	lock  sync.Mutex
+	total TrafficStats
+	codes map[uint64]*TrafficStats
+}
+
+func newTrafficMeter() *trafficMeter {
+	return &trafficMeter{codes: make(map[uint64]*TrafficStats)}
+}
+
+// code returns the counters of a message code. The caller must hold the lock.
+func (m *trafficMeter) code(code uint64) *TrafficStats {
+	stats, ok := m.codes[code]
+	if !ok {
+		stats = new(TrafficStats)
+		m.codes[code] = stats
+	}
+	return stats
+}
+
+// markIn counts a received message.
+func (m *trafficMeter) markIn(code uint64, size uint32) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	stats := m.code(code)
+	stats.InPackets++
+	stats.InBytes += uint64(size)
+	m.total.InPackets++
+	m.total.InBytes += uint64(size)
+}
+
+// markOut counts a sent message.
+func (m *trafficMeter) markOut(code uint64, size uint32) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	stats := m.code(code)
+	stats.OutPackets++
+	stats.OutBytes += uint64(size)
+	m.total.OutPackets++
+	m.total.OutBytes += uint64(size)
+}
+
+// stats returns a copy of the total and per message code counters.
+func (m *trafficMeter) stats() (TrafficStats, map[uint64]TrafficStats) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	codes := make(map[uint64]TrafficStats, len(m.codes))
+	for code, stats := range m.codes {
+		codes[code] = *stats
+	}
+	return m.total, codes
+}
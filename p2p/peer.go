@@ -22,6 +22,7 @@ import (
 	"io"
 	"net"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -67,6 +68,13 @@ type Peer struct {
 	protoErr chan error
 	closed   chan struct{}
 	disc     chan DiscReason
+
+	created time.Time     // time the connection was established
+	base    *trafficMeter // traffic of the base protocol messages
+
+	pingLock sync.Mutex
+	pingSent time.Time     // time of the last unanswered ping, zero if none
+	rtt      time.Duration // round trip time of the last answered ping
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -126,6 +134,8 @@ func newPeer(conn *conn, protocols []Protocol) *Peer {
 		disc:     make(chan DiscReason),
 		protoErr: make(chan error, len(protomap)+1), // protocols + pingLoop
 		closed:   make(chan struct{}),
+		created:  time.Now(),
+		base:     newTrafficMeter(),
 	}
 	return p
 }
@@ -195,7 +205,11 @@ func (p *Peer) pingLoop() {
 	for {
 		select {
 		case <-ping.C:
-			if err := SendItems(p.rw, pingMsg); err != nil {
+			p.pingLock.Lock()
+			p.pingSent = time.Now()
+			p.pingLock.Unlock()
+
+			if err := p.writeBase(pingMsg); err != nil {
 				p.protoErr <- err
 				return
 			}
@@ -205,6 +219,19 @@ func (p *Peer) pingLoop() {
 	}
 }
 
+// writeBase sends a base protocol message, accounting its traffic.
+func (p *Peer) writeBase(code uint64, data ...interface{}) error {
+	size, r, err := rlp.EncodeToReader(data)
+	if err != nil {
+		return err
+	}
+	if err := p.rw.WriteMsg(Msg{Code: code, Size: uint32(size), Payload: r}); err != nil {
+		return err
+	}
+	p.base.markOut(code, uint32(size))
+	return nil
+}
+
 func (p *Peer) readLoop(errc chan<- error) {
 	defer p.wg.Done()
 	for {
@@ -222,10 +249,20 @@ func (p *Peer) readLoop(errc chan<- error) {
 }
 
 func (p *Peer) handle(msg Msg) error {
+	if msg.Code < baseProtocolLength {
+		p.base.markIn(msg.Code, msg.Size)
+	}
 	switch {
 	case msg.Code == pingMsg:
 		msg.Discard()
-		go SendItems(p.rw, pongMsg)
+		go p.writeBase(pongMsg)
+	case msg.Code == pongMsg:
+		p.pingLock.Lock()
+		if !p.pingSent.IsZero() {
+			p.rtt, p.pingSent = msg.ReceivedAt.Sub(p.pingSent), time.Time{}
+		}
+		p.pingLock.Unlock()
+		return msg.Discard()
 	case msg.Code == discMsg:
 		var reason [1]DiscReason
 		// This is the last message. We don't need to discard or
@@ -241,6 +278,7 @@ func (p *Peer) handle(msg Msg) error {
 		if err != nil {
 			return fmt.Errorf("msg code out of range: %v", msg.Code)
 		}
+		proto.traffic.markIn(msg.Code-proto.offset, msg.Size)
 		select {
 		case proto.in <- msg:
 			return nil
@@ -278,7 +316,7 @@ outer:
 					offset -= old.Length
 				}
 				// Assign the new match
-				result[cap.Name] = &protoRW{Protocol: proto, offset: offset, in: make(chan Msg), w: rw}
+				result[cap.Name] = &protoRW{Protocol: proto, offset: offset, in: make(chan Msg), w: rw, traffic: newTrafficMeter()}
 				offset += proto.Length
 
 				continue outer
@@ -329,16 +367,21 @@ type protoRW struct {
 	werr   chan<- error    // for write results
 	offset uint64
 	w      MsgWriter
+
+	traffic *trafficMeter // traffic by message code, relative to offset
 }
 
 func (rw *protoRW) WriteMsg(msg Msg) (err error) {
 	if msg.Code >= rw.Length {
 		return newPeerError(errInvalidMsgCode, "not handled")
 	}
+	code := msg.Code
 	msg.Code += rw.offset
 	select {
 	case <-rw.wstart:
-		err = rw.w.WriteMsg(msg)
+		if err = rw.w.WriteMsg(msg); err == nil {
+			rw.traffic.markOut(code, msg.Size)
+		}
 		// Report write status back to Peer.run. It will initiate
 		// shutdown if the error is non-nil and unblock the next write
 		// otherwise. The calling protocol code should exit for errors
@@ -371,6 +414,9 @@ type PeerInfo struct {
 		LocalAddress  string `json:"localAddress"`  // Local endpoint of the TCP data connection
 		RemoteAddress string `json:"remoteAddress"` // Remote endpoint of the TCP data connection
 	} `json:"network"`
+	Connected string                 `json:"connected"` // Duration of the connection
+	Latency   string                 `json:"latency"`   // Round trip time of the last answered ping
+	Traffic   TrafficStats           `json:"traffic"`   // Total traffic exchanged with the peer
 	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
 }
 
@@ -390,6 +436,13 @@ func (p *Peer) Info() *PeerInfo {
 	}
 	info.Network.LocalAddress = p.LocalAddr().String()
 	info.Network.RemoteAddress = p.RemoteAddr().String()
+	info.Connected = p.Connected().String()
+	info.Latency = p.Latency().String()
+	info.Traffic, _ = p.base.stats()
+	for _, proto := range p.running {
+		total, _ := proto.traffic.stats()
+		info.Traffic.add(total)
+	}
 
 	// Gather all the running protocol infos
 	for _, proto := range p.running {
@@ -405,3 +458,70 @@ func (p *Peer) Info() *PeerInfo {
 	}
 	return info
 }
+
+// Connected returns the time elapsed since the connection was established.
+func (p *Peer) Connected() time.Duration {
+	return time.Since(p.created)
+}
+
+// Latency returns the round trip time of the last answered ping, or zero if
+// no ping was answered yet.
+func (p *Peer) Latency() time.Duration {
+	p.pingLock.Lock()
+	defer p.pingLock.Unlock()
+
+	return p.rtt
+}
+
+// ProtocolStats contains the traffic exchanged with a peer over a single
+// protocol, both in total and split up by message code.
+type ProtocolStats struct {
+	Traffic  TrafficStats            `json:"traffic"`  // Total traffic of the protocol
+	Messages map[string]TrafficStats `json:"messages"` // Traffic by message code (relative to the protocol)
+}
+
+// PeerStats contains the traffic statistics of a connected peer.
+type PeerStats struct {
+	ID        string                    `json:"id"`        // Unique node identifier
+	Name      string                    `json:"name"`      // Name of the node
+	Connected string                    `json:"connected"` // Duration of the connection
+	Latency   string                    `json:"latency"`   // Round trip time of the last answered ping
+	Traffic   TrafficStats              `json:"traffic"`   // Total traffic exchanged with the peer
+	Protocols map[string]*ProtocolStats `json:"protocols"` // Traffic by protocol, including the base protocol
+}
+
+// Stats gathers the traffic statistics of the peer. The traffic of the base
+// protocol (pings, disconnects) is reported under the "p2p" protocol.
+func (p *Peer) Stats() *PeerStats {
+	stats := &PeerStats{
+		ID:        p.ID().String(),
+		Name:      p.Name(),
+		Connected: p.Connected().String(),
+		Latency:   p.Latency().String(),
+		Protocols: make(map[string]*ProtocolStats),
+	}
+	collect := func(name string, meter *trafficMeter) {
+		total, codes := meter.stats()
+		proto := &ProtocolStats{Traffic: total, Messages: make(map[string]TrafficStats, len(codes))}
+		for code, traffic := range codes {
+			proto.Messages[strconv.FormatUint(code, 10)] = traffic
+		}
+		stats.Protocols[name] = proto
+		stats.Traffic.add(total)
+	}
+	collect("p2p", p.base)
+	for _, proto := range p.running {
+		collect(proto.Name, proto.traffic)
+	}
+	return stats
+}
+
+// peerStatsByTraffic orders peer statistics by the total amount of bytes
+// exchanged, highest first.
+type peerStatsByTraffic []*PeerStats
+
+func (ps peerStatsByTraffic) Len() int      { return len(ps) }
+func (ps peerStatsByTraffic) Swap(i, j int) { ps[i], ps[j] = ps[j], ps[i] }
+func (ps peerStatsByTraffic) Less(i, j int) bool {
+	return ps[i].Traffic.bytes() > ps[j].Traffic.bytes()
+}
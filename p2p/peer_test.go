@@ -129,6 +129,77 @@ func TestPeerPing(t *testing.T) {
 	}
 }
 
+func TestPeerTrafficStats(t *testing.T) {
+	done := make(chan struct{})
+	proto := Protocol{
+		Name:   "a",
+		Length: 5,
+		Run: func(peer *Peer, rw MsgReadWriter) error {
+			if err := ExpectMsg(rw, 2, []uint{1}); err != nil {
+				t.Error(err)
+			}
+			if err := SendItems(rw, 3, "foo", "bar"); err != nil {
+				t.Error(err)
+			}
+			close(done)
+			return nil
+		},
+	}
+	closer, rw, peer, _ := testPeer([]Protocol{proto})
+	defer closer()
+
+	Send(rw, baseProtocolLength+2, []uint{1})
+	if err := ExpectMsg(rw, baseProtocolLength+3, []string{"foo", "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	stats := peer.Stats()
+	a := stats.Protocols["a"]
+	if a == nil {
+		t.Fatalf("missing protocol stats: %+v", stats.Protocols)
+	}
+	if want := (TrafficStats{InPackets: 1, InBytes: 2}); a.Messages["2"] != want {
+		t.Errorf("inbound message stats mismatch: have %+v, want %+v", a.Messages["2"], want)
+	}
+	if want := (TrafficStats{OutPackets: 1, OutBytes: 9}); a.Messages["3"] != want {
+		t.Errorf("outbound message stats mismatch: have %+v, want %+v", a.Messages["3"], want)
+	}
+	if want := (TrafficStats{InPackets: 1, InBytes: 2, OutPackets: 1, OutBytes: 9}); a.Traffic != want {
+		t.Errorf("protocol stats mismatch: have %+v, want %+v", a.Traffic, want)
+	}
+	if stats.Traffic != a.Traffic {
+		t.Errorf("total stats mismatch: have %+v, want %+v", stats.Traffic, a.Traffic)
+	}
+}
+
+func TestPeerLatency(t *testing.T) {
+	closer, rw, peer, _ := testPeer(nil)
+	defer closer()
+
+	peer.pingLock.Lock()
+	peer.pingSent = time.Now().Add(-50 * time.Millisecond)
+	peer.pingLock.Unlock()
+
+	if err := SendItems(rw, pongMsg); err != nil {
+		t.Fatal(err)
+	}
+	// Wait for the pong to be processed
+	if err := SendItems(rw, pingMsg); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExpectMsg(rw, pongMsg, nil); err != nil {
+		t.Fatal(err)
+	}
+	if rtt := peer.Latency(); rtt < 50*time.Millisecond {
+		t.Errorf("latency too low: have %v, want >= 50ms", rtt)
+	}
+	base := peer.Stats().Protocols["p2p"]
+	if base.Messages["2"].InPackets != 1 || base.Messages["3"].InPackets != 1 {
+		t.Errorf("base protocol stats mismatch: %+v", base.Messages)
+	}
+}
+
 func TestPeerDisconnect(t *testing.T) {
 	closer, rw, _, disc := testPeer(nil)
 	defer closer()
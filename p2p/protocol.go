@@ -72,6 +72,16 @@ func (cap Cap) String() string {
 	return fmt.Sprintf("%s/%d", cap.Name, cap.Version)
 }
 
+// hasCap reports whether the capability is contained in caps.
+func hasCap(caps []Cap, cap Cap) bool {
+	for _, c := range caps {
+		if c == cap {
+			return true
+		}
+	}
+	return false
+}
+
 type capsByNameAndVersion []Cap
 
 func (cs capsByNameAndVersion) Len() int      { return len(cs) }
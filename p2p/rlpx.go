@@ -28,6 +28,7 @@ import (
 	"fmt"
 	"hash"
 	"io"
+	"io/ioutil"
 	"net"
 	"sync"
 	"time"
@@ -38,6 +39,7 @@ import (
 	"github.com/krypton/go-krypton/crypto/sha3"
 	"github.com/krypton/go-krypton/p2p/discover"
 	"github.com/krypton/go-krypton/rlp"
+	"github.com/syndtr/gosnappy/snappy"
 )
 
 const (
@@ -63,8 +65,21 @@ const (
 	// This is shorter than the usual timeout because we don't want
 	// to wait if the connection is known to be bad anyway.
 	discWriteTimeout = 1 * time.Second
+
+	// Maximum decompressed size of a message, the largest message
+	// an uncompressed frame can carry. Lower per-protocol limits
+	// (e.g. kr.ProtocolMaxMsgSize) are checked by the protocols
+	// against the decompressed size.
+	maxDecompressedSize = int(maxUint24)
 )
 
+var errPlainMessageTooLarge = errors.New("message length >= 16MB")
+
+// snappyCap is advertised in the protocol handshake by nodes supporting
+// snappy compressed message payloads. It doesn't name a subprotocol, so
+// nodes not knowing it never match it and keep using plain payloads.
+var snappyCap = Cap{Name: "snappy", Version: 1}
+
 // rlpx is the transport protocol used by actual (non-test) connections.
 // It wraps the frame encoder with locks and read/write deadlines.
 type rlpx struct {
@@ -124,6 +139,14 @@ func (t *rlpx) doProtoHandshake(our *protoHandshake) (their *protoHandshake, err
 	if err := <-werr; err != nil {
 		return nil, fmt.Errorf("write error: %v", err)
 	}
+	// Compress all further messages if both sides support it
+	if hasCap(our.Caps, snappyCap) && hasCap(their.Caps, snappyCap) {
+		t.rmu.Lock()
+		t.wmu.Lock()
+		t.rw.snappy = true
+		t.wmu.Unlock()
+		t.rmu.Unlock()
+	}
 	return their, nil
 }
 
@@ -497,6 +520,8 @@ type rlpxFrameRW struct {
 	macCipher  cipher.Block
 	egressMAC  hash.Hash
 	ingressMAC hash.Hash
+
+	snappy bool // whether message payloads are snappy compressed
 }
 
 func newRLPXFrameRW(conn io.ReadWriter, s secrets) *rlpxFrameRW {
@@ -524,6 +549,24 @@ func newRLPXFrameRW(conn io.ReadWriter, s secrets) *rlpxFrameRW {
 func (rw *rlpxFrameRW) WriteMsg(msg Msg) error {
 	ptype, _ := rlp.EncodeToBytes(msg.Code)
 
+	// compress the payload if negotiated
+	if rw.snappy {
+		if msg.Size > maxUint24 {
+			return errPlainMessageTooLarge
+		}
+		payload, err := ioutil.ReadAll(msg.Payload)
+		if err != nil {
+			return err
+		}
+		if payload, err = snappy.Encode(nil, payload); err != nil {
+			return err
+		}
+		egressRawMeter.Mark(int64(msg.Size))
+		egressCompressedMeter.Mark(int64(len(payload)))
+
+		msg.Size, msg.Payload = uint32(len(payload)), bytes.NewReader(payload)
+	}
+
 	// write header
 	headbuf := make([]byte, 32)
 	fsize := uint32(len(ptype)) + msg.Size
@@ -609,6 +652,26 @@ func (rw *rlpxFrameRW) ReadMsg() (msg Msg, err error) {
 	}
 	msg.Size = uint32(content.Len())
 	msg.Payload = content
+
+	// decompress the payload if negotiated, checking the
+	// decompressed size before allocating any memory.
+	if rw.snappy {
+		payload := framebuf[fsize-msg.Size : fsize]
+		size, err := snappy.DecodedLen(payload)
+		if err != nil {
+			return msg, err
+		}
+		if size > maxDecompressedSize {
+			return msg, fmt.Errorf("decompressed message too large: %d > %d", size, maxDecompressedSize)
+		}
+		if payload, err = snappy.Decode(nil, payload); err != nil {
+			return msg, err
+		}
+		ingressRawMeter.Mark(int64(size))
+		ingressCompressedMeter.Mark(int64(msg.Size))
+
+		msg.Size, msg.Payload = uint32(size), bytes.NewReader(payload)
+	}
 	return msg, nil
 }
 
@@ -19,6 +19,7 @@ package p2p
 import (
 	"bytes"
 	"crypto/rand"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -212,6 +213,87 @@ func TestProtocolHandshake(t *testing.T) {
 	wg.Wait()
 }
 
+// This test checks that compression is only enabled if both sides support it
+// and that nodes supporting it still handshake with baseline version 4 peers.
+func TestProtocolHandshakeSnappy(t *testing.T) {
+	var (
+		legacyCaps = []Cap{{"kr", 61}}
+		snappyCaps = []Cap{{"kr", 61}, snappyCap}
+	)
+	tests := []struct {
+		dialCaps, listenCaps []Cap
+		snappy               bool
+	}{
+		{dialCaps: legacyCaps, listenCaps: legacyCaps, snappy: false},
+		{dialCaps: snappyCaps, listenCaps: legacyCaps, snappy: false},
+		{dialCaps: legacyCaps, listenCaps: snappyCaps, snappy: false},
+		{dialCaps: snappyCaps, listenCaps: snappyCaps, snappy: true},
+	}
+	for i, tt := range tests {
+		var (
+			prv0, _  = crypto.GenerateKey()
+			prv1, _  = crypto.GenerateKey()
+			node1    = &discover.Node{ID: discover.PubkeyID(&prv1.PublicKey), IP: net.IP{5, 6, 7, 8}, TCP: 44}
+			fd0, fd1 = net.Pipe()
+			rlpx0    = newRLPX(fd0).(*rlpx)
+			rlpx1    = newRLPX(fd1).(*rlpx)
+			payload  = []interface{}{strings.Repeat("test", 100)}
+			errc     = make(chan error, 1)
+		)
+		go func() {
+			if _, err := rlpx0.doEncHandshake(prv0, node1); err != nil {
+				errc <- err
+				return
+			}
+			if _, err := rlpx0.doProtoHandshake(&protoHandshake{Version: baseProtocolVersion, Caps: tt.dialCaps, ID: discover.PubkeyID(&prv0.PublicKey)}); err != nil {
+				errc <- err
+				return
+			}
+			errc <- Send(rlpx0, 0x10, payload)
+		}()
+		if _, err := rlpx1.doEncHandshake(prv1, nil); err != nil {
+			t.Fatalf("test %d: listen side enc handshake failed: %v", i, err)
+		}
+		if _, err := rlpx1.doProtoHandshake(&protoHandshake{Version: baseProtocolVersion, Caps: tt.listenCaps, ID: node1.ID}); err != nil {
+			t.Fatalf("test %d: listen side proto handshake failed: %v", i, err)
+		}
+		if rlpx1.rw.snappy != tt.snappy {
+			t.Errorf("test %d: compression mismatch: got %v, want %v", i, rlpx1.rw.snappy, tt.snappy)
+		}
+		if err := ExpectMsg(rlpx1, 0x10, payload); err != nil {
+			t.Errorf("test %d: message mismatch: %v", i, err)
+		}
+		if err := <-errc; err != nil {
+			t.Errorf("test %d: dial side error: %v", i, err)
+		}
+		fd0.Close()
+		fd1.Close()
+	}
+}
+
+// This test checks that a baseline version 4 peer, which rejects any other
+// version and any extra handshake fields, accepts our protocol handshake.
+func TestProtocolHandshakeLegacy(t *testing.T) {
+	srv := &Server{PrivateKey: newkey(), MaxPeers: 10, NoDial: true, Protocols: []Protocol{discard}}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	p1, p2 := MsgPipe()
+	defer p1.Close()
+	go Send(p1, handshakeMsg, srv.ourHandshake)
+
+	legacy := &protoHandshake{Version: 4, Caps: []Cap{discard.cap()}}
+	hs, err := readProtocolHandshake(p2, legacy)
+	if err != nil {
+		t.Fatalf("legacy peer rejected handshake: %v", err)
+	}
+	if !hasCap(hs.Caps, discard.cap()) || !hasCap(hs.Caps, snappyCap) {
+		t.Errorf("advertised capabilities mismatch: have %v", hs.Caps)
+	}
+}
+
 func TestProtocolHandshakeErrors(t *testing.T) {
 	our := &protoHandshake{Version: 3, Caps: []Cap{{"foo", 2}, {"bar", 3}}, Name: "quux"}
 	id := randomID()
@@ -307,6 +389,52 @@ ba628a4ba590cb43f7848f41c4382885
 	}
 }
 
+func TestRLPXFrameSnappy(t *testing.T) {
+	buf := new(bytes.Buffer)
+	hash := fakeHash([]byte{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1})
+	rw := newRLPXFrameRW(buf, secrets{
+		AES:        crypto.Sha3(),
+		MAC:        crypto.Sha3(),
+		IngressMAC: hash,
+		EgressMAC:  hash,
+	})
+	rw.snappy = true
+
+	// Compressible messages are sent compressed and read back in full.
+	wmsg := []interface{}{strings.Repeat("test", 1000)}
+	want, _ := rlp.EncodeToBytes(wmsg)
+	if err := Send(rw, 8, wmsg); err != nil {
+		t.Fatalf("WriteMsg error: %v", err)
+	}
+	if buf.Len() >= len(want) {
+		t.Errorf("message not compressed: %d bytes written for %d byte payload", buf.Len(), len(want))
+	}
+	msg, err := rw.ReadMsg()
+	if err != nil {
+		t.Fatalf("ReadMsg error: %v", err)
+	}
+	if msg.Size != uint32(len(want)) {
+		t.Errorf("msg size mismatch: got %d, want %d", msg.Size, len(want))
+	}
+	if payload, _ := ioutil.ReadAll(msg.Payload); !bytes.Equal(payload, want) {
+		t.Errorf("msg payload mismatch:\ngot  %x\nwant %x", payload, want)
+	}
+
+	// Messages decompressing beyond the limit are rejected without
+	// being decompressed. The snappy header is just the decoded length.
+	header := make([]byte, binary.MaxVarintLen64)
+	header = header[:binary.PutUvarint(header, uint64(maxDecompressedSize+1))]
+
+	rw.snappy = false
+	if err := rw.WriteMsg(Msg{Code: 8, Size: uint32(len(header)), Payload: bytes.NewReader(header)}); err != nil {
+		t.Fatalf("WriteMsg error: %v", err)
+	}
+	rw.snappy = true
+	if _, err := rw.ReadMsg(); err == nil {
+		t.Errorf("oversized message accepted")
+	}
+}
+
 type fakeHash []byte
 
 func (fakeHash) Write(p []byte) (int, error) { return len(p), nil }
@@ -22,12 +22,14 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/krypton/go-krypton/logger"
 	"github.com/krypton/go-krypton/logger/glog"
 	"github.com/krypton/go-krypton/p2p/discover"
+	"github.com/krypton/go-krypton/p2p/dnsdisc"
 	"github.com/krypton/go-krypton/p2p/nat"
 )
 
@@ -92,6 +94,17 @@ type Server struct {
 	// allowed to connect, even above the peer limit.
 	TrustedNodes []*discover.Node
 
+	// DNSDiscovery lists the URLs of signed node trees published
+	// in DNS (enodetree://<public key>@<domain>). Their nodes are
+	// dialed like the ones found by the discovery mechanism.
+	DNSDiscovery []string
+
+	// DiscoveryTopics lists the topics (protocol names) to search
+	// the discovery network for. Every other discovery lookup finds
+	// nodes advertising one of them instead of random nodes. The
+	// server itself advertises the names of all its protocols.
+	DiscoveryTopics []string
+
 	// NodeDatabase is the path to the database containing the previously seen
 	// live nodes in the network.
 	NodeDatabase string
@@ -125,11 +138,13 @@ type Server struct {
 	// the whole protocol stack.
 	newTransport func(net.Conn) transport
 	newPeerHook  func(*Peer)
+	dnsResolver  dnsdisc.Resolver
 
 	lock    sync.Mutex // protects running
 	running bool
 
 	ntab         discoverTable
+	dnsClient    *dnsdisc.Client
 	listener     net.Listener
 	ourHandshake *protoHandshake
 	lastLookup   time.Time
@@ -335,19 +350,37 @@ func (srv *Server) Start() (err error) {
 			return err
 		}
 		srv.ntab = ntab
+
+		// advertise the protocols we run
+		for _, topic := range srv.protocolTopics() {
+			go ntab.RegisterTopic(topic, srv.quit)
+		}
+	}
+
+	// DNS node trees
+	if len(srv.DNSDiscovery) > 0 {
+		if srv.dnsResolver == nil {
+			srv.dnsResolver = dnsdisc.DefaultResolver
+		}
+		srv.dnsClient = dnsdisc.NewClient(srv.dnsResolver)
 	}
 
 	dynPeers := srv.MaxPeers / 2
-	if !srv.Discovery {
+	if !srv.Discovery && len(srv.DNSDiscovery) == 0 {
 		dynPeers = 0
 	}
 	dialer := newDialState(srv.StaticNodes, srv.ntab, dynPeers)
+	dialer.dnsTrees = srv.DNSDiscovery
+	for _, topic := range srv.DiscoveryTopics {
+		dialer.topics = append(dialer.topics, discover.Topic(topic))
+	}
 
 	// handshake
 	srv.ourHandshake = &protoHandshake{Version: baseProtocolVersion, Name: srv.Name, ID: discover.PubkeyID(&srv.PrivateKey.PublicKey)}
 	for _, p := range srv.Protocols {
 		srv.ourHandshake.Caps = append(srv.ourHandshake.Caps, p.cap())
 	}
+	srv.ourHandshake.Caps = append(srv.ourHandshake.Caps, snappyCap)
 	// listen/dial
 	if srv.ListenAddr != "" {
 		if err := srv.startListening(); err != nil {
@@ -364,6 +397,20 @@ func (srv *Server) Start() (err error) {
 	return nil
 }
 
+// protocolTopics returns the discovery topics of the protocols we run. Each name
+// is advertised once, even if several versions of the protocol are run.
+func (srv *Server) protocolTopics() []discover.Topic {
+	var topics []discover.Topic
+	seen := make(map[string]bool)
+	for _, p := range srv.Protocols {
+		if !seen[p.Name] {
+			seen[p.Name] = true
+			topics = append(topics, discover.Topic(p.Name))
+		}
+	}
+	return topics
+}
+
 func (srv *Server) startListening() error {
 	// Launch the TCP listener.
 	listener, err := net.Listen("tcp", srv.ListenAddr)
@@ -597,7 +644,7 @@ func (srv *Server) listenLoop() {
 // setupConn runs the handshakes and attempts to add the connection
 // as a peer. It returns when the connection has been added as a peer
 // or the handshakes have failed.
-func (srv *Server) setupConn(fd net.Conn, flags connFlag, dialDest *discover.Node) {
+func (srv *Server) setupConn(fd net.Conn, flags connFlag, dialDest *discover.Node) error {
 	// Prevent leftover pending conns from entering the handshake.
 	srv.lock.Lock()
 	running := srv.running
@@ -605,46 +652,60 @@ func (srv *Server) setupConn(fd net.Conn, flags connFlag, dialDest *discover.Nod
 	c := &conn{fd: fd, transport: srv.newTransport(fd), flags: flags, cont: make(chan error)}
 	if !running {
 		c.close(errServerStopped)
-		return
+		return errServerStopped
 	}
 	// Run the encryption handshake.
 	var err error
 	if c.id, err = c.doEncHandshake(srv.PrivateKey, dialDest); err != nil {
 		glog.V(logger.Debug).Infof("%v faild enc handshake: %v", c, err)
 		c.close(err)
-		return
+		return err
 	}
 	// For dialed connections, check that the remote public key matches.
 	if dialDest != nil && c.id != dialDest.ID {
 		c.close(DiscUnexpectedIdentity)
 		glog.V(logger.Debug).Infof("%v dialed identity mismatch, want %x", c, dialDest.ID[:8])
-		return
+		return DiscUnexpectedIdentity
 	}
 	if err := srv.checkpoint(c, srv.posthandshake); err != nil {
 		glog.V(logger.Debug).Infof("%v failed checkpoint posthandshake: %v", c, err)
 		c.close(err)
-		return
+		return err
 	}
 	// Run the protocol handshake
 	phs, err := c.doProtoHandshake(srv.ourHandshake)
 	if err != nil {
 		glog.V(logger.Debug).Infof("%v failed proto handshake: %v", c, err)
 		c.close(err)
-		return
+		return err
 	}
 	if phs.ID != c.id {
 		glog.V(logger.Debug).Infof("%v wrong proto handshake identity: %x", c, phs.ID[:8])
 		c.close(DiscUnexpectedIdentity)
-		return
+		return DiscUnexpectedIdentity
 	}
 	c.caps, c.name = phs.Caps, phs.Name
 	if err := srv.checkpoint(c, srv.addpeer); err != nil {
 		glog.V(logger.Debug).Infof("%v failed checkpoint addpeer: %v", c, err)
 		c.close(err)
-		return
+		return err
 	}
 	// If the checks completed successfully, runPeer has now been
 	// launched by run.
+	return nil
+}
+
+// SetupConn runs the handshakes on a connection established outside of the
+// server (e.g. over an in-memory pipe) and adds it as a peer if successful.
+// For outbound connections dialDest is the node the connection was made to,
+// for inbound ones it is nil. The peer has been added once SetupConn returns
+// without an error.
+func (srv *Server) SetupConn(fd net.Conn, dialDest *discover.Node) error {
+	flags := inboundConn
+	if dialDest != nil {
+		flags = dynDialedConn
+	}
+	return srv.setupConn(fd, flags, dialDest)
 }
 
 // checkpoint sends the conn to run, which performs the
@@ -752,3 +813,16 @@ func (srv *Server) PeersInfo() []*PeerInfo {
 	}
 	return infos
 }
+
+// PeersStats returns the traffic statistics of the connected peers, ordered
+// by the total amount of bytes exchanged, highest first.
+func (srv *Server) PeersStats() []*PeerStats {
+	stats := make([]*PeerStats, 0, srv.PeerCount())
+	for _, peer := range srv.Peers() {
+		if peer != nil {
+			stats = append(stats, peer.Stats())
+		}
+	}
+	sort.Sort(peerStatsByTraffic(stats))
+	return stats
+}
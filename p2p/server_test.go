@@ -303,6 +303,24 @@ func TestServerAtCap(t *testing.T) {
 
 }
 
+// Tests that protocols run in several versions advertise their discovery topic
+// only once.
+func TestServerProtocolTopics(t *testing.T) {
+	srv := &Server{
+		Protocols: []Protocol{
+			{Name: "kr", Version: 63},
+			{Name: "shh", Version: 3},
+			{Name: "kr", Version: 62},
+			{Name: "shh", Version: 2},
+			{Name: "kr", Version: 61},
+		},
+	}
+	want := []discover.Topic{"kr", "shh"}
+	if topics := srv.protocolTopics(); !reflect.DeepEqual(topics, want) {
+		t.Errorf("topics mismatch: have %v, want %v", topics, want)
+	}
+}
+
 func TestServerSetupConn(t *testing.T) {
 	id := randomID()
 	srvkey := newkey()
@@ -0,0 +1,428 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package simulations runs networks of in-process p2p servers for testing.
+//
+// All nodes of a simulated network are full p2p.Server instances running the
+// real encryption and protocol handshakes, but they are linked by in-memory
+// connections instead of sockets. Links can be given latency and packet loss,
+// and the topology can be changed while the network is running by connecting,
+// disconnecting and partitioning nodes. Nothing touches the network.
+package simulations
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krypton/go-krypton/crypto"
+	"github.com/krypton/go-krypton/p2p"
+	"github.com/krypton/go-krypton/p2p/discover"
+)
+
+const (
+	defaultMaxPeers   = 25
+	defaultRetransmit = 200 * time.Millisecond
+
+	// Interval at which the network state is polled while waiting.
+	pollInterval = 10 * time.Millisecond
+)
+
+var (
+	errSelfConnect  = errors.New("node can't connect to itself")
+	errConnected    = errors.New("nodes already connected")
+	errNotConnected = errors.New("nodes not connected")
+	errPartitioned  = errors.New("nodes are partitioned")
+	errWaitTimeout  = errors.New("timed out waiting for network condition")
+)
+
+// Config contains the parameters of a simulated network.
+type Config struct {
+	Nodes int // Number of nodes in the network
+
+	// Protocols returns the protocols run by the node with the given
+	// index. It is called once for every node, before it is started.
+	Protocols func(index int) []p2p.Protocol
+
+	MaxPeers int // Maximum number of peers of each node, defaults to 25
+
+	Latency    time.Duration // One-way latency of every link
+	Loss       float64       // Probability of a write being lost (0..1)
+	Retransmit time.Duration // Delay of lost writes until retransmission, defaults to 200ms
+
+	Seed int64 // Seed of the random loss of links, for reproducible runs
+}
+
+// Node is a single node of a simulated network.
+type Node struct {
+	Index  int
+	ID     discover.NodeID
+	Server *p2p.Server
+}
+
+// link is a connection between two nodes.
+type link struct {
+	conn *linkConn // Either end, closing it shuts down the link
+}
+
+// linkKey identifies a link by the indexes of its nodes, lower one first.
+type linkKey [2]int
+
+func makeLinkKey(a, b int) linkKey {
+	if a > b {
+		a, b = b, a
+	}
+	return linkKey{a, b}
+}
+
+// Network is a running simulated network.
+type Network struct {
+	config Config
+	nodes  []*Node
+	index  map[discover.NodeID]int
+
+	lock   sync.Mutex
+	links  map[linkKey]*link
+	groups map[int]int // Partition group of each node, nil if not partitioned
+	rand   *rand.Rand
+}
+
+// New creates and starts a simulated network. The nodes are not connected to
+// each other, use Connect or ConnectTopology to link them.
+func New(config Config) (*Network, error) {
+	if config.MaxPeers == 0 {
+		config.MaxPeers = defaultMaxPeers
+	}
+	if config.Retransmit == 0 {
+		config.Retransmit = defaultRetransmit
+	}
+	network := &Network{
+		config: config,
+		index:  make(map[discover.NodeID]int),
+		links:  make(map[linkKey]*link),
+		rand:   rand.New(rand.NewSource(config.Seed)),
+	}
+	for i := 0; i < config.Nodes; i++ {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			network.Stop()
+			return nil, err
+		}
+		srv := &p2p.Server{
+			PrivateKey: key,
+			Name:       fmt.Sprintf("sim-node-%d", i),
+			MaxPeers:   config.MaxPeers,
+			NoDial:     true,
+		}
+		if config.Protocols != nil {
+			srv.Protocols = config.Protocols(i)
+		}
+		if err := srv.Start(); err != nil {
+			network.Stop()
+			return nil, fmt.Errorf("node %d: %v", i, err)
+		}
+		node := &Node{Index: i, ID: discover.PubkeyID(&key.PublicKey), Server: srv}
+		network.nodes = append(network.nodes, node)
+		network.index[node.ID] = i
+	}
+	return network, nil
+}
+
+// Stop shuts down all the nodes of the network.
+func (self *Network) Stop() {
+	self.lock.Lock()
+	for key, link := range self.links {
+		link.conn.Close()
+		delete(self.links, key)
+	}
+	self.lock.Unlock()
+
+	for _, node := range self.nodes {
+		node.Server.Stop()
+	}
+}
+
+// Nodes returns all the nodes of the network.
+func (self *Network) Nodes() []*Node {
+	return self.nodes
+}
+
+// Node returns the node with the given index.
+func (self *Network) Node(index int) *Node {
+	return self.nodes[index]
+}
+
+// Lookup returns the index of the node with the given ID, -1 if unknown.
+func (self *Network) Lookup(id discover.NodeID) int {
+	if index, ok := self.index[id]; ok {
+		return index
+	}
+	return -1
+}
+
+// Connect links node a to node b, a dialing b. It returns when both nodes
+// have completed the handshakes and added each other as peers.
+func (self *Network) Connect(a, b int) error {
+	if a == b {
+		return errSelfConnect
+	}
+	key := makeLinkKey(a, b)
+
+	self.lock.Lock()
+	if link, ok := self.links[key]; ok && !link.conn.isClosed() {
+		self.lock.Unlock()
+		return errConnected
+	}
+	if self.groups != nil && self.groups[a] != self.groups[b] {
+		self.lock.Unlock()
+		return errPartitioned
+	}
+	config := linkConfig{latency: self.config.Latency, loss: self.config.Loss, retransmit: self.config.Retransmit}
+	dialer, listener := newLink(config, self.rand.Int63(), fmt.Sprintf("node-%d", a), fmt.Sprintf("node-%d", b))
+	self.links[key] = &link{conn: dialer}
+	self.lock.Unlock()
+
+	// Run the handshakes on both ends
+	errc := make(chan error, 1)
+	go func() {
+		errc <- self.nodes[b].Server.SetupConn(listener, nil)
+	}()
+	err := self.nodes[a].Server.SetupConn(dialer, &discover.Node{ID: self.nodes[b].ID})
+	if lerr := <-errc; err == nil {
+		err = lerr
+	}
+	if err != nil {
+		self.drop(key, dialer)
+		return fmt.Errorf("connecting node %d to %d: %v", a, b, err)
+	}
+	return nil
+}
+
+// Disconnect shuts down the link between two nodes. It returns when both
+// nodes have dropped each other as peers.
+func (self *Network) Disconnect(a, b int) error {
+	key := makeLinkKey(a, b)
+
+	self.lock.Lock()
+	link, ok := self.links[key]
+	self.lock.Unlock()
+	if !ok {
+		return errNotConnected
+	}
+	self.drop(key, link.conn)
+
+	_, err := self.WaitFor(func(s *Snapshot) bool { return !s.Linked(a, b) }, 5*time.Second)
+	return err
+}
+
+// drop closes a link and forgets about it, unless it was replaced already.
+func (self *Network) drop(key linkKey, conn *linkConn) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	conn.Close()
+	if link, ok := self.links[key]; ok && link.conn == conn {
+		delete(self.links, key)
+	}
+}
+
+// Partition splits the network into groups of nodes. Links between nodes
+// of different groups are shut down and can't be established until the
+// partition is healed. Nodes not listed in any group form one together.
+func (self *Network) Partition(groups ...[]int) {
+	self.lock.Lock()
+	self.groups = make(map[int]int)
+	for i := range self.nodes {
+		self.groups[i] = -1
+	}
+	for group, nodes := range groups {
+		for _, node := range nodes {
+			self.groups[node] = group
+		}
+	}
+	var cut []linkKey
+	for key, link := range self.links {
+		if self.groups[key[0]] != self.groups[key[1]] {
+			link.conn.Close()
+			delete(self.links, key)
+			cut = append(cut, key)
+		}
+	}
+	self.lock.Unlock()
+
+	self.WaitFor(func(s *Snapshot) bool {
+		for _, key := range cut {
+			if s.Linked(key[0], key[1]) {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second)
+}
+
+// Heal removes the partitioning of the network. Links shut down by the
+// partition are not reestablished.
+func (self *Network) Heal() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.groups = nil
+}
+
+// ConnectTopology links the nodes of the network according to a topology.
+func (self *Network) ConnectTopology(topology Topology) error {
+	for _, conn := range topology(len(self.nodes)) {
+		if err := self.Connect(conn[0], conn[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Snapshot captures the current peer connections of all nodes.
+func (self *Network) Snapshot() *Snapshot {
+	snap := &Snapshot{Peers: make([][]int, len(self.nodes))}
+	for i, node := range self.nodes {
+		peers := []int{}
+		for _, peer := range node.Server.Peers() {
+			if index := self.Lookup(peer.ID()); index >= 0 {
+				peers = append(peers, index)
+			}
+		}
+		sort.Ints(peers)
+		snap.Peers[i] = peers
+	}
+	return snap
+}
+
+// WaitFor polls the state of the network until the given condition holds
+// or the timeout expires. It returns the last snapshot taken.
+func (self *Network) WaitFor(cond func(*Snapshot) bool, timeout time.Duration) (*Snapshot, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		snap := self.Snapshot()
+		if cond(snap) {
+			return snap, nil
+		}
+		if time.Now().After(deadline) {
+			return snap, errWaitTimeout
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// Snapshot is the state of the peer connections of a network at one point
+// in time.
+type Snapshot struct {
+	Peers [][]int // Indexes of the peers of each node, sorted
+}
+
+// Connected checks whether node a has node b as a peer.
+func (self *Snapshot) Connected(a, b int) bool {
+	i := sort.SearchInts(self.Peers[a], b)
+	return i < len(self.Peers[a]) && self.Peers[a][i] == b
+}
+
+// Linked checks whether node a and node b are peers of each other, or either
+// of them still considers the other a peer.
+func (self *Snapshot) Linked(a, b int) bool {
+	return self.Connected(a, b) || self.Connected(b, a)
+}
+
+// Conns returns the links between the nodes, each listed once with the
+// lower index first. Connections seen only by one side are included too.
+func (self *Snapshot) Conns() [][2]int {
+	var conns [][2]int
+	for a := range self.Peers {
+		for b := a + 1; b < len(self.Peers); b++ {
+			if self.Linked(a, b) {
+				conns = append(conns, [2]int{a, b})
+			}
+		}
+	}
+	return conns
+}
+
+// Matches checks whether the snapshot contains exactly the links of the
+// topology, all of them established on both sides.
+func (self *Snapshot) Matches(topology Topology) bool {
+	want := make(map[linkKey]bool)
+	for _, conn := range topology(len(self.Peers)) {
+		want[makeLinkKey(conn[0], conn[1])] = true
+	}
+	for a := range self.Peers {
+		for b := a + 1; b < len(self.Peers); b++ {
+			both := self.Connected(a, b) && self.Connected(b, a)
+			if both != self.Linked(a, b) || both != want[linkKey{a, b}] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (self *Snapshot) String() string {
+	lines := make([]string, len(self.Peers))
+	for i, peers := range self.Peers {
+		lines[i] = fmt.Sprintf("%d: %v", i, peers)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Topology returns the links of a network with n nodes.
+type Topology func(n int) [][2]int
+
+// Line links every node to the next one.
+func Line(n int) [][2]int {
+	var conns [][2]int
+	for i := 0; i < n-1; i++ {
+		conns = append(conns, [2]int{i, i + 1})
+	}
+	return conns
+}
+
+// Ring links every node to the next one and the last one to the first.
+func Ring(n int) [][2]int {
+	conns := Line(n)
+	if n > 2 {
+		conns = append(conns, [2]int{n - 1, 0})
+	}
+	return conns
+}
+
+// Star links every node to the first one.
+func Star(n int) [][2]int {
+	var conns [][2]int
+	for i := 1; i < n; i++ {
+		conns = append(conns, [2]int{0, i})
+	}
+	return conns
+}
+
+// Full links every node to every other node.
+func Full(n int) [][2]int {
+	var conns [][2]int
+	for a := 0; a < n; a++ {
+		for b := a + 1; b < n; b++ {
+			conns = append(conns, [2]int{a, b})
+		}
+	}
+	return conns
+}
@@ -0,0 +1,199 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/krypton/go-krypton/p2p"
+)
+
+// pingProtocol pings every new peer once, answers the pings of the peers and
+// reports the measured round trip times.
+func pingProtocol(rtts chan<- time.Duration) p2p.Protocol {
+	return p2p.Protocol{
+		Name:    "ping",
+		Version: 1,
+		Length:  2,
+		Run: func(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
+			start := time.Now()
+			if err := p2p.Send(rw, 0, []uint{}); err != nil {
+				return err
+			}
+			for {
+				msg, err := rw.ReadMsg()
+				if err != nil {
+					return err
+				}
+				msg.Discard()
+
+				switch msg.Code {
+				case 0:
+					if err := p2p.Send(rw, 1, []uint{}); err != nil {
+						return err
+					}
+				case 1:
+					rtts <- time.Since(start)
+				}
+			}
+		},
+	}
+}
+
+func newTestNetwork(t *testing.T, config Config) *Network {
+	network, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create network: %v", err)
+	}
+	return network
+}
+
+func TestTopologies(t *testing.T) {
+	tests := []struct {
+		topology Topology
+		conns    [][2]int
+	}{
+		{Line, [][2]int{{0, 1}, {1, 2}, {2, 3}}},
+		{Ring, [][2]int{{0, 1}, {0, 3}, {1, 2}, {2, 3}}},
+		{Star, [][2]int{{0, 1}, {0, 2}, {0, 3}}},
+		{Full, [][2]int{{0, 1}, {0, 2}, {0, 3}, {1, 2}, {1, 3}, {2, 3}}},
+	}
+	for i, tt := range tests {
+		network := newTestNetwork(t, Config{Nodes: 4})
+		if err := network.ConnectTopology(tt.topology); err != nil {
+			t.Fatalf("test %d: failed to connect: %v", i, err)
+		}
+		snap := network.Snapshot()
+		if !snap.Matches(tt.topology) {
+			t.Errorf("test %d: snapshot doesn't match topology:\n%v", i, snap)
+		}
+		if conns := snap.Conns(); !reflect.DeepEqual(conns, tt.conns) {
+			t.Errorf("test %d: connection mismatch: have %v, want %v", i, conns, tt.conns)
+		}
+		network.Stop()
+	}
+}
+
+func TestConnectDisconnect(t *testing.T) {
+	network := newTestNetwork(t, Config{Nodes: 3})
+	defer network.Stop()
+
+	if err := network.Connect(0, 0); err != errSelfConnect {
+		t.Errorf("self connect error mismatch: have %v, want %v", err, errSelfConnect)
+	}
+	if err := network.ConnectTopology(Line); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	if err := network.Connect(1, 0); err != errConnected {
+		t.Errorf("reconnect error mismatch: have %v, want %v", err, errConnected)
+	}
+	if err := network.Disconnect(1, 0); err != nil {
+		t.Fatalf("failed to disconnect: %v", err)
+	}
+	if snap := network.Snapshot(); snap.Linked(0, 1) || !snap.Connected(1, 2) {
+		t.Errorf("unexpected connections after disconnect:\n%v", snap)
+	}
+	if err := network.Disconnect(0, 2); err != errNotConnected {
+		t.Errorf("disconnect error mismatch: have %v, want %v", err, errNotConnected)
+	}
+	// Links dropped by the nodes themselves can be reestablished
+	network.Node(2).Server.Peers()[0].Disconnect(p2p.DiscRequested)
+	if _, err := network.WaitFor(func(s *Snapshot) bool { return !s.Linked(1, 2) }, time.Second); err != nil {
+		t.Fatalf("peer not dropped: %v", err)
+	}
+	if err := network.Connect(2, 1); err != nil {
+		t.Fatalf("failed to reconnect: %v", err)
+	}
+	if snap := network.Snapshot(); !snap.Matches(func(int) [][2]int { return [][2]int{{1, 2}} }) {
+		t.Errorf("unexpected connections after reconnect:\n%v", snap)
+	}
+}
+
+func TestPartition(t *testing.T) {
+	network := newTestNetwork(t, Config{Nodes: 5})
+	defer network.Stop()
+
+	if err := network.ConnectTopology(Full); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	network.Partition([]int{0, 1}, []int{2, 3})
+
+	partitioned := func(int) [][2]int { return [][2]int{{0, 1}, {2, 3}} }
+	if snap := network.Snapshot(); !snap.Matches(partitioned) {
+		t.Errorf("snapshot doesn't match partition:\n%v", snap)
+	}
+	if err := network.Connect(0, 2); err != errPartitioned {
+		t.Errorf("cross partition connect error mismatch: have %v, want %v", err, errPartitioned)
+	}
+	if err := network.Connect(0, 4); err != errPartitioned {
+		t.Errorf("unlisted node connect error mismatch: have %v, want %v", err, errPartitioned)
+	}
+	network.Heal()
+	if err := network.Connect(0, 2); err != nil {
+		t.Errorf("failed to connect after healing: %v", err)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	rtts := make(chan time.Duration, 2)
+	network := newTestNetwork(t, Config{
+		Nodes:     2,
+		Latency:   50 * time.Millisecond,
+		Protocols: func(int) []p2p.Protocol { return []p2p.Protocol{pingProtocol(rtts)} },
+	})
+	defer network.Stop()
+
+	if err := network.Connect(0, 1); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case rtt := <-rtts:
+			if rtt < 100*time.Millisecond {
+				t.Errorf("round trip time too short: %v", rtt)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("ping timeout")
+		}
+	}
+}
+
+func TestLoss(t *testing.T) {
+	rtts := make(chan time.Duration, 2)
+	network := newTestNetwork(t, Config{
+		Nodes:      2,
+		Loss:       0.5,
+		Retransmit: 20 * time.Millisecond,
+		Seed:       1,
+		Protocols:  func(int) []p2p.Protocol { return []p2p.Protocol{pingProtocol(rtts)} },
+	})
+	defer network.Stop()
+
+	// Lost writes are retransmitted, the connection works but slower
+	if err := network.Connect(0, 1); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-rtts:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("ping timeout")
+		}
+	}
+}
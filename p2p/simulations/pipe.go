@@ -0,0 +1,224 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the in-memory connections simulated nodes are linked with.
+
+package simulations
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+var errLinkClosed = errors.New("link closed")
+
+// timeoutError is returned by reads exceeding their deadline.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// linkConfig contains the network conditions of a link.
+type linkConfig struct {
+	latency    time.Duration // One-way delay of every write
+	loss       float64       // Probability of a write being lost and retransmitted
+	retransmit time.Duration // Additional delay of lost writes
+}
+
+// chunk is the data of a single write in transit.
+type chunk struct {
+	data    []byte
+	deliver time.Time // Time the data becomes readable at the other end
+}
+
+// stream is one direction of a link. Writes never block, reads block until
+// data has been delivered. Like TCP, lost writes are not dropped but delayed
+// by retransmission, keeping the stream intact and in order.
+type stream struct {
+	lock     sync.Mutex
+	queue    []*chunk
+	closed   bool
+	deadline time.Time     // Read deadline, zero if none
+	notify   chan struct{} // Wakes up a blocked reader
+
+	config linkConfig
+	rand   *rand.Rand
+}
+
+func newStream(config linkConfig, seed int64) *stream {
+	return &stream{
+		notify: make(chan struct{}, 1),
+		config: config,
+		rand:   rand.New(rand.NewSource(seed)),
+	}
+}
+
+// wakeup notifies a blocked reader of a change. The caller must hold the lock.
+func (s *stream) wakeup() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *stream) write(b []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return 0, errLinkClosed
+	}
+	deliver := time.Now().Add(s.config.latency)
+	if s.config.loss > 0 && s.rand.Float64() < s.config.loss {
+		deliver = deliver.Add(s.config.retransmit)
+	}
+	// Data is delivered in order, even if an earlier write was delayed
+	if n := len(s.queue); n > 0 && deliver.Before(s.queue[n-1].deliver) {
+		deliver = s.queue[n-1].deliver
+	}
+	data := make([]byte, len(b))
+	copy(data, b)
+	s.queue = append(s.queue, &chunk{data: data, deliver: deliver})
+	s.wakeup()
+
+	return len(b), nil
+}
+
+func (s *stream) read(b []byte) (int, error) {
+	for {
+		s.lock.Lock()
+		if s.closed {
+			s.lock.Unlock()
+			return 0, io.EOF
+		}
+		now := time.Now()
+		if !s.deadline.IsZero() && !now.Before(s.deadline) {
+			s.lock.Unlock()
+			return 0, timeoutError{}
+		}
+		// Read from the first chunk if it arrived already
+		var wait time.Duration = -1
+		if len(s.queue) > 0 {
+			head := s.queue[0]
+			if !now.Before(head.deliver) {
+				n := copy(b, head.data)
+				if head.data = head.data[n:]; len(head.data) == 0 {
+					s.queue = s.queue[1:]
+				}
+				s.lock.Unlock()
+				return n, nil
+			}
+			wait = head.deliver.Sub(now)
+		}
+		if !s.deadline.IsZero() && (wait < 0 || s.deadline.Sub(now) < wait) {
+			wait = s.deadline.Sub(now)
+		}
+		s.lock.Unlock()
+
+		// Wait for new data, a state change or the next delivery
+		if wait < 0 {
+			<-s.notify
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-s.notify:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+func (s *stream) setDeadline(t time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.deadline = t
+	s.wakeup()
+}
+
+func (s *stream) isClosed() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.closed
+}
+
+func (s *stream) close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	s.queue = nil
+	s.wakeup()
+}
+
+// linkAddr is the address of a simulated node.
+type linkAddr string
+
+func (a linkAddr) Network() string { return "sim" }
+func (a linkAddr) String() string  { return string(a) }
+
+// linkConn is one end of a link, implementing net.Conn.
+type linkConn struct {
+	in, out       *stream
+	local, remote net.Addr
+}
+
+// newLink creates a pair of connected in-memory connections, delivering data
+// according to the given network conditions.
+func newLink(config linkConfig, seed int64, local, remote string) (*linkConn, *linkConn) {
+	ab, ba := newStream(config, seed), newStream(config, seed+1)
+	return &linkConn{in: ba, out: ab, local: linkAddr(local), remote: linkAddr(remote)},
+		&linkConn{in: ab, out: ba, local: linkAddr(remote), remote: linkAddr(local)}
+}
+
+func (c *linkConn) Read(b []byte) (int, error)  { return c.in.read(b) }
+func (c *linkConn) Write(b []byte) (int, error) { return c.out.write(b) }
+func (c *linkConn) LocalAddr() net.Addr         { return c.local }
+func (c *linkConn) RemoteAddr() net.Addr        { return c.remote }
+
+// isClosed checks whether the link was shut down by either end.
+func (c *linkConn) isClosed() bool {
+	return c.in.isClosed() || c.out.isClosed()
+}
+
+// Close shuts down both directions of the link.
+func (c *linkConn) Close() error {
+	c.in.close()
+	c.out.close()
+	return nil
+}
+
+func (c *linkConn) SetDeadline(t time.Time) error {
+	c.in.setDeadline(t)
+	return nil
+}
+
+func (c *linkConn) SetReadDeadline(t time.Time) error {
+	c.in.setDeadline(t)
+	return nil
+}
+
+// SetWriteDeadline is a noop, writes never block.
+func (c *linkConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -50,6 +50,7 @@ var (
 	AdminMapping = map[string]adminhandler{
 		"admin_addPeer":            (*adminApi).AddPeer,
 		"admin_peers":              (*adminApi).Peers,
+		"admin_peerStats":          (*adminApi).PeerStats,
 		"admin_nodeInfo":           (*adminApi).NodeInfo,
 		"admin_exportChain":        (*adminApi).ExportChain,
 		"admin_importChain":        (*adminApi).ImportChain,
@@ -72,6 +73,20 @@ var (
 		"admin_sleep":              (*adminApi).Sleep,
 		"admin_enableUserAgent":    (*adminApi).EnableUserAgent,
 	}
+
+	// methods exempt from the request deadline: chain imports and exports, sleeps
+	// and registrar transactions awaiting the sender's signature
+	adminUntimed = map[string]bool{
+		"admin_exportChain":        true,
+		"admin_importChain":        true,
+		"admin_setGlobalRegistrar": true,
+		"admin_setHashReg":         true,
+		"admin_setUrlHint":         true,
+		"admin_register":           true,
+		"admin_registerUrl":        true,
+		"admin_sleepBlocks":        true,
+		"admin_sleep":              true,
+	}
 )
 
 // admin callback handler
@@ -123,6 +138,11 @@ func (self *adminApi) ApiVersion() string {
 	return AdminApiversion
 }
 
+// Untimed reports whether the given method is exempt from the request deadline
+func (self *adminApi) Untimed(method string) bool {
+	return adminUntimed[method]
+}
+
 func (self *adminApi) AddPeer(req *shared.Request) (interface{}, error) {
 	args := new(AddPeerArgs)
 	if err := self.coder.Decode(req.Params, &args); err != nil {
@@ -140,6 +160,10 @@ func (self *adminApi) Peers(req *shared.Request) (interface{}, error) {
 	return self.krypton.Network().PeersInfo(), nil
 }
 
+func (self *adminApi) PeerStats(req *shared.Request) (interface{}, error) {
+	return self.krypton.Network().PeersStats(), nil
+}
+
 func (self *adminApi) NodeInfo(req *shared.Request) (interface{}, error) {
 	return self.krypton.Network().NodeInfo(), nil
 }
@@ -16,6 +16,8 @@
 
 package api
 
+import "github.com/krypton/go-krypton/rpc/shared"
+
 const Admin_JS = `
 web3._extend({
 	property: 'admin',
@@ -140,6 +142,10 @@ web3._extend({
 			name: 'peers',
 			getter: 'admin_peers'
 		}),
+		new web3._extend.Property({
+			name: 'peerStats',
+			getter: 'admin_peerStats'
+		}),
 		new web3._extend.Property({
 			name: 'datadir',
 			getter: 'admin_datadir'
@@ -147,3 +153,29 @@ web3._extend({
 	]
 });
 `
+
+// Admin_Docs documents the admin API in the console.
+var Admin_Docs = []shared.MethodDoc{
+	{Name: "addPeer", Params: []string{"url"}, Description: "Connects to the node with the given enode URL."},
+	{Name: "datadir", Property: true, Description: "Data directory of the node."},
+	{Name: "exportChain", Params: []string{"file"}, Description: "Exports the blockchain to the given file."},
+	{Name: "getContractInfo", Params: []string{"address"}, Description: "Returns the contract info registered for the contract at address."},
+	{Name: "httpGet", Params: []string{"uri", "path?"}, Description: "Downloads the content at uri, optionally saving it to path."},
+	{Name: "importChain", Params: []string{"file"}, Description: "Imports a blockchain from the given file."},
+	{Name: "nodeInfo", Property: true, Description: "Information about the running node."},
+	{Name: "peerStats", Property: true, Description: "Traffic statistics of the connected peers, by protocol and message code."},
+	{Name: "peers", Property: true, Description: "Information about the connected peers."},
+	{Name: "register", Params: []string{"sender", "address", "contentHash"}, Description: "Registers the content hash of a contract's info."},
+	{Name: "registerUrl", Params: []string{"sender", "contentHash", "url"}, Description: "Registers the url a content hash can be downloaded from."},
+	{Name: "saveInfo", Params: []string{"contractInfo", "file"}, Description: "Saves contract info to file and returns its content hash."},
+	{Name: "setGlobalRegistrar", Params: []string{"nameReg", "address?"}, Description: "Sets or deploys the global registrar."},
+	{Name: "setHashReg", Params: []string{"hashReg", "sender"}, Description: "Sets or deploys the hash registrar."},
+	{Name: "setSolc", Params: []string{"path"}, Description: "Sets the path of the Solidity compiler."},
+	{Name: "setUrlHint", Params: []string{"urlHint", "sender"}, Description: "Sets or deploys the url hint registrar."},
+	{Name: "sleepBlocks", Params: []string{"n", "timeout?"}, Description: "Waits until n new blocks are imported or the timeout (seconds) expires."},
+	{Name: "startNatSpec", Description: "Enables NatSpec transaction confirmations."},
+	{Name: "startRPC", Params: []string{"host?", "port?", "corsDomain?", "apis?"}, Description: "Starts the HTTP RPC server."},
+	{Name: "stopNatSpec", Description: "Disables NatSpec transaction confirmations."},
+	{Name: "stopRPC", Description: "Stops the HTTP RPC server."},
+	{Name: "verbosity", Params: []string{"level"}, Description: "Sets the logging verbosity (0-6)."},
+}
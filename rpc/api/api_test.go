@@ -21,6 +21,7 @@ import (
 
 	"encoding/json"
 	"strconv"
+	"strings"
 
 	"github.com/krypton/go-krypton/common/compiler"
 	"github.com/krypton/go-krypton/kr"
@@ -168,3 +169,59 @@ func TestCompileSolidity(t *testing.T) {
 		t.Errorf("Expected %s got %s", expDeveloperDoc, string(devdoc))
 	}
 }
+
+func TestMethodDocs(t *testing.T) {
+	apis, err := ParseApiString(shared.AllApis, codec.JSON, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create apis: %v", err)
+	}
+	res, err := Merge(apis...).Execute(&shared.Request{Method: "methodDocs"})
+	if err != nil {
+		t.Fatalf("methodDocs failed: %v", err)
+	}
+	docs, ok := res.(map[string][]shared.MethodDoc)
+	if !ok {
+		t.Fatalf("unexpected methodDocs result type %T", res)
+	}
+	for _, name := range strings.Split(shared.AllApis, ",") {
+		if len(docs[name]) == 0 {
+			t.Errorf("api %s is not documented", name)
+		}
+		for _, doc := range docs[name] {
+			if doc.Name == "" || doc.Description == "" {
+				t.Errorf("api %s: incomplete documentation %+v", name, doc)
+			}
+		}
+	}
+	// Methods of the api's extending web3.js must be defined by their extension
+	for _, name := range []string{shared.AdminApiName, shared.DebugApiName, shared.MinerApiName, shared.MultisigApiName, shared.PersonalApiName, shared.TxPoolApiName} {
+		for _, doc := range docs[name] {
+			if !strings.Contains(Javascript(name), "name: '"+doc.Name+"'") {
+				t.Errorf("%s documented but not defined", doc.Signature(name))
+			}
+		}
+	}
+}
+
+// Tests that the methods exempt from the request deadline are declared by the
+// API providing them and exposed through the merged API.
+func TestUntimedMethods(t *testing.T) {
+	apis, err := ParseApiString(shared.AllApis, codec.JSON, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create apis: %v", err)
+	}
+	merged, ok := Merge(apis...).(shared.UntimedApi)
+	if !ok {
+		t.Fatalf("merged api doesn't declare untimed methods")
+	}
+	for _, method := range []string{"admin_sleep", "debug_traceBadBlock", "eth_sendTransaction", "multisig_confirm", "personal_deriveAccount", "personal_sendTransaction", "personal_sign"} {
+		if !merged.Untimed(method) {
+			t.Errorf("%s: not untimed", method)
+		}
+	}
+	for _, method := range []string{"eth_getLogs", "eth_getFilterLogs", "personal_listAccounts", "shh_post"} {
+		if merged.Untimed(method) {
+			t.Errorf("%s: untimed", method)
+		}
+	}
+}
@@ -21,8 +21,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"strings"
 	"testing"
 
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/crypto"
 	"github.com/krypton/go-krypton/rpc/shared"
 )
 
@@ -2034,6 +2037,151 @@ func TestWhisperFilterArgsToMissing(t *testing.T) {
 	}
 }
 
+func TestWhisperFilterArgsSymKeyID(t *testing.T) {
+	input := `[{"topics": ["0x68656c6c6f20776f726c64"], "symKeyID": "0x2b1ec6d8a3c6a2e4a7a85e54f1d9e1f8"}]`
+
+	args := new(WhisperFilterArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Error(err)
+	}
+
+	if args.SymKeyID != "0x2b1ec6d8a3c6a2e4a7a85e54f1d9e1f8" {
+		t.Errorf("SymKeyID shoud be %v but is %v", "0x2b1ec6d8a3c6a2e4a7a85e54f1d9e1f8", args.SymKeyID)
+	}
+}
+
+func TestWhisperFilterArgsSymKeyIDInt(t *testing.T) {
+	input := `[{"symKeyID": 2}]`
+
+	args := new(WhisperFilterArgs)
+	str := ExpectInvalidTypeError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestWhisperSymKeyArgs(t *testing.T) {
+	input := `["0x2b1ec6d8a3c6a2e4a7a85e54f1d9e1f8"]`
+
+	args := new(WhisperSymKeyArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Error(err)
+	}
+
+	if args.Key != "0x2b1ec6d8a3c6a2e4a7a85e54f1d9e1f8" {
+		t.Errorf("Key shoud be %v but is %v", "0x2b1ec6d8a3c6a2e4a7a85e54f1d9e1f8", args.Key)
+	}
+}
+
+func TestWhisperSymKeyArgsEmpty(t *testing.T) {
+	input := `[]`
+
+	args := new(WhisperSymKeyArgs)
+	str := ExpectInsufficientParamsError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestWhisperMailRequestArgs(t *testing.T) {
+	input := `[{"peer": "0x34ag445g3455b34", "from": 1440000000, "to": "0x55d4a800", "topics": ["0x68656c6c6f20776f726c64"]}]`
+
+	args := new(WhisperMailRequestArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Error(err)
+	}
+
+	if args.Peer != "0x34ag445g3455b34" {
+		t.Errorf("Peer shoud be %v but is %v", "0x34ag445g3455b34", args.Peer)
+	}
+	if args.From != 1440000000 {
+		t.Errorf("From shoud be %v but is %v", 1440000000, args.From)
+	}
+	if args.To != 1440000000 {
+		t.Errorf("To shoud be %v but is %v", 1440000000, args.To)
+	}
+	if len(args.Topics) != 1 || args.Topics[0] != "0x68656c6c6f20776f726c64" {
+		t.Errorf("Topics shoud be %v but is %v", []string{"0x68656c6c6f20776f726c64"}, args.Topics)
+	}
+}
+
+func TestWhisperMailRequestArgsPeerMissing(t *testing.T) {
+	input := `[{"from": 1440000000}]`
+
+	args := new(WhisperMailRequestArgs)
+	str := ExpectInvalidTypeError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestWhisperIdentityPassArgs(t *testing.T) {
+	input := `["0x04abcd", "secret"]`
+
+	args := new(WhisperIdentityPassArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Error(err)
+	}
+
+	if args.Identity != "0x04abcd" {
+		t.Errorf("Identity shoud be %v but is %v", "0x04abcd", args.Identity)
+	}
+	if args.Passphrase != "secret" {
+		t.Errorf("Passphrase shoud be %v but is %v", "secret", args.Passphrase)
+	}
+}
+
+func TestWhisperIdentityPassArgsPassphraseMissing(t *testing.T) {
+	input := `["0x04abcd"]`
+
+	args := new(WhisperIdentityPassArgs)
+	str := ExpectInsufficientParamsError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestWhisperNewIdentityArgs(t *testing.T) {
+	args := new(WhisperNewIdentityArgs)
+	if err := json.Unmarshal([]byte(`[]`), &args); err != nil {
+		t.Error(err)
+	}
+	if args.Passphrase != nil {
+		t.Errorf("Passphrase shoud be nil but is %v", *args.Passphrase)
+	}
+
+	args = new(WhisperNewIdentityArgs)
+	if err := json.Unmarshal([]byte(`["secret"]`), &args); err != nil {
+		t.Error(err)
+	}
+	if args.Passphrase == nil || *args.Passphrase != "secret" {
+		t.Errorf("Passphrase shoud be %v but is %v", "secret", args.Passphrase)
+	}
+}
+
+func TestWhisperTopicInterestArgs(t *testing.T) {
+	input := `[["0x68656c6c6f", "0x776f726c64"]]`
+
+	args := new(WhisperTopicInterestArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Error(err)
+	}
+
+	if len(args.Topics) != 2 || args.Topics[0] != "0x68656c6c6f" || args.Topics[1] != "0x776f726c64" {
+		t.Errorf("Topics shoud be %v but is %v", []string{"0x68656c6c6f", "0x776f726c64"}, args.Topics)
+	}
+}
+
+func TestWhisperTopicInterestArgsInvalid(t *testing.T) {
+	input := `[[6]]`
+
+	args := new(WhisperTopicInterestArgs)
+	str := ExpectInvalidTypeError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
 func TestWhisperFilterArgsTopicInt(t *testing.T) {
 	input := `[{"topics": [6], "to": "0x34ag445g3455b34"}]`
 
@@ -2647,3 +2795,220 @@ func TestDataArgsInvalid(t *testing.T) {
 		t.Error(str)
 	}
 }
+
+func TestNewWalletArgs(t *testing.T) {
+	input := `["secret", "m/44'/60'/1'/0"]`
+
+	args := new(NewWalletArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Fatal(err)
+	}
+	if args.Passphrase != "secret" {
+		t.Errorf("Passphrase shoud be %v but is %v", "secret", args.Passphrase)
+	}
+	if args.BasePath.String() != "m/44'/60'/1'/0" {
+		t.Errorf("BasePath shoud be %v but is %v", "m/44'/60'/1'/0", args.BasePath)
+	}
+}
+
+func TestNewWalletArgsInvalidPath(t *testing.T) {
+	input := `["secret", "m/44'/x"]`
+
+	args := new(NewWalletArgs)
+	str := ExpectValidationError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestImportMnemonicArgs(t *testing.T) {
+	input := `["abandon about", "secret"]`
+
+	args := new(ImportMnemonicArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Fatal(err)
+	}
+	if args.Mnemonic != "abandon about" {
+		t.Errorf("Mnemonic shoud be %v but is %v", "abandon about", args.Mnemonic)
+	}
+	if args.Passphrase != "secret" {
+		t.Errorf("Passphrase shoud be %v but is %v", "secret", args.Passphrase)
+	}
+	if args.BasePath != nil {
+		t.Errorf("BasePath shoud be nil but is %v", args.BasePath)
+	}
+}
+
+func TestDeriveAccountArgsPassphraseMissing(t *testing.T) {
+	input := `["1f0d1e2c-7a44-4c4e-9a0e-2b1a6b1f4c11"]`
+
+	args := new(DeriveAccountArgs)
+	str := ExpectInsufficientParamsError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestSendTxWithPassphraseArgs(t *testing.T) {
+	input := `[{"from": "0xb60e8dd61c5d32be8058bb8eb970870f07233155", "to": "0xd46e8dd67c5d32be8058bb8eb970870f072445675", "value": "0x9184e72a"}, "secret"]`
+
+	args := new(SendTxWithPassphraseArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Fatal(err)
+	}
+	if args.Tx.From != "0xb60e8dd61c5d32be8058bb8eb970870f07233155" {
+		t.Errorf("From shoud be %v but is %v", "0xb60e8dd61c5d32be8058bb8eb970870f07233155", args.Tx.From)
+	}
+	if args.Tx.Value.Cmp(big.NewInt(0x9184e72a)) != 0 {
+		t.Errorf("Value shoud be %v but is %v", 0x9184e72a, args.Tx.Value)
+	}
+	if args.Passphrase != "secret" {
+		t.Errorf("Passphrase shoud be %v but is %v", "secret", args.Passphrase)
+	}
+}
+
+func TestSendTxWithPassphraseArgsPassphraseMissing(t *testing.T) {
+	input := `[{"from": "0xb60e8dd61c5d32be8058bb8eb970870f07233155"}]`
+
+	args := new(SendTxWithPassphraseArgs)
+	str := ExpectInsufficientParamsError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestSendTxWithPassphraseArgsFromMissing(t *testing.T) {
+	input := `[{"to": "0xd46e8dd67c5d32be8058bb8eb970870f072445675"}, "secret"]`
+
+	args := new(SendTxWithPassphraseArgs)
+	str := ExpectValidationError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestSignArgs(t *testing.T) {
+	input := `["0xdeadbeef", "0xb60e8dd61c5d32be8058bb8eb970870f07233155", "secret"]`
+
+	args := new(SignArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Fatal(err)
+	}
+	if args.Data != "0xdeadbeef" {
+		t.Errorf("Data shoud be %v but is %v", "0xdeadbeef", args.Data)
+	}
+	if args.Address != "0xb60e8dd61c5d32be8058bb8eb970870f07233155" {
+		t.Errorf("Address shoud be %v but is %v", "0xb60e8dd61c5d32be8058bb8eb970870f07233155", args.Address)
+	}
+}
+
+func TestSignArgsInvalidAddress(t *testing.T) {
+	input := `["0xdeadbeef", "0xb60e8dd6", "secret"]`
+
+	args := new(SignArgs)
+	str := ExpectValidationError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestImportRawKeyArgsPassphraseMissing(t *testing.T) {
+	input := `["0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"]`
+
+	args := new(ImportRawKeyArgs)
+	str := ExpectInsufficientParamsError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func newMultisigProposeInput(t *testing.T, threshold int) string {
+	identity, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cosigner, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf(`[{
+		"identity": "0x%x",
+		"cosigners": ["0x%x"],
+		"from": "0xb60e8dd61c5d32be8058bb8eb970870f07233155",
+		"wallet": "0xd46e8dd67c5d32be8058bb8eb970870f07244567",
+		"value": "0x9184e72a",
+		"data": "0xdeadbeef",
+		"signers": ["0xb60e8dd61c5d32be8058bb8eb970870f07233155", "0xf466859ead1932d743d622cb74fc058882e8648a"],
+		"threshold": %d
+	}]`, crypto.FromECDSAPub(&identity.PublicKey), crypto.FromECDSAPub(&cosigner.PublicKey), threshold)
+}
+
+func TestMultisigProposeArgs(t *testing.T) {
+	args := new(MultisigProposeArgs)
+	if err := json.Unmarshal([]byte(newMultisigProposeInput(t, 2)), &args); err != nil {
+		t.Fatal(err)
+	}
+	if args.Identity == nil || len(args.Cosigners) != 1 {
+		t.Errorf("Identities not decoded: identity %v, cosigners %v", args.Identity, args.Cosigners)
+	}
+	if args.From != common.HexToAddress("0xb60e8dd61c5d32be8058bb8eb970870f07233155") {
+		t.Errorf("From shoud be %v but is %x", "0xb60e8dd61c5d32be8058bb8eb970870f07233155", args.From)
+	}
+	if args.Value.Cmp(big.NewInt(0x9184e72a)) != 0 {
+		t.Errorf("Value shoud be %v but is %v", 0x9184e72a, args.Value)
+	}
+	if !bytes.Equal(args.Data, common.FromHex("0xdeadbeef")) {
+		t.Errorf("Data shoud be %v but is %x", "0xdeadbeef", args.Data)
+	}
+	if len(args.Signers) != 2 || args.Threshold != 2 {
+		t.Errorf("Signers shoud be 2 of 2 but is %d of %d", args.Threshold, len(args.Signers))
+	}
+	if args.Gas != nil || args.GasPrice != nil {
+		t.Errorf("Gas and GasPrice shoud be unset but are %v and %v", args.Gas, args.GasPrice)
+	}
+}
+
+func TestMultisigProposeArgsInvalidIdentity(t *testing.T) {
+	input := `[{"identity": "0xdeadbeef", "cosigners": ["0xdeadbeef"], "signers": ["0xb60e8dd61c5d32be8058bb8eb970870f07233155"], "threshold": 1}]`
+
+	args := new(MultisigProposeArgs)
+	str := ExpectValidationError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestMultisigProposeArgsThresholdMissing(t *testing.T) {
+	input := strings.Replace(newMultisigProposeInput(t, 2), `"threshold": 2`, `"threshold": null`, 1)
+
+	args := new(MultisigProposeArgs)
+	str := ExpectValidationError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestMultisigConfirmArgs(t *testing.T) {
+	input := `["0x6a5e5c1e5d8a8fdd7e1c94f8ae8d5b5a7a3c7d84e3e7e8b6b1a2d1e6f8b9c0d1", "0xb60e8dd61c5d32be8058bb8eb970870f07233155"]`
+
+	args := new(MultisigConfirmArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Fatal(err)
+	}
+	if args.Hash != common.HexToHash("0x6a5e5c1e5d8a8fdd7e1c94f8ae8d5b5a7a3c7d84e3e7e8b6b1a2d1e6f8b9c0d1") {
+		t.Errorf("Hash shoud be %v but is %x", "0x6a5e5c1e5d8a8fdd7e1c94f8ae8d5b5a7a3c7d84e3e7e8b6b1a2d1e6f8b9c0d1", args.Hash)
+	}
+	if args.Signer != common.HexToAddress("0xb60e8dd61c5d32be8058bb8eb970870f07233155") {
+		t.Errorf("Signer shoud be %v but is %x", "0xb60e8dd61c5d32be8058bb8eb970870f07233155", args.Signer)
+	}
+}
+
+func TestMultisigConfirmArgsSignerMissing(t *testing.T) {
+	input := `["0x6a5e5c1e5d8a8fdd7e1c94f8ae8d5b5a7a3c7d84e3e7e8b6b1a2d1e6f8b9c0d1"]`
+
+	args := new(MultisigConfirmArgs)
+	str := ExpectInsufficientParamsError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
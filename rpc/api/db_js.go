@@ -16,6 +16,8 @@
 
 package api
 
+import "github.com/krypton/go-krypton/rpc/shared"
+
 const Db_JS = `
 web3._extend({
 	property: 'db',
@@ -27,3 +29,11 @@ web3._extend({
 	]
 });
 `
+
+// Db_Docs documents the db API in the console.
+var Db_Docs = []shared.MethodDoc{
+	{Name: "getHex", Params: []string{"db", "key"}, Description: "Returns hex data stored under key in the local database."},
+	{Name: "getString", Params: []string{"db", "key"}, Description: "Returns the string stored under key in the local database."},
+	{Name: "putHex", Params: []string{"db", "key", "value"}, Description: "Stores hex data under key in the local database."},
+	{Name: "putString", Params: []string{"db", "key", "value"}, Description: "Stores a string under key in the local database."},
+}
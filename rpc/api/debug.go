@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/krypton/krash"
+	"github.com/krypton/go-krypton/common"
 	"github.com/krypton/go-krypton/core"
 	"github.com/krypton/go-krypton/core/state"
 	"github.com/krypton/go-krypton/core/vm"
@@ -40,13 +41,22 @@ const (
 var (
 	// mapping between methods and handlers
 	DebugMapping = map[string]debughandler{
-		"debug_dumpBlock":    (*debugApi).DumpBlock,
-		"debug_getBlockRlp":  (*debugApi).GetBlockRlp,
-		"debug_printBlock":   (*debugApi).PrintBlock,
-		"debug_processBlock": (*debugApi).ProcessBlock,
-		"debug_seedHash":     (*debugApi).SeedHash,
-		"debug_setHead":      (*debugApi).SetHead,
-		"debug_metrics":      (*debugApi).Metrics,
+		"debug_dumpBlock":     (*debugApi).DumpBlock,
+		"debug_getBlockRlp":   (*debugApi).GetBlockRlp,
+		"debug_printBlock":    (*debugApi).PrintBlock,
+		"debug_processBlock":  (*debugApi).ProcessBlock,
+		"debug_seedHash":      (*debugApi).SeedHash,
+		"debug_setHead":       (*debugApi).SetHead,
+		"debug_metrics":       (*debugApi).Metrics,
+		"debug_getBadBlocks":  (*debugApi).GetBadBlocks,
+		"debug_traceBadBlock": (*debugApi).TraceBadBlock,
+	}
+
+	// methods exempt from the request deadline: state dumps and block reprocessing
+	debugUntimed = map[string]bool{
+		"debug_dumpBlock":     true,
+		"debug_processBlock":  true,
+		"debug_traceBadBlock": true,
 	}
 )
 
@@ -99,6 +109,11 @@ func (self *debugApi) ApiVersion() string {
 	return DebugApiVersion
 }
 
+// Untimed reports whether the given method is exempt from the request deadline
+func (self *debugApi) Untimed(method string) bool {
+	return debugUntimed[method]
+}
+
 func (self *debugApi) PrintBlock(req *shared.Request) (interface{}, error) {
 	args := new(BlockNumArg)
 	if err := self.codec.Decode(req.Params, &args); err != nil {
@@ -193,6 +208,76 @@ func (self *debugApi) ProcessBlock(req *shared.Request) (interface{}, error) {
 	return true, nil
 }
 
+func (self *debugApi) GetBadBlocks(req *shared.Request) (interface{}, error) {
+	bad := self.krypton.BlockChain().BadBlocks()
+
+	blocks := make([]*BadBlockRes, len(bad))
+	for i, block := range bad {
+		blocks[i] = NewBadBlockRes(block)
+	}
+	return blocks, nil
+}
+
+// TraceBadBlock re-executes the transactions of a stored rejected block on
+// top of its parent state, returning the VM trace of each. Execution stops at
+// the first transaction that cannot be applied.
+func (self *debugApi) TraceBadBlock(req *shared.Request) (interface{}, error) {
+	args := new(HashArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+
+	blockchain := self.krypton.BlockChain()
+	bad := blockchain.GetBadBlock(common.HexToHash(args.Hash))
+	if bad == nil {
+		return nil, fmt.Errorf("bad block %s not found", args.Hash)
+	}
+	block := bad.Block
+
+	parent := blockchain.GetBlock(block.ParentHash())
+	if parent == nil {
+		return nil, fmt.Errorf("parent block %x not found", block.ParentHash())
+	}
+	statedb, err := state.New(parent.Root(), self.krypton.ChainDb())
+	if err != nil {
+		return nil, err
+	}
+
+	old := vm.Debug
+	defer func() { vm.Debug = old }()
+	vm.Debug = true
+
+	var (
+		header = block.Header()
+		gp     = new(core.GasPool).AddGas(block.GasLimit())
+		traces = make([]*TransactionTraceRes, 0, len(block.Transactions()))
+	)
+	for i, tx := range block.Transactions() {
+		statedb.StartRecord(tx.Hash(), block.Hash(), i)
+
+		env := core.NewEnv(statedb, blockchain, tx, header)
+		_, gas, failed, err := core.ApplyMessage(env, tx, gp)
+
+		trace := &TransactionTraceRes{
+			TransactionHash: newHexData(tx.Hash()),
+			GasUsed:         newHexNum(gas),
+			Failed:          failed,
+			StructLogs:      make([]*StructLogRes, len(env.StructLogs())),
+		}
+		for j, log := range env.StructLogs() {
+			trace.StructLogs[j] = NewStructLogRes(log)
+		}
+		if err != nil {
+			trace.Error = err.Error()
+			traces = append(traces, trace)
+			break
+		}
+		statedb.IntermediateRoot()
+		traces = append(traces, trace)
+	}
+	return traces, nil
+}
+
 func (self *debugApi) SeedHash(req *shared.Request) (interface{}, error) {
 	args := new(BlockNumArg)
 	if err := self.codec.Decode(req.Params, &args); err != nil {
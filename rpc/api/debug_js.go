@@ -16,6 +16,8 @@
 
 package api
 
+import "github.com/krypton/go-krypton/rpc/shared"
+
 const Debug_JS = `
 web3._extend({
 	property: 'debug',
@@ -62,6 +64,18 @@ web3._extend({
 			call: 'debug_metrics',
 			params: 1,
 			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'getBadBlocks',
+			call: 'debug_getBadBlocks',
+			params: 0,
+			inputFormatter: []
+		}),
+		new web3._extend.Method({
+			name: 'traceBadBlock',
+			call: 'debug_traceBadBlock',
+			params: 1,
+			inputFormatter: [null]
 		})
 	],
 	properties:
@@ -69,3 +83,16 @@ web3._extend({
 	]
 });
 `
+
+// Debug_Docs documents the debug API in the console.
+var Debug_Docs = []shared.MethodDoc{
+	{Name: "dumpBlock", Params: []string{"number"}, Description: "Dumps the state of all accounts at the given block."},
+	{Name: "getBadBlocks", Description: "Returns the most recently rejected blocks that passed their seal verification, with the reason of their rejection."},
+	{Name: "getBlockRlp", Params: []string{"number"}, Description: "Returns the RLP encoding of the given block."},
+	{Name: "metrics", Params: []string{"raw?"}, Description: "Returns the collected metrics, unformatted if raw is true."},
+	{Name: "printBlock", Params: []string{"number"}, Description: "Returns a human readable dump of the given block."},
+	{Name: "processBlock", Params: []string{"number"}, Description: "Re-processes the given block and returns the VM trace."},
+	{Name: "seedHash", Params: []string{"number"}, Description: "Returns the PoW seed hash of the given block."},
+	{Name: "setHead", Params: []string{"number"}, Description: "Rewinds the blockchain to the given block."},
+	{Name: "traceBadBlock", Params: []string{"hash"}, Description: "Re-executes the given rejected block and returns the VM trace of its transactions."},
+}
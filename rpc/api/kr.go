@@ -59,6 +59,7 @@ var (
 		"eth_mining":                              (*krApi).IsMining,
 		"eth_syncing":                             (*krApi).IsSyncing,
 		"eth_gasPrice":                            (*krApi).GasPrice,
+		"eth_gasPriceDistribution":                (*krApi).GasPriceDistribution,
 		"eth_getStorage":                          (*krApi).GetStorage,
 		"eth_storageAt":                           (*krApi).GetStorage,
 		"eth_getStorageAt":                        (*krApi).GetStorageAt,
@@ -103,6 +104,16 @@ var (
 		"eth_pendingTransactions":                 (*krApi).PendingTransactions,
 		"eth_getTransactionReceipt":               (*krApi).GetTransactionReceipt,
 	}
+
+	// methods exempt from the request deadline, as signing may wait for a
+	// passphrase prompt or an external signer's approval
+	krUntimed = map[string]bool{
+		"eth_sign":            true,
+		"eth_sendTransaction": true,
+		"eth_signTransaction": true,
+		"eth_transact":        true,
+		"eth_resend":          true,
+	}
 )
 
 // create new krApi instance
@@ -138,6 +149,11 @@ func (self *krApi) ApiVersion() string {
 	return KrApiVersion
 }
 
+// Untimed reports whether the given method is exempt from the request deadline
+func (self *krApi) Untimed(method string) bool {
+	return krUntimed[method]
+}
+
 func (self *krApi) Accounts(req *shared.Request) (interface{}, error) {
 	return self.xkr.Accounts(), nil
 }
@@ -188,6 +204,10 @@ func (self *krApi) GasPrice(req *shared.Request) (interface{}, error) {
 	return newHexNum(self.xkr.DefaultGasPrice().Bytes()), nil
 }
 
+func (self *krApi) GasPriceDistribution(req *shared.Request) (interface{}, error) {
+	return NewGasPriceDistributionRes(self.xkr.GasPriceDistribution()), nil
+}
+
 func (self *krApi) GetStorage(req *shared.Request) (interface{}, error) {
 	args := new(GetStorageArgs)
 	if err := self.codec.Decode(req.Params, &args); err != nil {
@@ -314,19 +334,7 @@ func (self *krApi) SignTransaction(req *shared.Request) (interface{}, error) {
 		return nil, shared.NewDecodeParamError(err.Error())
 	}
 
-	// nonce may be nil ("guess" mode)
-	var nonce string
-	if args.Nonce != nil {
-		nonce = args.Nonce.String()
-	}
-
-	var gas, price string
-	if args.Gas != nil {
-		gas = args.Gas.String()
-	}
-	if args.GasPrice != nil {
-		price = args.GasPrice.String()
-	}
+	nonce, gas, price := args.optionalQuantities()
 	tx, err := self.xkr.SignTransaction(args.From, args.To, nonce, args.Value.String(), gas, price, args.Data)
 	if err != nil {
 		return nil, err
@@ -346,19 +354,7 @@ func (self *krApi) SendTransaction(req *shared.Request) (interface{}, error) {
 		return nil, shared.NewDecodeParamError(err.Error())
 	}
 
-	// nonce may be nil ("guess" mode)
-	var nonce string
-	if args.Nonce != nil {
-		nonce = args.Nonce.String()
-	}
-
-	var gas, price string
-	if args.Gas != nil {
-		gas = args.Gas.String()
-	}
-	if args.GasPrice != nil {
-		price = args.GasPrice.String()
-	}
+	nonce, gas, price := args.optionalQuantities()
 	v, err := self.xkr.Transact(args.From, args.To, nonce, args.Value.String(), gas, price, args.Data)
 	if err != nil {
 		return nil, err
@@ -379,17 +375,16 @@ func (self *krApi) GetNatSpec(req *shared.Request) (interface{}, error) {
 }
 
 func (self *krApi) EstimateGas(req *shared.Request) (interface{}, error) {
-	_, gas, err := self.doCall(req.Params)
-	if err != nil {
-		return nil, err
+	args := new(CallArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
 	}
 
-	// TODO unwrap the parent method's ToHex call
-	if len(gas) == 0 {
-		return newHexNum(0), nil
-	} else {
-		return newHexNum(common.String2Big(gas)), err
+	gas, err := self.xkr.EstimateGas(args.From, args.To, args.Value.String(), args.Gas.String(), args.GasPrice.String(), args.Data)
+	if err != nil {
+		return nil, err
 	}
+	return newHexNum(common.String2Big(gas)), nil
 }
 
 func (self *krApi) Call(req *shared.Request) (interface{}, error) {
@@ -613,7 +608,7 @@ func (self *krApi) GetFilterLogs(req *shared.Request) (interface{}, error) {
 		return nil, shared.NewDecodeParamError(err.Error())
 	}
 
-	return NewLogsRes(self.xkr.Logs(args.Id)), nil
+	return NewLogsRes(self.xkr.Logs(args.Id, req.Cancel)), nil
 }
 
 func (self *krApi) GetLogs(req *shared.Request) (interface{}, error) {
@@ -621,7 +616,7 @@ func (self *krApi) GetLogs(req *shared.Request) (interface{}, error) {
 	if err := self.codec.Decode(req.Params, &args); err != nil {
 		return nil, shared.NewDecodeParamError(err.Error())
 	}
-	return NewLogsRes(self.xkr.AllLogs(args.Earliest, args.Latest, args.Skip, args.Max, args.Address, args.Topics)), nil
+	return NewLogsRes(self.xkr.AllLogs(args.Earliest, args.Latest, args.Skip, args.Max, args.Address, args.Topics, req.Cancel)), nil
 }
 
 func (self *krApi) GetWork(req *shared.Request) (interface{}, error) {
@@ -439,6 +439,22 @@ func (args *NewTxArgs) UnmarshalJSON(b []byte) (err error) {
 	return nil
 }
 
+// optionalQuantities returns the nonce, gas and gas price as expected by xkr,
+// empty if they are to be filled in by the node. The nonce may be missing to
+// use the next one of the sender ("guess" mode).
+func (args *NewTxArgs) optionalQuantities() (nonce, gas, price string) {
+	if args.Nonce != nil {
+		nonce = args.Nonce.String()
+	}
+	if args.Gas != nil {
+		gas = args.Gas.String()
+	}
+	if args.GasPrice != nil {
+		price = args.GasPrice.String()
+	}
+	return nonce, gas, price
+}
+
 type SourceArgs struct {
 	Source string
 }
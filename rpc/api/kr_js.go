@@ -16,6 +16,8 @@
 
 package api
 
+import "github.com/krypton/go-krypton/rpc/shared"
+
 // JS api provided by web3.js
 // eth_sign not standard
 
@@ -60,7 +62,53 @@ web3._extend({
 		new web3._extend.Property({
 			name: 'pendingTransactions',
 			getter: 'eth_pendingTransactions'
+		}),
+		new web3._extend.Property({
+			name: 'gasPriceDistribution',
+			getter: 'eth_gasPriceDistribution'
 		})
 	]
 });
 `
+
+// Kr_Docs documents the kr API in the console.
+var Kr_Docs = []shared.MethodDoc{
+	{Name: "accounts", Property: true, Description: "Addresses of the accounts owned by the node."},
+	{Name: "blockNumber", Property: true, Description: "Number of the most recent block."},
+	{Name: "call", Params: []string{"transaction", "block?"}, Description: "Executes a message call without creating a transaction and returns its output."},
+	{Name: "coinbase", Property: true, Description: "Address mining rewards are credited to."},
+	{Name: "compile.lll", Params: []string{"source"}, Description: "Compiles LLL source code."},
+	{Name: "compile.serpent", Params: []string{"source"}, Description: "Compiles Serpent source code."},
+	{Name: "compile.solidity", Params: []string{"source"}, Description: "Compiles Solidity source code."},
+	{Name: "contract", Params: []string{"abi"}, Description: "Creates a contract object for the given ABI."},
+	{Name: "defaultAccount", Property: true, Description: "Sender used by transactions that don't specify one."},
+	{Name: "defaultBlock", Property: true, Description: "Block used by calls that don't specify one."},
+	{Name: "estimateGas", Params: []string{"transaction"}, Description: "Estimates the gas the transaction needs to succeed."},
+	{Name: "filter", Params: []string{"options", "callback?"}, Description: "Installs a filter for logs, 'latest' blocks or 'pending' transactions."},
+	{Name: "gasPrice", Property: true, Description: "Current gas price suggested by the node."},
+	{Name: "gasPriceDistribution", Property: true, Description: "Lowest gas prices paid in recent blocks, with their percentiles, for choosing a gas price."},
+	{Name: "getBalance", Params: []string{"address", "block?"}, Description: "Returns the balance of the account at the given block."},
+	{Name: "getBlock", Params: []string{"hashOrNumber", "fullTransactions?"}, Description: "Returns the block with the given hash or number."},
+	{Name: "getBlockTransactionCount", Params: []string{"hashOrNumber"}, Description: "Returns the number of transactions in the given block."},
+	{Name: "getBlockUncleCount", Params: []string{"hashOrNumber"}, Description: "Returns the number of uncles in the given block."},
+	{Name: "getCode", Params: []string{"address", "block?"}, Description: "Returns the code of the contract at the given block."},
+	{Name: "getCompilers", Description: "Returns the available compilers."},
+	{Name: "getNatSpec", Params: []string{"transaction"}, Description: "Returns the NatSpec notice of the transaction."},
+	{Name: "getStorageAt", Params: []string{"address", "position", "block?"}, Description: "Returns a storage slot of the contract at the given block."},
+	{Name: "getTransaction", Params: []string{"hash"}, Description: "Returns the transaction with the given hash."},
+	{Name: "getTransactionCount", Params: []string{"address", "block?"}, Description: "Returns the number of transactions sent from the account."},
+	{Name: "getTransactionFromBlock", Params: []string{"hashOrNumber", "index"}, Description: "Returns a transaction by its position in a block."},
+	{Name: "getTransactionReceipt", Params: []string{"hash"}, Description: "Returns the receipt of the transaction with the given hash."},
+	{Name: "getUncle", Params: []string{"hashOrNumber", "index", "fullTransactions?"}, Description: "Returns an uncle by its position in a block."},
+	{Name: "hashrate", Property: true, Description: "Hashes per second the node is mining with."},
+	{Name: "mining", Property: true, Description: "Whether the node is mining."},
+	{Name: "namereg", Property: true, Description: "The name registrar contract."},
+	{Name: "pendingTransactions", Property: true, Description: "Pending transactions sent from the node's accounts."},
+	{Name: "resend", Params: []string{"transaction", "gasPrice?", "gasLimit?"}, Description: "Replaces a pending transaction with new gas settings."},
+	{Name: "sendRawTransaction", Params: []string{"data"}, Description: "Submits a signed, RLP encoded transaction."},
+	{Name: "sendTransaction", Params: []string{"transaction"}, Description: "Signs and submits a transaction from an unlocked account."},
+	{Name: "sign", Params: []string{"address", "data"}, Description: "Signs data with the key of an unlocked account."},
+	{Name: "signTransaction", Params: []string{"transaction"}, Description: "Signs a transaction without submitting it."},
+	{Name: "submitTransaction", Params: []string{"transaction"}, Description: "Submits a signed transaction."},
+	{Name: "syncing", Property: true, Description: "Synchronisation progress, or false if not syncing."},
+}
@@ -30,6 +30,7 @@ const (
 type MergedApi struct {
 	apis    map[string]string
 	methods map[string]shared.KryptonApi
+	untimed map[string]bool
 }
 
 // create new merged api instance
@@ -37,11 +38,16 @@ func newMergedApi(apis ...shared.KryptonApi) *MergedApi {
 	mergedApi := new(MergedApi)
 	mergedApi.apis = make(map[string]string, len(apis))
 	mergedApi.methods = make(map[string]shared.KryptonApi)
+	mergedApi.untimed = make(map[string]bool)
 
 	for _, api := range apis {
 		mergedApi.apis[api.Name()] = api.ApiVersion()
+		untimed, _ := api.(shared.UntimedApi)
 		for _, method := range api.Methods() {
 			mergedApi.methods[method] = api
+			if untimed != nil && untimed.Untimed(method) {
+				mergedApi.untimed[method] = true
+			}
 		}
 	}
 	return mergedApi
@@ -69,6 +75,12 @@ func (self *MergedApi) Execute(req *shared.Request) (interface{}, error) {
 	return nil, shared.NewNotImplementedError(req.Method)
 }
 
+// Untimed reports whether the given method is exempt from the request deadline,
+// as declared by the API providing it
+func (self *MergedApi) Untimed(method string) bool {
+	return self.untimed[method]
+}
+
 func (self *MergedApi) Name() string {
 	return shared.MergedApiName
 }
@@ -81,6 +93,16 @@ func (self *MergedApi) handle(req *shared.Request) (interface{}, error) {
 	if req.Method == "modules" { // provided API's
 		return self.apis, nil
 	}
+	if req.Method == "methodDocs" { // console documentation of the provided API's
+		docs := make(map[string][]shared.MethodDoc, len(self.apis))
+		for name, _ := range self.apis {
+			if doc := Docs(name); doc != nil {
+				docs[name] = doc
+			}
+		}
+		docs[shared.Web3ApiName] = Web3_Docs
+		return docs, nil
+	}
 
 	return nil, nil
 }
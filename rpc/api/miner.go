@@ -41,6 +41,11 @@ var (
 		"miner_stopAutoDAG":  (*minerApi).StopAutoDAG,
 		"miner_stop":         (*minerApi).StopMiner,
 	}
+
+	// methods exempt from the request deadline: DAG generation
+	minerUntimed = map[string]bool{
+		"miner_makeDAG": true,
+	}
 )
 
 // miner callback handler
@@ -90,6 +95,11 @@ func (self *minerApi) ApiVersion() string {
 	return MinerApiVersion
 }
 
+// Untimed reports whether the given method is exempt from the request deadline
+func (self *minerApi) Untimed(method string) bool {
+	return minerUntimed[method]
+}
+
 func (self *minerApi) StartMiner(req *shared.Request) (interface{}, error) {
 	args := new(StartMinerArgs)
 	if err := self.codec.Decode(req.Params, &args); err != nil {
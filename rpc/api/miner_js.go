@@ -16,6 +16,8 @@
 
 package api
 
+import "github.com/krypton/go-krypton/rpc/shared"
+
 const Miner_JS = `
 web3._extend({
 	property: 'miner',
@@ -81,3 +83,16 @@ web3._extend({
 	]
 });
 `
+
+// Miner_Docs documents the miner API in the console.
+var Miner_Docs = []shared.MethodDoc{
+	{Name: "hashrate", Property: true, Description: "Hashes per second the miner achieves."},
+	{Name: "makeDAG", Params: []string{"blockNumber"}, Description: "Generates the mining DAG for the given block."},
+	{Name: "setExtra", Params: []string{"extra"}, Description: "Sets the extra data included in mined blocks."},
+	{Name: "setGasPrice", Params: []string{"price"}, Description: "Sets the minimum gas price of transactions to mine."},
+	{Name: "setKryptonbase", Params: []string{"address"}, Description: "Sets the address mining rewards are credited to."},
+	{Name: "start", Params: []string{"threads?"}, Description: "Starts mining with the given number of threads."},
+	{Name: "startAutoDAG", Description: "Starts automatically pre-generating the next mining DAG."},
+	{Name: "stop", Description: "Stops mining."},
+	{Name: "stopAutoDAG", Description: "Stops automatic mining DAG generation."},
+}
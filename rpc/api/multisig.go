@@ -0,0 +1,254 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"github.com/krypton/go-krypton/accounts/multisig"
+	"github.com/krypton/go-krypton/kr"
+	"github.com/krypton/go-krypton/rpc/codec"
+	"github.com/krypton/go-krypton/rpc/shared"
+	"github.com/krypton/go-krypton/xkr"
+)
+
+const (
+	MultisigApiVersion = "1.0"
+)
+
+var (
+	// mapping between methods and handlers
+	multisigMapping = map[string]multisighandler{
+		"multisig_propose":     (*multisigApi).Propose,
+		"multisig_confirm":     (*multisigApi).Confirm,
+		"multisig_submit":      (*multisigApi).Submit,
+		"multisig_proposals":   (*multisigApi).Proposals,
+		"multisig_getProposal": (*multisigApi).GetProposal,
+	}
+
+	// methods exempt from the request deadline, as signing may wait for a
+	// passphrase prompt or an external signer's approval
+	multisigUntimed = map[string]bool{
+		"multisig_propose": true,
+		"multisig_confirm": true,
+		"multisig_submit":  true,
+	}
+)
+
+// multisig callback handler
+type multisighandler func(*multisigApi, *shared.Request) (interface{}, error)
+
+// multisig api provider
+type multisigApi struct {
+	xkr     *xkr.XKr
+	krypton *kr.Krypton
+	methods map[string]multisighandler
+	codec   codec.ApiCoder
+}
+
+// create a new multisig api instance
+func NewMultisigApi(xkr *xkr.XKr, kr *kr.Krypton, coder codec.Codec) *multisigApi {
+	return &multisigApi{
+		xkr:     xkr,
+		krypton: kr,
+		methods: multisigMapping,
+		codec:   coder.New(nil),
+	}
+}
+
+// collection with supported methods
+func (self *multisigApi) Methods() []string {
+	methods := make([]string, len(self.methods))
+	i := 0
+	for k := range self.methods {
+		methods[i] = k
+		i++
+	}
+	return methods
+}
+
+// Execute given request
+func (self *multisigApi) Execute(req *shared.Request) (interface{}, error) {
+	if callback, ok := self.methods[req.Method]; ok {
+		return callback(self, req)
+	}
+
+	return nil, shared.NewNotImplementedError(req.Method)
+}
+
+func (self *multisigApi) Name() string {
+	return shared.MultisigApiName
+}
+
+func (self *multisigApi) ApiVersion() string {
+	return MultisigApiVersion
+}
+
+// Untimed reports whether the given method is exempt from the request deadline
+func (self *multisigApi) Untimed(method string) bool {
+	return multisigUntimed[method]
+}
+
+// coordinator returns the multisig coordinator of the node, which only runs
+// if whisper is enabled.
+func (self *multisigApi) coordinator(req *shared.Request) (*multisig.Coordinator, error) {
+	if c := self.krypton.Multisig(); c != nil {
+		return c, nil
+	}
+	return nil, newWhisperOfflineError(req.Method)
+}
+
+func (self *multisigApi) Propose(req *shared.Request) (interface{}, error) {
+	args := new(MultisigProposeArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+	c, err := self.coordinator(req)
+	if err != nil {
+		return nil, err
+	}
+	p := &multisig.Proposal{
+		Wallet:    args.Wallet,
+		Nonce:     args.Nonce,
+		Value:     args.Value,
+		Gas:       args.Gas,
+		GasPrice:  args.GasPrice,
+		Data:      args.Data,
+		Signers:   args.Signers,
+		Threshold: args.Threshold,
+		Proposer:  args.From,
+	}
+	if p.Gas == nil {
+		p.Gas = xkr.DefaultGas()
+	}
+	if p.GasPrice == nil {
+		p.GasPrice = self.xkr.DefaultGasPrice()
+	}
+	hash, err := c.Propose(args.Identity, p, args.Cosigners)
+	if err != nil {
+		return nil, err
+	}
+	return hash.Hex(), nil
+}
+
+func (self *multisigApi) Confirm(req *shared.Request) (interface{}, error) {
+	args := new(MultisigConfirmArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+	c, err := self.coordinator(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Confirm(args.Hash, args.Signer); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (self *multisigApi) Submit(req *shared.Request) (interface{}, error) {
+	args := new(MultisigHashArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+	c, err := self.coordinator(req)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := c.Submit(args.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return tx.Hex(), nil
+}
+
+func (self *multisigApi) Proposals(req *shared.Request) (interface{}, error) {
+	c, err := self.coordinator(req)
+	if err != nil {
+		return nil, err
+	}
+	proposals := []*MultisigProposalRes{}
+	for _, status := range c.Proposals() {
+		proposals = append(proposals, newMultisigProposalRes(status))
+	}
+	return proposals, nil
+}
+
+func (self *multisigApi) GetProposal(req *shared.Request) (interface{}, error) {
+	args := new(MultisigHashArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+	c, err := self.coordinator(req)
+	if err != nil {
+		return nil, err
+	}
+	status, err := c.Proposal(args.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return newMultisigProposalRes(status), nil
+}
+
+// MultisigProposalRes is the state of a multisig proposal.
+type MultisigProposalRes struct {
+	Hash      *hexdata   `json:"hash"`
+	Wallet    *hexdata   `json:"wallet"`
+	Nonce     *hexnum    `json:"nonce"`
+	Value     *hexnum    `json:"value"`
+	Gas       *hexnum    `json:"gas"`
+	GasPrice  *hexnum    `json:"gasPrice"`
+	Data      *hexdata   `json:"data"`
+	Call      string     `json:"call"` // decoded call, empty if it can't be decoded
+	Signers   []*hexdata `json:"signers"`
+	Threshold uint64     `json:"threshold"`
+	From      *hexdata   `json:"from"`
+	Own       bool       `json:"own"`
+	Confirmed []*hexdata `json:"confirmed"`
+	Expires   int64      `json:"expires"` // unix time the proposal is dropped unless submitted
+	Error     string     `json:"error,omitempty"`
+}
+
+func newMultisigProposalRes(status *multisig.Status) *MultisigProposalRes {
+	p := status.Proposal
+	res := &MultisigProposalRes{
+		Hash:      newHexData(status.Hash),
+		Wallet:    newHexData(p.Wallet),
+		Nonce:     newHexNum(p.Nonce),
+		Value:     newHexNum(p.Value),
+		Gas:       newHexNum(p.Gas),
+		GasPrice:  newHexNum(p.GasPrice),
+		Data:      newHexData(p.Data),
+		Signers:   []*hexdata{},
+		Threshold: p.Threshold,
+		From:      newHexData(p.Proposer),
+		Own:       status.Own,
+		Confirmed: []*hexdata{},
+		Expires:   status.Expires.Unix(),
+	}
+	if status.Call != nil {
+		res.Call = status.Call.String()
+	}
+	for _, signer := range p.Signers {
+		res.Signers = append(res.Signers, newHexData(signer))
+	}
+	for _, signer := range status.Confirmed {
+		res.Confirmed = append(res.Confirmed, newHexData(signer))
+	}
+	if status.Error != nil {
+		res.Error = status.Error.Error()
+	}
+	return res
+}
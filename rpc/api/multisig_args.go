@@ -0,0 +1,192 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/crypto"
+	"github.com/krypton/go-krypton/rpc/shared"
+)
+
+type MultisigProposeArgs struct {
+	Identity  *ecdsa.PublicKey
+	Cosigners []*ecdsa.PublicKey
+	From      common.Address
+	Wallet    common.Address
+	Nonce     uint64
+	Value     *big.Int
+	Gas       *big.Int // nil if not given
+	GasPrice  *big.Int // nil if not given
+	Data      []byte
+	Signers   []common.Address
+	Threshold uint64
+}
+
+func (args *MultisigProposeArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []struct {
+		Identity  string      `json:"identity"`
+		Cosigners []string    `json:"cosigners"`
+		From      string      `json:"from"`
+		Wallet    string      `json:"wallet"`
+		Nonce     interface{} `json:"nonce"`
+		Value     interface{} `json:"value"`
+		Gas       interface{} `json:"gas"`
+		GasPrice  interface{} `json:"gasPrice"`
+		Data      string      `json:"data"`
+		Signers   []string    `json:"signers"`
+		Threshold interface{} `json:"threshold"`
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return shared.NewDecodeParamError(err.Error())
+	}
+	if len(obj) < 1 {
+		return shared.NewInsufficientParamsError(len(obj), 1)
+	}
+	params := obj[0]
+
+	if args.Identity, err = parsePubkey("identity", params.Identity); err != nil {
+		return err
+	}
+	if len(params.Cosigners) == 0 {
+		return shared.NewValidationError("cosigners", "is required")
+	}
+	for i, cosigner := range params.Cosigners {
+		pub, err := parsePubkey(fmt.Sprintf("cosigners[%d]", i), cosigner)
+		if err != nil {
+			return err
+		}
+		args.Cosigners = append(args.Cosigners, pub)
+	}
+	if !common.IsHexAddress(params.From) {
+		return shared.NewValidationError("from", "not a valid address")
+	}
+	args.From = common.HexToAddress(params.From)
+	if !common.IsHexAddress(params.Wallet) {
+		return shared.NewValidationError("wallet", "not a valid address")
+	}
+	args.Wallet = common.HexToAddress(params.Wallet)
+	if len(params.Signers) == 0 {
+		return shared.NewValidationError("signers", "is required")
+	}
+	for _, signer := range params.Signers {
+		if !common.IsHexAddress(signer) {
+			return shared.NewValidationError("signers", fmt.Sprintf("%q is not a valid address", signer))
+		}
+		args.Signers = append(args.Signers, common.HexToAddress(signer))
+	}
+	if params.Threshold == nil {
+		return shared.NewValidationError("threshold", "is required")
+	}
+	threshold, err := numString(params.Threshold)
+	if err != nil {
+		return shared.NewInvalidTypeError("threshold", "not a number")
+	}
+	args.Threshold = threshold.Uint64()
+
+	args.Value = new(big.Int)
+	if params.Value != nil {
+		if args.Value, err = numString(params.Value); err != nil {
+			return shared.NewInvalidTypeError("value", "not a number")
+		}
+	}
+	if params.Nonce != nil {
+		nonce, err := numString(params.Nonce)
+		if err != nil {
+			return shared.NewInvalidTypeError("nonce", "not a number")
+		}
+		args.Nonce = nonce.Uint64()
+	}
+	if params.Gas != nil {
+		if args.Gas, err = numString(params.Gas); err != nil {
+			return shared.NewInvalidTypeError("gas", "not a number")
+		}
+	}
+	if params.GasPrice != nil {
+		if args.GasPrice, err = numString(params.GasPrice); err != nil {
+			return shared.NewInvalidTypeError("gasPrice", "not a number")
+		}
+	}
+	args.Data = common.FromHex(params.Data)
+
+	return nil
+}
+
+// parsePubkey decodes a hex encoded whisper identity.
+func parsePubkey(name, identity string) (*ecdsa.PublicKey, error) {
+	pub := crypto.ToECDSAPub(common.FromHex(identity))
+	if pub == nil || pub.X == nil {
+		return nil, shared.NewValidationError(name, "not a valid identity")
+	}
+	return pub, nil
+}
+
+type MultisigConfirmArgs struct {
+	Hash   common.Hash
+	Signer common.Address
+}
+
+func (args *MultisigConfirmArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return shared.NewDecodeParamError(err.Error())
+	}
+	if len(obj) < 2 {
+		return shared.NewInsufficientParamsError(len(obj), 2)
+	}
+	hash, ok := obj[0].(string)
+	if !ok {
+		return shared.NewInvalidTypeError("hash", "not a string")
+	}
+	args.Hash = common.HexToHash(hash)
+
+	signer, ok := obj[1].(string)
+	if !ok {
+		return shared.NewInvalidTypeError("signer", "not a string")
+	}
+	if !common.IsHexAddress(signer) {
+		return shared.NewValidationError("signer", "not a valid address")
+	}
+	args.Signer = common.HexToAddress(signer)
+
+	return nil
+}
+
+type MultisigHashArgs struct {
+	Hash common.Hash
+}
+
+func (args *MultisigHashArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return shared.NewDecodeParamError(err.Error())
+	}
+	if len(obj) < 1 {
+		return shared.NewInsufficientParamsError(len(obj), 1)
+	}
+	hash, ok := obj[0].(string)
+	if !ok {
+		return shared.NewInvalidTypeError("hash", "not a string")
+	}
+	args.Hash = common.HexToHash(hash)
+
+	return nil
+}
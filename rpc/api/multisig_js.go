@@ -0,0 +1,64 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import "github.com/krypton/go-krypton/rpc/shared"
+
+const Multisig_JS = `
+web3._extend({
+	property: 'multisig',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'propose',
+			call: 'multisig_propose',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'confirm',
+			call: 'multisig_confirm',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'submit',
+			call: 'multisig_submit',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getProposal',
+			call: 'multisig_getProposal',
+			params: 1
+		})
+	],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'proposals',
+			getter: 'multisig_proposals'
+		})
+	]
+});
+`
+
+// Multisig_Docs documents the multisig API in the console.
+var Multisig_Docs = []shared.MethodDoc{
+	{Name: "confirm", Params: []string{"hash", "signer"}, Description: "Confirms the proposal with the given hash by signing it with the unlocked account of a signer, sending the confirmation to the proposer."},
+	{Name: "getProposal", Params: []string{"hash"}, Description: "Returns the proposal with the given hash, its call decoded with the wallet ABI configured on this node and the confirmations known to this node."},
+	{Name: "proposals", Property: true, Description: "Proposals made by or sent to the whisper identities of this node."},
+	{Name: "propose", Params: []string{"proposal"}, Description: "Sends a call of the multisig wallet proposal.wallet with proposal.data to the whisper identities proposal.cosigners, signed with the identity proposal.identity. Once proposal.threshold of proposal.signers confirmed it, proposal.from submits the call with their signatures appended. Returns the proposal hash."},
+	{Name: "submit", Params: []string{"hash"}, Description: "Submits the own proposal with the given hash once its threshold of confirmations is met, e.g. to retry a failed submission. Returns the transaction hash."},
+}
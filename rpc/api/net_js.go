@@ -16,6 +16,8 @@
 
 package api
 
+import "github.com/krypton/go-krypton/rpc/shared"
+
 const Net_JS = `
 web3._extend({
 	property: 'net',
@@ -37,3 +39,11 @@ web3._extend({
 	]
 });
 `
+
+// Net_Docs documents the net API in the console.
+var Net_Docs = []shared.MethodDoc{
+	{Name: "addPeer", Params: []string{"url"}, Description: "Connects to the node with the given enode URL."},
+	{Name: "listening", Property: true, Description: "Whether the node accepts inbound connections."},
+	{Name: "peerCount", Property: true, Description: "Number of connected peers."},
+	{Name: "version", Property: true, Description: "Network id of the node."},
+}
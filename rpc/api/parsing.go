@@ -22,10 +22,15 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"math/big"
+	"strconv"
 	"strings"
 
 	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/core"
 	"github.com/krypton/go-krypton/core/types"
+	"github.com/krypton/go-krypton/core/vm"
+	"github.com/krypton/go-krypton/kr"
+	"github.com/krypton/go-krypton/rlp"
 	"github.com/krypton/go-krypton/rpc/shared"
 )
 
@@ -165,6 +170,7 @@ type BlockRes struct {
 	BlockHash       *hexdata          `json:"hash"`
 	ParentHash      *hexdata          `json:"parentHash"`
 	Nonce           *hexdata          `json:"nonce"`
+	MixHash         *hexdata          `json:"mixHash"`
 	Sha3Uncles      *hexdata          `json:"sha3Uncles"`
 	LogsBloom       *hexdata          `json:"logsBloom"`
 	TransactionRoot *hexdata          `json:"transactionsRoot"`
@@ -189,6 +195,7 @@ func (b *BlockRes) MarshalJSON() ([]byte, error) {
 			BlockHash       *hexdata          `json:"hash"`
 			ParentHash      *hexdata          `json:"parentHash"`
 			Nonce           *hexdata          `json:"nonce"`
+			MixHash         *hexdata          `json:"mixHash"`
 			Sha3Uncles      *hexdata          `json:"sha3Uncles"`
 			LogsBloom       *hexdata          `json:"logsBloom"`
 			TransactionRoot *hexdata          `json:"transactionsRoot"`
@@ -210,6 +217,7 @@ func (b *BlockRes) MarshalJSON() ([]byte, error) {
 		ext.BlockHash = b.BlockHash
 		ext.ParentHash = b.ParentHash
 		ext.Nonce = b.Nonce
+		ext.MixHash = b.MixHash
 		ext.Sha3Uncles = b.Sha3Uncles
 		ext.LogsBloom = b.LogsBloom
 		ext.TransactionRoot = b.TransactionRoot
@@ -235,6 +243,7 @@ func (b *BlockRes) MarshalJSON() ([]byte, error) {
 			BlockHash       *hexdata   `json:"hash"`
 			ParentHash      *hexdata   `json:"parentHash"`
 			Nonce           *hexdata   `json:"nonce"`
+			MixHash         *hexdata   `json:"mixHash"`
 			Sha3Uncles      *hexdata   `json:"sha3Uncles"`
 			LogsBloom       *hexdata   `json:"logsBloom"`
 			TransactionRoot *hexdata   `json:"transactionsRoot"`
@@ -256,6 +265,7 @@ func (b *BlockRes) MarshalJSON() ([]byte, error) {
 		ext.BlockHash = b.BlockHash
 		ext.ParentHash = b.ParentHash
 		ext.Nonce = b.Nonce
+		ext.MixHash = b.MixHash
 		ext.Sha3Uncles = b.Sha3Uncles
 		ext.LogsBloom = b.LogsBloom
 		ext.TransactionRoot = b.TransactionRoot
@@ -292,6 +302,7 @@ func NewBlockRes(block *types.Block, td *big.Int, fullTx bool) *BlockRes {
 	res.BlockHash = newHexData(block.Hash())
 	res.ParentHash = newHexData(block.ParentHash())
 	res.Nonce = newHexData(block.Nonce())
+	res.MixHash = newHexData(block.MixDigest())
 	res.Sha3Uncles = newHexData(block.UncleHash())
 	res.LogsBloom = newHexData(block.Bloom())
 	res.TransactionRoot = newHexData(block.TxHash())
@@ -336,6 +347,9 @@ type TransactionRes struct {
 	Gas         *hexnum  `json:"gas"`
 	GasPrice    *hexnum  `json:"gasPrice"`
 	Input       *hexdata `json:"input"`
+	V           *hexnum  `json:"v"`
+	R           *hexnum  `json:"r"`
+	S           *hexnum  `json:"s"`
 }
 
 func NewTransactionRes(tx *types.Transaction) *TransactionRes {
@@ -356,6 +370,10 @@ func NewTransactionRes(tx *types.Transaction) *TransactionRes {
 	v.Gas = newHexNum(tx.Gas())
 	v.GasPrice = newHexNum(tx.GasPrice())
 	v.Input = newHexData(tx.Data())
+	sigV, sigR, sigS := tx.SignatureValues()
+	v.V = newHexNum(sigV)
+	v.R = newHexNum(sigR)
+	v.S = newHexNum(sigS)
 	return v
 }
 
@@ -364,6 +382,7 @@ type UncleRes struct {
 	BlockHash       *hexdata `json:"hash"`
 	ParentHash      *hexdata `json:"parentHash"`
 	Nonce           *hexdata `json:"nonce"`
+	MixHash         *hexdata `json:"mixHash"`
 	Sha3Uncles      *hexdata `json:"sha3Uncles"`
 	ReceiptHash     *hexdata `json:"receiptHash"`
 	LogsBloom       *hexdata `json:"logsBloom"`
@@ -388,6 +407,7 @@ func NewUncleRes(h *types.Header) *UncleRes {
 	v.ParentHash = newHexData(h.ParentHash)
 	v.Sha3Uncles = newHexData(h.UncleHash)
 	v.Nonce = newHexData(h.Nonce[:])
+	v.MixHash = newHexData(h.MixDigest)
 	v.LogsBloom = newHexData(h.Bloom)
 	v.TransactionRoot = newHexData(h.TxHash)
 	v.StateRoot = newHexData(h.Root)
@@ -462,6 +482,116 @@ func NewReceiptRes(rec *types.Receipt) *ReceiptRes {
 	return v
 }
 
+// gasPricePercentiles are the percentiles reported with a gas price
+// distribution.
+var gasPricePercentiles = []int{10, 25, 50, 75, 90}
+
+type GasPriceDistributionRes struct {
+	BlockNumber *hexnum            `json:"blockNumber"`
+	BlockHash   *hexdata           `json:"blockHash"`
+	Blocks      int                `json:"blocks"`
+	Prices      []*hexnum          `json:"prices"`
+	Percentiles map[string]*hexnum `json:"percentiles"`
+}
+
+func NewGasPriceDistributionRes(dist *kr.GasPriceDistribution) *GasPriceDistributionRes {
+	v := &GasPriceDistributionRes{
+		BlockNumber: newHexNum(dist.Number),
+		BlockHash:   newHexData(dist.Head),
+		Blocks:      dist.Blocks,
+		Prices:      make([]*hexnum, len(dist.Prices)),
+		Percentiles: make(map[string]*hexnum),
+	}
+	for i, price := range dist.Prices {
+		v.Prices[i] = newHexNum(price)
+	}
+	if len(dist.Prices) > 0 {
+		for _, p := range gasPricePercentiles {
+			v.Percentiles[strconv.Itoa(p)] = newHexNum(dist.Percentile(p))
+		}
+	}
+	return v
+}
+
+type BadBlockRes struct {
+	BlockHash   *hexdata        `json:"hash"`
+	BlockNumber *hexnum         `json:"number"`
+	ParentHash  *hexdata        `json:"parentHash"`
+	Error       string          `json:"error"`
+	Rlp         string          `json:"rlp"`
+	Receipts    []*ReceiptRes   `json:"receipts"`
+	StateDiff   json.RawMessage `json:"stateDiff"`
+}
+
+func NewBadBlockRes(bad *core.BadBlock) *BadBlockRes {
+	v := &BadBlockRes{
+		BlockHash:   newHexData(bad.Block.Hash()),
+		BlockNumber: newHexNum(bad.Block.Number()),
+		ParentHash:  newHexData(bad.Block.ParentHash()),
+		Error:       bad.Error,
+		Receipts:    make([]*ReceiptRes, len(bad.Receipts)),
+		StateDiff:   json.RawMessage("null"),
+	}
+	encoded, _ := rlp.EncodeToBytes(bad.Block)
+	v.Rlp = common.Bytes2Hex(encoded)
+
+	for i, receipt := range bad.Receipts {
+		v.Receipts[i] = NewReceiptRes(receipt)
+	}
+	if len(bad.StateDiff) > 0 {
+		v.StateDiff = json.RawMessage(bad.StateDiff)
+	}
+	return v
+}
+
+type TransactionTraceRes struct {
+	TransactionHash *hexdata        `json:"transactionHash"`
+	GasUsed         *hexnum         `json:"gasUsed"`
+	Failed          bool            `json:"failed"`
+	Error           string          `json:"error,omitempty"`
+	StructLogs      []*StructLogRes `json:"structLogs"`
+}
+
+type StructLogRes struct {
+	Pc      uint64            `json:"pc"`
+	Op      string            `json:"op"`
+	Gas     *hexnum           `json:"gas"`
+	GasCost *hexnum           `json:"gasCost"`
+	Memory  []string          `json:"memory"`
+	Stack   []string          `json:"stack"`
+	Storage map[string]string `json:"storage"`
+	Error   string            `json:"error,omitempty"`
+}
+
+func NewStructLogRes(log vm.StructLog) *StructLogRes {
+	v := &StructLogRes{
+		Pc:      log.Pc,
+		Op:      log.Op.String(),
+		Gas:     newHexNum(log.Gas),
+		GasCost: newHexNum(log.GasCost),
+		Memory:  make([]string, 0, (len(log.Memory)+31)/32),
+		Stack:   make([]string, len(log.Stack)),
+		Storage: make(map[string]string),
+	}
+	for i := 0; i < len(log.Memory); i += 32 {
+		end := i + 32
+		if end > len(log.Memory) {
+			end = len(log.Memory)
+		}
+		v.Memory = append(v.Memory, common.Bytes2Hex(common.RightPadBytes(log.Memory[i:end], 32)))
+	}
+	for i, value := range log.Stack {
+		v.Stack[i] = common.Bytes2Hex(common.LeftPadBytes(value.Bytes(), 32))
+	}
+	for key, value := range log.Storage {
+		v.Storage[common.Bytes2Hex(key[:])] = common.Bytes2Hex(common.LeftPadBytes(value, 32))
+	}
+	if log.Err != nil {
+		v.Error = log.Err.Error()
+	}
+	return v
+}
+
 func numString(raw interface{}) (*big.Int, error) {
 	var number *big.Int
 	// Parse as integer
@@ -20,8 +20,11 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/krypton/go-krypton/accounts"
 	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/crypto"
 	"github.com/krypton/go-krypton/kr"
+	"github.com/krypton/go-krypton/rlp"
 	"github.com/krypton/go-krypton/rpc/codec"
 	"github.com/krypton/go-krypton/rpc/shared"
 	"github.com/krypton/go-krypton/xkr"
@@ -34,9 +37,32 @@ const (
 var (
 	// mapping between methods and handlers
 	personalMapping = map[string]personalhandler{
-		"personal_listAccounts":  (*personalApi).ListAccounts,
-		"personal_newAccount":    (*personalApi).NewAccount,
-		"personal_unlockAccount": (*personalApi).UnlockAccount,
+		"personal_listAccounts":    (*personalApi).ListAccounts,
+		"personal_newAccount":      (*personalApi).NewAccount,
+		"personal_unlockAccount":   (*personalApi).UnlockAccount,
+		"personal_newWallet":       (*personalApi).NewWallet,
+		"personal_importMnemonic":  (*personalApi).ImportMnemonic,
+		"personal_deriveAccount":   (*personalApi).DeriveAccount,
+		"personal_listWallets":     (*personalApi).ListWallets,
+		"personal_lockAccount":     (*personalApi).LockAccount,
+		"personal_importRawKey":    (*personalApi).ImportRawKey,
+		"personal_sendTransaction": (*personalApi).SendTransaction,
+		"personal_signTransaction": (*personalApi).SignTransaction,
+		"personal_sign":            (*personalApi).Sign,
+	}
+
+	// methods exempt from the request deadline, as key derivation and signing may
+	// take long or wait for a passphrase prompt or an external signer's approval
+	personalUntimed = map[string]bool{
+		"personal_newAccount":      true,
+		"personal_unlockAccount":   true,
+		"personal_newWallet":       true,
+		"personal_importMnemonic":  true,
+		"personal_deriveAccount":   true,
+		"personal_importRawKey":    true,
+		"personal_sendTransaction": true,
+		"personal_signTransaction": true,
+		"personal_sign":            true,
 	}
 )
 
@@ -89,6 +115,11 @@ func (self *personalApi) ApiVersion() string {
 	return PersonalApiVersion
 }
 
+// Untimed reports whether the given method is exempt from the request deadline
+func (self *personalApi) Untimed(method string) bool {
+	return personalUntimed[method]
+}
+
 func (self *personalApi) ListAccounts(req *shared.Request) (interface{}, error) {
 	return self.xkr.Accounts(), nil
 }
@@ -137,3 +168,137 @@ func (self *personalApi) UnlockAccount(req *shared.Request) (interface{}, error)
 	err := am.TimedUnlock(addr, *args.Passphrase, time.Duration(args.Duration)*time.Second)
 	return err == nil, err
 }
+
+func (self *personalApi) LockAccount(req *shared.Request) (interface{}, error) {
+	args := new(LockAccountArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+	err := self.krypton.AccountManager().Lock(common.HexToAddress(args.Address))
+	return err == nil, err
+}
+
+func (self *personalApi) ImportRawKey(req *shared.Request) (interface{}, error) {
+	args := new(ImportRawKeyArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+	key, err := crypto.HexToECDSA(common.Bytes2Hex(common.FromHex(args.Key)))
+	if err != nil {
+		return nil, shared.NewValidationError("key", err.Error())
+	}
+	acc, err := self.krypton.AccountManager().ImportECDSA(key, args.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return acc.Address.Hex(), nil
+}
+
+// SendTransaction signs and sends a transaction, unlocking the sender with the
+// passphrase for this transaction only.
+func (self *personalApi) SendTransaction(req *shared.Request) (interface{}, error) {
+	args := new(SendTxWithPassphraseArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+	nonce, gas, price := args.Tx.optionalQuantities()
+	return self.xkr.TransactWithPassphrase(args.Passphrase, args.Tx.From, args.Tx.To, nonce, args.Tx.Value.String(), gas, price, args.Tx.Data)
+}
+
+// SignTransaction signs a transaction without sending it, unlocking the sender
+// with the passphrase for this transaction only.
+func (self *personalApi) SignTransaction(req *shared.Request) (interface{}, error) {
+	args := new(SendTxWithPassphraseArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+	nonce, gas, price := args.Tx.optionalQuantities()
+	tx, err := self.xkr.SignTransactionWithPassphrase(args.Passphrase, args.Tx.From, args.Tx.To, nonce, args.Tx.Value.String(), gas, price, args.Tx.Data)
+	if err != nil {
+		return nil, err
+	}
+	data, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, err
+	}
+	return JsonTransaction{"0x" + common.Bytes2Hex(data), newTx(tx)}, nil
+}
+
+// Sign signs the prefixed hash of the data (see accounts.TextHash), so the
+// signature can't be replayed as a transaction.
+func (self *personalApi) Sign(req *shared.Request) (interface{}, error) {
+	args := new(SignArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+	acc := accounts.Account{Address: common.HexToAddress(args.Address)}
+	sig, err := self.krypton.AccountManager().SignWithPassphrase(acc, args.Passphrase, accounts.TextHash(common.FromHex(args.Data)))
+	if err != nil {
+		return nil, err
+	}
+	return common.ToHex(sig), nil
+}
+
+// walletResult is the RPC representation of an HD wallet.
+type walletResult struct {
+	Id       string   `json:"id"`
+	BasePath string   `json:"basePath"`
+	Accounts []string `json:"accounts"`
+	Mnemonic string   `json:"mnemonic,omitempty"` // only revealed on creation
+}
+
+func newWalletResult(wallet accounts.Wallet) *walletResult {
+	result := &walletResult{Id: wallet.Id, BasePath: wallet.BasePath, Accounts: []string{}}
+	for _, acc := range wallet.Accounts {
+		result.Accounts = append(result.Accounts, acc.Address.Hex())
+	}
+	return result
+}
+
+func (self *personalApi) NewWallet(req *shared.Request) (interface{}, error) {
+	args := new(NewWalletArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+	mnemonic, wallet, err := self.krypton.AccountManager().NewWallet(args.BasePath, args.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	result := newWalletResult(wallet)
+	result.Mnemonic = mnemonic
+	return result, nil
+}
+
+func (self *personalApi) ImportMnemonic(req *shared.Request) (interface{}, error) {
+	args := new(ImportMnemonicArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+	wallet, err := self.krypton.AccountManager().ImportMnemonic(args.Mnemonic, args.BasePath, args.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return newWalletResult(wallet), nil
+}
+
+func (self *personalApi) DeriveAccount(req *shared.Request) (interface{}, error) {
+	args := new(DeriveAccountArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+	acc, err := self.krypton.AccountManager().DeriveAccount(args.Wallet, args.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return acc.Address.Hex(), nil
+}
+
+func (self *personalApi) ListWallets(req *shared.Request) (interface{}, error) {
+	wallets := self.krypton.AccountManager().Wallets()
+
+	results := make([]*walletResult, len(wallets))
+	for i, wallet := range wallets {
+		results[i] = newWalletResult(wallet)
+	}
+	return results, nil
+}
@@ -19,6 +19,8 @@ package api
 import (
 	"encoding/json"
 
+	"github.com/krypton/go-krypton/accounts/hd"
+	"github.com/krypton/go-krypton/common"
 	"github.com/krypton/go-krypton/rpc/shared"
 )
 
@@ -83,3 +85,246 @@ func (args *UnlockAccountArgs) UnmarshalJSON(b []byte) (err error) {
 
 	return nil
 }
+
+// NewWalletArgs holds the passphrase protecting a new HD wallet and the
+// optional BIP-44 path its accounts are derived under.
+type NewWalletArgs struct {
+	Passphrase string
+	BasePath   hd.DerivationPath
+}
+
+func (args *NewWalletArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return shared.NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 1 {
+		return shared.NewInsufficientParamsError(len(obj), 1)
+	}
+
+	passphrasestr, ok := obj[0].(string)
+	if !ok {
+		return shared.NewInvalidTypeError("passphrase", "not a string")
+	}
+	args.Passphrase = passphrasestr
+
+	if len(obj) >= 2 && obj[1] != nil {
+		if args.BasePath, err = parseBasePath(obj[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ImportMnemonicArgs holds the mnemonic of an HD wallet to restore, the
+// passphrase to protect it with and the optional BIP-44 base path.
+type ImportMnemonicArgs struct {
+	Mnemonic   string
+	Passphrase string
+	BasePath   hd.DerivationPath
+}
+
+func (args *ImportMnemonicArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return shared.NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 2 {
+		return shared.NewInsufficientParamsError(len(obj), 2)
+	}
+
+	mnemonicstr, ok := obj[0].(string)
+	if !ok {
+		return shared.NewInvalidTypeError("mnemonic", "not a string")
+	}
+	args.Mnemonic = mnemonicstr
+
+	passphrasestr, ok := obj[1].(string)
+	if !ok {
+		return shared.NewInvalidTypeError("passphrase", "not a string")
+	}
+	args.Passphrase = passphrasestr
+
+	if len(obj) >= 3 && obj[2] != nil {
+		if args.BasePath, err = parseBasePath(obj[2]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeriveAccountArgs holds the id of the HD wallet to derive the next account
+// of and its passphrase.
+type DeriveAccountArgs struct {
+	Wallet     string
+	Passphrase string
+}
+
+func (args *DeriveAccountArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return shared.NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 2 {
+		return shared.NewInsufficientParamsError(len(obj), 2)
+	}
+
+	walletstr, ok := obj[0].(string)
+	if !ok {
+		return shared.NewInvalidTypeError("wallet", "not a string")
+	}
+	args.Wallet = walletstr
+
+	passphrasestr, ok := obj[1].(string)
+	if !ok {
+		return shared.NewInvalidTypeError("passphrase", "not a string")
+	}
+	args.Passphrase = passphrasestr
+
+	return nil
+}
+
+// parseBasePath parses a BIP-44 derivation path parameter.
+func parseBasePath(param interface{}) (hd.DerivationPath, error) {
+	pathstr, ok := param.(string)
+	if !ok {
+		return nil, shared.NewInvalidTypeError("basePath", "not a string")
+	}
+	path, err := hd.ParseDerivationPath(pathstr)
+	if err != nil {
+		return nil, shared.NewValidationError("basePath", err.Error())
+	}
+	return path, nil
+}
+
+// SendTxWithPassphraseArgs holds a transaction to sign or send and the
+// passphrase unlocking its sender for this transaction only.
+type SendTxWithPassphraseArgs struct {
+	Tx         NewTxArgs
+	Passphrase string
+}
+
+func (args *SendTxWithPassphraseArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []json.RawMessage
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return shared.NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 2 {
+		return shared.NewInsufficientParamsError(len(obj), 2)
+	}
+
+	// The transaction is decoded as the single parameter of kr_sendTransaction
+	txparams, _ := json.Marshal(obj[:1])
+	if err := json.Unmarshal(txparams, &args.Tx); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(obj[1], &args.Passphrase); err != nil {
+		return shared.NewInvalidTypeError("passphrase", "not a string")
+	}
+
+	return nil
+}
+
+// SignArgs holds the data to sign with personal_sign, the signing account and
+// its passphrase.
+type SignArgs struct {
+	Data       string
+	Address    string
+	Passphrase string
+}
+
+func (args *SignArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return shared.NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 3 {
+		return shared.NewInsufficientParamsError(len(obj), 3)
+	}
+
+	datastr, ok := obj[0].(string)
+	if !ok {
+		return shared.NewInvalidTypeError("data", "not a string")
+	}
+	args.Data = datastr
+
+	addrstr, ok := obj[1].(string)
+	if !ok {
+		return shared.NewInvalidTypeError("address", "not a string")
+	}
+	if !common.IsHexAddress(addrstr) {
+		return shared.NewValidationError("address", "not a valid address")
+	}
+	args.Address = addrstr
+
+	passphrasestr, ok := obj[2].(string)
+	if !ok {
+		return shared.NewInvalidTypeError("passphrase", "not a string")
+	}
+	args.Passphrase = passphrasestr
+
+	return nil
+}
+
+type LockAccountArgs struct {
+	Address string
+}
+
+func (args *LockAccountArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return shared.NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 1 {
+		return shared.NewInsufficientParamsError(len(obj), 1)
+	}
+
+	addrstr, ok := obj[0].(string)
+	if !ok {
+		return shared.NewInvalidTypeError("address", "not a string")
+	}
+	args.Address = addrstr
+
+	return nil
+}
+
+// ImportRawKeyArgs holds a hex encoded private key to import and the
+// passphrase to encrypt it with.
+type ImportRawKeyArgs struct {
+	Key        string
+	Passphrase string
+}
+
+func (args *ImportRawKeyArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return shared.NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 2 {
+		return shared.NewInsufficientParamsError(len(obj), 2)
+	}
+
+	keystr, ok := obj[0].(string)
+	if !ok {
+		return shared.NewInvalidTypeError("key", "not a string")
+	}
+	args.Key = keystr
+
+	passphrasestr, ok := obj[1].(string)
+	if !ok {
+		return shared.NewInvalidTypeError("passphrase", "not a string")
+	}
+	args.Passphrase = passphrasestr
+
+	return nil
+}
@@ -16,6 +16,8 @@
 
 package api
 
+import "github.com/krypton/go-krypton/rpc/shared"
+
 const Personal_JS = `
 web3._extend({
 	property: 'personal',
@@ -33,6 +35,56 @@ web3._extend({
 			call: 'personal_unlockAccount',
 			params: 3,
 			inputFormatter: [null, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'newWallet',
+			call: 'personal_newWallet',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'importMnemonic',
+			call: 'personal_importMnemonic',
+			params: 3,
+			inputFormatter: [null, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'deriveAccount',
+			call: 'personal_deriveAccount',
+			params: 2,
+			inputFormatter: [null, null],
+			outputFormatter: web3._extend.utils.toAddress
+		}),
+		new web3._extend.Method({
+			name: 'lockAccount',
+			call: 'personal_lockAccount',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'importRawKey',
+			call: 'personal_importRawKey',
+			params: 2,
+			inputFormatter: [null, null],
+			outputFormatter: web3._extend.utils.toAddress
+		}),
+		new web3._extend.Method({
+			name: 'sendTransaction',
+			call: 'personal_sendTransaction',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputTransactionFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'signTransaction',
+			call: 'personal_signTransaction',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputTransactionFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'sign',
+			call: 'personal_sign',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter, null]
 		})
 	],
 	properties:
@@ -40,7 +92,27 @@ web3._extend({
 		new web3._extend.Property({
 			name: 'listAccounts',
 			getter: 'personal_listAccounts'
+		}),
+		new web3._extend.Property({
+			name: 'listWallets',
+			getter: 'personal_listWallets'
 		})
 	]
 });
 `
+
+// Personal_Docs documents the personal API in the console.
+var Personal_Docs = []shared.MethodDoc{
+	{Name: "deriveAccount", Params: []string{"wallet", "passphrase"}, Description: "Derives the next account of an HD wallet."},
+	{Name: "importMnemonic", Params: []string{"mnemonic", "passphrase", "basePath?"}, Description: "Restores an HD wallet from its BIP-39 mnemonic."},
+	{Name: "importRawKey", Params: []string{"hexKey", "passphrase"}, Description: "Imports an unencrypted private key, storing it protected by the passphrase."},
+	{Name: "listAccounts", Property: true, Description: "Addresses of the accounts owned by the node."},
+	{Name: "listWallets", Property: true, Description: "HD wallets and the accounts derived from them."},
+	{Name: "lockAccount", Params: []string{"address"}, Description: "Locks the account, removing its key from memory."},
+	{Name: "newAccount", Params: []string{"passphrase?"}, Description: "Creates a new account protected by the passphrase."},
+	{Name: "newWallet", Params: []string{"passphrase", "basePath?"}, Description: "Creates an HD wallet, returning its mnemonic. Write the mnemonic down, it is never shown again."},
+	{Name: "sendTransaction", Params: []string{"tx", "passphrase"}, Description: "Sends the transaction, unlocking the sender for this transaction only."},
+	{Name: "sign", Params: []string{"data", "address", "passphrase"}, Description: "Signs the data prefixed with \"\\x19Krypton Signed Message:\\n\" and its length, so it can't be a transaction."},
+	{Name: "signTransaction", Params: []string{"tx", "passphrase"}, Description: "Signs the transaction without sending it, unlocking the sender for this transaction only."},
+	{Name: "unlockAccount", Params: []string{"address", "passphrase?", "duration?"}, Description: "Unlocks the account for duration seconds."},
+}
@@ -36,10 +36,22 @@ var (
 		"shh_post":             (*shhApi).Post,
 		"shh_hasIdentity":      (*shhApi).HasIdentity,
 		"shh_newIdentity":      (*shhApi).NewIdentity,
+		"shh_addIdentity":      (*shhApi).AddIdentity,
+		"shh_exportIdentity":   (*shhApi).ExportIdentity,
+		"shh_deleteIdentity":   (*shhApi).DeleteIdentity,
 		"shh_newFilter":        (*shhApi).NewFilter,
 		"shh_uninstallFilter":  (*shhApi).UninstallFilter,
 		"shh_getMessages":      (*shhApi).GetMessages,
 		"shh_getFilterChanges": (*shhApi).GetFilterChanges,
+
+		"shh_newSymKey":                  (*shhApi).NewSymKey,
+		"shh_addSymKey":                  (*shhApi).AddSymKey,
+		"shh_generateSymKeyFromPassword": (*shhApi).GenerateSymKeyFromPassword,
+		"shh_hasSymKey":                  (*shhApi).HasSymKey,
+		"shh_getSymKey":                  (*shhApi).GetSymKey,
+		"shh_deleteSymKey":               (*shhApi).DeleteSymKey,
+		"shh_requestMessages":            (*shhApi).RequestMessages,
+		"shh_setTopicInterest":           (*shhApi).SetTopicInterest,
 	}
 )
 
@@ -116,7 +128,7 @@ func (self *shhApi) Post(req *shared.Request) (interface{}, error) {
 		return nil, err
 	}
 
-	err := w.Post(args.Payload, args.To, args.From, args.Topics, args.Priority, args.Ttl)
+	err := w.Post(args.Payload, args.To, args.From, args.SymKeyID, args.Topics, args.Priority, args.Ttl)
 	if err != nil {
 		return false, err
 	}
@@ -144,16 +156,78 @@ func (self *shhApi) NewIdentity(req *shared.Request) (interface{}, error) {
 		return nil, newWhisperOfflineError(req.Method)
 	}
 
+	args := new(WhisperNewIdentityArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, err
+	}
+
+	if args.Passphrase != nil {
+		return w.NewStoredIdentity(*args.Passphrase)
+	}
 	return w.NewIdentity(), nil
 }
 
+func (self *shhApi) AddIdentity(req *shared.Request) (interface{}, error) {
+	w := self.xkr.Whisper()
+	if w == nil {
+		return nil, newWhisperOfflineError(req.Method)
+	}
+
+	args := new(WhisperIdentityPassArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, err
+	}
+
+	return w.AddIdentity(args.Identity, args.Passphrase)
+}
+
+func (self *shhApi) ExportIdentity(req *shared.Request) (interface{}, error) {
+	w := self.xkr.Whisper()
+	if w == nil {
+		return nil, newWhisperOfflineError(req.Method)
+	}
+
+	args := new(WhisperIdentityPassArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, err
+	}
+
+	return w.ExportIdentity(args.Identity, args.Passphrase)
+}
+
+func (self *shhApi) DeleteIdentity(req *shared.Request) (interface{}, error) {
+	w := self.xkr.Whisper()
+	if w == nil {
+		return nil, newWhisperOfflineError(req.Method)
+	}
+
+	args := new(WhisperIdentityPassArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, err
+	}
+
+	if err := w.DeleteIdentity(args.Identity, args.Passphrase); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (self *shhApi) NewFilter(req *shared.Request) (interface{}, error) {
 	args := new(WhisperFilterArgs)
 	if err := self.codec.Decode(req.Params, &args); err != nil {
 		return nil, err
 	}
+	if len(args.SymKeyID) > 0 {
+		w := self.xkr.Whisper()
+		if w == nil {
+			return nil, newWhisperOfflineError(req.Method)
+		}
+		if !w.HasSymKey(args.SymKeyID) {
+			return nil, shared.NewValidationError("symKeyID", "unknown symmetric key")
+		}
+	}
 
-	id := self.xkr.NewWhisperFilter(args.To, args.From, args.Topics)
+	id := self.xkr.NewWhisperFilter(args.To, args.From, args.SymKeyID, args.Topics)
 	return newHexNum(big.NewInt(int64(id)).Bytes()), nil
 }
 
@@ -194,3 +268,114 @@ func (self *shhApi) GetMessages(req *shared.Request) (interface{}, error) {
 
 	return self.xkr.WhisperMessages(args.Id), nil
 }
+
+func (self *shhApi) NewSymKey(req *shared.Request) (interface{}, error) {
+	w := self.xkr.Whisper()
+	if w == nil {
+		return nil, newWhisperOfflineError(req.Method)
+	}
+
+	return w.GenerateSymKey()
+}
+
+func (self *shhApi) AddSymKey(req *shared.Request) (interface{}, error) {
+	w := self.xkr.Whisper()
+	if w == nil {
+		return nil, newWhisperOfflineError(req.Method)
+	}
+
+	args := new(WhisperSymKeyArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, err
+	}
+
+	return w.AddSymKey(args.Key)
+}
+
+func (self *shhApi) GenerateSymKeyFromPassword(req *shared.Request) (interface{}, error) {
+	w := self.xkr.Whisper()
+	if w == nil {
+		return nil, newWhisperOfflineError(req.Method)
+	}
+
+	args := new(WhisperSymKeyArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, err
+	}
+
+	return w.AddSymKeyFromPassword(args.Key)
+}
+
+func (self *shhApi) HasSymKey(req *shared.Request) (interface{}, error) {
+	w := self.xkr.Whisper()
+	if w == nil {
+		return nil, newWhisperOfflineError(req.Method)
+	}
+
+	args := new(WhisperSymKeyArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, err
+	}
+
+	return w.HasSymKey(args.Key), nil
+}
+
+func (self *shhApi) GetSymKey(req *shared.Request) (interface{}, error) {
+	w := self.xkr.Whisper()
+	if w == nil {
+		return nil, newWhisperOfflineError(req.Method)
+	}
+
+	args := new(WhisperSymKeyArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, err
+	}
+
+	return w.GetSymKey(args.Key)
+}
+
+func (self *shhApi) DeleteSymKey(req *shared.Request) (interface{}, error) {
+	w := self.xkr.Whisper()
+	if w == nil {
+		return nil, newWhisperOfflineError(req.Method)
+	}
+
+	args := new(WhisperSymKeyArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, err
+	}
+
+	return w.DeleteSymKey(args.Key), nil
+}
+
+func (self *shhApi) RequestMessages(req *shared.Request) (interface{}, error) {
+	w := self.xkr.Whisper()
+	if w == nil {
+		return nil, newWhisperOfflineError(req.Method)
+	}
+
+	args := new(WhisperMailRequestArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, err
+	}
+
+	if err := w.RequestMessages(args.Peer, args.From, args.To, args.Topics); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (self *shhApi) SetTopicInterest(req *shared.Request) (interface{}, error) {
+	w := self.xkr.Whisper()
+	if w == nil {
+		return nil, newWhisperOfflineError(req.Method)
+	}
+
+	args := new(WhisperTopicInterestArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, err
+	}
+
+	w.SetTopicInterest(args.Topics)
+	return true, nil
+}
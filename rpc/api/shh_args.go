@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"time"
 
 	"github.com/krypton/go-krypton/rpc/shared"
 )
@@ -28,6 +29,7 @@ type WhisperMessageArgs struct {
 	Payload  string
 	To       string
 	From     string
+	SymKeyID string
 	Topics   []string
 	Priority uint32
 	Ttl      uint32
@@ -38,6 +40,7 @@ func (args *WhisperMessageArgs) UnmarshalJSON(b []byte) (err error) {
 		Payload  string
 		To       string
 		From     string
+		SymKeyID string
 		Topics   []string
 		Priority interface{}
 		Ttl      interface{}
@@ -53,6 +56,7 @@ func (args *WhisperMessageArgs) UnmarshalJSON(b []byte) (err error) {
 	args.Payload = obj[0].Payload
 	args.To = obj[0].To
 	args.From = obj[0].From
+	args.SymKeyID = obj[0].SymKeyID
 	args.Topics = obj[0].Topics
 
 	var num *big.Int
@@ -93,10 +97,125 @@ func (args *WhisperIdentityArgs) UnmarshalJSON(b []byte) (err error) {
 	return nil
 }
 
+// WhisperNewIdentityArgs holds the optional passphrase a new identity is
+// persisted with.
+type WhisperNewIdentityArgs struct {
+	Passphrase *string
+}
+
+func (args *WhisperNewIdentityArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return shared.NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) >= 1 && obj[0] != nil {
+		if passphrasestr, ok := obj[0].(string); ok {
+			args.Passphrase = &passphrasestr
+		} else {
+			return shared.NewInvalidTypeError("passphrase", "not a string")
+		}
+	}
+
+	return nil
+}
+
+// WhisperIdentityPassArgs holds the arguments of the persisted identity methods:
+// a key or identity and the passphrase protecting it.
+type WhisperIdentityPassArgs struct {
+	Identity   string
+	Passphrase string
+}
+
+func (args *WhisperIdentityPassArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return shared.NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 2 {
+		return shared.NewInsufficientParamsError(len(obj), 2)
+	}
+
+	identitystr, ok := obj[0].(string)
+	if !ok {
+		return shared.NewInvalidTypeError("identity", "not a string")
+	}
+	args.Identity = identitystr
+
+	passphrasestr, ok := obj[1].(string)
+	if !ok {
+		return shared.NewInvalidTypeError("passphrase", "not a string")
+	}
+	args.Passphrase = passphrasestr
+
+	return nil
+}
+
+// WhisperTopicInterestArgs holds the topics a node wants forwarded.
+type WhisperTopicInterestArgs struct {
+	Topics []string
+}
+
+func (args *WhisperTopicInterestArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return shared.NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 1 {
+		return shared.NewInsufficientParamsError(len(obj), 1)
+	}
+
+	if obj[0] == nil {
+		return nil
+	}
+	topics, ok := obj[0].([]interface{})
+	if !ok {
+		return shared.NewInvalidTypeError("topics", "not an array")
+	}
+	for _, topic := range topics {
+		topicstr, ok := topic.(string)
+		if !ok {
+			return shared.NewInvalidTypeError("topics", "not a string array")
+		}
+		args.Topics = append(args.Topics, topicstr)
+	}
+
+	return nil
+}
+
+// WhisperSymKeyArgs holds the single string argument of the symmetric key
+// methods: a key, a password or the id of an installed key.
+type WhisperSymKeyArgs struct {
+	Key string
+}
+
+func (args *WhisperSymKeyArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return shared.NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 1 {
+		return shared.NewInsufficientParamsError(len(obj), 1)
+	}
+
+	argstr, ok := obj[0].(string)
+	if !ok {
+		return shared.NewInvalidTypeError("arg0", "not a string")
+	}
+
+	args.Key = argstr
+
+	return nil
+}
+
 type WhisperFilterArgs struct {
-	To     string
-	From   string
-	Topics [][]string
+	To       string
+	From     string
+	SymKeyID string
+	Topics   [][]string
 }
 
 // UnmarshalJSON implements the json.Unmarshaler interface, invoked to convert a
@@ -104,9 +223,10 @@ type WhisperFilterArgs struct {
 func (args *WhisperFilterArgs) UnmarshalJSON(b []byte) (err error) {
 	// Unmarshal the JSON message and sanity check
 	var obj []struct {
-		To     interface{} `json:"to"`
-		From   interface{} `json:"from"`
-		Topics interface{} `json:"topics"`
+		To       interface{} `json:"to"`
+		From     interface{} `json:"from"`
+		SymKeyID interface{} `json:"symKeyID"`
+		Topics   interface{} `json:"topics"`
 	}
 	if err := json.Unmarshal(b, &obj); err != nil {
 		return shared.NewDecodeParamError(err.Error())
@@ -133,6 +253,13 @@ func (args *WhisperFilterArgs) UnmarshalJSON(b []byte) (err error) {
 		}
 		args.From = argstr
 	}
+	if obj[0].SymKeyID != nil {
+		argstr, ok := obj[0].SymKeyID.(string)
+		if !ok {
+			return shared.NewInvalidTypeError("symKeyID", "is not a string")
+		}
+		args.SymKeyID = argstr
+	}
 	// Construct the nested topic array
 	if obj[0].Topics != nil {
 		// Make sure we have an actual topic array
@@ -172,3 +299,53 @@ func (args *WhisperFilterArgs) UnmarshalJSON(b []byte) (err error) {
 	}
 	return nil
 }
+
+// WhisperMailRequestArgs specifies the mail server to request archived messages
+// from, the time range they were sent in and the topics they should match.
+type WhisperMailRequestArgs struct {
+	Peer   string
+	From   uint32
+	To     uint32
+	Topics []string
+}
+
+func (args *WhisperMailRequestArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []struct {
+		Peer   interface{}
+		From   interface{}
+		To     interface{}
+		Topics []string
+	}
+
+	if err = json.Unmarshal(b, &obj); err != nil {
+		return shared.NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 1 {
+		return shared.NewInsufficientParamsError(len(obj), 1)
+	}
+
+	peer, ok := obj[0].Peer.(string)
+	if !ok {
+		return shared.NewInvalidTypeError("peer", "is not a string")
+	}
+	args.Peer = peer
+	args.Topics = obj[0].Topics
+
+	var num *big.Int
+	if num, err = numString(obj[0].From); err != nil {
+		return err
+	}
+	args.From = uint32(num.Int64())
+
+	if obj[0].To == nil {
+		args.To = uint32(time.Now().Unix())
+	} else {
+		if num, err = numString(obj[0].To); err != nil {
+			return err
+		}
+		args.To = uint32(num.Int64())
+	}
+
+	return nil
+}
@@ -16,12 +16,68 @@
 
 package api
 
+import "github.com/krypton/go-krypton/rpc/shared"
+
 const Shh_JS = `
 web3._extend({
 	property: 'shh',
 	methods:
 	[
-
+		new web3._extend.Method({
+			name: 'addIdentity',
+			call: 'shh_addIdentity',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'exportIdentity',
+			call: 'shh_exportIdentity',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'deleteIdentity',
+			call: 'shh_deleteIdentity',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'setTopicInterest',
+			call: 'shh_setTopicInterest',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'newSymKey',
+			call: 'shh_newSymKey',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'addSymKey',
+			call: 'shh_addSymKey',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'generateSymKeyFromPassword',
+			call: 'shh_generateSymKeyFromPassword',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'hasSymKey',
+			call: 'shh_hasSymKey',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getSymKey',
+			call: 'shh_getSymKey',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'deleteSymKey',
+			call: 'shh_deleteSymKey',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'requestMessages',
+			call: 'shh_requestMessages',
+			params: 1
+		})
 	],
 	properties:
 	[
@@ -32,3 +88,25 @@ web3._extend({
 	]
 });
 `
+
+// Shh_Docs documents the shh API in the console.
+var Shh_Docs = []shared.MethodDoc{
+	{Name: "addIdentity", Params: []string{"key", "passphrase"}, Description: "Imports a hex encoded private key into the identity store encrypted with the passphrase, or unlocks a stored identity by its public key, and returns the public key."},
+	{Name: "addSymKey", Params: []string{"key"}, Description: "Installs a hex encoded 32 byte symmetric key and returns its id."},
+	{Name: "addToGroup", Params: []string{"identity"}, Description: "Adds an identity to the group."},
+	{Name: "deleteIdentity", Params: []string{"identity", "passphrase"}, Description: "Removes a stored identity, authorized by its passphrase."},
+	{Name: "deleteSymKey", Params: []string{"id"}, Description: "Removes the symmetric key with the given id."},
+	{Name: "exportIdentity", Params: []string{"identity", "passphrase"}, Description: "Decrypts a stored identity with the passphrase and returns its private key."},
+	{Name: "filter", Params: []string{"options", "callback?"}, Description: "Installs a filter for whisper messages, optionally for those encrypted with the symmetric key of id options.symKeyID."},
+	{Name: "generateSymKeyFromPassword", Params: []string{"password"}, Description: "Installs a symmetric key derived from the password and returns its id."},
+	{Name: "getSymKey", Params: []string{"id"}, Description: "Returns the symmetric key with the given id."},
+	{Name: "hasIdentity", Params: []string{"identity"}, Description: "Returns whether the node holds the private key of the identity."},
+	{Name: "hasSymKey", Params: []string{"id"}, Description: "Returns whether a symmetric key with the given id is installed."},
+	{Name: "newGroup", Description: "Creates a new group."},
+	{Name: "newIdentity", Description: "Creates a new identity and returns its public key. Over RPC an optional passphrase persists it in the identity store."},
+	{Name: "newSymKey", Description: "Generates a random symmetric key and returns its id."},
+	{Name: "post", Params: []string{"message"}, Description: "Posts a whisper message, encrypted to message.to or with the symmetric key of id message.symKeyID. message.priority is the proof of work in thousandths, at least the minimum of the node."},
+	{Name: "requestMessages", Params: []string{"request"}, Description: "Requests the archived messages sent between request.from and request.to (unix times, to defaults to now) with any of the hex encoded request.topics from the mail server request.peer."},
+	{Name: "setTopicInterest", Params: []string{"topics"}, Description: "Asks peers to forward only the messages with any of the hex encoded topics, or all messages if the list is empty."},
+	{Name: "version", Property: true, Description: "Whisper protocol version."},
+}
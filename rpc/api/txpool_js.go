@@ -16,6 +16,8 @@
 
 package api
 
+import "github.com/krypton/go-krypton/rpc/shared"
+
 const TxPool_JS = `
 web3._extend({
 	property: 'txpool',
@@ -31,3 +33,8 @@ web3._extend({
 	]
 });
 `
+
+// TxPool_Docs documents the txpool API in the console.
+var TxPool_Docs = []shared.MethodDoc{
+	{Name: "status", Property: true, Description: "Number of pending and queued transactions."},
+}
@@ -27,132 +27,6 @@ import (
 	"github.com/krypton/go-krypton/xkr"
 )
 
-var (
-	// Mapping between the different methods each api supports
-	AutoCompletion = map[string][]string{
-		"admin": []string{
-			"addPeer",
-			"datadir",
-			"enableUserAgent",
-			"exportChain",
-			"getContractInfo",
-			"httpGet",
-			"importChain",
-			"nodeInfo",
-			"peers",
-			"register",
-			"registerUrl",
-			"saveInfo",
-			"setGlobalRegistrar",
-			"setHashReg",
-			"setUrlHint",
-			"setSolc",
-			"sleep",
-			"sleepBlocks",
-			"startNatSpec",
-			"startRPC",
-			"stopNatSpec",
-			"stopRPC",
-			"verbosity",
-		},
-		"db": []string{
-			"getString",
-			"putString",
-			"getHex",
-			"putHex",
-		},
-		"debug": []string{
-			"dumpBlock",
-			"getBlockRlp",
-			"metrics",
-			"printBlock",
-			"processBlock",
-			"seedHash",
-			"setHead",
-		},
-		"kr": []string{
-			"accounts",
-			"blockNumber",
-			"call",
-			"contract",
-			"coinbase",
-			"compile.lll",
-			"compile.serpent",
-			"compile.solidity",
-			"contract",
-			"defaultAccount",
-			"defaultBlock",
-			"estimateGas",
-			"filter",
-			"getBalance",
-			"getBlock",
-			"getBlockTransactionCount",
-			"getBlockUncleCount",
-			"getCode",
-			"getNatSpec",
-			"getCompilers",
-			"gasPrice",
-			"getStorageAt",
-			"getTransaction",
-			"getTransactionCount",
-			"getTransactionFromBlock",
-			"getTransactionReceipt",
-			"getUncle",
-			"hashrate",
-			"mining",
-			"namereg",
-			"pendingTransactions",
-			"resend",
-			"sendRawTransaction",
-			"sendTransaction",
-			"sign",
-			"syncing",
-		},
-		"miner": []string{
-			"hashrate",
-			"makeDAG",
-			"setKryptonbase",
-			"setExtra",
-			"setGasPrice",
-			"startAutoDAG",
-			"start",
-			"stopAutoDAG",
-			"stop",
-		},
-		"net": []string{
-			"peerCount",
-			"listening",
-		},
-		"personal": []string{
-			"listAccounts",
-			"newAccount",
-			"unlockAccount",
-		},
-		"shh": []string{
-			"post",
-			"newIdentity",
-			"hasIdentity",
-			"newGroup",
-			"addToGroup",
-			"filter",
-		},
-		"txpool": []string{
-			"status",
-		},
-		"web3": []string{
-			"sha3",
-			"version",
-			"fromWei",
-			"toWei",
-			"toHex",
-			"toAscii",
-			"fromAscii",
-			"toBigNumber",
-			"isAddress",
-		},
-	}
-)
-
 // Parse a comma separated API string to individual api's
 func ParseApiString(apistr string, codec codec.Codec, xkr *xkr.XKr, kr *kr.Krypton) ([]shared.KryptonApi, error) {
 	if len(strings.TrimSpace(apistr)) == 0 {
@@ -174,6 +48,8 @@ func ParseApiString(apistr string, codec codec.Codec, xkr *xkr.XKr, kr *kr.Krypt
 			apis[i] = NewKrApi(xkr, kr, codec)
 		case shared.MinerApiName:
 			apis[i] = NewMinerApi(kr, codec)
+		case shared.MultisigApiName:
+			apis[i] = NewMultisigApi(xkr, kr, codec)
 		case shared.NetApiName:
 			apis[i] = NewNetApi(xkr, kr, codec)
 		case shared.ShhApiName:
@@ -204,6 +80,8 @@ func Javascript(name string) string {
 		return Kr_JS
 	case shared.MinerApiName:
 		return Miner_JS
+	case shared.MultisigApiName:
+		return Multisig_JS
 	case shared.NetApiName:
 		return Net_JS
 	case shared.ShhApiName:
@@ -216,3 +94,34 @@ func Javascript(name string) string {
 
 	return ""
 }
+
+// Docs returns the console documentation of the methods and properties of the
+// api with the given name.
+func Docs(name string) []shared.MethodDoc {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case shared.AdminApiName:
+		return Admin_Docs
+	case shared.DebugApiName:
+		return Debug_Docs
+	case shared.DbApiName:
+		return Db_Docs
+	case shared.KrApiName:
+		return Kr_Docs
+	case shared.MinerApiName:
+		return Miner_Docs
+	case shared.MultisigApiName:
+		return Multisig_Docs
+	case shared.NetApiName:
+		return Net_Docs
+	case shared.ShhApiName:
+		return Shh_Docs
+	case shared.TxPoolApiName:
+		return TxPool_Docs
+	case shared.PersonalApiName:
+		return Personal_Docs
+	case shared.Web3ApiName:
+		return Web3_Docs
+	}
+
+	return nil
+}
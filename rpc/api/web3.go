@@ -34,6 +34,19 @@ var (
 		"web3_sha3":          (*web3Api).Sha3,
 		"web3_clientVersion": (*web3Api).ClientVersion,
 	}
+
+	// Web3_Docs documents the web3 utilities in the console.
+	Web3_Docs = []shared.MethodDoc{
+		{Name: "fromAscii", Params: []string{"string"}, Description: "Converts an ASCII string to hex."},
+		{Name: "fromWei", Params: []string{"number", "unit"}, Description: "Converts an amount of wei to the given unit."},
+		{Name: "isAddress", Params: []string{"string"}, Description: "Returns whether the string is a valid address."},
+		{Name: "sha3", Params: []string{"string"}, Description: "Returns the Keccak-256 hash of the string."},
+		{Name: "toAscii", Params: []string{"hex"}, Description: "Converts hex data to an ASCII string."},
+		{Name: "toBigNumber", Params: []string{"value"}, Description: "Converts a number or numeric string to a BigNumber."},
+		{Name: "toHex", Params: []string{"value"}, Description: "Converts a value to hex."},
+		{Name: "toWei", Params: []string{"number", "unit"}, Description: "Converts an amount in the given unit to wei."},
+		{Name: "version", Property: true, Description: "Versions of the node, the API and the networks."},
+	}
 )
 
 // web3 callback handler
@@ -0,0 +1,271 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package client implements a Go client for the Krypton JSON-RPC API, reachable
+// over HTTP, IPC or websocket connections.
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/krypton/go-krypton/logger"
+	"github.com/krypton/go-krypton/logger/glog"
+	"github.com/krypton/go-krypton/rpc/shared"
+)
+
+// DefaultTimeout is the time a call may take before it is abandoned, unless the
+// client is configured otherwise.
+const DefaultTimeout = 60 * time.Second
+
+var (
+	ErrClientClosed = errors.New("client closed")
+	ErrTimeout      = errors.New("request timed out")
+	ErrNoResult     = errors.New("no result in JSON-RPC response")
+
+	errConnectionLost = errors.New("connection lost")
+)
+
+// Error is an error returned by the remote node in response to a call.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", err.Message, err.Code)
+}
+
+// BatchElem is a single request in a batch call. The result of a successful
+// call is unmarshalled into Result, failures are reported in Error.
+type BatchElem struct {
+	Method string
+	Args   []interface{}
+	Result interface{}
+	Error  error
+}
+
+// jsonRequest is an outbound JSON-RPC request.
+type jsonRequest struct {
+	Id      uint32          `json:"id"`
+	Jsonrpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// jsonResponse is an inbound JSON-RPC response, successful or failed.
+type jsonResponse struct {
+	Id     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+// transport is the connection specific part of a client, delivering a request
+// (or batch of requests) to the remote node and collecting the responses.
+type transport interface {
+	// roundTrip sends the encoded payload and waits until a response arrived to
+	// every request or the timeout expires.
+	roundTrip(payload []byte, ids []uint32, timeout time.Duration) (map[uint32]*jsonResponse, error)
+
+	// close tears down the connection, aborting any pending calls.
+	close()
+}
+
+// Client is a connection to a Krypton RPC endpoint. It is safe for concurrent
+// use by multiple goroutines, each having any number of calls in flight.
+type Client struct {
+	transport transport
+	lastId    uint32
+	timeout   int64 // Call timeout in nanoseconds, accessed atomically
+
+	closed bool
+	lock   sync.RWMutex
+}
+
+// Dial connects to the RPC endpoint at the given location. Endpoints starting
+// with http:// or https:// are reached via HTTP, ws:// via websockets, anything
+// else is considered to be the path of an IPC socket (optionally prefixed with
+// ipc:).
+func Dial(endpoint string) (*Client, error) {
+	var (
+		t   transport
+		err error
+	)
+	switch {
+	case strings.HasPrefix(endpoint, "http://"), strings.HasPrefix(endpoint, "https://"):
+		t = newHttpTransport(endpoint)
+	case strings.HasPrefix(endpoint, "ws://"):
+		t, err = newStreamTransport(func() (messageConn, error) { return dialWebsocket(endpoint) })
+	default:
+		path := strings.TrimPrefix(endpoint, "ipc:")
+		t, err = newStreamTransport(func() (messageConn, error) { return dialIpc(path) })
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		transport: t,
+		timeout:   int64(DefaultTimeout),
+	}, nil
+}
+
+// SetTimeout changes the time calls may take before they are abandoned.
+func (c *Client) SetTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&c.timeout, int64(timeout))
+}
+
+// Close tears down the connection to the remote node. Pending calls are aborted.
+func (c *Client) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		c.transport.close()
+	}
+}
+
+// Call invokes the given method with the arguments and unmarshals the result into
+// result, which should be a pointer. A nil result discards the reply.
+func (c *Client) Call(result interface{}, method string, args ...interface{}) error {
+	return c.CallTimeout(time.Duration(atomic.LoadInt64(&c.timeout)), result, method, args...)
+}
+
+// CallTimeout is like Call, but abandons the call if no reply arrived within the
+// given timeout.
+func (c *Client) CallTimeout(timeout time.Duration, result interface{}, method string, args ...interface{}) error {
+	batch := []BatchElem{{Method: method, Args: args, Result: result}}
+	if err := c.send(batch, false, timeout); err != nil {
+		return err
+	}
+	return batch[0].Error
+}
+
+// BatchCall sends all given requests as a single batch and waits for the server
+// to reply to all of them. The returned error only reports transport failures,
+// errors of individual calls are stored in the Error field of the elements.
+func (c *Client) BatchCall(batch []BatchElem) error {
+	return c.BatchCallTimeout(time.Duration(atomic.LoadInt64(&c.timeout)), batch)
+}
+
+// BatchCallTimeout is like BatchCall, but abandons the batch if no reply arrived
+// within the given timeout.
+func (c *Client) BatchCallTimeout(timeout time.Duration, batch []BatchElem) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	return c.send(batch, true, timeout)
+}
+
+// send encodes the batch into JSON-RPC requests, delivers them via the transport
+// and fills in the results.
+func (c *Client) send(batch []BatchElem, isBatch bool, timeout time.Duration) error {
+	c.lock.RLock()
+	closed := c.closed
+	c.lock.RUnlock()
+
+	if closed {
+		return ErrClientClosed
+	}
+	// Assemble the requests with unique ids
+	requests := make([]*jsonRequest, len(batch))
+	ids := make([]uint32, len(batch))
+	for i, elem := range batch {
+		args := elem.Args
+		if args == nil {
+			args = []interface{}{}
+		}
+		params, err := json.Marshal(args)
+		if err != nil {
+			return err
+		}
+		ids[i] = atomic.AddUint32(&c.lastId, 1)
+		requests[i] = &jsonRequest{
+			Id:      ids[i],
+			Jsonrpc: shared.JsonRpcVersion,
+			Method:  elem.Method,
+			Params:  params,
+		}
+	}
+	var (
+		payload []byte
+		err     error
+	)
+	if isBatch {
+		payload, err = json.Marshal(requests)
+	} else {
+		payload, err = json.Marshal(requests[0])
+	}
+	if err != nil {
+		return err
+	}
+	// Deliver the requests and distribute the responses
+	responses, err := c.transport.roundTrip(payload, ids, timeout)
+	if err != nil {
+		return err
+	}
+	for i, id := range ids {
+		res, ok := responses[id]
+		switch {
+		case !ok:
+			batch[i].Error = ErrNoResult
+		case res.Error != nil:
+			batch[i].Error = res.Error
+		case batch[i].Result != nil && len(res.Result) > 0:
+			batch[i].Error = json.Unmarshal(res.Result, batch[i].Result)
+		}
+	}
+	return nil
+}
+
+// parseResponses decodes a single JSON-RPC response or a batch of them, indexing
+// them by request id. Responses with unknown ids are discarded.
+func parseResponses(data []byte) (map[uint32]*jsonResponse, error) {
+	var responses []*jsonResponse
+
+	data = []byte(strings.TrimSpace(string(data)))
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &responses); err != nil {
+			return nil, err
+		}
+	} else {
+		res := new(jsonResponse)
+		if err := json.Unmarshal(data, res); err != nil {
+			return nil, err
+		}
+		responses = append(responses, res)
+	}
+	indexed := make(map[uint32]*jsonResponse)
+	for _, res := range responses {
+		var id uint32
+		if err := json.Unmarshal(res.Id, &id); err != nil {
+			// The server fails requests it can't decode with a null id. Such a
+			// failure can only be attributed if it's the only response.
+			if len(responses) == 1 && res.Error != nil {
+				return nil, res.Error
+			}
+			glog.V(logger.Debug).Infof("dropping response with invalid id %s", res.Id)
+			continue
+		}
+		indexed[id] = res
+	}
+	return indexed, nil
+}
@@ -0,0 +1,475 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/core/types"
+	"github.com/krypton/go-krypton/core/vm"
+	"github.com/krypton/go-krypton/crypto"
+	"github.com/krypton/go-krypton/rlp"
+	"github.com/krypton/go-krypton/rpc/api"
+)
+
+// testNode is a fake RPC server, serving a tiny chain using the response encoders
+// of the real RPC API.
+type testNode struct {
+	blocks []*types.Block
+	logs   vm.Logs
+
+	lock    sync.Mutex
+	sent    []*types.Transaction // Raw transactions submitted to the node
+	heads   []common.Hash        // Block hashes not yet reported to the block filter
+	filters int                  // Number of installed block filters
+}
+
+func newTestNode(t *testing.T) *testNode {
+	key, _ := crypto.GenerateKey()
+
+	genesis := types.NewBlock(&types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(131072), GasLimit: big.NewInt(3141592), GasUsed: new(big.Int), Time: big.NewInt(0)}, nil, nil, nil)
+	uncle := &types.Header{ParentHash: genesis.Hash(), Number: big.NewInt(1), Difficulty: big.NewInt(131072), GasLimit: big.NewInt(3141592), GasUsed: new(big.Int), Time: big.NewInt(5), Extra: []byte("uncle")}
+
+	tx1, _ := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1000), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(key)
+	tx2, _ := types.NewContractCreation(1, new(big.Int), big.NewInt(100000), big.NewInt(1), []byte{0x60, 0x00}).SignECDSA(key)
+
+	header := &types.Header{
+		ParentHash: genesis.Hash(),
+		Coinbase:   common.Address{0xaa},
+		Number:     big.NewInt(1),
+		Difficulty: big.NewInt(131072),
+		GasLimit:   big.NewInt(3141592),
+		GasUsed:    big.NewInt(21000),
+		Time:       big.NewInt(10),
+		Extra:      []byte("test"),
+		MixDigest:  common.Hash{0x0b},
+		Nonce:      types.EncodeNonce(42),
+	}
+	block := types.NewBlock(header, []*types.Transaction{tx1, tx2}, []*types.Header{uncle}, nil)
+
+	log := vm.NewLog(common.Address{0x02}, []common.Hash{{0x03}}, []byte{0x04}, 1)
+	log.TxHash, log.BlockHash, log.Index = tx1.Hash(), block.Hash(), 0
+
+	return &testNode{blocks: []*types.Block{genesis, block}, logs: vm.Logs{log}}
+}
+
+// handle executes a single RPC method.
+func (n *testNode) handle(method string, params []json.RawMessage) (interface{}, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	var arg0 string
+	if len(params) > 0 {
+		json.Unmarshal(params[0], &arg0)
+	}
+	switch method {
+	case "eth_blockNumber":
+		return fmt.Sprintf("%#x", len(n.blocks)-1), nil
+
+	case "eth_getBlockByNumber", "eth_getBlockByHash":
+		var full bool
+		json.Unmarshal(params[1], &full)
+		for _, block := range n.blocks {
+			if (method == "eth_getBlockByNumber" && fmt.Sprintf("%#x", block.Number()) == arg0) || block.Hash().Hex() == arg0 {
+				return api.NewBlockRes(block, block.Difficulty(), full), nil
+			}
+		}
+		if arg0 == "latest" {
+			return api.NewBlockRes(n.blocks[len(n.blocks)-1], nil, full), nil
+		}
+		return nil, nil
+
+	case "eth_getUncleByBlockHashAndIndex":
+		var index string
+		json.Unmarshal(params[1], &index)
+		for _, block := range n.blocks {
+			if block.Hash().Hex() == arg0 {
+				return api.NewUncleRes(block.Uncles()[common.String2Big(index).Int64()]), nil
+			}
+		}
+		return nil, nil
+
+	case "eth_getBalance":
+		// Report the first address byte as the balance
+		return fmt.Sprintf("%#x", common.FromHex(arg0)[0]), nil
+
+	case "eth_sendRawTransaction":
+		tx := new(types.Transaction)
+		if err := rlp.DecodeBytes(common.FromHex(arg0), tx); err != nil {
+			return nil, &Error{Code: -32602, Message: err.Error()}
+		}
+		n.sent = append(n.sent, tx)
+		return tx.Hash().Hex(), nil
+
+	case "eth_getLogs":
+		var query struct {
+			Address []string   `json:"address"`
+			Topics  [][]string `json:"topics"`
+		}
+		json.Unmarshal(params[0], &query)
+		if len(query.Address) != 1 || query.Address[0] != n.logs[0].Address.Hex() {
+			return []interface{}{}, nil
+		}
+		return api.NewLogsRes(n.logs), nil
+
+	case "eth_newBlockFilter":
+		n.filters++
+		return "0x1", nil
+
+	case "eth_getFilterChanges":
+		hashes := make([]string, len(n.heads))
+		for i, hash := range n.heads {
+			hashes[i] = hash.Hex()
+		}
+		n.heads = nil
+		return hashes, nil
+
+	case "eth_uninstallFilter":
+		n.filters--
+		return true, nil
+
+	case "test_sleep":
+		n.lock.Unlock()
+		time.Sleep(time.Second)
+		n.lock.Lock()
+		return nil, nil
+	}
+	return nil, &Error{Code: -32601, Message: fmt.Sprintf("The method %s does not exist/is not available", method)}
+}
+
+// serve answers an encoded request or batch of requests.
+func (n *testNode) serve(data []byte) []byte {
+	type request struct {
+		Id     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	answer := func(req *request) map[string]interface{} {
+		res := map[string]interface{}{"id": req.Id, "jsonrpc": "2.0"}
+		if result, err := n.handle(req.Method, req.Params); err != nil {
+			res["error"] = err
+		} else {
+			res["result"] = result
+		}
+		return res
+	}
+	var reply interface{}
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		var batch []*request
+		json.Unmarshal(data, &batch)
+
+		responses := make([]interface{}, len(batch))
+		for i, req := range batch {
+			responses[i] = answer(req)
+		}
+		reply = responses
+	} else {
+		req := new(request)
+		json.Unmarshal(data, req)
+		reply = answer(req)
+	}
+	out, _ := json.Marshal(reply)
+	return out
+}
+
+// ServeHTTP implements http.Handler, answering plain HTTP requests or upgrading
+// them to websocket connections.
+func (n *testNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Upgrade") != "websocket" {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(n.serve(body))
+		return
+	}
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", wsAcceptKey(r.Header.Get("Sec-WebSocket-Key")))
+	rw.Flush()
+
+	var wlock sync.Mutex
+	for {
+		_, opcode, payload, err := readWsFrame(rw)
+		if err != nil || opcode == wsOpClose {
+			return
+		}
+		go func() {
+			reply := n.serve(payload)
+			wlock.Lock()
+			writeWsFrame(conn, wsOpText, reply, false)
+			wlock.Unlock()
+		}()
+	}
+}
+
+// serveIpc accepts connections on the listener, answering concatenated JSON
+// requests concurrently.
+func (n *testNode) serveIpc(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+
+			var wlock sync.Mutex
+			dec := json.NewDecoder(bufio.NewReader(conn))
+			for {
+				var msg json.RawMessage
+				if err := dec.Decode(&msg); err != nil {
+					return
+				}
+				go func() {
+					reply := n.serve(msg)
+					wlock.Lock()
+					conn.Write(reply)
+					wlock.Unlock()
+				}()
+			}
+		}()
+	}
+}
+
+// dialTestNodes starts the fake node on all transports and connects to it.
+func dialTestNodes(t *testing.T, node *testNode) (clients map[string]*Client, teardown func()) {
+	server := httptest.NewServer(node)
+
+	dir, err := ioutil.TempDir("", "rpc-client-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("unix", filepath.Join(dir, "test.ipc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go node.serveIpc(listener)
+
+	clients = make(map[string]*Client)
+	for name, endpoint := range map[string]string{
+		"http": server.URL,
+		"ws":   "ws://" + strings.TrimPrefix(server.URL, "http://"),
+		"ipc":  filepath.Join(dir, "test.ipc"),
+	} {
+		if clients[name], err = Dial(endpoint); err != nil {
+			t.Fatalf("%s: failed to dial: %v", name, err)
+		}
+	}
+	return clients, func() {
+		for _, client := range clients {
+			client.Close()
+		}
+		listener.Close()
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// Tests that blocks and headers are reassembled from their RPC encoding.
+func TestBlockRetrieval(t *testing.T) {
+	node := newTestNode(t)
+	clients, teardown := dialTestNodes(t, node)
+	defer teardown()
+
+	want := node.blocks[1]
+	for name, client := range clients {
+		number, err := client.BlockNumber()
+		if err != nil || number.Cmp(want.Number()) != 0 {
+			t.Errorf("%s: block number mismatch: have %v/%v, want %v", name, number, err, want.Number())
+		}
+		block, err := client.BlockByNumber(big.NewInt(1))
+		if err != nil {
+			t.Fatalf("%s: failed to retrieve block: %v", name, err)
+		}
+		if block.Hash() != want.Hash() {
+			t.Errorf("%s: block hash mismatch: have %x, want %x", name, block.Hash(), want.Hash())
+		}
+		if len(block.Transactions()) != 2 || len(block.Uncles()) != 1 {
+			t.Errorf("%s: block body mismatch: have %d txs/%d uncles, want 2/1", name, len(block.Transactions()), len(block.Uncles()))
+		}
+		if block, err = client.BlockByHash(want.Hash()); err != nil || block.Hash() != want.Hash() {
+			t.Errorf("%s: block by hash mismatch: %v", name, err)
+		}
+		header, err := client.HeaderByNumber(nil)
+		if err != nil || header.Hash() != want.Hash() {
+			t.Errorf("%s: latest header mismatch: %v", name, err)
+		}
+		if _, err := client.BlockByNumber(big.NewInt(2)); err != ErrNotFound {
+			t.Errorf("%s: missing block error mismatch: have %v, want %v", name, err, ErrNotFound)
+		}
+	}
+}
+
+// Tests that many calls can be in flight concurrently, with the responses routed
+// back to the right callers.
+func TestConcurrentCalls(t *testing.T) {
+	clients, teardown := dialTestNodes(t, newTestNode(t))
+	defer teardown()
+
+	for name, client := range clients {
+		var wg sync.WaitGroup
+		for i := 0; i < 64; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				balance, err := client.BalanceAt(common.Address{byte(i)}, nil)
+				if err != nil || balance.Int64() != int64(i) {
+					t.Errorf("%s: call %d: balance mismatch: have %v/%v, want %d", name, i, balance, err, i)
+				}
+			}(i)
+		}
+		wg.Wait()
+	}
+}
+
+// Tests that batches are delivered in one go, with individual failures reported
+// per element.
+func TestBatchCall(t *testing.T) {
+	clients, teardown := dialTestNodes(t, newTestNode(t))
+	defer teardown()
+
+	for name, client := range clients {
+		var balance, number hexBig
+		batch := []BatchElem{
+			{Method: "eth_getBalance", Args: []interface{}{common.Address{7}.Hex(), "latest"}, Result: &balance},
+			{Method: "eth_unknown"},
+			{Method: "eth_blockNumber", Result: &number},
+		}
+		if err := client.BatchCall(batch); err != nil {
+			t.Fatalf("%s: batch failed: %v", name, err)
+		}
+		if batch[0].Error != nil || balance.Int().Int64() != 7 {
+			t.Errorf("%s: balance mismatch: have %v/%v, want 7", name, balance.Int(), batch[0].Error)
+		}
+		if err, ok := batch[1].Error.(*Error); !ok || err.Code != -32601 {
+			t.Errorf("%s: unknown method error mismatch: have %v", name, batch[1].Error)
+		}
+		if batch[2].Error != nil || number.Int().Int64() != 1 {
+			t.Errorf("%s: block number mismatch: have %v/%v, want 1", name, number.Int(), batch[2].Error)
+		}
+	}
+}
+
+// Tests that calls are abandoned after their timeout.
+func TestCallTimeout(t *testing.T) {
+	clients, teardown := dialTestNodes(t, newTestNode(t))
+	defer teardown()
+
+	for name, client := range clients {
+		if err := client.CallTimeout(50*time.Millisecond, nil, "test_sleep"); err == nil {
+			t.Errorf("%s: slow call didn't time out", name)
+		}
+		// Make sure the client remains usable
+		if _, err := client.BlockNumber(); err != nil {
+			t.Errorf("%s: call after timeout failed: %v", name, err)
+		}
+	}
+}
+
+// Tests that transactions are submitted in their raw encoding and logs decoded.
+func TestTransactionsAndLogs(t *testing.T) {
+	node := newTestNode(t)
+	clients, teardown := dialTestNodes(t, node)
+	defer teardown()
+
+	for name, client := range clients {
+		tx := node.blocks[1].Transactions()[0]
+		if err := client.SendTransaction(tx); err != nil {
+			t.Fatalf("%s: failed to send transaction: %v", name, err)
+		}
+		node.lock.Lock()
+		if sent := node.sent[len(node.sent)-1]; sent.Hash() != tx.Hash() {
+			t.Errorf("%s: sent transaction mismatch: have %x, want %x", name, sent.Hash(), tx.Hash())
+		}
+		node.lock.Unlock()
+
+		logs, err := client.FilterLogs(FilterQuery{
+			FromBlock: big.NewInt(0),
+			Addresses: []common.Address{{0x02}},
+			Topics:    [][]common.Hash{nil, {{0x03}}},
+		})
+		if err != nil {
+			t.Fatalf("%s: failed to filter logs: %v", name, err)
+		}
+		if len(logs) != 1 {
+			t.Fatalf("%s: log count mismatch: have %d, want 1", name, len(logs))
+		}
+		if want := node.logs[0]; logs[0].Address != want.Address || logs[0].Topics[0] != want.Topics[0] || logs[0].TxHash != want.TxHash || logs[0].BlockNumber != want.BlockNumber {
+			t.Errorf("%s: log mismatch: have %v, want %v", name, logs[0], want)
+		}
+	}
+}
+
+// Tests that new chain heads are delivered to subscribers, and that the filter is
+// uninstalled when unsubscribing.
+func TestSubscribeNewHead(t *testing.T) {
+	defer func(interval time.Duration) { filterPollInterval = interval }(filterPollInterval)
+	filterPollInterval = 10 * time.Millisecond
+
+	node := newTestNode(t)
+	clients, teardown := dialTestNodes(t, node)
+	defer teardown()
+
+	client := clients["ipc"]
+	heads := make(chan *types.Header)
+	sub, err := client.SubscribeNewHead(heads)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	node.lock.Lock()
+	node.heads = append(node.heads, node.blocks[0].Hash(), node.blocks[1].Hash())
+	node.lock.Unlock()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case head := <-heads:
+			if head.Hash() != node.blocks[i].Hash() {
+				t.Errorf("head %d mismatch: have %x, want %x", i, head.Hash(), node.blocks[i].Hash())
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(time.Second):
+			t.Fatalf("head %d not delivered", i)
+		}
+	}
+	sub.Unsubscribe()
+	if _, ok := <-sub.Err(); ok {
+		t.Errorf("error channel not closed after unsubscribe")
+	}
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	if node.filters != 0 {
+		t.Errorf("filter not uninstalled")
+	}
+}
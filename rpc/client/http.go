@@ -0,0 +1,67 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// httpTransport delivers each request (or batch) in a separate HTTP POST,
+// allowing any number of them to be in flight concurrently.
+type httpTransport struct {
+	endpoint string
+	client   *http.Transport
+}
+
+func newHttpTransport(endpoint string) *httpTransport {
+	return &httpTransport{
+		endpoint: endpoint,
+		client:   &http.Transport{Proxy: http.ProxyFromEnvironment},
+	}
+}
+
+func (t *httpTransport) roundTrip(payload []byte, ids []uint32, timeout time.Duration) (map[uint32]*jsonResponse, error) {
+	req, err := http.NewRequest("POST", t.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Transport: t.client, Timeout: timeout}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP request failed: %s", res.Status)
+	}
+	reply, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseResponses(reply)
+}
+
+func (t *httpTransport) close() {
+	t.client.CloseIdleConnections()
+}
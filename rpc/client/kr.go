@@ -0,0 +1,401 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/core/types"
+	"github.com/krypton/go-krypton/core/vm"
+	"github.com/krypton/go-krypton/rlp"
+)
+
+// This file contains typed wrappers around the eth_ namespace of the RPC API,
+// converting the JSON encoded replies back into core types.
+
+var (
+	ErrNotFound     = errors.New("not found")
+	errHashMismatch = errors.New("hash mismatch, server sent inconsistent data")
+)
+
+// hexBytes is a byte slice encoded as a 0x prefixed hex string. A null value
+// decodes into a nil slice.
+type hexBytes []byte
+
+func (b *hexBytes) UnmarshalJSON(input []byte) error {
+	if string(input) == "null" {
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(input, &str); err != nil {
+		return err
+	}
+	*b = common.FromHex(str)
+	return nil
+}
+
+// hexBig is a big integer encoded as a 0x prefixed hex string.
+type hexBig big.Int
+
+func (b *hexBig) UnmarshalJSON(input []byte) error {
+	var str string
+	if err := json.Unmarshal(input, &str); err != nil {
+		return err
+	}
+	if !common.HasHexPrefix(str) {
+		return fmt.Errorf("invalid hex number %q", str)
+	}
+	digits := strings.TrimLeft(str[2:], "0")
+	if digits == "" {
+		digits = "0"
+	}
+	if _, ok := (*big.Int)(b).SetString(digits, 16); !ok {
+		return fmt.Errorf("invalid hex number %q", str)
+	}
+	return nil
+}
+
+func (b *hexBig) Int() *big.Int {
+	return new(big.Int).Set((*big.Int)(b))
+}
+
+// blockNumberArg encodes a block number for the RPC API, nil meaning the latest.
+func blockNumberArg(number *big.Int) string {
+	if number == nil {
+		return "latest"
+	}
+	return fmt.Sprintf("%#x", number)
+}
+
+// rpcHeader is the JSON encoding of a block or uncle header.
+type rpcHeader struct {
+	Hash        hexBytes `json:"hash"`
+	Number      hexBig   `json:"number"`
+	ParentHash  hexBytes `json:"parentHash"`
+	Nonce       hexBytes `json:"nonce"`
+	MixHash     hexBytes `json:"mixHash"`
+	UncleHash   hexBytes `json:"sha3Uncles"`
+	Bloom       hexBytes `json:"logsBloom"`
+	TxHash      hexBytes `json:"transactionsRoot"`
+	Root        hexBytes `json:"stateRoot"`
+	ReceiptRoot hexBytes `json:"receiptRoot"` // Used by blocks
+	ReceiptHash hexBytes `json:"receiptHash"` // Used by uncles
+	Coinbase    hexBytes `json:"miner"`
+	Difficulty  hexBig   `json:"difficulty"`
+	Extra       hexBytes `json:"extraData"`
+	GasLimit    hexBig   `json:"gasLimit"`
+	GasUsed     hexBig   `json:"gasUsed"`
+	Time        hexBig   `json:"timestamp"`
+}
+
+// header assembles the core header and checks that it hashes to the value
+// reported by the server.
+func (h *rpcHeader) header() (*types.Header, error) {
+	receipts := h.ReceiptRoot
+	if len(receipts) == 0 {
+		receipts = h.ReceiptHash
+	}
+	header := &types.Header{
+		ParentHash:  common.BytesToHash(h.ParentHash),
+		UncleHash:   common.BytesToHash(h.UncleHash),
+		Coinbase:    common.BytesToAddress(h.Coinbase),
+		Root:        common.BytesToHash(h.Root),
+		TxHash:      common.BytesToHash(h.TxHash),
+		ReceiptHash: common.BytesToHash(receipts),
+		Bloom:       types.BytesToBloom(h.Bloom),
+		Difficulty:  h.Difficulty.Int(),
+		Number:      h.Number.Int(),
+		GasLimit:    h.GasLimit.Int(),
+		GasUsed:     h.GasUsed.Int(),
+		Time:        h.Time.Int(),
+		Extra:       []byte(h.Extra),
+		MixDigest:   common.BytesToHash(h.MixHash),
+	}
+	copy(header.Nonce[:], h.Nonce)
+
+	if header.Hash() != common.BytesToHash(h.Hash) {
+		return nil, errHashMismatch
+	}
+	return header, nil
+}
+
+// rpcTransaction is the JSON encoding of a signed transaction.
+type rpcTransaction struct {
+	Hash     hexBytes `json:"hash"`
+	Nonce    hexBig   `json:"nonce"`
+	To       hexBytes `json:"to"`
+	Value    hexBig   `json:"value"`
+	Gas      hexBig   `json:"gas"`
+	GasPrice hexBig   `json:"gasPrice"`
+	Input    hexBytes `json:"input"`
+	V        hexBig   `json:"v"`
+	R        hexBig   `json:"r"`
+	S        hexBig   `json:"s"`
+}
+
+// transaction assembles the signed core transaction and checks that it hashes to
+// the value reported by the server.
+func (t *rpcTransaction) transaction() (*types.Transaction, error) {
+	var tx *types.Transaction
+	if t.To == nil {
+		tx = types.NewContractCreation(t.Nonce.Int().Uint64(), t.Value.Int(), t.Gas.Int(), t.GasPrice.Int(), t.Input)
+	} else {
+		tx = types.NewTransaction(t.Nonce.Int().Uint64(), common.BytesToAddress(t.To), t.Value.Int(), t.Gas.Int(), t.GasPrice.Int(), t.Input)
+	}
+	v := t.V.Int().Int64()
+	if v < 27 || v > 28 {
+		return nil, fmt.Errorf("invalid signature value v = %d", v)
+	}
+	sig := make([]byte, 65)
+	copy(sig[:32], common.LeftPadBytes(t.R.Int().Bytes(), 32))
+	copy(sig[32:64], common.LeftPadBytes(t.S.Int().Bytes(), 32))
+	sig[64] = byte(v - 27)
+
+	tx, err := tx.WithSignature(sig)
+	if err != nil {
+		return nil, err
+	}
+	if tx.Hash() != common.BytesToHash(t.Hash) {
+		return nil, errHashMismatch
+	}
+	return tx, nil
+}
+
+// rpcBlock is the JSON encoding of a block including its full transactions.
+type rpcBlock struct {
+	rpcHeader
+	Transactions []*rpcTransaction `json:"transactions"`
+	Uncles       []hexBytes        `json:"uncles"`
+}
+
+// rpcLog is the JSON encoding of a contract log event.
+type rpcLog struct {
+	Address          hexBytes   `json:"address"`
+	Topics           []hexBytes `json:"topics"`
+	Data             hexBytes   `json:"data"`
+	BlockNumber      hexBig     `json:"blockNumber"`
+	LogIndex         hexBig     `json:"logIndex"`
+	BlockHash        hexBytes   `json:"blockHash"`
+	TransactionHash  hexBytes   `json:"transactionHash"`
+	TransactionIndex hexBig     `json:"transactionIndex"`
+}
+
+func (l *rpcLog) log() *vm.Log {
+	log := &vm.Log{
+		Address:     common.BytesToAddress(l.Address),
+		Topics:      make([]common.Hash, len(l.Topics)),
+		Data:        []byte(l.Data),
+		BlockNumber: l.BlockNumber.Int().Uint64(),
+		TxHash:      common.BytesToHash(l.TransactionHash),
+		TxIndex:     uint(l.TransactionIndex.Int().Uint64()),
+		BlockHash:   common.BytesToHash(l.BlockHash),
+		Index:       uint(l.LogIndex.Int().Uint64()),
+	}
+	for i, topic := range l.Topics {
+		log.Topics[i] = common.BytesToHash(topic)
+	}
+	return log
+}
+
+// callNullable is like Call, but reports a null result as ErrNotFound.
+func (c *Client) callNullable(result interface{}, method string, args ...interface{}) error {
+	var raw json.RawMessage
+	if err := c.Call(&raw, method, args...); err != nil {
+		return err
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return ErrNotFound
+	}
+	return json.Unmarshal(raw, result)
+}
+
+// BlockNumber returns the number of the most recent block in the canonical chain.
+func (c *Client) BlockNumber() (*big.Int, error) {
+	var number hexBig
+	if err := c.Call(&number, "eth_blockNumber"); err != nil {
+		return nil, err
+	}
+	return number.Int(), nil
+}
+
+// HeaderByNumber returns the canonical header with the given number, or the
+// latest one if number is nil.
+func (c *Client) HeaderByNumber(number *big.Int) (*types.Header, error) {
+	return c.getHeader("eth_getBlockByNumber", blockNumberArg(number))
+}
+
+// HeaderByHash returns the header of the block with the given hash.
+func (c *Client) HeaderByHash(hash common.Hash) (*types.Header, error) {
+	return c.getHeader("eth_getBlockByHash", hash.Hex())
+}
+
+func (c *Client) getHeader(method string, id string) (*types.Header, error) {
+	res := new(rpcHeader)
+	if err := c.callNullable(res, method, id, false); err != nil {
+		return nil, err
+	}
+	return res.header()
+}
+
+// BlockByNumber returns the canonical block with the given number, or the latest
+// one if number is nil.
+func (c *Client) BlockByNumber(number *big.Int) (*types.Block, error) {
+	return c.getBlock("eth_getBlockByNumber", blockNumberArg(number))
+}
+
+// BlockByHash returns the block with the given hash.
+func (c *Client) BlockByHash(hash common.Hash) (*types.Block, error) {
+	return c.getBlock("eth_getBlockByHash", hash.Hex())
+}
+
+// getBlock retrieves a block along with its transactions. Uncle headers are not
+// included in the block encoding, so they are fetched in a follow-up batch.
+func (c *Client) getBlock(method string, id string) (*types.Block, error) {
+	res := new(rpcBlock)
+	if err := c.callNullable(res, method, id, true); err != nil {
+		return nil, err
+	}
+	header, err := res.header()
+	if err != nil {
+		return nil, err
+	}
+	txs := make([]*types.Transaction, len(res.Transactions))
+	for i, rtx := range res.Transactions {
+		if txs[i], err = rtx.transaction(); err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i, err)
+		}
+	}
+	uncles := make([]*types.Header, len(res.Uncles))
+	if len(uncles) > 0 {
+		results := make([]*rpcHeader, len(uncles))
+		batch := make([]BatchElem, len(uncles))
+		for i := range batch {
+			results[i] = new(rpcHeader)
+			batch[i] = BatchElem{
+				Method: "eth_getUncleByBlockHashAndIndex",
+				Args:   []interface{}{header.Hash().Hex(), fmt.Sprintf("%#x", i)},
+				Result: results[i],
+			}
+		}
+		if err := c.BatchCall(batch); err != nil {
+			return nil, err
+		}
+		for i := range batch {
+			if batch[i].Error != nil {
+				return nil, fmt.Errorf("uncle %d: %v", i, batch[i].Error)
+			}
+			if uncles[i], err = results[i].header(); err != nil {
+				return nil, fmt.Errorf("uncle %d: %v", i, err)
+			}
+			if uncles[i].Hash() != common.BytesToHash(res.Uncles[i]) {
+				return nil, fmt.Errorf("uncle %d: %v", i, errHashMismatch)
+			}
+		}
+	}
+	block := types.NewBlockWithHeader(header).WithBody(txs, uncles)
+	if types.DeriveSha(types.Transactions(txs)) != header.TxHash || types.CalcUncleHash(uncles) != header.UncleHash {
+		return nil, errHashMismatch
+	}
+	return block, nil
+}
+
+// BalanceAt returns the balance of the account at the given block, or the latest
+// one if blockNumber is nil.
+func (c *Client) BalanceAt(account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	var balance hexBig
+	if err := c.Call(&balance, "eth_getBalance", account.Hex(), blockNumberArg(blockNumber)); err != nil {
+		return nil, err
+	}
+	return balance.Int(), nil
+}
+
+// NonceAt returns the nonce of the account at the given block, or the latest one
+// if blockNumber is nil.
+func (c *Client) NonceAt(account common.Address, blockNumber *big.Int) (uint64, error) {
+	var nonce hexBig
+	if err := c.Call(&nonce, "eth_getTransactionCount", account.Hex(), blockNumberArg(blockNumber)); err != nil {
+		return 0, err
+	}
+	return nonce.Int().Uint64(), nil
+}
+
+// SendTransaction injects a signed transaction into the pending pool of the
+// remote node.
+func (c *Client) SendTransaction(tx *types.Transaction) error {
+	data, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return err
+	}
+	return c.Call(nil, "eth_sendRawTransaction", common.ToHex(data))
+}
+
+// FilterQuery contains the criteria of a log search. Nil block numbers mean the
+// latest block, an empty address list matches any contract, and nil entries in
+// the topic list match any topic at that position.
+type FilterQuery struct {
+	FromBlock *big.Int
+	ToBlock   *big.Int
+	Addresses []common.Address
+	Topics    [][]common.Hash
+}
+
+func (q FilterQuery) args() map[string]interface{} {
+	args := map[string]interface{}{
+		"fromBlock": blockNumberArg(q.FromBlock),
+		"toBlock":   blockNumberArg(q.ToBlock),
+	}
+	if len(q.Addresses) > 0 {
+		addresses := make([]string, len(q.Addresses))
+		for i, address := range q.Addresses {
+			addresses[i] = address.Hex()
+		}
+		args["address"] = addresses
+	}
+	if len(q.Topics) > 0 {
+		topics := make([]interface{}, len(q.Topics))
+		for i, alternatives := range q.Topics {
+			if len(alternatives) == 0 {
+				continue // nil matches anything
+			}
+			hashes := make([]string, len(alternatives))
+			for j, topic := range alternatives {
+				hashes[j] = topic.Hex()
+			}
+			topics[i] = hashes
+		}
+		args["topics"] = topics
+	}
+	return args
+}
+
+// FilterLogs executes a one-off log search on the remote node.
+func (c *Client) FilterLogs(q FilterQuery) (vm.Logs, error) {
+	var res []*rpcLog
+	if err := c.Call(&res, "eth_getLogs", q.args()); err != nil {
+		return nil, err
+	}
+	logs := make(vm.Logs, len(res))
+	for i, l := range res {
+		logs[i] = l.log()
+	}
+	return logs, nil
+}
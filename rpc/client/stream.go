@@ -0,0 +1,217 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/krypton/go-krypton/logger"
+	"github.com/krypton/go-krypton/logger/glog"
+)
+
+// messageConn is a connection transferring whole JSON messages.
+type messageConn interface {
+	ReadMessage() ([]byte, error)
+	WriteMessage([]byte) error
+	Close() error
+}
+
+// ipcConn is a messageConn over an IPC socket, where messages are concatenated
+// JSON values.
+type ipcConn struct {
+	conn net.Conn
+	dec  *json.Decoder
+}
+
+func dialIpc(path string) (messageConn, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &ipcConn{conn: conn, dec: json.NewDecoder(conn)}, nil
+}
+
+func (c *ipcConn) ReadMessage() ([]byte, error) {
+	var msg json.RawMessage
+	if err := c.dec.Decode(&msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (c *ipcConn) WriteMessage(msg []byte) error {
+	_, err := c.conn.Write(msg)
+	return err
+}
+
+func (c *ipcConn) Close() error {
+	return c.conn.Close()
+}
+
+// streamTransport multiplexes calls over a single persistent connection. Writes
+// are serialized, while a dedicated goroutine reads the responses and routes
+// them to the waiting calls by request id. If the connection breaks (e.g. the
+// server closed it due to inactivity), it is re-dialed on the next call.
+type streamTransport struct {
+	dial func() (messageConn, error)
+
+	conn    messageConn                   // Currently open connection, nil if broken
+	pending map[uint32]chan *jsonResponse // Calls waiting for their response
+	closed  bool
+
+	lock  sync.Mutex // Protects the connection state and the pending calls
+	wlock sync.Mutex // Serializes writes to the connection
+}
+
+func newStreamTransport(dial func() (messageConn, error)) (*streamTransport, error) {
+	t := &streamTransport{
+		dial:    dial,
+		pending: make(map[uint32]chan *jsonResponse),
+	}
+	// Dial once to report unreachable endpoints early
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, err := t.connect(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// connect returns the open connection, dialing a new one if needed. The caller
+// must hold the lock.
+func (t *streamTransport) connect() (messageConn, error) {
+	if t.closed {
+		return nil, ErrClientClosed
+	}
+	if t.conn == nil {
+		conn, err := t.dial()
+		if err != nil {
+			return nil, err
+		}
+		t.conn = conn
+		go t.read(conn)
+	}
+	return t.conn, nil
+}
+
+func (t *streamTransport) roundTrip(payload []byte, ids []uint32, timeout time.Duration) (map[uint32]*jsonResponse, error) {
+	// Register the pending calls and make sure there's a connection to use
+	t.lock.Lock()
+	conn, err := t.connect()
+	if err != nil {
+		t.lock.Unlock()
+		return nil, err
+	}
+	replies := make(chan *jsonResponse, len(ids))
+	for _, id := range ids {
+		t.pending[id] = replies
+	}
+	t.lock.Unlock()
+
+	defer func() {
+		t.lock.Lock()
+		for _, id := range ids {
+			delete(t.pending, id)
+		}
+		t.lock.Unlock()
+	}()
+	// Send the requests and wait for all responses to arrive
+	t.wlock.Lock()
+	err = conn.WriteMessage(payload)
+	t.wlock.Unlock()
+	if err != nil {
+		t.drop(conn, err)
+		return nil, err
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	responses := make(map[uint32]*jsonResponse)
+	for len(responses) < len(ids) {
+		select {
+		case res := <-replies:
+			if res == nil {
+				return nil, errConnectionLost
+			}
+			var id uint32
+			json.Unmarshal(res.Id, &id)
+			responses[id] = res
+
+		case <-timer.C:
+			return nil, ErrTimeout
+		}
+	}
+	return responses, nil
+}
+
+// read is the receive loop of a connection, routing each response to the call
+// waiting for it.
+func (t *streamTransport) read(conn messageConn) {
+	for {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			t.drop(conn, err)
+			return
+		}
+		responses, err := parseResponses(msg)
+		if err != nil {
+			glog.V(logger.Debug).Infof("dropping invalid RPC response: %v", err)
+			continue
+		}
+		t.lock.Lock()
+		for id, res := range responses {
+			if replies, ok := t.pending[id]; ok {
+				replies <- res
+				delete(t.pending, id)
+			}
+		}
+		t.lock.Unlock()
+	}
+}
+
+// drop discards a broken connection, failing all calls pending on it.
+func (t *streamTransport) drop(conn messageConn, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.conn != conn {
+		return
+	}
+	glog.V(logger.Debug).Infof("RPC connection dropped: %v", err)
+
+	conn.Close()
+	t.conn = nil
+	for id, replies := range t.pending {
+		replies <- nil
+		delete(t.pending, id)
+	}
+}
+
+func (t *streamTransport) close() {
+	t.lock.Lock()
+	conn := t.conn
+	t.closed = true
+	t.lock.Unlock()
+
+	if conn != nil {
+		t.drop(conn, ErrClientClosed)
+	}
+}
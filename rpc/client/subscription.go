@@ -0,0 +1,116 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/core/types"
+	"github.com/krypton/go-krypton/logger"
+	"github.com/krypton/go-krypton/logger/glog"
+)
+
+// filterPollInterval is the time between two polls of a server side filter.
+var filterPollInterval = time.Second
+
+// Subscription is a stream of events delivered from the remote node. The RPC API
+// has no push notifications, so events are collected by polling a server side
+// filter, which is removed again when the subscription ends.
+type Subscription struct {
+	client *Client
+	filter json.RawMessage // Id of the server side filter
+
+	err  chan error
+	quit chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// SubscribeNewHead delivers the header of every block newly added to the
+// canonical chain of the remote node into ch. Slow consumers block the delivery
+// of further headers, but no headers are dropped.
+func (c *Client) SubscribeNewHead(ch chan<- *types.Header) (*Subscription, error) {
+	var filter json.RawMessage
+	if err := c.Call(&filter, "eth_newBlockFilter"); err != nil {
+		return nil, err
+	}
+	sub := &Subscription{
+		client: c,
+		filter: filter,
+		err:    make(chan error, 1),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go sub.loop(ch)
+	return sub, nil
+}
+
+// Err returns a channel on which the error terminating the subscription is
+// delivered. The channel is closed by Unsubscribe.
+func (s *Subscription) Err() <-chan error {
+	return s.err
+}
+
+// Unsubscribe stops the delivery of events and removes the server side filter.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.quit)
+		<-s.done
+
+		if err := s.client.Call(nil, "eth_uninstallFilter", s.filter); err != nil {
+			glog.V(logger.Debug).Infof("failed to uninstall filter %s: %v", s.filter, err)
+		}
+		close(s.err)
+	})
+}
+
+// loop polls the block filter, retrieving and delivering the header of every new
+// block until unsubscribed or a call fails.
+func (s *Subscription) loop(ch chan<- *types.Header) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(filterPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.quit:
+			return
+		}
+		var hashes []hexBytes
+		if err := s.client.Call(&hashes, "eth_getFilterChanges", s.filter); err != nil {
+			s.err <- err
+			return
+		}
+		for _, hash := range hashes {
+			header, err := s.client.HeaderByHash(common.BytesToHash(hash))
+			if err != nil {
+				s.err <- err
+				return
+			}
+			select {
+			case ch <- header:
+			case <-s.quit:
+				return
+			}
+		}
+	}
+}
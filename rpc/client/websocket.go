@@ -0,0 +1,230 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// This file contains a minimal RFC 6455 websocket client, sufficient to carry
+// JSON-RPC messages as text frames.
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xa
+
+	wsMaxMessageSize = 16 * 1024 * 1024 // Maximum size of a reassembled message
+	wsGUID           = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+var (
+	errWsClosed       = errors.New("websocket closed by peer")
+	errWsTooLarge     = errors.New("websocket message too large")
+	errWsBadHandshake = errors.New("websocket handshake failed")
+)
+
+// wsConn is a messageConn over a websocket connection.
+type wsConn struct {
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// dialWebsocket opens a websocket connection to the given ws:// endpoint.
+func dialWebsocket(endpoint string) (messageConn, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "80")
+	}
+	conn, err := net.Dial("tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	ws := &wsConn{conn: conn, rd: bufio.NewReader(conn)}
+	if err := ws.handshake(u); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ws, nil
+}
+
+// handshake upgrades the raw connection to the websocket protocol.
+func (ws *wsConn) handshake(u *url.URL) error {
+	nonce := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	path := u.RequestURI()
+	req := fmt.Sprintf("GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\nOrigin: http://%s\r\n\r\n", path, u.Host, key, u.Host)
+	if _, err := io.WriteString(ws.conn, req); err != nil {
+		return err
+	}
+	res, err := http.ReadResponse(ws.rd, &http.Request{Method: "GET"})
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols || res.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) {
+		return errWsBadHandshake
+	}
+	return nil
+}
+
+// wsAcceptKey computes the accept token the server must reply with for the key.
+func wsAcceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// ReadMessage reads the next data message, reassembling fragmented ones and
+// answering any interleaved control frames.
+func (ws *wsConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := readWsFrame(ws.rd)
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case wsOpPing:
+			if err := writeWsFrame(ws.conn, wsOpPong, payload, true); err != nil {
+				return nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			writeWsFrame(ws.conn, wsOpClose, nil, true)
+			return nil, errWsClosed
+		}
+		if len(message)+len(payload) > wsMaxMessageSize {
+			return nil, errWsTooLarge
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// WriteMessage sends the message in a single masked text frame.
+func (ws *wsConn) WriteMessage(msg []byte) error {
+	return writeWsFrame(ws.conn, wsOpText, msg, true)
+}
+
+func (ws *wsConn) Close() error {
+	writeWsFrame(ws.conn, wsOpClose, nil, true)
+	return ws.conn.Close()
+}
+
+// readWsFrame reads a single websocket frame, unmasking its payload if needed.
+func readWsFrame(r io.Reader) (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(r, head[:]); err != nil {
+		return
+	}
+	fin, opcode = head[0]&0x80 != 0, head[0]&0x0f
+	masked, size := head[1]&0x80 != 0, uint64(head[1]&0x7f)
+
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(r, ext[:]); err != nil {
+			return
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(r, ext[:]); err != nil {
+			return
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+	if size > wsMaxMessageSize {
+		err = errWsTooLarge
+		return
+	}
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(r, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, size)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// writeWsFrame writes a single final websocket frame. Clients must mask all the
+// frames they send, servers must not.
+func writeWsFrame(w io.Writer, opcode byte, payload []byte, masked bool) error {
+	frame := []byte{0x80 | opcode, 0}
+	switch size := len(payload); {
+	case size < 126:
+		frame[1] = byte(size)
+	case size <= 0xffff:
+		frame[1] = 126
+		frame = append(frame, byte(size>>8), byte(size))
+	default:
+		frame[1] = 127
+		ext := make([]byte, 8)
+		binary.BigEndian.PutUint64(ext, uint64(size))
+		frame = append(frame, ext...)
+	}
+	if masked {
+		frame[1] |= 0x80
+
+		mask := make([]byte, 4)
+		if _, err := io.ReadFull(rand.Reader, mask); err != nil {
+			return err
+		}
+		frame = append(frame, mask...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	_, err := w.Write(frame)
+	return err
+}
@@ -0,0 +1,151 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package comms
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/krypton/go-krypton/logger"
+	"github.com/krypton/go-krypton/logger/glog"
+	"github.com/krypton/go-krypton/rpc/shared"
+)
+
+const (
+	DefaultMaxBatchSize   = 100              // Maximum number of requests accepted in a single batch
+	DefaultRequestTimeout = 30 * time.Second // Maximum time a single request may execute
+
+	batchWorkers = 8 // Number of batch entries executed concurrently per batch
+)
+
+// executor runs RPC requests against an API, enforcing the batch size limit and
+// the per request execution deadline.
+type executor struct {
+	api          shared.KryptonApi
+	maxBatchSize int
+	timeout      time.Duration
+}
+
+// newExecutor creates a request executor for the given API. Non-positive limits
+// are replaced by their defaults.
+func newExecutor(api shared.KryptonApi, maxBatchSize int, timeout time.Duration) *executor {
+	if maxBatchSize <= 0 {
+		maxBatchSize = DefaultMaxBatchSize
+	}
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
+	return &executor{
+		api:          api,
+		maxBatchSize: maxBatchSize,
+		timeout:      timeout,
+	}
+}
+
+// execute runs a single request and assembles its response. If the request does
+// not finish within the deadline, it is signalled to abort and a timeout error
+// is returned in its stead. Methods the API declares untimed run without a
+// deadline.
+//
+// Note, the handler of a timed out request is not interrupted forcibly: it is
+// expected to watch the request's Cancel channel if it may run long.
+func (self *executor) execute(req *shared.Request) *interface{} {
+	type result struct {
+		reply interface{}
+		err   error
+	}
+	run := func() (res result) {
+		defer func() {
+			if r := recover(); r != nil {
+				glog.Errorf("panic executing %s: %v\n", req.Method, r)
+				res = result{nil, fmt.Errorf("%s method failed", req.Method)}
+			}
+		}()
+		reply, err := self.api.Execute(req)
+		return result{reply, err}
+	}
+	if api, ok := self.api.(shared.UntimedApi); ok && api.Untimed(req.Method) {
+		res := run()
+		return shared.NewRpcResponse(req.Id, req.Jsonrpc, res.reply, res.err)
+	}
+	cancel := make(chan struct{})
+	req.Cancel = cancel
+
+	done := make(chan result, 1)
+	go func() { done <- run() }()
+
+	timer := time.NewTimer(self.timeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-done:
+		return shared.NewRpcResponse(req.Id, req.Jsonrpc, res.reply, res.err)
+	case <-timer.C:
+		close(cancel)
+		glog.V(logger.Debug).Infof("RPC request %s timed out after %v", req.Method, self.timeout)
+		return shared.NewRpcResponse(req.Id, req.Jsonrpc, nil, shared.NewTimeoutError(req.Method, self.timeout))
+	}
+}
+
+// executeBatch runs all requests of a batch concurrently on a bounded pool of
+// workers. Responses are returned in request order, omitting notifications (i.e.
+// requests without an id). If the batch is too large, a single error response is
+// returned for the whole batch.
+func (self *executor) executeBatch(requests []*shared.Request) interface{} {
+	if len(requests) > self.maxBatchSize {
+		err := fmt.Errorf("batch too large, have %d requests, max %d", len(requests), self.maxBatchSize)
+		return shared.NewRpcErrorResponse(nil, shared.JsonRpcVersion, -32600, err)
+	}
+	responses := make([]*interface{}, len(requests))
+
+	workers := batchWorkers
+	if len(requests) < workers {
+		workers = len(requests)
+	}
+	tasks := make(chan int, len(requests))
+	for i := range requests {
+		tasks <- i
+	}
+	close(tasks)
+
+	var pending sync.WaitGroup
+	pending.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer pending.Done()
+			for index := range tasks {
+				if requests[index] == nil {
+					var res interface{} = shared.NewRpcErrorResponse(nil, shared.JsonRpcVersion, -32600, fmt.Errorf("invalid request"))
+					responses[index] = &res
+					continue
+				}
+				responses[index] = self.execute(requests[index])
+			}
+		}()
+	}
+	pending.Wait()
+
+	// Drop the responses to notifications, retaining request order
+	filtered := make([]*interface{}, 0, len(responses))
+	for i, res := range responses {
+		if requests[i] == nil || requests[i].Id != nil {
+			filtered = append(filtered, res)
+		}
+	}
+	return filtered
+}
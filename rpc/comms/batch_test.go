@@ -0,0 +1,168 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package comms
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/krypton/go-krypton/rpc/shared"
+)
+
+// testApi is an RPC API where "sleep" blocks until the request is cancelled,
+// "slow" returns its params after a long delay and "echo" after a short one.
+type testApi struct {
+	running   int32 // Number of currently executing requests
+	peak      int32 // Maximum number of concurrently executing requests
+	cancelled chan struct{}
+	untimed   map[string]bool // Methods exempt from the request deadline
+}
+
+func (api *testApi) Name() string       { return "test" }
+func (api *testApi) ApiVersion() string { return "1.0" }
+func (api *testApi) Methods() []string  { return []string{"echo", "sleep", "slow"} }
+
+func (api *testApi) Untimed(method string) bool { return api.untimed[method] }
+
+func (api *testApi) Execute(req *shared.Request) (interface{}, error) {
+	running := atomic.AddInt32(&api.running, 1)
+	defer atomic.AddInt32(&api.running, -1)
+
+	for {
+		peak := atomic.LoadInt32(&api.peak)
+		if running <= peak || atomic.CompareAndSwapInt32(&api.peak, peak, running) {
+			break
+		}
+	}
+	switch req.Method {
+	case "sleep":
+		<-req.Cancel
+		close(api.cancelled)
+		return nil, nil
+	case "slow":
+		time.Sleep(100 * time.Millisecond)
+		return string(req.Params), nil
+	default:
+		time.Sleep(10 * time.Millisecond)
+		return string(req.Params), nil
+	}
+}
+
+func newTestRequest(id interface{}, method string, param string) *shared.Request {
+	return &shared.Request{
+		Id:      id,
+		Jsonrpc: shared.JsonRpcVersion,
+		Method:  method,
+		Params:  json.RawMessage(param),
+	}
+}
+
+// Tests that batch entries are executed concurrently, but the responses are
+// returned in request order with notifications omitted.
+func TestBatchOrdering(t *testing.T) {
+	api := &testApi{}
+	exec := newExecutor(api, 0, time.Second)
+
+	requests := make([]*shared.Request, 3*batchWorkers)
+	for i := range requests {
+		var id interface{} = i
+		if i%3 == 0 {
+			id = nil
+		}
+		requests[i] = newTestRequest(id, "echo", string('a'+rune(i)))
+	}
+	responses, ok := exec.executeBatch(requests).([]*interface{})
+	if !ok {
+		t.Fatalf("batch response type mismatch: have %T", exec.executeBatch(requests))
+	}
+	if len(responses) != 2*batchWorkers {
+		t.Fatalf("response count mismatch: have %d, want %d", len(responses), 2*batchWorkers)
+	}
+	for i, res := range responses {
+		index := i/2*3 + i%2 + 1
+		success, ok := (*res).(*shared.SuccessResponse)
+		if !ok {
+			t.Fatalf("response %d: not successful: %v", i, *res)
+		}
+		if success.Id != index || success.Result != string('a'+rune(index)) {
+			t.Errorf("response %d: mismatch: have %v/%v, want %v/%v", i, success.Id, success.Result, index, string('a'+rune(index)))
+		}
+	}
+	if peak := atomic.LoadInt32(&api.peak); peak < 2 || peak > batchWorkers {
+		t.Errorf("concurrency mismatch: have %d, want within [2, %d]", peak, batchWorkers)
+	}
+}
+
+// Tests that batches above the configured limit are rejected as a whole.
+func TestBatchSizeLimit(t *testing.T) {
+	exec := newExecutor(&testApi{}, 2, time.Second)
+
+	requests := []*shared.Request{
+		newTestRequest(1, "echo", "1"),
+		newTestRequest(2, "echo", "2"),
+		newTestRequest(3, "echo", "3"),
+	}
+	res, ok := exec.executeBatch(requests).(*shared.ErrorResponse)
+	if !ok {
+		t.Fatalf("oversized batch accepted")
+	}
+	if res.Error.Code != -32600 {
+		t.Errorf("error code mismatch: have %d, want %d", res.Error.Code, -32600)
+	}
+}
+
+// Tests that a slow request is cancelled after its deadline, without stalling
+// the other entries of the same batch.
+func TestBatchRequestTimeout(t *testing.T) {
+	api := &testApi{cancelled: make(chan struct{})}
+	exec := newExecutor(api, 0, 50*time.Millisecond)
+
+	requests := []*shared.Request{
+		newTestRequest(1, "sleep", "1"),
+		newTestRequest(2, "echo", "2"),
+	}
+	responses := exec.executeBatch(requests).([]*interface{})
+	if len(responses) != 2 {
+		t.Fatalf("response count mismatch: have %d, want %d", len(responses), 2)
+	}
+	failure, ok := (*responses[0]).(*shared.ErrorResponse)
+	if !ok {
+		t.Fatalf("slow request didn't time out: %v", *responses[0])
+	}
+	if failure.Error.Code != -32000 {
+		t.Errorf("error code mismatch: have %d, want %d", failure.Error.Code, -32000)
+	}
+	if _, ok := (*responses[1]).(*shared.SuccessResponse); !ok {
+		t.Errorf("fast request failed: %v", *responses[1])
+	}
+	select {
+	case <-api.cancelled:
+	case <-time.After(time.Second):
+		t.Fatalf("timed out request not cancelled")
+	}
+}
+
+// Tests that methods exempt from the deadline run to completion.
+func TestBatchUntimedMethod(t *testing.T) {
+	exec := newExecutor(&testApi{untimed: map[string]bool{"slow": true}}, 0, 10*time.Millisecond)
+	res := exec.execute(newTestRequest(1, "slow", `"done"`))
+	if success, ok := (*res).(*shared.SuccessResponse); !ok || success.Result != `"done"` {
+		t.Errorf("untimed request failed: %v", *res)
+	}
+}
@@ -59,7 +59,7 @@ type KryptonClient interface {
 	SupportedModules() (map[string]string, error)
 }
 
-func handle(id int, conn net.Conn, api shared.KryptonApi, c codec.Codec) {
+func handle(id int, conn net.Conn, exec *executor, c codec.Codec) {
 	codec := c.New(conn)
 
 	defer func() {
@@ -78,33 +78,15 @@ func handle(id int, conn net.Conn, api shared.KryptonApi, c codec.Codec) {
 			return
 		}
 
+		var response interface{}
 		if isBatch {
-			responses := make([]*interface{}, len(requests))
-			responseCount := 0
-			for _, req := range requests {
-				res, err := api.Execute(req)
-				if req.Id != nil {
-					rpcResponse := shared.NewRpcResponse(req.Id, req.Jsonrpc, res, err)
-					responses[responseCount] = rpcResponse
-					responseCount += 1
-				}
-			}
-
-			err = codec.WriteResponse(responses[:responseCount])
-			if err != nil {
-				glog.V(logger.Debug).Infof("Closed IPC Conn %06d send err - %v\n", id, err)
-				return
-			}
+			response = exec.executeBatch(requests)
 		} else {
-			var rpcResponse interface{}
-			res, err := api.Execute(requests[0])
-
-			rpcResponse = shared.NewRpcResponse(requests[0].Id, requests[0].Jsonrpc, res, err)
-			err = codec.WriteResponse(rpcResponse)
-			if err != nil {
-				glog.V(logger.Debug).Infof("Closed IPC Conn %06d send err - %v\n", id, err)
-				return
-			}
+			response = exec.execute(requests[0])
+		}
+		if err = codec.WriteResponse(response); err != nil {
+			glog.V(logger.Debug).Infof("Closed IPC Conn %06d send err - %v\n", id, err)
+			return
 		}
 	}
 }
@@ -48,9 +48,11 @@ var (
 )
 
 type HttpConfig struct {
-	ListenAddress string
-	ListenPort    uint
-	CorsDomain    string
+	ListenAddress  string
+	ListenPort     uint
+	CorsDomain     string
+	MaxBatchSize   int           // Maximum number of requests in a batch (0 = default)
+	RequestTimeout time.Duration // Maximum execution time of a request (0 = default)
 }
 
 // stopServer augments http.Server with idle connection tracking.
@@ -66,7 +68,7 @@ type stopServer struct {
 
 type handler struct {
 	codec codec.Codec
-	api   shared.KryptonApi
+	exec  *executor
 }
 
 // StartHTTP starts listening for RPC requests sent via HTTP.
@@ -82,7 +84,7 @@ func StartHttp(cfg HttpConfig, codec codec.Codec, api shared.KryptonApi) error {
 		return nil // RPC service already running on given host/port
 	}
 	// Set up the request handler, wrapping it with CORS headers if configured.
-	handler := http.Handler(&handler{codec, api})
+	handler := http.Handler(&handler{codec, newExecutor(api, cfg.MaxBatchSize, cfg.RequestTimeout)})
 	if len(cfg.CorsDomain) > 0 {
 		opts := cors.Options{
 			AllowedMethods: []string{"POST"},
@@ -123,25 +125,14 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	c := h.codec.New(nil)
 	var rpcReq shared.Request
 	if err = c.Decode(payload, &rpcReq); err == nil {
-		reply, err := h.api.Execute(&rpcReq)
-		res := shared.NewRpcResponse(rpcReq.Id, rpcReq.Jsonrpc, reply, err)
+		res := h.exec.execute(&rpcReq)
 		sendJSON(w, &res)
 		return
 	}
 
-	var reqBatch []shared.Request
+	var reqBatch []*shared.Request
 	if err = c.Decode(payload, &reqBatch); err == nil {
-		resBatch := make([]*interface{}, len(reqBatch))
-		resCount := 0
-		for i, rpcReq := range reqBatch {
-			reply, err := h.api.Execute(&rpcReq)
-			if rpcReq.Id != nil { // this leaves nil entries in the response batch for later removal
-				resBatch[i] = shared.NewRpcResponse(rpcReq.Id, rpcReq.Jsonrpc, reply, err)
-				resCount += 1
-			}
-		}
-		// make response omitting nil entries
-		sendJSON(w, resBatch[:resCount])
+		sendJSON(w, h.exec.executeBatch(reqBatch))
 		return
 	}
 
@@ -21,6 +21,7 @@ import (
 	"math/rand"
 	"net"
 	"os"
+	"time"
 
 	"encoding/json"
 
@@ -37,7 +38,9 @@ type Stopper interface {
 type InitFunc func(conn net.Conn) (Stopper, shared.KryptonApi, error)
 
 type IpcConfig struct {
-	Endpoint string
+	Endpoint       string
+	MaxBatchSize   int           // Maximum number of requests in a batch (0 = default)
+	RequestTimeout time.Duration // Maximum execution time of a request (0 = default)
 }
 
 type ipcClient struct {
@@ -128,7 +131,7 @@ func ipcLoop(cfg IpcConfig, codec codec.Codec, initializer InitFunc, l net.Liste
 				return
 			}
 			defer stopper.Stop()
-			handle(id, conn, api, codec)
+			handle(id, conn, newExecutor(api, cfg.MaxBatchSize, cfg.RequestTimeout), codec)
 		}()
 	}
 }
@@ -16,7 +16,10 @@
 
 package shared
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type InvalidTypeError struct {
 	method string
@@ -124,3 +127,19 @@ func NewNotAvailableError(method string, reason string) *NotAvailableError {
 		Reason: reason,
 	}
 }
+
+type TimeoutError struct {
+	Method  string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s method timed out after %v", e.Method, e.Timeout)
+}
+
+func NewTimeoutError(method string, timeout time.Duration) *TimeoutError {
+	return &TimeoutError{
+		Method:  method,
+		Timeout: timeout,
+	}
+}
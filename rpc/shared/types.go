@@ -18,6 +18,7 @@ package shared
 
 import (
 	"encoding/json"
+	"strings"
 
 	"github.com/krypton/go-krypton/logger"
 	"github.com/krypton/go-krypton/logger/glog"
@@ -38,12 +39,42 @@ type KryptonApi interface {
 	Methods() []string
 }
 
+// UntimedApi is implemented by APIs providing methods which are exempt from the
+// request deadline, as they wait for user interaction (e.g. a passphrase prompt
+// or an external signer's approval) or are meant to run for a requested time.
+// All other long running methods should abort when their request is cancelled.
+type UntimedApi interface {
+	// Untimed reports whether the given method runs without a deadline
+	Untimed(method string) bool
+}
+
 // RPC request
 type Request struct {
 	Id      interface{}     `json:"id"`
 	Jsonrpc string          `json:"jsonrpc"`
 	Method  string          `json:"method"`
 	Params  json.RawMessage `json:"params"`
+
+	// Cancel is closed by the server when it gives up on the request (e.g. its
+	// deadline passed). Long running methods may watch it to abort early.
+	Cancel <-chan struct{} `json:"-"`
+}
+
+// Documentation of a method or property an API exposes in the console
+type MethodDoc struct {
+	Name        string   `json:"name"`               // Name within the API's console namespace
+	Params      []string `json:"params,omitempty"`   // Parameter names, optional ones end with '?'
+	Property    bool     `json:"property,omitempty"` // Whether it's a property instead of a method
+	Description string   `json:"description"`
+}
+
+// Signature returns the console invocation of the method, e.g.
+// kr.getBalance(address, block?).
+func (doc MethodDoc) Signature(api string) string {
+	if doc.Property {
+		return api + "." + doc.Name
+	}
+	return api + "." + doc.Name + "(" + strings.Join(doc.Params, ", ") + ")"
 }
 
 // RPC response
@@ -92,7 +123,7 @@ func NewRpcResponse(id interface{}, jsonrpcver string, reply interface{}, err er
 	case *NotImplementedError:
 		jsonerr := &ErrorObject{-32601, err.Error()}
 		response = &ErrorResponse{Jsonrpc: jsonrpcver, Id: id, Error: jsonerr}
-	case *NotReadyError:
+	case *NotReadyError, *TimeoutError:
 		jsonerr := &ErrorObject{-32000, err.Error()}
 		response = &ErrorResponse{Jsonrpc: jsonrpcver, Id: id, Error: jsonerr}
 	case *DecodeParamError, *InsufficientParamsError, *ValidationError, *InvalidTypeError:
@@ -25,6 +25,7 @@ const (
 	DebugApiName    = "debug"
 	MergedApiName   = "merged"
 	MinerApiName    = "miner"
+	MultisigApiName = "multisig"
 	NetApiName      = "net"
 	ShhApiName      = "shh"
 	TxPoolApiName   = "txpool"
@@ -37,7 +38,7 @@ const (
 var (
 	// All API's
 	AllApis = strings.Join([]string{
-		AdminApiName, DbApiName, KrApiName, DebugApiName, MinerApiName, NetApiName,
-		ShhApiName, TxPoolApiName, PersonalApiName, Web3ApiName,
+		AdminApiName, DbApiName, KrApiName, DebugApiName, MinerApiName, MultisigApiName,
+		NetApiName, ShhApiName, TxPoolApiName, PersonalApiName, Web3ApiName,
 	}, ",")
 )
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"sync"
 
 	"github.com/krypton/go-krypton/accounts"
 	"github.com/krypton/go-krypton/common"
@@ -36,12 +37,17 @@ type RemoteFrontend struct {
 	d       *json.Decoder
 	e       *json.Encoder
 	n       int
+	lock    sync.Mutex // serializes agent round trips from concurrently executing requests
 }
 
 // NewRemoteFrontend creates a new frontend which will interact with an user agent
 // over the given connection
 func NewRemoteFrontend(conn net.Conn, mgr *accounts.Manager) *RemoteFrontend {
-	return &RemoteFrontend{false, mgr, json.NewDecoder(conn), json.NewEncoder(conn), 0}
+	return &RemoteFrontend{
+		mgr: mgr,
+		d:   json.NewDecoder(conn),
+		e:   json.NewEncoder(conn),
+	}
 }
 
 // Enable will enable user interaction
@@ -53,6 +59,8 @@ func (fe *RemoteFrontend) AskPassword() (string, bool) {
 	if !fe.enabled {
 		return "", false
 	}
+	fe.lock.Lock()
+	defer fe.lock.Unlock()
 
 	err := fe.send(AskPasswordMethod)
 	if err != nil {
@@ -80,6 +88,8 @@ func (fe *RemoteFrontend) UnlockAccount(address []byte) bool {
 	if !fe.enabled {
 		return false
 	}
+	fe.lock.Lock()
+	defer fe.lock.Unlock()
 
 	err := fe.send(AskPasswordMethod, common.Bytes2Hex(address))
 	if err != nil {
@@ -110,6 +120,8 @@ func (fe *RemoteFrontend) ConfirmTransaction(tx string) bool {
 	if !fe.enabled {
 		return true // backwards compatibility
 	}
+	fe.lock.Lock()
+	defer fe.lock.Unlock()
 
 	err := fe.send(ConfirmTransactionMethod, tx)
 	if err != nil {
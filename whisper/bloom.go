@@ -0,0 +1,78 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the topic bloom filter peers advertise their interests with.
+
+package whisper
+
+const (
+	TopicBloomLength = 64 // Length of a topic bloom filter in bytes
+
+	topicBloomBits = 3 // Number of bits set in the bloom filter by a single topic
+)
+
+// TopicBloom is a bloom filter of the topics a peer is interested in. Full nodes
+// only forward envelopes with a topic matching the bloom filter of a peer.
+type TopicBloom [TopicBloomLength]byte
+
+// NewTopicBloom creates a bloom filter matching all the given topics.
+func NewTopicBloom(topics ...Topic) *TopicBloom {
+	bloom := new(TopicBloom)
+	for _, topic := range topics {
+		bloom.Add(topic)
+	}
+	return bloom
+}
+
+// topicBloomIndexes returns the indexes of the bloom filter bits set by a topic.
+// Each index is made of 9 consecutive bits of the topic, addressing any of the
+// 512 bits in the filter.
+func topicBloomIndexes(topic Topic) [topicBloomBits]uint {
+	var indexes [topicBloomBits]uint
+	for i := range indexes {
+		bits := uint(topic[0])<<24 | uint(topic[1])<<16 | uint(topic[2])<<8 | uint(topic[3])
+		indexes[i] = (bits >> uint(9*i)) % (8 * TopicBloomLength)
+	}
+	return indexes
+}
+
+// Add inserts a topic into the bloom filter.
+func (self *TopicBloom) Add(topic Topic) {
+	for _, index := range topicBloomIndexes(topic) {
+		self[index/8] |= 1 << (index % 8)
+	}
+}
+
+// Test checks whether a topic is (probably) contained in the bloom filter.
+func (self *TopicBloom) Test(topic Topic) bool {
+	for _, index := range topicBloomIndexes(topic) {
+		if self[index/8]&(1<<(index%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches checks whether any of the topics of an envelope is (probably) contained
+// in the bloom filter.
+func (self *TopicBloom) Matches(envelope *Envelope) bool {
+	for _, topic := range envelope.Topics {
+		if self.Test(topic) {
+			return true
+		}
+	}
+	return false
+}
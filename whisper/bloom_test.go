@@ -0,0 +1,51 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package whisper
+
+import "testing"
+
+func TestTopicBloom(t *testing.T) {
+	included := NewTopicsFromStrings("alpha", "beta")
+	excluded := NewTopicsFromStrings("gamma", "delta", "epsilon")
+
+	bloom := NewTopicBloom(included...)
+	for _, topic := range included {
+		if !bloom.Test(topic) {
+			t.Errorf("topic %x: not contained in bloom", topic)
+		}
+	}
+	for _, topic := range excluded {
+		if bloom.Test(topic) {
+			t.Errorf("topic %x: falsely contained in bloom", topic)
+		}
+	}
+	// Envelopes match if any of their topics are contained
+	tests := []struct {
+		topics []Topic
+		match  bool
+	}{
+		{nil, false},
+		{included[:1], true},
+		{excluded, false},
+		{append(excluded[:1:1], included[1]), true},
+	}
+	for i, tt := range tests {
+		if match := bloom.Matches(&Envelope{Topics: tt.topics}); match != tt.match {
+			t.Errorf("test %d: match mismatch: have %v, want %v", i, match, tt.match)
+		}
+	}
+}
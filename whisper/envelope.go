@@ -23,6 +23,7 @@ import (
 	"crypto/ecdsa"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/krypton/go-krypton/common"
@@ -41,6 +42,7 @@ type Envelope struct {
 	Nonce  uint32
 
 	hash common.Hash // Cached hash of the envelope to avoid rehashing every time
+	pow  float64     // Cached proof of work of the envelope
 }
 
 // NewEnvelope wraps a Whisper message with expiration and destination data
@@ -55,26 +57,70 @@ func NewEnvelope(ttl time.Duration, topics []Topic, msg *Message) *Envelope {
 	}
 }
 
-// Seal closes the envelope by spending the requested amount of time as a proof
-// of work on hashing the data.
-func (self *Envelope) Seal(pow time.Duration) {
+// Seal closes the envelope by searching for a nonce that brings its proof of
+// work to at least the requested target. If no such nonce is found before the
+// timeout expires, the best nonce found is kept and ErrSealTimeout returned.
+func (self *Envelope) Seal(target float64, timeout time.Duration) error {
 	d := make([]byte, 64)
-	copy(d[:32], self.rlpWithoutNonce())
+	copy(d[:32], crypto.Sha3(self.rlpWithoutNonce()))
 
-	finish, bestBit := time.Now().Add(pow).UnixNano(), 0
-	for nonce := uint32(0); time.Now().UnixNano() < finish; {
+	// Calculate the number of leading zero bits needed to reach the target
+	needBit := 0
+	if work := target * float64(self.size()) * float64(self.ttl()); work > 1 {
+		needBit = int(math.Ceil(math.Log2(work)))
+	}
+	finish, bestBit := time.Now().Add(timeout), -1
+	for nonce := uint32(0); ; {
 		for i := 0; i < 1024; i++ {
 			binary.BigEndian.PutUint32(d[60:], nonce)
 
 			firstBit := common.FirstBitSet(common.BigD(crypto.Sha3(d)))
 			if firstBit > bestBit {
 				self.Nonce, bestBit = nonce, firstBit
+				if bestBit >= needBit {
+					self.pow = 0
+					return nil
+				}
 			}
 			nonce++
 		}
+		if time.Now().After(finish) {
+			self.pow = 0
+			return ErrSealTimeout
+		}
 	}
 }
 
+// PoW returns the proof of work of the envelope: the work spent sealing it per
+// byte of its size and second of its time to live. The work covers the hash of
+// the entire envelope contents, so any modification after sealing invalidates it.
+func (self *Envelope) PoW() float64 {
+	if self.pow == 0 {
+		d := make([]byte, 64)
+		copy(d[:32], crypto.Sha3(self.rlpWithoutNonce()))
+		binary.BigEndian.PutUint32(d[60:], self.Nonce)
+
+		work := math.Pow(2, float64(common.FirstBitSet(common.BigD(crypto.Sha3(d)))))
+		self.pow = work / float64(self.size()) / float64(self.ttl())
+	}
+	return self.pow
+}
+
+// ttl returns the time to live of the envelope used in the proof of work, at
+// least one second.
+func (self *Envelope) ttl() uint32 {
+	if self.TTL == 0 {
+		return 1
+	}
+	return self.TTL
+}
+
+// size returns the number of bytes the envelope occupies, used both for the
+// proof of work and the accounting of the message pool.
+func (self *Envelope) size() int {
+	return len(self.rlpWithoutNonce()) + 4 // nonce
+}
+
 // rlpWithoutNonce returns the RLP encoded envelope contents, except the nonce.
 func (self *Envelope) rlpWithoutNonce() []byte {
 	enc, _ := rlp.EncodeToBytes([]interface{}{self.Expiry, self.TTL, self.Topics, self.Data})
@@ -83,9 +129,47 @@ func (self *Envelope) rlpWithoutNonce() []byte {
 
 // Open extracts the message contained within a potentially encrypted envelope.
 func (self *Envelope) Open(key *ecdsa.PrivateKey) (msg *Message, err error) {
-	// Split open the payload into a message construct
-	data := self.Data
+	message, err := self.message()
+	if err != nil {
+		return nil, err
+	}
+	// Decrypt the message, if requested
+	if key == nil {
+		return message, nil
+	}
+	err = message.decrypt(key)
+	switch err {
+	case nil:
+		return message, nil
+
+	case ecies.ErrInvalidPublicKey: // Payload isn't encrypted
+		return message, err
+
+	default:
+		return nil, fmt.Errorf("unable to open envelope, decrypt failed: %v", err)
+	}
+}
 
+// OpenSymmetric extracts the message contained within an envelope encrypted with
+// the given symmetric key.
+func (self *Envelope) OpenSymmetric(key []byte) (*Message, error) {
+	message, err := self.message()
+	if err != nil {
+		return nil, err
+	}
+	if err := message.decryptSymmetric(key); err != nil {
+		return nil, fmt.Errorf("unable to open envelope, decrypt failed: %v", err)
+	}
+	return message, nil
+}
+
+// message splits open the payload of the envelope into a message construct,
+// leaving its payload as is.
+func (self *Envelope) message() (*Message, error) {
+	data := self.Data
+	if len(data) == 0 {
+		return nil, fmt.Errorf("unable to open envelope. Empty data")
+	}
 	message := &Message{
 		Flags: data[0],
 		Sent:  time.Unix(int64(self.Expiry-self.TTL), 0),
@@ -102,21 +186,7 @@ func (self *Envelope) Open(key *ecdsa.PrivateKey) (msg *Message, err error) {
 	}
 	message.Payload = data
 
-	// Decrypt the message, if requested
-	if key == nil {
-		return message, nil
-	}
-	err = message.decrypt(key)
-	switch err {
-	case nil:
-		return message, nil
-
-	case ecies.ErrInvalidPublicKey: // Payload isn't encrypted
-		return message, err
-
-	default:
-		return nil, fmt.Errorf("unable to open envelope, decrypt failed: %v", err)
-	}
+	return message, nil
 }
 
 // Hash returns the SHA3 hash of the envelope, calculating it if not yet done.
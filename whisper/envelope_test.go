@@ -156,3 +156,32 @@ func TestEnvelopeIdentifiedOpenTargeted(t *testing.T) {
 		t.Fatalf("payload mismatch: have 0x%x, want 0x%x", opened.Payload, payload)
 	}
 }
+
+func TestEnvelopeSeal(t *testing.T) {
+	envelope := &Envelope{
+		Expiry: 1500000000,
+		TTL:    uint32(DefaultTTL / time.Second),
+		Topics: NewTopicsFromStrings("seal topic"),
+		Data:   []byte("sealed envelope payload"),
+	}
+	if err := envelope.Seal(DefaultMinPoW, DefaultSealTimeout); err != nil {
+		t.Fatalf("failed to seal envelope: %v", err)
+	}
+	if pow := envelope.PoW(); pow < DefaultMinPoW {
+		t.Fatalf("proof of work below target: have %v, want >= %v", pow, DefaultMinPoW)
+	}
+	// Modify the data past the first 32 RLP bytes and check that the work is lost
+	tampered := *envelope
+	tampered.Data = []byte("sealed envelope payloaD")
+	tampered.pow = 0
+	if pow := tampered.PoW(); pow >= DefaultMinPoW {
+		t.Fatalf("tampered envelope kept its proof of work: have %v, want < %v", pow, DefaultMinPoW)
+	}
+}
+
+func TestEnvelopeSealTimeout(t *testing.T) {
+	envelope := NewEnvelope(DefaultTTL, nil, NewMessage([]byte("expensive envelope")))
+	if err := envelope.Seal(1e12, 10*time.Millisecond); err != ErrSealTimeout {
+		t.Fatalf("seal error mismatch: have %v, want %v", err, ErrSealTimeout)
+	}
+}
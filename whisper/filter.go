@@ -26,10 +26,11 @@ import (
 
 // Filter is used to subscribe to specific types of whisper messages.
 type Filter struct {
-	To     *ecdsa.PublicKey   // Recipient of the message
-	From   *ecdsa.PublicKey   // Sender of the message
-	Topics [][]Topic          // Topics to filter messages with
-	Fn     func(msg *Message) // Handler in case of a match
+	To       *ecdsa.PublicKey   // Recipient of the message
+	From     *ecdsa.PublicKey   // Sender of the message
+	SymKeyID string             // Id of the symmetric key the message is encrypted with
+	Topics   [][]Topic          // Topics to filter messages with
+	Fn       func(msg *Message) // Handler in case of a match
 }
 
 // NewFilterTopics creates a 2D topic array used by whisper.Filter from binary
@@ -95,10 +96,11 @@ func NewFilterTopicsFromStringsFlat(data ...string) [][]Topic {
 // filterer is the internal, fully initialized filter ready to match inbound
 // messages to a variety of criteria.
 type filterer struct {
-	to      string                 // Recipient of the message
-	from    string                 // Sender of the message
-	matcher *topicMatcher          // Topics to filter messages with
-	fn      func(data interface{}) // Handler in case of a match
+	to       string                 // Recipient of the message
+	from     string                 // Sender of the message
+	symKeyID string                 // Id of the symmetric key of the message
+	matcher  *topicMatcher          // Topics to filter messages with
+	fn       func(data interface{}) // Handler in case of a match
 }
 
 // Compare checks if the specified filter matches the current one.
@@ -112,6 +114,9 @@ func (self filterer) Compare(f filter.Filter) bool {
 	if len(self.from) > 0 && self.from != filter.from {
 		return false
 	}
+	if len(self.symKeyID) > 0 && self.symKeyID != filter.symKeyID {
+		return false
+	}
 	// Check the topic filtering
 	topics := make([]Topic, len(filter.matcher.conditions))
 	for i, group := range filter.matcher.conditions {
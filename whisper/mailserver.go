@@ -0,0 +1,258 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the mail server archiving envelopes for peers that were offline when
+// they were broadcast.
+
+package whisper
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/krdb"
+	"github.com/krypton/go-krypton/rlp"
+)
+
+const (
+	maxMailRange        = 7 * 24 * 3600   // Maximum time range a mail request may span
+	maxMailResponse     = 256             // Maximum number of envelopes in a response packet
+	maxMailEnvelopes    = 16 * 1024       // Maximum number of envelopes served for a single request
+	mailRequestInterval = 5 * time.Second // Minimum time between two mail requests of a peer
+
+	archivePruneCycle = time.Minute // Interval of dropping stale envelopes from the archive
+)
+
+var archiveRetention uint32 = 30 * 24 * 3600 // Seconds envelopes are archived for after being sent
+
+var (
+	archiveSentPrefix     = []byte("s") // sent prefix + time + hash -> nil, index of all envelopes
+	archiveTopicPrefix    = []byte("t") // topic prefix + topic + time + hash -> nil, index of envelopes by topic
+	archiveEnvelopePrefix = []byte("e") // envelope prefix + hash -> envelope
+)
+
+// mailRequest is the request of a client for the archived envelopes sent within
+// a time range, matching any of the topics (or all if none are given).
+type mailRequest struct {
+	From   uint32
+	To     uint32
+	Topics []Topic
+}
+
+// archive persists envelopes into a database, indexed by their send time and
+// topics. Every envelope has its own index entries, ordered by send time, so
+// both storing and querying avoid rewriting or loading unrelated entries.
+type archive struct {
+	db krdb.Database
+}
+
+// newArchive creates an envelope archive on top of a database.
+func newArchive(db krdb.Database) *archive {
+	return &archive{db: db}
+}
+
+// sentKey assembles the index key of an envelope in the index of all envelopes.
+func sentKey(at uint32, hash common.Hash) []byte {
+	key := make([]byte, len(archiveSentPrefix)+4+len(hash))
+	copy(key, archiveSentPrefix)
+	binary.BigEndian.PutUint32(key[len(archiveSentPrefix):], at)
+	copy(key[len(archiveSentPrefix)+4:], hash[:])
+	return key
+}
+
+// topicKey assembles the index key of an envelope in the index of a topic.
+func topicKey(topic Topic, at uint32, hash common.Hash) []byte {
+	key := make([]byte, len(archiveTopicPrefix)+len(topic)+4+len(hash))
+	copy(key, archiveTopicPrefix)
+	copy(key[len(archiveTopicPrefix):], topic[:])
+	binary.BigEndian.PutUint32(key[len(archiveTopicPrefix)+len(topic):], at)
+	copy(key[len(archiveTopicPrefix)+len(topic)+4:], hash[:])
+	return key
+}
+
+// envelopeKey assembles the database key of an archived envelope.
+func envelopeKey(hash common.Hash) []byte {
+	return append(common.CopyBytes(archiveEnvelopePrefix), hash[:]...)
+}
+
+// sent returns the time an envelope was sent, in seconds since the epoch.
+func sent(envelope *Envelope) uint32 {
+	return envelope.Expiry - envelope.TTL
+}
+
+// store persists an envelope and adds it to the index of all envelopes and to
+// the indexes of its topics.
+func (self *archive) store(envelope *Envelope) error {
+	hash := envelope.Hash()
+	if _, err := self.db.Get(envelopeKey(hash)); err == nil {
+		return nil // already archived
+	}
+	blob, err := rlp.EncodeToBytes(envelope)
+	if err != nil {
+		return err
+	}
+	at := sent(envelope)
+
+	batch := self.db.NewBatch()
+	if err := batch.Put(envelopeKey(hash), blob); err != nil {
+		return err
+	}
+	if err := batch.Put(sentKey(at, hash), nil); err != nil {
+		return err
+	}
+	for _, topic := range envelope.Topics {
+		if err := batch.Put(topicKey(topic, at, hash), nil); err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
+
+// load retrieves an archived envelope.
+func (self *archive) load(hash common.Hash) (*Envelope, error) {
+	blob, err := self.db.Get(envelopeKey(hash))
+	if err != nil {
+		return nil, fmt.Errorf("archived envelope %x missing: %v", hash, err)
+	}
+	envelope := new(Envelope)
+	if err := rlp.DecodeBytes(blob, envelope); err != nil {
+		return nil, fmt.Errorf("archived envelope %x corrupt: %v", hash, err)
+	}
+	return envelope, nil
+}
+
+// prune drops all the envelopes sent before the given time (seconds since the
+// epoch), along with their index entries.
+func (self *archive) prune(before uint32) error {
+	it := self.db.NewIteratorWithPrefix(archiveSentPrefix)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(archiveSentPrefix)+4+len(common.Hash{}) {
+			continue
+		}
+		at := binary.BigEndian.Uint32(key[len(archiveSentPrefix):])
+		if at >= before {
+			break
+		}
+		hash := common.BytesToHash(key[len(archiveSentPrefix)+4:])
+
+		// Drop the topic entries first, so an interrupted prune is retried
+		if envelope, err := self.load(hash); err == nil {
+			for _, topic := range envelope.Topics {
+				if err := self.db.Delete(topicKey(topic, at, hash)); err != nil {
+					return err
+				}
+			}
+		}
+		if err := self.db.Delete(envelopeKey(hash)); err != nil {
+			return err
+		}
+		if err := self.db.Delete(sentKey(at, hash)); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// query streams the archived envelopes sent within [from, to] that have any of
+// the given topics to deliver, in batches of at most maxMailResponse envelopes.
+// Without topics all envelopes of the range are returned. At most limit
+// envelopes are delivered, the rest of the range being silently dropped.
+func (self *archive) query(from, to uint32, topics []Topic, limit int, deliver func([]*Envelope) error) error {
+	if from > to {
+		return fmt.Errorf("invalid time range: %d > %d", from, to)
+	}
+	if to-from > maxMailRange {
+		return fmt.Errorf("time range too large: %ds > %ds", to-from, maxMailRange)
+	}
+	// Assemble the index prefixes to iterate over
+	var prefixes [][]byte
+	if len(topics) == 0 {
+		prefixes = append(prefixes, archiveSentPrefix)
+	}
+	for _, topic := range topics {
+		prefixes = append(prefixes, append(common.CopyBytes(archiveTopicPrefix), topic[:]...))
+	}
+	// Iterate over the time range of each index, delivering full batches
+	var (
+		batch     []*Envelope
+		delivered = 0
+		seen      = make(map[common.Hash]struct{})
+	)
+	for _, prefix := range prefixes {
+		if err := self.iterate(prefix, from, to, func(hash common.Hash) (bool, error) {
+			if _, ok := seen[hash]; ok {
+				return true, nil
+			}
+			seen[hash] = struct{}{}
+
+			envelope, err := self.load(hash)
+			if err != nil {
+				return false, err
+			}
+			batch = append(batch, envelope)
+			if delivered++; len(batch) == maxMailResponse || delivered == limit {
+				if err := deliver(batch); err != nil {
+					return false, err
+				}
+				batch = nil
+			}
+			return delivered < limit, nil
+		}); err != nil {
+			return err
+		}
+		if delivered >= limit {
+			return nil
+		}
+	}
+	if len(batch) > 0 {
+		return deliver(batch)
+	}
+	return nil
+}
+
+// iterate calls fn with the hashes of the index entries under prefix that were
+// sent within [from, to], in order of their send time, until fn returns false.
+func (self *archive) iterate(prefix []byte, from, to uint32, fn func(common.Hash) (bool, error)) error {
+	it := self.db.NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	start := make([]byte, len(prefix)+4)
+	copy(start, prefix)
+	binary.BigEndian.PutUint32(start[len(prefix):], from)
+
+	for ok := it.Seek(start); ok; ok = it.Next() {
+		key := it.Key()
+		if len(key) != len(prefix)+4+len(common.Hash{}) {
+			continue
+		}
+		if binary.BigEndian.Uint32(key[len(prefix):]) > to {
+			break
+		}
+		more, err := fn(common.BytesToHash(key[len(prefix)+4:]))
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+	}
+	return it.Error()
+}
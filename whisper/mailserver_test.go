@@ -0,0 +1,350 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package whisper
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/krdb"
+	"github.com/krypton/go-krypton/p2p"
+	"github.com/krypton/go-krypton/p2p/discover"
+)
+
+// newArchivedEnvelope creates an envelope sent at the given time with the given
+// topics.
+func newArchivedEnvelope(t *testing.T, at uint32, payload string, topics ...string) *Envelope {
+	envelope, err := NewMessage([]byte(payload)).Wrap(0, Options{
+		Topics: NewTopicsFromStrings(topics...),
+		TTL:    DefaultTTL,
+	})
+	if err != nil {
+		t.Fatalf("failed to wrap message: %v", err)
+	}
+	envelope.Expiry = at + envelope.TTL
+	return envelope
+}
+
+// queryArchive collects the envelopes streamed by an archive query.
+func queryArchive(archive *archive, from, to uint32, topics []Topic, limit int) ([]*Envelope, error) {
+	var found []*Envelope
+	err := archive.query(from, to, topics, limit, func(batch []*Envelope) error {
+		if len(batch) > maxMailResponse {
+			return fmt.Errorf("oversized batch: %d > %d", len(batch), maxMailResponse)
+		}
+		found = append(found, batch...)
+		return nil
+	})
+	return found, err
+}
+
+func TestArchiveQuery(t *testing.T) {
+	db, _ := krdb.NewMemDatabase()
+	archive := newArchive(db)
+
+	base := uint32(1440000000)
+	envelopes := []*Envelope{
+		newArchivedEnvelope(t, base, "first", "alpha"),
+		newArchivedEnvelope(t, base+30, "second", "beta"),
+		newArchivedEnvelope(t, base+90, "third", "alpha", "beta"),
+		newArchivedEnvelope(t, base+3600, "fourth"),
+	}
+	for _, envelope := range envelopes {
+		if err := archive.store(envelope); err != nil {
+			t.Fatalf("failed to archive envelope: %v", err)
+		}
+	}
+	// Storing an envelope twice must not duplicate it
+	if err := archive.store(envelopes[0]); err != nil {
+		t.Fatalf("failed to re-archive envelope: %v", err)
+	}
+	tests := []struct {
+		from, to uint32
+		topics   []string
+		want     []int
+	}{
+		{base, base + 3600, nil, []int{0, 1, 2, 3}},                    // everything
+		{base, base + 3600, []string{"alpha"}, []int{0, 2}},            // single topic
+		{base, base + 3600, []string{"alpha", "beta"}, []int{0, 1, 2}}, // any of the topics
+		{base + 1, base + 90, nil, []int{1, 2}},                        // exact range
+		{base + 91, base + 3599, nil, []int{}},                         // gap between envelopes
+		{base, base + 3600, []string{"gamma"}, []int{}},                // unknown topic
+	}
+	for i, tt := range tests {
+		found, err := queryArchive(archive, tt.from, tt.to, NewTopicsFromStrings(tt.topics...), maxMailEnvelopes)
+		if err != nil {
+			t.Errorf("test %d: query failed: %v", i, err)
+			continue
+		}
+		hashes := make(map[common.Hash]bool)
+		for _, envelope := range found {
+			hashes[envelope.Hash()] = true
+		}
+		if len(found) != len(tt.want) || len(hashes) != len(tt.want) {
+			t.Errorf("test %d: result count mismatch: have %d, want %d", i, len(found), len(tt.want))
+			continue
+		}
+		for _, idx := range tt.want {
+			if !hashes[envelopes[idx].Hash()] {
+				t.Errorf("test %d: envelope %d missing", i, idx)
+			}
+		}
+	}
+	// Invalid ranges must be rejected
+	if _, err := queryArchive(archive, base+1, base, nil, maxMailEnvelopes); err == nil {
+		t.Errorf("inverted range accepted")
+	}
+	if _, err := queryArchive(archive, base, base+maxMailRange+1, nil, maxMailEnvelopes); err == nil {
+		t.Errorf("oversized range accepted")
+	}
+}
+
+// Tests that large query results are streamed in bounded batches and capped.
+func TestArchiveQueryLimit(t *testing.T) {
+	db, _ := krdb.NewMemDatabase()
+	archive := newArchive(db)
+
+	base := uint32(1440000000)
+	for i := 0; i < 2*maxMailResponse+10; i++ {
+		if err := archive.store(newArchivedEnvelope(t, base+uint32(i), fmt.Sprintf("mail %d", i), "alpha")); err != nil {
+			t.Fatalf("failed to archive envelope %d: %v", i, err)
+		}
+	}
+	for i, limit := range []int{1, maxMailResponse, maxMailResponse + 1, 2*maxMailResponse + 5, 4 * maxMailResponse} {
+		for _, topics := range [][]Topic{nil, NewTopicsFromStrings("alpha")} {
+			batches, found := 0, 0
+			err := archive.query(base, base+3600, topics, limit, func(batch []*Envelope) error {
+				if len(batch) > maxMailResponse {
+					t.Errorf("test %d: oversized batch: %d > %d", i, len(batch), maxMailResponse)
+				}
+				batches, found = batches+1, found+len(batch)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("test %d: query failed: %v", i, err)
+				continue
+			}
+			want := limit
+			if want > 2*maxMailResponse+10 {
+				want = 2*maxMailResponse + 10
+			}
+			if found != want {
+				t.Errorf("test %d: result count mismatch: have %d, want %d", i, found, want)
+			}
+			if wantBatches := (want + maxMailResponse - 1) / maxMailResponse; batches != wantBatches {
+				t.Errorf("test %d: batch count mismatch: have %d, want %d", i, batches, wantBatches)
+			}
+		}
+	}
+}
+
+func TestMailServer(t *testing.T) {
+	// Start the mail server and feed it with messages while the client is offline
+	db, _ := krdb.NewMemDatabase()
+	server := New()
+	server.EnableMailServer(db)
+	server.Start()
+	defer server.Stop()
+
+	now := uint32(time.Now().Unix())
+	for _, envelope := range []*Envelope{
+		newArchivedEnvelope(t, now-120, "expired mail", "mail topic"),
+		newArchivedEnvelope(t, now-60, "unrelated mail", "other topic"),
+	} {
+		envelope.Expiry = now - 1
+		if err := envelope.Seal(DefaultPoW, DefaultSealTimeout); err != nil {
+			t.Fatalf("failed to reseal envelope: %v", err)
+		}
+		if err := server.Send(envelope); err != nil {
+			t.Fatalf("failed to send envelope: %v", err)
+		}
+	}
+	time.Sleep(2 * expirationCycle) // wait for the envelopes to expire from the pool
+
+	// Connect the client and request the archived messages
+	client := New()
+	client.Start()
+	defer client.Stop()
+
+	serverId, clientId := discover.NodeID{1}, discover.NodeID{2}
+	src, dst := p2p.MsgPipe()
+	defer src.Close()
+
+	go server.handlePeer(p2p.NewPeer(clientId, "client", nil), src, shh3)
+	go client.handlePeer(p2p.NewPeer(serverId, "server", nil), dst, shh3)
+
+	arrived := make(chan *Message, 2)
+	client.Watch(Filter{
+		Topics: NewFilterTopicsFromStringsFlat("mail topic"),
+		Fn: func(msg *Message) {
+			arrived <- msg
+		},
+	})
+	for i := 0; ; i++ {
+		err := client.RequestMessages(serverId, now-3600, now, NewTopicsFromStrings("mail topic"))
+		if err == nil {
+			break
+		}
+		if i == 10 {
+			t.Fatalf("failed to request messages: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case msg := <-arrived:
+		if string(msg.Payload) != "expired mail" {
+			t.Errorf("payload mismatch: have %q, want %q", msg.Payload, "expired mail")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("archived message receive timeout")
+	}
+	select {
+	case msg := <-arrived:
+		t.Fatalf("unexpected message delivered: %q", msg.Payload)
+	case <-time.After(transmissionCycle):
+	}
+	// Requests to unknown peers must fail
+	if err := client.RequestMessages(discover.NodeID{3}, now-3600, now, nil); err == nil {
+		t.Errorf("request to unknown peer succeeded")
+	}
+}
+
+// Tests that mail requests coming in faster than allowed are dropped.
+func TestMailRequestRateLimit(t *testing.T) {
+	db, _ := krdb.NewMemDatabase()
+	client := New()
+	client.EnableMailServer(db)
+
+	now := uint32(time.Now().Unix())
+	if err := client.mail.store(newArchivedEnvelope(t, now-60, "archived mail", "mail topic")); err != nil {
+		t.Fatalf("failed to archive envelope: %v", err)
+	}
+	tester, err := initTestPeer(startTestPeerClient(client, shh3))
+	if err != nil {
+		t.Fatalf("failed to start initialized peer: %v", err)
+	}
+	defer tester.stream.Close()
+
+	// Request the mail twice in a row and count the responses
+	responses := make(chan struct{}, 2)
+	go func() {
+		for {
+			msg, err := tester.stream.ReadMsg()
+			if err != nil {
+				return
+			}
+			if msg.Code == mailResponseCode {
+				responses <- struct{}{}
+			}
+			msg.Discard()
+		}
+	}()
+	for i := 0; i < 2; i++ {
+		if err := p2p.Send(tester.stream, mailRequestCode, &mailRequest{From: now - 3600, To: now}); err != nil {
+			t.Fatalf("failed to send mail request %d: %v", i, err)
+		}
+	}
+	timeout := time.After(3 * transmissionCycle)
+	for served := 0; ; {
+		select {
+		case <-responses:
+			if served++; served > 1 {
+				t.Fatalf("rate limited mail request served")
+			}
+		case <-timeout:
+			if served == 0 {
+				t.Fatalf("mail request not served")
+			}
+			return
+		}
+	}
+}
+
+func TestArchivePrune(t *testing.T) {
+	db, _ := krdb.NewMemDatabase()
+	archive := newArchive(db)
+
+	base := uint32(1440000000)
+	envelopes := []*Envelope{
+		newArchivedEnvelope(t, base, "first", "alpha"),
+		newArchivedEnvelope(t, base+90, "second", "beta"),
+		newArchivedEnvelope(t, base+3600, "third", "alpha"),
+	}
+	for _, envelope := range envelopes {
+		if err := archive.store(envelope); err != nil {
+			t.Fatalf("failed to archive envelope: %v", err)
+		}
+	}
+	// Prune the first two envelopes
+	if err := archive.prune(base + 91); err != nil {
+		t.Fatalf("failed to prune archive: %v", err)
+	}
+	found, err := queryArchive(archive, base, base+3600, nil, maxMailEnvelopes)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(found) != 1 || found[0].Hash() != envelopes[2].Hash() {
+		t.Errorf("pruned archive content mismatch: have %d envelopes, want only the last", len(found))
+	}
+	for i, envelope := range envelopes[:2] {
+		if _, err := db.Get(envelopeKey(envelope.Hash())); err == nil {
+			t.Errorf("envelope %d: still stored after pruning", i)
+		}
+		if _, err := db.Get(sentKey(sent(envelope), envelope.Hash())); err == nil {
+			t.Errorf("envelope %d: index still stored after pruning", i)
+		}
+		if _, err := db.Get(topicKey(envelope.Topics[0], sent(envelope), envelope.Hash())); err == nil {
+			t.Errorf("envelope %d: topic index still stored after pruning", i)
+		}
+	}
+}
+
+// Tests that mail is not requested from peers running the legacy protocol,
+// which has no mail codes.
+func TestMailLegacyPeer(t *testing.T) {
+	db, _ := krdb.NewMemDatabase()
+	server := New()
+	server.EnableMailServer(db)
+	server.Start()
+	defer server.Stop()
+
+	client := New()
+	client.Start()
+	defer client.Stop()
+
+	serverId, clientId := discover.NodeID{1}, discover.NodeID{2}
+	src, dst := p2p.MsgPipe()
+	defer src.Close()
+
+	go server.handlePeer(p2p.NewPeer(clientId, "client", nil), src, shh2)
+	go client.handlePeer(p2p.NewPeer(serverId, "server", nil), dst, shh2)
+
+	now := uint32(time.Now().Unix())
+	for i := 0; ; i++ {
+		err := client.RequestMessages(serverId, now-3600, now, nil)
+		if err != nil && strings.Contains(err.Error(), "doesn't support") {
+			break
+		}
+		if i == 10 {
+			t.Fatalf("mail request error mismatch: have %v, want unsupported", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
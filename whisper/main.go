@@ -52,7 +52,7 @@ func main() {
 		PrivateKey: key,
 		MaxPeers:   10,
 		Name:       name,
-		Protocols:  []p2p.Protocol{shh.Protocol()},
+		Protocols:  shh.Protocols(),
 		ListenAddr: ":30300",
 		NAT:        nat.Any(),
 	}
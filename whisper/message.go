@@ -20,7 +20,12 @@
 package whisper
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/ecdsa"
+	crand "crypto/rand"
+	"fmt"
+	"io"
 	"math/rand"
 	"time"
 
@@ -41,14 +46,16 @@ type Message struct {
 	Sent time.Time     // Time when the message was posted into the network
 	TTL  time.Duration // Maximum time to live allowed for the message
 
-	To   *ecdsa.PublicKey // Message recipient (identity used to decode the message)
-	Hash common.Hash      // Message envelope hash to act as a unique id
+	To       *ecdsa.PublicKey // Message recipient (identity used to decode the message)
+	SymKeyID string           // Id of the symmetric key used to decode the message
+	Hash     common.Hash      // Message envelope hash to act as a unique id
 }
 
 // Options specifies the exact way a message should be wrapped into an Envelope.
 type Options struct {
 	From   *ecdsa.PrivateKey
 	To     *ecdsa.PublicKey
+	KeySym []byte // Symmetric key to encrypt with, exclusive with To
 	TTL    time.Duration
 	Topics []Topic
 }
@@ -69,9 +76,10 @@ func NewMessage(payload []byte) *Message {
 
 // Wrap bundles the message into an Envelope to transmit over the network.
 //
-// pow (Proof Of Work) controls how much time to spend on hashing the message,
-// inherently controlling its priority through the network (smaller hash, bigger
-// priority).
+// pow (Proof Of Work) is the work per byte and second of TTL the envelope is
+// sealed with, inherently controlling its priority through the network (smaller
+// hash, bigger priority). Sealing fails if the target isn't reached within
+// DefaultSealTimeout.
 //
 // The user can control the amount of identity, privacy and encryption through
 // the options parameter as follows:
@@ -79,7 +87,13 @@ func NewMessage(payload []byte) *Message {
 //   - options.From != nil && options.To == nil: signed broadcast (known sender)
 //   - options.From == nil && options.To != nil: encrypted anonymous message
 //   - options.From != nil && options.To != nil: encrypted signed message
-func (self *Message) Wrap(pow time.Duration, options Options) (*Envelope, error) {
+//
+// Instead of To, a symmetric key shared by a group of recipients may be set in
+// options.KeySym to encrypt the message with.
+func (self *Message) Wrap(pow float64, options Options) (*Envelope, error) {
+	if options.To != nil && options.KeySym != nil {
+		return nil, fmt.Errorf("both asymmetric and symmetric encryption requested")
+	}
 	// Use the default TTL if non was specified
 	if options.TTL == 0 {
 		options.TTL = DefaultTTL
@@ -97,10 +111,16 @@ func (self *Message) Wrap(pow time.Duration, options Options) (*Envelope, error)
 			return nil, err
 		}
 	}
+	if options.KeySym != nil {
+		if err := self.encryptSymmetric(options.KeySym); err != nil {
+			return nil, err
+		}
+	}
 	// Wrap the processed message, seal it and return
 	envelope := NewEnvelope(options.TTL, options.Topics, self)
-	envelope.Seal(pow)
-
+	if err := envelope.Seal(pow, DefaultSealTimeout); err != nil {
+		return nil, err
+	}
 	return envelope, nil
 }
 
@@ -144,6 +164,52 @@ func (self *Message) decrypt(key *ecdsa.PrivateKey) error {
 	return err
 }
 
+// encryptSymmetric encrypts a message payload with a symmetric key using
+// AES-GCM, prepending the random nonce to the ciphertext.
+func (self *Message) encryptSymmetric(key []byte) error {
+	gcm, err := newSymmetricCipher(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(crand.Reader, nonce); err != nil {
+		return err
+	}
+	self.Payload = gcm.Seal(nonce, nonce, self.Payload, nil)
+	return nil
+}
+
+// decryptSymmetric decrypts a payload encrypted with a symmetric key, failing if
+// the payload was encrypted with a different one.
+func (self *Message) decryptSymmetric(key []byte) error {
+	gcm, err := newSymmetricCipher(key)
+	if err != nil {
+		return err
+	}
+	if len(self.Payload) < gcm.NonceSize() {
+		return fmt.Errorf("payload too short for symmetric decryption")
+	}
+	nonce, ciphertext := self.Payload[:gcm.NonceSize()], self.Payload[gcm.NonceSize():]
+	cleartext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return err
+	}
+	self.Payload = cleartext
+	return nil
+}
+
+// newSymmetricCipher creates the AES-GCM cipher of a symmetric key.
+func newSymmetricCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != SymKeyLength {
+		return nil, ErrInvalidSymKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
 // hash calculates the SHA3 checksum of the message flags and payload.
 func (self *Message) hash() []byte {
 	return crypto.Sha3(append([]byte{self.Flags}, self.Payload...))
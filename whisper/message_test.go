@@ -156,3 +156,50 @@ func TestMessageFullCrypto(t *testing.T) {
 		t.Fatalf("public key mismatch: have 0x%x, want 0x%x", p2, p1)
 	}
 }
+
+// Tests whkrypton a message can be encrypted and decrypted with a symmetric key,
+// and that other keys fail to open it.
+func TestMessageSymmetricEncryptDecrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{0x01}, SymKeyLength)
+	payload := []byte("hello world")
+
+	msg := NewMessage(payload)
+	envelope, err := msg.Wrap(DefaultPoW, Options{
+		KeySym: key,
+	})
+	if err != nil {
+		t.Fatalf("failed to encrypt message: %v", err)
+	}
+	if bytes.Contains(msg.Payload, payload) {
+		t.Fatalf("payload not encrypted: 0x%x", msg.Payload)
+	}
+	out, err := envelope.OpenSymmetric(key)
+	if err != nil {
+		t.Fatalf("failed to open encrypted message: %v", err)
+	}
+	if !bytes.Equal(out.Payload, payload) {
+		t.Errorf("payload mismatch: have 0x%x, want 0x%x", out.Payload, payload)
+	}
+	if _, err := envelope.OpenSymmetric(bytes.Repeat([]byte{0x02}, SymKeyLength)); err == nil {
+		t.Errorf("opened message with wrong key")
+	}
+	if _, err := envelope.OpenSymmetric(key[1:]); err == nil {
+		t.Errorf("opened message with invalid key")
+	}
+}
+
+// Tests that a message can't be encrypted both to a recipient and with a
+// symmetric key.
+func TestMessageMixedEncryption(t *testing.T) {
+	to, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to create recipient crypto key: %v", err)
+	}
+	_, err = NewMessage([]byte("hello world")).Wrap(DefaultPoW, Options{
+		To:     &to.PublicKey,
+		KeySym: make([]byte, SymKeyLength),
+	})
+	if err == nil {
+		t.Fatalf("wrapped message with mixed encryption")
+	}
+}
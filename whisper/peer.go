@@ -18,6 +18,8 @@ package whisper
 
 import (
 	"fmt"
+	"math"
+	"sync"
 	"time"
 
 	"github.com/krypton/go-krypton/common"
@@ -30,23 +32,35 @@ import (
 
 // peer represents a whisper protocol peer connection.
 type peer struct {
-	host *Whisper
-	peer *p2p.Peer
-	ws   p2p.MsgReadWriter
+	host    *Whisper
+	peer    *p2p.Peer
+	ws      p2p.MsgReadWriter
+	version uint // Negotiated protocol version
 
 	known *set.Set // Messages already known by the peer to avoid wasting bandwidth
+	ready bool     // Whether the handshake completed, guarded by the host's peerMu
+
+	powRequirement float64 // Minimum proof of work of the envelopes the peer accepts
+
+	interest   *TopicBloom  // Topics the peer wants forwarded, nil if all
+	interestMu sync.RWMutex // Mutex to sync the topic interest of the peer
+
+	advertiseCh chan struct{} // Notification to advertise the topic interest of the host
 
 	quit chan struct{}
 }
 
 // newPeer creates a new whisper peer object, but does not run the handshake itself.
-func newPeer(host *Whisper, remote *p2p.Peer, rw p2p.MsgReadWriter) *peer {
+func newPeer(host *Whisper, remote *p2p.Peer, rw p2p.MsgReadWriter, version uint) *peer {
 	return &peer{
-		host:  host,
-		peer:  remote,
-		ws:    rw,
-		known: set.New(),
-		quit:  make(chan struct{}),
+		host:    host,
+		peer:    remote,
+		ws:      rw,
+		version: version,
+		known:   set.New(),
+
+		advertiseCh: make(chan struct{}, 1),
+		quit:        make(chan struct{}),
 	}
 }
 
@@ -69,7 +83,11 @@ func (self *peer) handshake() error {
 	// Send the handshake status message asynchronously
 	errc := make(chan error, 1)
 	go func() {
-		errc <- p2p.SendItems(self.ws, statusCode, protocolVersion)
+		if self.version < shh3 {
+			errc <- p2p.SendItems(self.ws, statusCode, uint64(self.version))
+			return
+		}
+		errc <- p2p.SendItems(self.ws, statusCode, uint64(self.version), math.Float64bits(self.host.minPoW))
 	}()
 	// Fetch the remote status packet and verify protocol match
 	packet, err := self.ws.ReadMsg()
@@ -87,9 +105,26 @@ func (self *peer) handshake() error {
 	if err != nil {
 		return fmt.Errorf("bad status message: %v", err)
 	}
-	if peerVersion != protocolVersion {
-		return fmt.Errorf("protocol version mismatch %d != %d", peerVersion, protocolVersion)
+	if peerVersion != uint64(self.version) {
+		return fmt.Errorf("protocol version mismatch %d != %d", peerVersion, self.version)
+	}
+	// Retrieve the proof of work requirement of the peer, if advertised. Legacy
+	// peers don't, accepting all envelopes.
+	var powBits uint64
+	if self.version >= shh3 {
+		switch powBits, err = s.Uint(); {
+		case err == rlp.EOL:
+			powBits = 0
+		case err != nil:
+			return fmt.Errorf("bad status message: %v", err)
+		}
 	}
+	pow := math.Float64frombits(powBits)
+	if math.IsNaN(pow) || math.IsInf(pow, 0) || pow < 0 {
+		return fmt.Errorf("invalid proof of work requirement: %v", pow)
+	}
+	self.powRequirement = pow
+
 	// Wait until out own status is consumed too
 	if err := <-errc; err != nil {
 		return fmt.Errorf("failed to send status packet: %v", err)
@@ -116,6 +151,12 @@ func (self *peer) update() {
 				return
 			}
 
+		case <-self.advertiseCh:
+			if err := self.advertiseInterest(); err != nil {
+				glog.V(logger.Info).Infof("%v: topic interest advertisement failed: %v", self.peer, err)
+				return
+			}
+
 		case <-self.quit:
 			return
 		}
@@ -132,6 +173,54 @@ func (self *peer) marked(envelope *Envelope) bool {
 	return self.known.Has(envelope.Hash())
 }
 
+// setInterest updates the topic bloom filter advertised by the peer. An empty
+// filter requests all envelopes.
+func (self *peer) setInterest(bloom []byte) error {
+	var interest *TopicBloom
+	switch len(bloom) {
+	case 0:
+	case TopicBloomLength:
+		interest = new(TopicBloom)
+		copy(interest[:], bloom)
+	default:
+		return fmt.Errorf("invalid topic bloom length: %d", len(bloom))
+	}
+	self.interestMu.Lock()
+	defer self.interestMu.Unlock()
+
+	self.interest = interest
+	return nil
+}
+
+// advertise schedules the advertisement of the current topic interest of the
+// host to the peer, unless it runs the legacy protocol not supporting them.
+func (self *peer) advertise() {
+	if self.version < shh3 {
+		return
+	}
+	select {
+	case self.advertiseCh <- struct{}{}:
+	default: // An advertisement is already pending, it will send the latest
+	}
+}
+
+// advertiseInterest sends the current topic interest of the host to the peer.
+func (self *peer) advertiseInterest() error {
+	var bloom []byte
+	if interest := self.host.topicInterest(); interest != nil {
+		bloom = interest[:]
+	}
+	return p2p.Send(self.ws, topicInterestCode, bloom)
+}
+
+// interested checks whether the peer wants an envelope forwarded.
+func (self *peer) interested(envelope *Envelope) bool {
+	self.interestMu.RLock()
+	defer self.interestMu.RUnlock()
+
+	return self.interest == nil || self.interest.Matches(envelope)
+}
+
 // expire iterates over all the known envelopes in the host and removes all
 // expired (unknown) ones from the known list.
 func (self *peer) expire() {
@@ -155,14 +244,20 @@ func (self *peer) expire() {
 }
 
 // broadcast iterates over the collection of envelopes and transmits yet unknown
-// ones over the network.
+// ones over the network, skipping those the peer doesn't accept. Envelopes not
+// matching the topic interest of the peer are kept unknown in case it changes.
 func (self *peer) broadcast() error {
 	// Fetch the envelopes and collect the unknown ones
 	envelopes := self.host.envelopes()
 	transmit := make([]*Envelope, 0, len(envelopes))
 	for _, envelope := range envelopes {
 		if !self.marked(envelope) {
-			transmit = append(transmit, envelope)
+			if !self.interested(envelope) {
+				continue
+			}
+			if envelope.PoW() >= self.powRequirement {
+				transmit = append(transmit, envelope)
+			}
 			self.mark(envelope)
 		}
 	}
@@ -17,6 +17,7 @@
 package whisper
 
 import (
+	"math"
 	"testing"
 	"time"
 
@@ -25,18 +26,23 @@ import (
 )
 
 type testPeer struct {
-	client *Whisper
-	stream *p2p.MsgPipeRW
-	termed chan struct{}
+	client  *Whisper
+	stream  *p2p.MsgPipeRW
+	termed  chan struct{}
+	version uint
 }
 
-func startTestPeer() *testPeer {
+func startTestPeer(version uint) *testPeer {
+	return startTestPeerClient(New(), version)
+}
+
+// startTestPeerClient connects a tester peer to a preconfigured whisper client.
+func startTestPeerClient(client *Whisper, version uint) *testPeer {
 	// Create a simulated P2P remote peer and data streams to it
 	remote := p2p.NewPeer(discover.NodeID{}, "", nil)
 	tester, tested := p2p.MsgPipe()
 
-	// Create a whisper client and connect with it to the tester peer
-	client := New()
+	// Start the whisper client and connect with it to the tester peer
 	client.Start()
 
 	termed := make(chan struct{})
@@ -45,24 +51,33 @@ func startTestPeer() *testPeer {
 		defer close(termed)
 		defer tested.Close()
 
-		client.handlePeer(remote, tested)
+		client.handlePeer(remote, tested, version)
 	}()
 
 	return &testPeer{
-		client: client,
-		stream: tester,
-		termed: termed,
+		client:  client,
+		stream:  tester,
+		termed:  termed,
+		version: version,
 	}
 }
 
-func startTestPeerInited() (*testPeer, error) {
-	peer := startTestPeer()
+func startTestPeerInited(version uint) (*testPeer, error) {
+	return initTestPeer(startTestPeer(version))
+}
 
-	if err := p2p.ExpectMsg(peer.stream, statusCode, []uint64{protocolVersion}); err != nil {
+// initTestPeer executes the handshake with the whisper client of a tester peer.
+func initTestPeer(peer *testPeer) (*testPeer, error) {
+	version := peer.version
+	status := []uint64{uint64(version), math.Float64bits(DefaultMinPoW)}
+	if version < shh3 {
+		status = status[:1]
+	}
+	if err := p2p.ExpectMsg(peer.stream, statusCode, status); err != nil {
 		peer.stream.Close()
 		return nil, err
 	}
-	if err := p2p.SendItems(peer.stream, statusCode, protocolVersion); err != nil {
+	if err := p2p.SendItems(peer.stream, statusCode, uint64(version)); err != nil {
 		peer.stream.Close()
 		return nil, err
 	}
@@ -70,10 +85,10 @@ func startTestPeerInited() (*testPeer, error) {
 }
 
 func TestPeerStatusMessage(t *testing.T) {
-	tester := startTestPeer()
+	tester := startTestPeer(shh3)
 
 	// Wait for the handshake status message and check it
-	if err := p2p.ExpectMsg(tester.stream, statusCode, []uint64{protocolVersion}); err != nil {
+	if err := p2p.ExpectMsg(tester.stream, statusCode, []uint64{uint64(shh3), math.Float64bits(DefaultMinPoW)}); err != nil {
 		t.Fatalf("status message mismatch: %v", err)
 	}
 	// Terminate the node
@@ -87,10 +102,10 @@ func TestPeerStatusMessage(t *testing.T) {
 }
 
 func TestPeerHandshakeFail(t *testing.T) {
-	tester := startTestPeer()
+	tester := startTestPeer(shh3)
 
 	// Wait for and check the handshake
-	if err := p2p.ExpectMsg(tester.stream, statusCode, []uint64{protocolVersion}); err != nil {
+	if err := p2p.ExpectMsg(tester.stream, statusCode, []uint64{uint64(shh3), math.Float64bits(DefaultMinPoW)}); err != nil {
 		t.Fatalf("status message mismatch: %v", err)
 	}
 	// Send an invalid handshake status and verify disconnect
@@ -105,14 +120,14 @@ func TestPeerHandshakeFail(t *testing.T) {
 }
 
 func TestPeerHandshakeSuccess(t *testing.T) {
-	tester := startTestPeer()
+	tester := startTestPeer(shh3)
 
 	// Wait for and check the handshake
-	if err := p2p.ExpectMsg(tester.stream, statusCode, []uint64{protocolVersion}); err != nil {
+	if err := p2p.ExpectMsg(tester.stream, statusCode, []uint64{uint64(shh3), math.Float64bits(DefaultMinPoW)}); err != nil {
 		t.Fatalf("status message mismatch: %v", err)
 	}
 	// Send a valid handshake status and make sure connection stays live
-	if err := p2p.SendItems(tester.stream, statusCode, protocolVersion); err != nil {
+	if err := p2p.SendItems(tester.stream, statusCode, uint64(shh3)); err != nil {
 		t.Fatalf("failed to send status: %v", err)
 	}
 	select {
@@ -133,7 +148,7 @@ func TestPeerHandshakeSuccess(t *testing.T) {
 
 func TestPeerSend(t *testing.T) {
 	// Start a tester and execute the handshake
-	tester, err := startTestPeerInited()
+	tester, err := startTestPeerInited(shh3)
 	if err != nil {
 		t.Fatalf("failed to start initialized peer: %v", err)
 	}
@@ -166,7 +181,7 @@ func TestPeerSend(t *testing.T) {
 
 func TestPeerDeliver(t *testing.T) {
 	// Start a tester and execute the handshake
-	tester, err := startTestPeerInited()
+	tester, err := startTestPeerInited(shh3)
 	if err != nil {
 		t.Fatalf("failed to start initialized peer: %v", err)
 	}
@@ -209,7 +224,7 @@ func TestPeerDeliver(t *testing.T) {
 
 func TestPeerMessageExpiration(t *testing.T) {
 	// Start a tester and execute the handshake
-	tester, err := startTestPeerInited()
+	tester, err := startTestPeerInited(shh3)
 	if err != nil {
 		t.Fatalf("failed to start initialized peer: %v", err)
 	}
@@ -256,3 +271,248 @@ func TestPeerMessageExpiration(t *testing.T) {
 		t.Fatalf("message not expired from cache")
 	}
 }
+
+func TestPeerPoWRequirement(t *testing.T) {
+	tester := startTestPeer(shh3)
+	defer tester.stream.Close()
+
+	// Execute the handshake, advertising an unreachable proof of work requirement
+	if err := p2p.ExpectMsg(tester.stream, statusCode, []uint64{uint64(shh3), math.Float64bits(DefaultMinPoW)}); err != nil {
+		t.Fatalf("status message mismatch: %v", err)
+	}
+	if err := p2p.SendItems(tester.stream, statusCode, uint64(shh3), math.Float64bits(math.MaxFloat64)); err != nil {
+		t.Fatalf("failed to send status: %v", err)
+	}
+	// Inject a message into the tester and make sure it's not forwarded
+	envelope, err := NewMessage([]byte("peer broadcast test message")).Wrap(DefaultPoW, Options{
+		TTL: DefaultTTL,
+	})
+	if err != nil {
+		t.Fatalf("failed to wrap message: %v", err)
+	}
+	if err := tester.client.Send(envelope); err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+	if err := p2p.ExpectMsg(tester.stream, messagesCode, []interface{}{}); err != nil {
+		t.Fatalf("message mismatch: %v", err)
+	}
+}
+
+func TestPeerLowPoWDrop(t *testing.T) {
+	// Start a tester and execute the handshake
+	tester, err := startTestPeerInited(shh3)
+	if err != nil {
+		t.Fatalf("failed to start initialized peer: %v", err)
+	}
+	defer tester.stream.Close()
+
+	// Deliver an unsealed envelope with a huge TTL and check that the peer is dropped
+	envelope, err := NewMessage([]byte("peer broadcast test message")).Wrap(0, Options{
+		TTL: 1000000 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("failed to wrap message: %v", err)
+	}
+	if err := p2p.Send(tester.stream, messagesCode, []*Envelope{envelope}); err != nil {
+		t.Fatalf("failed to transfer message: %v", err)
+	}
+	select {
+	case <-tester.termed:
+	case <-time.After(time.Second):
+		t.Fatalf("low proof of work peer not dropped")
+	}
+}
+
+// Tests that legacy peers, unaware of the proof of work requirement, are not
+// dropped for relaying cheap envelopes, which are ignored instead.
+func TestPeerLowPoWLegacy(t *testing.T) {
+	// Start a legacy tester and execute the handshake
+	tester, err := startTestPeerInited(shh2)
+	if err != nil {
+		t.Fatalf("failed to start initialized peer: %v", err)
+	}
+	defer tester.stream.Close()
+
+	envelope, err := NewMessage([]byte("peer broadcast test message")).Wrap(0, Options{
+		TTL: 1000000 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("failed to wrap message: %v", err)
+	}
+	if err := p2p.Send(tester.stream, messagesCode, []*Envelope{envelope}); err != nil {
+		t.Fatalf("failed to transfer message: %v", err)
+	}
+	select {
+	case <-tester.termed:
+		t.Fatalf("legacy peer dropped")
+	case <-time.After(2 * transmissionCycle):
+	}
+	if envelopes := tester.client.envelopes(); len(envelopes) != 0 {
+		t.Errorf("low proof of work envelope pooled")
+	}
+}
+
+func TestPeerFloodDrop(t *testing.T) {
+	client := New()
+	client.floodLimit = 1024
+
+	// Start a tester and execute the handshake
+	tester, err := initTestPeer(startTestPeerClient(client, shh3))
+	if err != nil {
+		t.Fatalf("failed to start initialized peer: %v", err)
+	}
+	defer tester.stream.Close()
+
+	// Deliver valid envelopes until the flood limit is exceeded
+	for i := 0; ; i++ {
+		envelope, err := NewMessage(make([]byte, 256)).Wrap(DefaultPoW, Options{
+			TTL: DefaultTTL,
+		})
+		if err != nil {
+			t.Fatalf("failed to wrap message: %v", err)
+		}
+		if err := p2p.Send(tester.stream, messagesCode, []*Envelope{envelope}); err != nil {
+			if i < client.floodLimit/envelope.size() {
+				t.Fatalf("peer dropped before reaching the flood limit: %v", err)
+			}
+			break
+		}
+		if i > 2*client.floodLimit/envelope.size() {
+			t.Fatalf("flooding peer not dropped")
+		}
+	}
+	select {
+	case <-tester.termed:
+	case <-time.After(time.Second):
+		t.Fatalf("flooding peer not dropped")
+	}
+}
+
+func TestPeerTopicInterest(t *testing.T) {
+	// Start a tester and execute the handshake
+	tester, err := startTestPeerInited(shh3)
+	if err != nil {
+		t.Fatalf("failed to start initialized peer: %v", err)
+	}
+	defer tester.stream.Close()
+
+	// Advertise interest in a single topic and inject two messages
+	wanted, unwanted := NewTopicFromString("wanted"), NewTopicFromString("unwanted")
+	if err := p2p.Send(tester.stream, topicInterestCode, NewTopicBloom(wanted)[:]); err != nil {
+		t.Fatalf("failed to send topic interest: %v", err)
+	}
+	envelopes := make([]*Envelope, 2)
+	for i, topic := range []Topic{wanted, unwanted} {
+		envelope, err := NewMessage([]byte("peer broadcast test message")).Wrap(DefaultPoW, Options{
+			Topics: []Topic{topic},
+			TTL:    DefaultTTL,
+		})
+		if err != nil {
+			t.Fatalf("failed to wrap message: %v", err)
+		}
+		if err := tester.client.Send(envelope); err != nil {
+			t.Fatalf("failed to send message: %v", err)
+		}
+		envelopes[i] = envelope
+	}
+	// Make sure only the wanted message is forwarded, until the interest is reset
+	if err := p2p.ExpectMsg(tester.stream, messagesCode, []interface{}{envelopes[0]}); err != nil {
+		t.Fatalf("message mismatch: %v", err)
+	}
+	if err := p2p.ExpectMsg(tester.stream, messagesCode, []interface{}{}); err != nil {
+		t.Fatalf("message mismatch: %v", err)
+	}
+	if err := p2p.Send(tester.stream, topicInterestCode, []byte{}); err != nil {
+		t.Fatalf("failed to send topic interest: %v", err)
+	}
+	if err := p2p.ExpectMsg(tester.stream, messagesCode, []interface{}{envelopes[1]}); err != nil {
+		t.Fatalf("message mismatch: %v", err)
+	}
+}
+
+func TestPeerTopicInterestAdvertisement(t *testing.T) {
+	// Start a tester and execute the handshake
+	tester, err := startTestPeerInited(shh3)
+	if err != nil {
+		t.Fatalf("failed to start initialized peer: %v", err)
+	}
+	defer tester.stream.Close()
+
+	// Update the interest of the node and check that it's advertised
+	topic := NewTopicFromString("wanted")
+	go tester.client.SetTopicInterest([]Topic{topic})
+
+	for {
+		packet, err := tester.stream.ReadMsg()
+		if err != nil {
+			t.Fatalf("failed to read packet: %v", err)
+		}
+		if packet.Code != topicInterestCode {
+			packet.Discard()
+			continue
+		}
+		var bloom []byte
+		if err := packet.Decode(&bloom); err != nil {
+			t.Fatalf("failed to decode topic interest: %v", err)
+		}
+		if string(bloom) != string(NewTopicBloom(topic)[:]) {
+			t.Fatalf("topic bloom mismatch: have %x, want %x", bloom, NewTopicBloom(topic)[:])
+		}
+		break
+	}
+	// Send an invalid interest and check that the peer is dropped
+	if err := p2p.Send(tester.stream, topicInterestCode, []byte{1, 2, 3}); err != nil {
+		t.Fatalf("failed to send topic interest: %v", err)
+	}
+	select {
+	case <-tester.termed:
+	case <-time.After(time.Second):
+		t.Fatalf("peer with invalid topic interest not dropped")
+	}
+}
+
+// Tests that advertising the topic interest doesn't wait for slow peers, and
+// that legacy peers don't get advertisements they can't handle.
+func TestPeerTopicInterestNonBlocking(t *testing.T) {
+	tester, err := startTestPeerInited(shh3)
+	if err != nil {
+		t.Fatalf("failed to start initialized peer: %v", err)
+	}
+	defer tester.stream.Close()
+
+	legacy, err := startTestPeerInited(shh2)
+	if err != nil {
+		t.Fatalf("failed to start initialized legacy peer: %v", err)
+	}
+	defer legacy.stream.Close()
+
+	// Neither tester reads, so sending to them would block
+	done := make(chan struct{})
+	go func() {
+		tester.client.SetTopicInterest([]Topic{NewTopicFromString("wanted")})
+		legacy.client.SetTopicInterest([]Topic{NewTopicFromString("wanted")})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("topic interest update blocked on a peer")
+	}
+	// Only envelope batches may arrive on the legacy connection
+	timeout := time.After(3 * transmissionCycle)
+	for {
+		select {
+		case <-timeout:
+			return
+		default:
+		}
+		packet, err := legacy.stream.ReadMsg()
+		if err != nil {
+			t.Fatalf("failed to read packet: %v", err)
+		}
+		if packet.Code != messagesCode {
+			t.Fatalf("legacy peer sent message code %d", packet.Code)
+		}
+		packet.Discard()
+	}
+}
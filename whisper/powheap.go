@@ -0,0 +1,76 @@
+// Copyright 2015 The go-krypton Authors
+// This file is part of the go-krypton library.
+//
+// The go-krypton library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-krypton library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-krypton library. If not, see <http://www.gnu.org/licenses/>.
+
+package whisper
+
+import (
+	"container/heap"
+
+	"github.com/krypton/go-krypton/common"
+)
+
+// powHeap is a min-heap of the pooled envelopes ordered by their proof of work,
+// used to find the cheapest envelopes to evict once the pool is full.
+type powHeap struct {
+	items []*Envelope
+	index map[common.Hash]int // Position of each envelope within items
+}
+
+func newPowHeap() *powHeap {
+	return &powHeap{index: make(map[common.Hash]int)}
+}
+
+func (h *powHeap) Len() int           { return len(h.items) }
+func (h *powHeap) Less(i, j int) bool { return h.items[i].PoW() < h.items[j].PoW() }
+
+func (h *powHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.index[h.items[i].Hash()] = i
+	h.index[h.items[j].Hash()] = j
+}
+
+func (h *powHeap) Push(x interface{}) {
+	envelope := x.(*Envelope)
+	h.index[envelope.Hash()] = len(h.items)
+	h.items = append(h.items, envelope)
+}
+
+func (h *powHeap) Pop() interface{} {
+	envelope := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	delete(h.index, envelope.Hash())
+	return envelope
+}
+
+// add inserts an envelope into the heap.
+func (h *powHeap) add(envelope *Envelope) {
+	heap.Push(h, envelope)
+}
+
+// remove drops the envelope with the given hash from the heap, if present.
+func (h *powHeap) remove(hash common.Hash) {
+	if i, ok := h.index[hash]; ok {
+		heap.Remove(h, i)
+	}
+}
+
+// cheapest returns the envelope with the lowest proof of work, nil if empty.
+func (h *powHeap) cheapest() *Envelope {
+	if len(h.items) == 0 {
+		return nil
+	}
+	return h.items[0]
+}
@@ -18,6 +18,11 @@ package whisper
 
 import (
 	"crypto/ecdsa"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
 	"sync"
 	"time"
 
@@ -25,18 +30,23 @@ import (
 	"github.com/krypton/go-krypton/crypto"
 	"github.com/krypton/go-krypton/crypto/ecies"
 	"github.com/krypton/go-krypton/event/filter"
+	"github.com/krypton/go-krypton/krdb"
 	"github.com/krypton/go-krypton/logger"
 	"github.com/krypton/go-krypton/logger/glog"
 	"github.com/krypton/go-krypton/p2p"
+	"github.com/krypton/go-krypton/p2p/discover"
+	"golang.org/x/crypto/pbkdf2"
 	"gopkg.in/fatih/set.v0"
 )
 
 const (
-	statusCode   = 0x00
-	messagesCode = 0x01
+	statusCode        = 0x00
+	messagesCode      = 0x01
+	mailRequestCode   = 0x02 // Request for archived envelopes from a mail server (shh/3)
+	mailResponseCode  = 0x03 // Archived envelopes delivered by a mail server (shh/3)
+	topicInterestCode = 0x04 // Bloom filter of the topics a peer wants forwarded (shh/3)
 
-	protocolVersion uint64 = 0x02
-	protocolName           = "shh"
+	protocolName = "shh"
 
 	signatureFlag   = byte(1 << 7)
 	signatureLength = 65
@@ -46,8 +56,45 @@ const (
 )
 
 const (
-	DefaultTTL = 50 * time.Second
-	DefaultPoW = 50 * time.Millisecond
+	DefaultTTL         = 50 * time.Second
+	DefaultPoW         = DefaultMinPoW    // Proof of work envelopes are sealed with by default
+	DefaultSealTimeout = 10 * time.Second // Maximum time spent sealing an envelope
+
+	SymKeyLength = 32 // Length of the AES-256 keys used for symmetric encryption
+
+	DefaultMinPoW       = 0.2              // Minimum work per byte per second of TTL accepted by default
+	DefaultMaxStoreSize = 32 * 1024 * 1024 // Maximum memory used by the envelope pool by default
+
+	symKeyIdLength      = 16    // Length of the random ids of symmetric keys
+	symKeyKdfIterations = 65536 // PBKDF2 iterations deriving keys from passwords
+)
+
+// Supported versions of the whisper protocol.
+const (
+	shh2 = 2 // Envelope relay only
+	shh3 = 3 // Adds mail server requests, proof of work requirements and topic interests
+)
+
+var (
+	// protocolVersions are the supported versions of the whisper protocol (first
+	// is primary), the newest shared version being used with a peer.
+	protocolVersions = []uint{shh3, shh2}
+
+	// protocolLengths are the number of message codes of each protocol version.
+	protocolLengths = []uint64{5, 2}
+)
+
+const (
+	floodWindow = 10 * time.Second // Period over which the inbound traffic of a peer is metered
+	floodLimit  = 4 * 1024 * 1024  // Default maximum envelope bytes a peer may send within a flood window
+)
+
+var (
+	ErrInvalidSymKey = errors.New("invalid symmetric key length")
+	ErrLowPoW        = errors.New("envelope proof of work below minimum")
+	ErrSealTimeout   = errors.New("envelope proof of work target not reached in time")
+	ErrStoreFull     = errors.New("envelope pool full of higher proof of work envelopes")
+	ErrNoKeyStore    = errors.New("no identity key store configured")
 )
 
 type MessageEvent struct {
@@ -59,17 +106,34 @@ type MessageEvent struct {
 // Whisper represents a dark communication interface through the Krypton
 // network, using its very own P2P communication layer.
 type Whisper struct {
-	protocol p2p.Protocol
-	filters  *filter.Filters
+	protocols []p2p.Protocol
+	filters   *filter.Filters
+
+	keys     map[string]*ecdsa.PrivateKey // Identities messages are decrypted with, by public key
+	keyMu    sync.RWMutex                 // Mutex to sync the identity set
+	keyStore crypto.KeyStore              // Encrypted persistent storage of identities, nil if none
 
-	keys map[string]*ecdsa.PrivateKey
+	symKeys  map[string][]byte // Symmetric keys tried on received messages, by id
+	symKeyMu sync.RWMutex      // Mutex to sync the symmetric key set
 
 	messages    map[common.Hash]*Envelope // Pool of messages currently tracked by this node
 	expirations map[uint32]*set.SetNonTS  // Message expiration pool (TODO: somkring lighter)
+	powHeap     *powHeap                  // Pooled messages ordered by proof of work for eviction
+	storeSize   int                       // Memory used by the pooled messages, in bytes
 	poolMu      sync.RWMutex              // Mutex to sync the message and expiration pools
 
-	peers  map[*peer]struct{} // Set of currently active peers
-	peerMu sync.RWMutex       // Mutex to sync the active peer set
+	minPoW       float64 // Minimum proof of work of accepted envelopes
+	maxStoreSize int     // Memory cap of the message pool, in bytes
+	floodLimit   int     // Maximum envelope bytes a peer may send within a flood window
+
+	peers       map[*peer]struct{}           // Set of currently active peers
+	mailServers map[discover.NodeID]struct{} // Peers trusted to deliver archived envelopes
+	peerMu      sync.RWMutex                 // Mutex to sync the active peer and mail server sets
+
+	interest   *TopicBloom // Topics this node wants forwarded, nil if all
+	interestMu sync.Mutex  // Mutex to sync the topic interest of the node
+
+	mail *archive // Envelope archive served to other peers, nil if not a mail server
 
 	quit chan struct{}
 }
@@ -80,32 +144,63 @@ func New() *Whisper {
 	whisper := &Whisper{
 		filters:     filter.New(),
 		keys:        make(map[string]*ecdsa.PrivateKey),
+		symKeys:     make(map[string][]byte),
 		messages:    make(map[common.Hash]*Envelope),
 		expirations: make(map[uint32]*set.SetNonTS),
+		powHeap:     newPowHeap(),
 		peers:       make(map[*peer]struct{}),
+		mailServers: make(map[discover.NodeID]struct{}),
 		quit:        make(chan struct{}),
+
+		minPoW:       DefaultMinPoW,
+		maxStoreSize: DefaultMaxStoreSize,
+		floodLimit:   floodLimit,
 	}
 	whisper.filters.Start()
 
-	// p2p whisper sub protocol handler
-	whisper.protocol = p2p.Protocol{
-		Name:    protocolName,
-		Version: uint(protocolVersion),
-		Length:  2,
-		Run:     whisper.handlePeer,
+	// p2p whisper sub protocol handlers, one for each supported version
+	for i, version := range protocolVersions {
+		version := version // Closure for the run
+		whisper.protocols = append(whisper.protocols, p2p.Protocol{
+			Name:    protocolName,
+			Version: version,
+			Length:  protocolLengths[i],
+			Run: func(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
+				return whisper.handlePeer(peer, rw, version)
+			},
+		})
 	}
-
 	return whisper
 }
 
-// Protocol returns the whisper sub-protocol handler for this particular client.
-func (self *Whisper) Protocol() p2p.Protocol {
-	return self.protocol
+// Protocols returns the whisper sub-protocol handlers of all the supported
+// versions for this particular client.
+func (self *Whisper) Protocols() []p2p.Protocol {
+	return self.protocols
 }
 
-// Version returns the whisper sub-protocols version number.
+// Version returns the primary whisper sub-protocol version number.
 func (self *Whisper) Version() uint {
-	return self.protocol.Version
+	return protocolVersions[0]
+}
+
+// SetMinPoW sets the minimum proof of work (see Envelope.PoW) of the envelopes
+// accepted into the pool. Peers are asked not to relay cheaper envelopes, and
+// are dropped if they do. It must be called before the node is started.
+func (self *Whisper) SetMinPoW(pow float64) {
+	self.minPoW = pow
+}
+
+// MinPoW returns the minimum proof of work of the envelopes accepted into the pool.
+func (self *Whisper) MinPoW() float64 {
+	return self.minPoW
+}
+
+// SetMaxStoreSize caps the memory used by the envelope pool. Once full, the
+// envelopes with the lowest proof of work are evicted to make room for new ones.
+// It must be called before the node is started.
+func (self *Whisper) SetMaxStoreSize(size int) {
+	self.maxStoreSize = size
 }
 
 // NewIdentity generates a new cryptographic identity for the client, and injects
@@ -115,29 +210,158 @@ func (self *Whisper) NewIdentity() *ecdsa.PrivateKey {
 	if err != nil {
 		panic(err)
 	}
-	self.keys[string(crypto.FromECDSAPub(&key.PublicKey))] = key
+	self.AddIdentity(key)
 
 	return key
 }
 
+// AddIdentity injects an existing cryptographic identity into the known ones for
+// message decryption.
+func (self *Whisper) AddIdentity(key *ecdsa.PrivateKey) {
+	self.keyMu.Lock()
+	defer self.keyMu.Unlock()
+
+	self.keys[string(crypto.FromECDSAPub(&key.PublicKey))] = key
+}
+
 // HasIdentity checks if the the whisper node is configured with the private key
 // of the specified public pair.
 func (self *Whisper) HasIdentity(key *ecdsa.PublicKey) bool {
-	return self.keys[string(crypto.FromECDSAPub(key))] != nil
+	return self.GetIdentity(key) != nil
 }
 
 // GetIdentity retrieves the private key of the specified public identity.
 func (self *Whisper) GetIdentity(key *ecdsa.PublicKey) *ecdsa.PrivateKey {
+	self.keyMu.RLock()
+	defer self.keyMu.RUnlock()
+
 	return self.keys[string(crypto.FromECDSAPub(key))]
 }
 
+// SetKeyStore configures the key store identities are persisted into, encrypted
+// with a passphrase. It must be called before the node is started.
+func (self *Whisper) SetKeyStore(ks crypto.KeyStore) {
+	self.keyStore = ks
+}
+
+// StoreIdentity persists an identity into the key store, encrypted with the given
+// passphrase, and injects it into the known identities for message decryption.
+func (self *Whisper) StoreIdentity(key *ecdsa.PrivateKey, passphrase string) error {
+	if self.keyStore == nil {
+		return ErrNoKeyStore
+	}
+	if err := self.keyStore.StoreKey(crypto.NewKeyFromECDSA(key), passphrase); err != nil {
+		return err
+	}
+	self.AddIdentity(key)
+	return nil
+}
+
+// UnlockIdentity decrypts a persisted identity with the given passphrase and
+// injects it into the known identities for message decryption.
+func (self *Whisper) UnlockIdentity(key *ecdsa.PublicKey, passphrase string) (*ecdsa.PrivateKey, error) {
+	prv, err := self.ExportIdentity(key, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	self.AddIdentity(prv)
+	return prv, nil
+}
+
+// ExportIdentity decrypts a persisted identity with the given passphrase and
+// returns its private key.
+func (self *Whisper) ExportIdentity(key *ecdsa.PublicKey, passphrase string) (*ecdsa.PrivateKey, error) {
+	if self.keyStore == nil {
+		return nil, ErrNoKeyStore
+	}
+	stored, err := self.keyStore.GetKey(crypto.PubkeyToAddress(*key), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return stored.PrivateKey, nil
+}
+
+// DeleteIdentity removes a persisted identity from the key store, authorized by
+// its passphrase, and drops it from the known identities.
+func (self *Whisper) DeleteIdentity(key *ecdsa.PublicKey, passphrase string) error {
+	if self.keyStore == nil {
+		return ErrNoKeyStore
+	}
+	if err := self.keyStore.DeleteKey(crypto.PubkeyToAddress(*key), passphrase); err != nil {
+		return err
+	}
+	self.keyMu.Lock()
+	defer self.keyMu.Unlock()
+
+	delete(self.keys, string(crypto.FromECDSAPub(key)))
+	return nil
+}
+
+// GenerateSymKey creates a new random symmetric key and installs it for message
+// decryption, returning the id it can be referenced with.
+func (self *Whisper) GenerateSymKey() (string, error) {
+	key := make([]byte, SymKeyLength)
+	if _, err := io.ReadFull(crand.Reader, key); err != nil {
+		return "", err
+	}
+	return self.AddSymKey(key)
+}
+
+// AddSymKey installs a symmetric key shared with other parties for message
+// decryption, returning the id it can be referenced with.
+func (self *Whisper) AddSymKey(key []byte) (string, error) {
+	if len(key) != SymKeyLength {
+		return "", ErrInvalidSymKey
+	}
+	id := make([]byte, symKeyIdLength)
+	if _, err := io.ReadFull(crand.Reader, id); err != nil {
+		return "", err
+	}
+	self.symKeyMu.Lock()
+	defer self.symKeyMu.Unlock()
+
+	self.symKeys[common.ToHex(id)] = common.CopyBytes(key)
+	return common.ToHex(id), nil
+}
+
+// AddSymKeyFromPassword derives a symmetric key from a password and installs it
+// for message decryption. All parties knowing the password derive the same key.
+func (self *Whisper) AddSymKeyFromPassword(password string) (string, error) {
+	return self.AddSymKey(pbkdf2.Key([]byte(password), nil, symKeyKdfIterations, SymKeyLength, sha256.New))
+}
+
+// HasSymKey checks if a symmetric key with the given id is installed.
+func (self *Whisper) HasSymKey(id string) bool {
+	return self.GetSymKey(id) != nil
+}
+
+// GetSymKey retrieves the symmetric key with the given id.
+func (self *Whisper) GetSymKey(id string) []byte {
+	self.symKeyMu.RLock()
+	defer self.symKeyMu.RUnlock()
+
+	return self.symKeys[id]
+}
+
+// DeleteSymKey removes the symmetric key with the given id, reporting whether it
+// was installed.
+func (self *Whisper) DeleteSymKey(id string) bool {
+	self.symKeyMu.Lock()
+	defer self.symKeyMu.Unlock()
+
+	_, ok := self.symKeys[id]
+	delete(self.symKeys, id)
+	return ok
+}
+
 // Watch installs a new message handler to run in case a matching packet arrives
 // from the whisper network.
 func (self *Whisper) Watch(options Filter) int {
 	filter := filterer{
-		to:      string(crypto.FromECDSAPub(options.To)),
-		from:    string(crypto.FromECDSAPub(options.From)),
-		matcher: newTopicMatcher(options.Topics...),
+		to:       string(crypto.FromECDSAPub(options.To)),
+		from:     string(crypto.FromECDSAPub(options.From)),
+		symKeyID: options.SymKeyID,
+		matcher:  newTopicMatcher(options.Topics...),
 		fn: func(data interface{}) {
 			options.Fn(data.(*Message))
 		},
@@ -150,6 +374,83 @@ func (self *Whisper) Unwatch(id int) {
 	self.filters.Uninstall(id)
 }
 
+// EnableMailServer turns the node into a mail server, archiving all the envelopes
+// it sees into the database to serve them to peers requesting them later. It must
+// be called before the node is started.
+func (self *Whisper) EnableMailServer(db krdb.Database) {
+	self.mail = newArchive(db)
+}
+
+// RequestMessages asks the connected mail server with the given id for the
+// archived envelopes sent within [from, to] (seconds since the epoch) having any
+// of the topics, or all envelopes if no topics are given. The delivered envelopes
+// are matched against the installed filters like freshly arrived ones.
+func (self *Whisper) RequestMessages(id discover.NodeID, from, to uint32, topics []Topic) error {
+	self.peerMu.Lock()
+	var server *peer
+	for p, _ := range self.peers {
+		if p.ready && p.peer.ID() == id {
+			server = p
+			break
+		}
+	}
+	if server != nil && server.version >= shh3 {
+		self.mailServers[id] = struct{}{}
+	}
+	self.peerMu.Unlock()
+
+	if server == nil {
+		return fmt.Errorf("mail server %x not connected", id[:8])
+	}
+	if server.version < shh3 {
+		return fmt.Errorf("peer %x doesn't support mail requests (shh/%d)", id[:8], server.version)
+	}
+	return p2p.Send(server.ws, mailRequestCode, &mailRequest{From: from, To: to, Topics: topics})
+}
+
+// SetTopicInterest advertises to all peers that only envelopes with any of the
+// given topics should be forwarded to this node, cutting the bandwidth of light
+// clients. Without topics all envelopes are requested again.
+func (self *Whisper) SetTopicInterest(topics []Topic) {
+	self.interestMu.Lock()
+	self.interest = nil
+	if len(topics) > 0 {
+		self.interest = NewTopicBloom(topics...)
+	}
+	self.interestMu.Unlock()
+
+	// Have the peers advertise the new interest, each from its own loop
+	self.peerMu.RLock()
+	defer self.peerMu.RUnlock()
+
+	for p, _ := range self.peers {
+		if p.ready {
+			p.advertise()
+		}
+	}
+}
+
+// activate marks a peer as having completed the handshake and schedules the
+// advertisement of the topic interest of the node to it, if any.
+func (self *Whisper) activate(p *peer) {
+	self.peerMu.Lock()
+	p.ready = true
+	self.peerMu.Unlock()
+
+	if self.topicInterest() != nil {
+		p.advertise()
+	}
+}
+
+// topicInterest returns the topic bloom filter of the node, nil if all topics
+// are requested.
+func (self *Whisper) topicInterest() *TopicBloom {
+	self.interestMu.Lock()
+	defer self.interestMu.Unlock()
+
+	return self.interest
+}
+
 // Send injects a message into the whisper send queue, to be distributed in the
 // network in the coming cycles.
 func (self *Whisper) Send(envelope *Envelope) error {
@@ -183,9 +484,9 @@ func (self *Whisper) Messages(id int) []*Message {
 
 // handlePeer is called by the underlying P2P layer when the whisper sub-protocol
 // connection is negotiated.
-func (self *Whisper) handlePeer(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
+func (self *Whisper) handlePeer(peer *p2p.Peer, rw p2p.MsgReadWriter, version uint) error {
 	// Create the new peer and start tracking it
-	whisperPeer := newPeer(self, peer, rw)
+	whisperPeer := newPeer(self, peer, rw, version)
 
 	self.peerMu.Lock()
 	self.peers[whisperPeer] = struct{}{}
@@ -201,36 +502,153 @@ func (self *Whisper) handlePeer(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
 	if err := whisperPeer.handshake(); err != nil {
 		return err
 	}
+	self.activate(whisperPeer)
+
 	whisperPeer.start()
 	defer whisperPeer.stop()
 
+	// Serve mail requests one at a time, outside of the read loop
+	mailServing := make(chan struct{}, 1)
+	lastMail := time.Time{}
+
 	// Read and process inbound messages directly to merge into client-global state
+	floodStart, floodTraffic := time.Now(), 0
 	for {
 		// Fetch the next packet and decode the contained envelopes
 		packet, err := rw.ReadMsg()
 		if err != nil {
 			return err
 		}
-		var envelopes []*Envelope
-		if err := packet.Decode(&envelopes); err != nil {
-			glog.V(logger.Info).Infof("%v: failed to decode envelope: %v", peer, err)
-			continue
-		}
-		// Inject all envelopes into the internal pool
-		for _, envelope := range envelopes {
-			if err := self.add(envelope); err != nil {
-				// TODO Punish peer here. Invalid envelope.
-				glog.V(logger.Debug).Infof("%v: failed to pool envelope: %v", peer, err)
+		switch packet.Code {
+		case messagesCode:
+			// Meter the inbound traffic, dropping peers flooding the node
+			if time.Since(floodStart) > floodWindow {
+				floodStart, floodTraffic = time.Now(), 0
+			}
+			if floodTraffic += int(packet.Size); floodTraffic > self.floodLimit {
+				return fmt.Errorf("flooding: %d bytes within %v", floodTraffic, floodWindow)
+			}
+			var envelopes []*Envelope
+			if err := packet.Decode(&envelopes); err != nil {
+				glog.V(logger.Info).Infof("%v: failed to decode envelope: %v", peer, err)
+				continue
+			}
+			// Inject all envelopes into the internal pool
+			for _, envelope := range envelopes {
+				whisperPeer.mark(envelope)
+				if err := self.add(envelope); err != nil {
+					// Legacy peers don't know our requirement, only drop informed ones
+					if err == ErrLowPoW && whisperPeer.version >= shh3 {
+						return fmt.Errorf("envelope %x: %v (%g < %g)", envelope.Hash(), err, envelope.PoW(), self.minPoW)
+					}
+					glog.V(logger.Debug).Infof("%v: failed to pool envelope: %v", peer, err)
+				}
+			}
+
+		case mailRequestCode:
+			var request mailRequest
+			if err := packet.Decode(&request); err != nil {
+				glog.V(logger.Info).Infof("%v: failed to decode mail request: %v", peer, err)
+				continue
+			}
+			if self.mail == nil {
+				glog.V(logger.Debug).Infof("%v: dropping mail request, not a mail server", peer)
+				continue
+			}
+			if time.Since(lastMail) < mailRequestInterval {
+				glog.V(logger.Debug).Infof("%v: dropping mail request, rate limit exceeded", peer)
+				continue
+			}
+			select {
+			case mailServing <- struct{}{}:
+				lastMail = time.Now()
+				go func() {
+					defer func() { <-mailServing }()
+					if err := self.serveMail(rw, &request); err != nil {
+						glog.V(logger.Debug).Infof("%v: failed to serve mail request: %v", peer, err)
+					}
+				}()
+			default:
+				glog.V(logger.Debug).Infof("%v: dropping mail request, previous one still served", peer)
+			}
+
+		case mailResponseCode:
+			self.peerMu.RLock()
+			_, trusted := self.mailServers[peer.ID()]
+			self.peerMu.RUnlock()
+
+			if !trusted {
+				glog.V(logger.Debug).Infof("%v: dropping mail from untrusted peer", peer)
+				packet.Discard()
+				continue
+			}
+			var envelopes []*Envelope
+			if err := packet.Decode(&envelopes); err != nil {
+				glog.V(logger.Info).Infof("%v: failed to decode mail: %v", peer, err)
+				continue
+			}
+			// Archived envelopes may well be expired, deliver without pooling
+			for _, envelope := range envelopes {
+				self.poolMu.RLock()
+				_, known := self.messages[envelope.Hash()]
+				self.poolMu.RUnlock()
+
+				if !known {
+					self.postEvent(envelope)
+				}
+			}
+
+		case topicInterestCode:
+			var bloom []byte
+			if err := packet.Decode(&bloom); err != nil {
+				return fmt.Errorf("bad topic interest: %v", err)
+			}
+			if err := whisperPeer.setInterest(bloom); err != nil {
+				return err
 			}
-			whisperPeer.mark(envelope)
+
+		default:
+			packet.Discard()
 		}
 	}
 }
 
+// serveMail answers a mail request with at most maxMailEnvelopes of the matching
+// archived envelopes, streamed in packets of limited size.
+func (self *Whisper) serveMail(rw p2p.MsgWriter, request *mailRequest) error {
+	return self.mail.query(request.From, request.To, request.Topics, maxMailEnvelopes, func(batch []*Envelope) error {
+		select {
+		case <-self.quit:
+			return fmt.Errorf("whisper stopped")
+		default:
+		}
+		return p2p.Send(rw, mailResponseCode, batch)
+	})
+}
+
 // add inserts a new envelope into the message pool to be distributed within the
 // whisper network. It also inserts the envelope into the expiration pool at the
-// appropriate time-stamp.
+// appropriate time-stamp. Envelopes below the minimum proof of work are rejected
+// and if the pool is full, the cheapest ones are evicted to make room.
 func (self *Whisper) add(envelope *Envelope) error {
+	added, err := self.pool(envelope)
+	if err != nil || !added {
+		return err
+	}
+	// Archive the message if serving mail and notify the local node of its arrival
+	if self.mail != nil {
+		if err := self.mail.store(envelope); err != nil {
+			glog.V(logger.Error).Infof("failed to archive whisper envelope %x: %v", envelope.Hash(), err)
+		}
+	}
+	go self.postEvent(envelope)
+
+	return nil
+}
+
+// pool inserts an envelope into the message and expiration pools, reporting
+// whether it is new.
+func (self *Whisper) pool(envelope *Envelope) (bool, error) {
 	self.poolMu.Lock()
 	defer self.poolMu.Unlock()
 
@@ -238,23 +656,35 @@ func (self *Whisper) add(envelope *Envelope) error {
 	hash := envelope.Hash()
 	if _, ok := self.messages[hash]; ok {
 		glog.V(logger.Detail).Infof("whisper envelope already cached: %x\n", envelope)
-		return nil
+		return false, nil
+	}
+	if envelope.PoW() < self.minPoW {
+		return false, ErrLowPoW
+	}
+	size := envelope.size()
+	for self.storeSize+size > self.maxStoreSize {
+		cheapest := self.powHeap.cheapest()
+		if cheapest == nil || cheapest.PoW() >= envelope.PoW() {
+			return false, ErrStoreFull
+		}
+		glog.V(logger.Detail).Infof("evicting whisper envelope %x (pow %g)", cheapest.Hash(), cheapest.PoW())
+		self.remove(cheapest.Hash())
 	}
 	self.messages[hash] = envelope
+	self.powHeap.add(envelope)
+	self.storeSize += size
 
 	// Insert the message into the expiration pool for later removal
 	if self.expirations[envelope.Expiry] == nil {
 		self.expirations[envelope.Expiry] = set.NewNonTS()
 	}
-	if !self.expirations[envelope.Expiry].Has(hash) {
+	added := !self.expirations[envelope.Expiry].Has(hash)
+	if added {
 		self.expirations[envelope.Expiry].Add(hash)
-
-		// Notify the local node of a message arrival
-		go self.postEvent(envelope)
 	}
 	glog.V(logger.Detail).Infof("cached whisper envelope %x\n", envelope)
 
-	return nil
+	return added, nil
 }
 
 // postEvent opens an envelope with the configured identities and delivers the
@@ -265,10 +695,24 @@ func (self *Whisper) postEvent(envelope *Envelope) {
 	}
 }
 
-// open tries to decrypt a whisper envelope with all the configured identities,
-// returning the decrypted message and the key used to achieve it. If not keys
-// are configured, open will return the payload as if non encrypted.
+// open tries to decrypt a whisper envelope with all the configured symmetric keys
+// and identities, returning the decrypted message and the key used to achieve it.
+// If not keys are configured, open will return the payload as if non encrypted.
 func (self *Whisper) open(envelope *Envelope) *Message {
+	// Symmetric decryption fails reliably for wrong keys, so try those first
+	self.symKeyMu.RLock()
+	for id, key := range self.symKeys {
+		if message, err := envelope.OpenSymmetric(key); err == nil {
+			self.symKeyMu.RUnlock()
+			message.SymKeyID = id
+			return message
+		}
+	}
+	self.symKeyMu.RUnlock()
+
+	self.keyMu.RLock()
+	defer self.keyMu.RUnlock()
+
 	// Short circuit if no identity is set, and assume clear-text
 	if len(self.keys) == 0 {
 		if message, err := envelope.Open(nil); err == nil {
@@ -296,17 +740,19 @@ func createFilter(message *Message, topics []Topic) filter.Filter {
 		matcher[i] = []Topic{topic}
 	}
 	return filterer{
-		to:      string(crypto.FromECDSAPub(message.To)),
-		from:    string(crypto.FromECDSAPub(message.Recover())),
-		matcher: newTopicMatcher(matcher...),
+		to:       string(crypto.FromECDSAPub(message.To)),
+		from:     string(crypto.FromECDSAPub(message.Recover())),
+		symKeyID: message.SymKeyID,
+		matcher:  newTopicMatcher(matcher...),
 	}
 }
 
 // update loops until the lifetime of the whisper node, updating its internal
-// state by expiring stale messages from the pool.
+// state by expiring stale messages from the pool and the mail archive.
 func (self *Whisper) update() {
-	// Start a ticker to check for expirations
+	// Start the tickers to check for expirations
 	expire := time.NewTicker(expirationCycle)
+	prune := time.NewTicker(archivePruneCycle)
 
 	// Repeat updates until termination is requested
 	for {
@@ -314,6 +760,13 @@ func (self *Whisper) update() {
 		case <-expire.C:
 			self.expire()
 
+		case <-prune.C:
+			if self.mail != nil {
+				if err := self.mail.prune(uint32(time.Now().Unix()) - archiveRetention); err != nil {
+					glog.V(logger.Error).Infof("failed to prune whisper mail archive: %v", err)
+				}
+			}
+
 		case <-self.quit:
 			return
 		}
@@ -334,13 +787,29 @@ func (self *Whisper) expire() {
 		}
 		// Dump all expired messages and remove timestamp
 		hashSet.Each(func(v interface{}) bool {
-			delete(self.messages, v.(common.Hash))
+			self.remove(v.(common.Hash))
 			return true
 		})
 		self.expirations[then].Clear()
 	}
 }
 
+// remove drops an envelope from the message, expiration and eviction pools. The
+// caller must hold the pool lock.
+func (self *Whisper) remove(hash common.Hash) {
+	envelope, ok := self.messages[hash]
+	if !ok {
+		return
+	}
+	delete(self.messages, hash)
+	self.powHeap.remove(hash)
+	self.storeSize -= envelope.size()
+
+	if hashSet := self.expirations[envelope.Expiry]; hashSet != nil {
+		hashSet.Remove(hash)
+	}
+}
+
 // envelopes retrieves all the messages currently pooled by the node.
 func (self *Whisper) envelopes() []*Envelope {
 	self.poolMu.RLock()
@@ -17,11 +17,17 @@
 package whisper
 
 import (
+	"bytes"
+	"io/ioutil"
+	"os"
 	"testing"
 	"time"
 
+	"github.com/krypton/go-krypton/common"
+	"github.com/krypton/go-krypton/crypto"
 	"github.com/krypton/go-krypton/p2p"
 	"github.com/krypton/go-krypton/p2p/discover"
+	"github.com/krypton/go-krypton/p2p/simulations"
 )
 
 func startTestCluster(n int) []*Whisper {
@@ -39,8 +45,8 @@ func startTestCluster(n int) []*Whisper {
 	for i := 1; i < n; i++ {
 		src, dst := p2p.MsgPipe()
 
-		go whispers[0].handlePeer(nodes[i], src)
-		go whispers[i].handlePeer(nodes[0], dst)
+		go whispers[0].handlePeer(nodes[i], src, shh3)
+		go whispers[i].handlePeer(nodes[0], dst, shh3)
 	}
 	return whispers
 }
@@ -174,6 +180,55 @@ func testBroadcast(anonymous bool, t *testing.T) {
 	}
 }
 
+// Tests that envelopes are relayed across a chain of real p2p servers.
+func TestSimulatedPropagation(t *testing.T) {
+	whispers := make([]*Whisper, 4)
+	defer func() {
+		for _, whisper := range whispers {
+			if whisper != nil {
+				whisper.Stop()
+			}
+		}
+	}()
+	network, err := simulations.New(simulations.Config{
+		Nodes:   len(whispers),
+		Latency: 10 * time.Millisecond,
+		Protocols: func(i int) []p2p.Protocol {
+			whispers[i] = New()
+			whispers[i].Start()
+			return whispers[i].Protocols()
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create network: %v", err)
+	}
+	defer network.Stop()
+
+	if err := network.ConnectTopology(simulations.Line); err != nil {
+		t.Fatalf("failed to connect network: %v", err)
+	}
+	done := make(chan struct{})
+	whispers[len(whispers)-1].Watch(Filter{
+		Topics: NewFilterTopicsFromStringsFlat("relayed topic"),
+		Fn:     func(msg *Message) { close(done) },
+	})
+	envelope, err := NewMessage([]byte("relayed whisper")).Wrap(DefaultPoW, Options{
+		Topics: NewTopicsFromStrings("relayed topic"),
+		TTL:    DefaultTTL,
+	})
+	if err != nil {
+		t.Fatalf("failed to wrap message: %v", err)
+	}
+	if err := whispers[0].Send(envelope); err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("relayed message receive timeout")
+	}
+}
+
 func TestMessageExpiration(t *testing.T) {
 	// Start the single node cluster and inject a dummy message
 	node := startTestCluster(1)[0]
@@ -208,3 +263,213 @@ func TestMessageExpiration(t *testing.T) {
 		t.Fatalf("message not expired from cache")
 	}
 }
+
+func TestSymKeyManagement(t *testing.T) {
+	node := New()
+
+	// Generated and added keys must be retrievable by their id
+	id, err := node.GenerateSymKey()
+	if err != nil {
+		t.Fatalf("failed to generate symmetric key: %v", err)
+	}
+	if key := node.GetSymKey(id); len(key) != SymKeyLength {
+		t.Fatalf("generated key length mismatch: have %d, want %d", len(key), SymKeyLength)
+	}
+	if _, err := node.AddSymKey(make([]byte, SymKeyLength-1)); err != ErrInvalidSymKey {
+		t.Fatalf("invalid key error mismatch: have %v, want %v", err, ErrInvalidSymKey)
+	}
+	// Password derived keys must match between nodes
+	id1, err := node.AddSymKeyFromPassword("shared secret")
+	if err != nil {
+		t.Fatalf("failed to derive symmetric key: %v", err)
+	}
+	id2, err := New().AddSymKeyFromPassword("shared secret")
+	if err != nil {
+		t.Fatalf("failed to derive symmetric key: %v", err)
+	}
+	if id1 == id2 {
+		t.Errorf("symmetric key ids not unique")
+	}
+	// Deleted keys must be gone
+	if !node.DeleteSymKey(id) {
+		t.Fatalf("failed to delete symmetric key")
+	}
+	if node.HasSymKey(id) || node.DeleteSymKey(id) {
+		t.Fatalf("symmetric key not deleted")
+	}
+	if !node.HasSymKey(id1) {
+		t.Fatalf("unrelated symmetric key deleted")
+	}
+}
+
+func TestSymmetricMessage(t *testing.T) {
+	// Start the sender-recipient-outsider cluster
+	cluster := startTestCluster(3)
+
+	sender, recipient, outsider := cluster[0], cluster[1], cluster[2]
+	senderKey, _ := sender.AddSymKeyFromPassword("group password")
+	recipientKey, _ := recipient.AddSymKeyFromPassword("group password")
+	outsiderKey, _ := outsider.AddSymKeyFromPassword("wrong password")
+
+	// Watch for arriving messages on the recipient and the outsider
+	done := make(chan *Message, 1)
+	recipient.Watch(Filter{
+		SymKeyID: recipientKey,
+		Fn: func(msg *Message) {
+			done <- msg
+		},
+	})
+	leaked := make(chan struct{}, 1)
+	outsider.Watch(Filter{
+		SymKeyID: outsiderKey,
+		Fn: func(msg *Message) {
+			leaked <- struct{}{}
+		},
+	})
+	// Send a group message from the sender
+	payload := []byte("group whisper")
+	envelope, err := NewMessage(payload).Wrap(DefaultPoW, Options{
+		KeySym: sender.GetSymKey(senderKey),
+		TTL:    DefaultTTL,
+	})
+	if err != nil {
+		t.Fatalf("failed to wrap message: %v", err)
+	}
+	if err := sender.Send(envelope); err != nil {
+		t.Fatalf("failed to send group message: %v", err)
+	}
+	// Wait for an arrival or a timeout
+	select {
+	case msg := <-done:
+		if !bytes.Equal(msg.Payload, payload) {
+			t.Errorf("payload mismatch: have 0x%x, want 0x%x", msg.Payload, payload)
+		}
+		if msg.SymKeyID != recipientKey {
+			t.Errorf("symmetric key id mismatch: have %s, want %s", msg.SymKeyID, recipientKey)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("group message receive timeout")
+	}
+	select {
+	case <-leaked:
+		t.Fatalf("message decrypted with wrong key")
+	case <-time.After(transmissionCycle):
+	}
+}
+
+func TestMinPoW(t *testing.T) {
+	client := New()
+
+	envelope, err := NewMessage([]byte("cheap message")).Wrap(DefaultPoW, Options{
+		TTL: DefaultTTL,
+	})
+	if err != nil {
+		t.Fatalf("failed to wrap message: %v", err)
+	}
+	client.SetMinPoW(envelope.PoW() * 2)
+	if err := client.Send(envelope); err != ErrLowPoW {
+		t.Fatalf("low proof of work error mismatch: have %v, want %v", err, ErrLowPoW)
+	}
+	client.SetMinPoW(envelope.PoW())
+	if err := client.Send(envelope); err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+}
+
+func TestStoreEviction(t *testing.T) {
+	client := New()
+	client.SetMinPoW(0)
+
+	// Create a batch of equally sized envelopes of increasing proof of work
+	envelopes := make([]*Envelope, 4)
+	for i := 0; i < len(envelopes); i++ {
+		envelope, err := NewMessage([]byte{byte(i)}).Wrap(0, Options{TTL: DefaultTTL})
+		if err != nil {
+			t.Fatalf("failed to wrap message %d: %v", i, err)
+		}
+		envelope.pow = float64(i + 1)
+		envelopes[i] = envelope
+	}
+	client.SetMaxStoreSize(2 * envelopes[0].size())
+
+	// Fill the pool and check that cheaper envelopes are evicted or rejected
+	for _, i := range []int{1, 2} {
+		if err := client.Send(envelopes[i]); err != nil {
+			t.Fatalf("failed to send message %d: %v", i, err)
+		}
+	}
+	if err := client.Send(envelopes[0]); err != ErrStoreFull {
+		t.Fatalf("full store error mismatch: have %v, want %v", err, ErrStoreFull)
+	}
+	if err := client.Send(envelopes[3]); err != nil {
+		t.Fatalf("failed to send message %d: %v", 3, err)
+	}
+	pooled := make(map[common.Hash]bool)
+	for _, envelope := range client.envelopes() {
+		pooled[envelope.Hash()] = true
+	}
+	if len(pooled) != 2 || !pooled[envelopes[2].Hash()] || !pooled[envelopes[3].Hash()] {
+		t.Fatalf("pool contents mismatch: have %v, want envelopes 2 and 3", pooled)
+	}
+	if client.storeSize != 2*envelopes[0].size() {
+		t.Fatalf("store size mismatch: have %d, want %d", client.storeSize, 2*envelopes[0].size())
+	}
+}
+
+func TestIdentityPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "whisper-identities")
+	if err != nil {
+		t.Fatalf("failed to create temporary key store: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	ks := crypto.NewKeyStorePassphrase(dir, crypto.LightScryptN, crypto.LightScryptP)
+
+	// Persisting identities requires a key store
+	key, _ := crypto.GenerateKey()
+	if err := New().StoreIdentity(key, "secret"); err != ErrNoKeyStore {
+		t.Fatalf("store without key store error mismatch: have %v, want %v", err, ErrNoKeyStore)
+	}
+	// Store an identity and restore it in a fresh node
+	client := New()
+	client.SetKeyStore(ks)
+	if err := client.StoreIdentity(key, "secret"); err != nil {
+		t.Fatalf("failed to store identity: %v", err)
+	}
+	if !client.HasIdentity(&key.PublicKey) {
+		t.Fatalf("stored identity not activated")
+	}
+	restarted := New()
+	restarted.SetKeyStore(ks)
+	if restarted.HasIdentity(&key.PublicKey) {
+		t.Fatalf("stored identity active before unlock")
+	}
+	if _, err := restarted.UnlockIdentity(&key.PublicKey, "wrong"); err == nil {
+		t.Fatalf("identity unlocked with wrong passphrase")
+	}
+	if _, err := restarted.UnlockIdentity(&key.PublicKey, "secret"); err != nil {
+		t.Fatalf("failed to unlock identity: %v", err)
+	}
+	if !restarted.HasIdentity(&key.PublicKey) {
+		t.Fatalf("unlocked identity not activated")
+	}
+	exported, err := restarted.ExportIdentity(&key.PublicKey, "secret")
+	if err != nil {
+		t.Fatalf("failed to export identity: %v", err)
+	}
+	if !bytes.Equal(crypto.FromECDSA(exported), crypto.FromECDSA(key)) {
+		t.Fatalf("exported key mismatch: have %x, want %x", crypto.FromECDSA(exported), crypto.FromECDSA(key))
+	}
+	// Delete the identity and make sure it's gone
+	if err := restarted.DeleteIdentity(&key.PublicKey, "wrong"); err == nil {
+		t.Fatalf("identity deleted with wrong passphrase")
+	}
+	if err := restarted.DeleteIdentity(&key.PublicKey, "secret"); err != nil {
+		t.Fatalf("failed to delete identity: %v", err)
+	}
+	if restarted.HasIdentity(&key.PublicKey) {
+		t.Fatalf("deleted identity still active")
+	}
+	if _, err := restarted.UnlockIdentity(&key.PublicKey, "secret"); err == nil {
+		t.Fatalf("deleted identity unlocked")
+	}
+}
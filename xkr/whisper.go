@@ -19,12 +19,15 @@
 package xkr
 
 import (
+	"crypto/ecdsa"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/krypton/go-krypton/common"
 	"github.com/krypton/go-krypton/crypto"
 	"github.com/krypton/go-krypton/logger"
+	"github.com/krypton/go-krypton/p2p/discover"
 	"github.com/krypton/go-krypton/whisper"
 )
 
@@ -47,14 +50,107 @@ func (self *Whisper) NewIdentity() string {
 	return common.ToHex(crypto.FromECDSAPub(&identity.PublicKey))
 }
 
+// NewStoredIdentity generates a new cryptographic identity for the client,
+// persists it encrypted with the passphrase and injects it into the known
+// identities for message decryption.
+func (self *Whisper) NewStoredIdentity(passphrase string) (string, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return "", err
+	}
+	if err := self.Whisper.StoreIdentity(key, passphrase); err != nil {
+		return "", err
+	}
+	return common.ToHex(crypto.FromECDSAPub(&key.PublicKey)), nil
+}
+
 // HasIdentity checks if the the whisper node is configured with the private key
 // of the specified public pair.
 func (self *Whisper) HasIdentity(key string) bool {
 	return self.Whisper.HasIdentity(crypto.ToECDSAPub(common.FromHex(key)))
 }
 
-// Post injects a message into the whisper network for distribution.
-func (self *Whisper) Post(payload string, to, from string, topics []string, priority, ttl uint32) error {
+// AddIdentity activates an identity for message decryption, returning its public
+// key. The key is either a hex encoded private key, which is imported into the
+// key store encrypted with the passphrase, or the public key of an identity
+// already in the key store, which is unlocked with the passphrase.
+func (self *Whisper) AddIdentity(key string, passphrase string) (string, error) {
+	blob := common.FromHex(key)
+	switch len(blob) {
+	case 32:
+		prv := crypto.ToECDSA(blob)
+		if err := self.Whisper.StoreIdentity(prv, passphrase); err != nil {
+			return "", err
+		}
+		return common.ToHex(crypto.FromECDSAPub(&prv.PublicKey)), nil
+
+	case 65:
+		pub, err := parseIdentity(key)
+		if err != nil {
+			return "", err
+		}
+		if _, err := self.Whisper.UnlockIdentity(pub, passphrase); err != nil {
+			return "", err
+		}
+		return key, nil
+
+	default:
+		return "", fmt.Errorf("invalid identity key length: %d", len(blob))
+	}
+}
+
+// ExportIdentity decrypts a persisted identity with the passphrase and returns its
+// hex encoded private key.
+func (self *Whisper) ExportIdentity(identity string, passphrase string) (string, error) {
+	pub, err := parseIdentity(identity)
+	if err != nil {
+		return "", err
+	}
+	prv, err := self.Whisper.ExportIdentity(pub, passphrase)
+	if err != nil {
+		return "", err
+	}
+	return common.ToHex(crypto.FromECDSA(prv)), nil
+}
+
+// DeleteIdentity removes a persisted identity, authorized by its passphrase.
+func (self *Whisper) DeleteIdentity(identity string, passphrase string) error {
+	pub, err := parseIdentity(identity)
+	if err != nil {
+		return err
+	}
+	return self.Whisper.DeleteIdentity(pub, passphrase)
+}
+
+// parseIdentity decodes the hex encoded public key of an identity.
+func parseIdentity(identity string) (*ecdsa.PublicKey, error) {
+	pub := crypto.ToECDSAPub(common.FromHex(identity))
+	if pub == nil || pub.X == nil {
+		return nil, fmt.Errorf("invalid identity: %s", identity)
+	}
+	return pub, nil
+}
+
+// AddSymKey installs a hex encoded symmetric key for message decryption and
+// returns its id.
+func (self *Whisper) AddSymKey(key string) (string, error) {
+	return self.Whisper.AddSymKey(common.FromHex(key))
+}
+
+// GetSymKey retrieves the hex encoded symmetric key with the given id.
+func (self *Whisper) GetSymKey(id string) (string, error) {
+	key := self.Whisper.GetSymKey(id)
+	if key == nil {
+		return "", fmt.Errorf("unknown symmetric key: %s", id)
+	}
+	return common.ToHex(key), nil
+}
+
+// Post injects a message into the whisper network for distribution. Messages are
+// encrypted either to the recipient public key to or with the symmetric key of
+// id symKeyID. The priority is the requested proof of work in thousandths, the
+// message being sealed with at least the minimum accepted by the local node.
+func (self *Whisper) Post(payload string, to, from, symKeyID string, topics []string, priority, ttl uint32) error {
 	// Decode the topic strings
 	topicsDecoded := make([][]byte, len(topics))
 	for i, topic := range topics {
@@ -67,6 +163,14 @@ func (self *Whisper) Post(payload string, to, from string, topics []string, prio
 		TTL:    time.Duration(ttl) * time.Second,
 		Topics: whisper.NewTopics(topicsDecoded...),
 	}
+	if len(symKeyID) != 0 {
+		if len(to) != 0 {
+			return fmt.Errorf("both recipient and symmetric key specified")
+		}
+		if options.KeySym = self.Whisper.GetSymKey(symKeyID); options.KeySym == nil {
+			return fmt.Errorf("unknown symmetric key: %s", symKeyID)
+		}
+	}
 	if len(from) != 0 {
 		if key := self.Whisper.GetIdentity(crypto.ToECDSAPub(common.FromHex(from))); key != nil {
 			options.From = key
@@ -75,7 +179,10 @@ func (self *Whisper) Post(payload string, to, from string, topics []string, prio
 		}
 	}
 	// Wrap and send the message
-	pow := time.Duration(priority) * time.Millisecond
+	pow := self.Whisper.MinPoW()
+	if requested := float64(priority) / 1000; requested > pow {
+		pow = requested
+	}
 	envelope, err := message.Wrap(pow, options)
 	if err != nil {
 		return err
@@ -86,9 +193,43 @@ func (self *Whisper) Post(payload string, to, from string, topics []string, prio
 	return nil
 }
 
+// RequestMessages asks a connected mail server, given by node id or enode URL,
+// for the archived messages sent within [from, to] having any of the hex encoded
+// topics.
+func (self *Whisper) RequestMessages(server string, from, to uint32, topics []string) error {
+	var id discover.NodeID
+	if strings.HasPrefix(server, "enode://") {
+		node, err := discover.ParseNode(server)
+		if err != nil {
+			return err
+		}
+		id = node.ID
+	} else {
+		var err error
+		if id, err = discover.HexID(server); err != nil {
+			return err
+		}
+	}
+	topicsDecoded := make([][]byte, len(topics))
+	for i, topic := range topics {
+		topicsDecoded[i] = common.FromHex(topic)
+	}
+	return self.Whisper.RequestMessages(id, from, to, whisper.NewTopics(topicsDecoded...))
+}
+
+// SetTopicInterest advertises to the peers that only envelopes with any of the
+// hex encoded topics should be forwarded, or all envelopes if none are given.
+func (self *Whisper) SetTopicInterest(topics []string) {
+	topicsDecoded := make([][]byte, len(topics))
+	for i, topic := range topics {
+		topicsDecoded[i] = common.FromHex(topic)
+	}
+	self.Whisper.SetTopicInterest(whisper.NewTopics(topicsDecoded...))
+}
+
 // Watch installs a new message handler to run in case a matching packet arrives
 // from the whisper network.
-func (self *Whisper) Watch(to, from string, topics [][]string, fn func(WhisperMessage)) int {
+func (self *Whisper) Watch(to, from, symKeyID string, topics [][]string, fn func(WhisperMessage)) int {
 	// Decode the topic strings
 	topicsDecoded := make([][][]byte, len(topics))
 	for i, condition := range topics {
@@ -99,9 +240,10 @@ func (self *Whisper) Watch(to, from string, topics [][]string, fn func(WhisperMe
 	}
 	// Assemble and inject the filter into the whisper client
 	filter := whisper.Filter{
-		To:     crypto.ToECDSAPub(common.FromHex(to)),
-		From:   crypto.ToECDSAPub(common.FromHex(from)),
-		Topics: whisper.NewFilterTopics(topicsDecoded...),
+		To:       crypto.ToECDSAPub(common.FromHex(to)),
+		From:     crypto.ToECDSAPub(common.FromHex(from)),
+		SymKeyID: symKeyID,
+		Topics:   whisper.NewFilterTopics(topicsDecoded...),
 	}
 	filter.Fn = func(message *whisper.Message) {
 		fn(NewWhisperMessage(message))
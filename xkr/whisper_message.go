@@ -30,12 +30,13 @@ import (
 type WhisperMessage struct {
 	ref *whisper.Message
 
-	Payload string `json:"payload"`
-	To      string `json:"to"`
-	From    string `json:"from"`
-	Sent    int64  `json:"sent"`
-	TTL     int64  `json:"ttl"`
-	Hash    string `json:"hash"`
+	Payload  string `json:"payload"`
+	To       string `json:"to"`
+	From     string `json:"from"`
+	SymKeyID string `json:"symKeyID,omitempty"`
+	Sent     int64  `json:"sent"`
+	TTL      int64  `json:"ttl"`
+	Hash     string `json:"hash"`
 }
 
 // NewWhisperMessage converts an internal message into an API version.
@@ -43,11 +44,12 @@ func NewWhisperMessage(message *whisper.Message) WhisperMessage {
 	return WhisperMessage{
 		ref: message,
 
-		Payload: common.ToHex(message.Payload),
-		From:    common.ToHex(crypto.FromECDSAPub(message.Recover())),
-		To:      common.ToHex(crypto.FromECDSAPub(message.To)),
-		Sent:    message.Sent.Unix(),
-		TTL:     int64(message.TTL / time.Second),
-		Hash:    common.ToHex(message.Hash.Bytes()),
+		Payload:  common.ToHex(message.Payload),
+		From:     common.ToHex(crypto.FromECDSAPub(message.Recover())),
+		To:       common.ToHex(crypto.FromECDSAPub(message.To)),
+		SymKeyID: message.SymKeyID,
+		Sent:     message.Sent.Unix(),
+		TTL:      int64(message.TTL / time.Second),
+		Hash:     common.ToHex(message.Hash.Bytes()),
 	}
 }
@@ -40,6 +40,7 @@ import (
 	"github.com/krypton/go-krypton/logger"
 	"github.com/krypton/go-krypton/logger/glog"
 	"github.com/krypton/go-krypton/miner"
+	"github.com/krypton/go-krypton/params"
 	"github.com/krypton/go-krypton/rlp"
 )
 
@@ -74,13 +75,14 @@ type XKr struct {
 	messagesMu sync.RWMutex
 	messages   map[int]*whisperFilter
 
-	transactMu sync.Mutex
+	transactMu sync.Mutex                            // Guards nonce reservations and pool insertion
+	nonces     map[common.Address]*nonceReservations // Nonces of transactions being signed
 
 	// read-only fields
 	backend       *kr.Krypton
 	frontend      Frontend
 	agent         *miner.RemoteAgent
-	gpo           *kr.GasPriceOracle
+	gpo           kr.GasPricer
 	state         *State
 	whisper       *Whisper
 	filterManager *filters.FilterSystem
@@ -104,7 +106,7 @@ func New(krypton *kr.Krypton, frontend Frontend) *XKr {
 		transactionQueue: make(map[int]*hashQueue),
 		messages:         make(map[int]*whisperFilter),
 		agent:            miner.NewRemoteAgent(),
-		gpo:              kr.NewGasPriceOracle(krypton),
+		gpo:              kr.NewGasPricer(krypton),
 	}
 	if krypton.Whisper() != nil {
 		xkr.whisper = NewWhisper(krypton.Whisper())
@@ -200,6 +202,12 @@ func (self *XKr) DefaultGasPrice() *big.Int {
 	return self.gpo.SuggestPrice()
 }
 
+// GasPriceDistribution returns the gas prices sampled from recent blocks,
+// regardless of the oracle suggesting the default gas price.
+func (self *XKr) GasPriceDistribution() *kr.GasPriceDistribution {
+	return self.backend.GasPrices().Distribution()
+}
+
 func (self *XKr) RemoteMining() *miner.RemoteAgent { return self.agent }
 
 func (self *XKr) AtStateNum(num int64) *XKr {
@@ -614,29 +622,37 @@ func (self *XKr) TransactionFilterChanged(id int) []common.Hash {
 	return nil
 }
 
-func (self *XKr) Logs(id int) vm.Logs {
+// Logs retrieves all logs matching the criteria of the installed filter. The
+// search is aborted when quit is closed.
+func (self *XKr) Logs(id int, quit <-chan struct{}) vm.Logs {
 	filter := self.filterManager.Get(id)
 	if filter != nil {
-		return filter.Find()
+		// Search on a copy, the installed filter may be queried concurrently
+		search := *filter
+		search.SetQuit(quit)
+		return search.Find()
 	}
 
 	return nil
 }
 
-func (self *XKr) AllLogs(earliest, latest int64, skip, max int, address []string, topics [][]string) vm.Logs {
+// AllLogs retrieves all logs matching the given criteria. The search is aborted
+// when quit is closed.
+func (self *XKr) AllLogs(earliest, latest int64, skip, max int, address []string, topics [][]string, quit <-chan struct{}) vm.Logs {
 	filter := filters.New(self.backend.ChainDb())
 	filter.SetBeginBlock(earliest)
 	filter.SetEndBlock(latest)
 	filter.SetAddresses(cAddress(address))
 	filter.SetTopics(cTopics(topics))
+	filter.SetQuit(quit)
 
 	return filter.Find()
 }
 
 // NewWhisperFilter creates and registers a new message filter to watch for
 // inbound whisper messages. All parameters at this point are assumed to be
-// HEX encoded.
-func (p *XKr) NewWhisperFilter(to, from string, topics [][]string) int {
+// HEX encoded, except for the id of the symmetric key messages are encrypted with.
+func (p *XKr) NewWhisperFilter(to, from, symKeyID string, topics [][]string) int {
 	// Pre-define the id to be filled later
 	var id int
 
@@ -647,7 +663,7 @@ func (p *XKr) NewWhisperFilter(to, from string, topics [][]string) int {
 		p.messages[id].insert(msg)
 	}
 	// Initialize the core whisper filter and wrap into xkr
-	id = p.Whisper().Watch(to, from, topics, callback)
+	id = p.Whisper().Watch(to, from, symKeyID, topics, callback)
 
 	p.messagesMu.Lock()
 	p.messages[id] = newWhisperFilter(id, p.Whisper())
@@ -797,6 +813,76 @@ func (self *XKr) PushTx(encodedTx string) (string, error) {
 
 func (self *XKr) Call(fromStr, toStr, valueStr, gasStr, gasPriceStr, dataStr string) (string, string, error) {
 	statedb := self.State().State().Copy()
+	msg := self.callMsg(statedb, fromStr, toStr, valueStr, gasStr, gasPriceStr, dataStr)
+
+	if msg.gas.Cmp(big.NewInt(0)) == 0 {
+		msg.gas = big.NewInt(50000000)
+	}
+
+	res, gas, _, err := self.applyCall(statedb, self.CurrentBlock().Header(), msg)
+	return common.ToHex(res), gas.String(), err
+}
+
+// EstimateGas searches for the lowest gas limit at which the given call
+// executes without failing against the pending state. The search is capped
+// by the requested gas (if any) or otherwise by the pending block gas limit.
+func (self *XKr) EstimateGas(fromStr, toStr, valueStr, gasStr, gasPriceStr, dataStr string) (string, error) {
+	pending := self.backend.Miner().PendingBlock()
+	header := pending.Header()
+
+	// Determine the boundaries of the binary search
+	lo := new(big.Int).Sub(params.TxGas, common.Big1)
+	hi := new(big.Int).Set(header.GasLimit)
+	if gas := common.Big(gasStr); gas.Cmp(params.TxGas) >= 0 {
+		hi = gas
+	}
+	limit := new(big.Int).Set(hi)
+
+	// executable runs the call with the given gas limit on a fresh copy of the
+	// pending state and reports whether it completed without failing.
+	executable := func(gas *big.Int) (bool, error) {
+		statedb := self.backend.Miner().PendingState().Copy()
+		msg := self.callMsg(statedb, fromStr, toStr, valueStr, gas.String(), gasPriceStr, dataStr)
+
+		_, _, failed, err := self.applyCall(statedb, header, msg)
+		if err != nil {
+			if core.IsInvalidTxErr(err) || core.IsGasLimitErr(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return !failed, nil
+	}
+	for new(big.Int).Add(lo, common.Big1).Cmp(hi) < 0 {
+		mid := new(big.Int).Add(lo, hi)
+		mid.Div(mid, common.Big2)
+
+		ok, err := executable(mid)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	// Reject the call if it fails even with the highest allowance
+	if hi.Cmp(limit) == 0 {
+		ok, err := executable(hi)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", fmt.Errorf("gas required exceeds allowance (%v) or always failing transaction", limit)
+		}
+	}
+	return hi.String(), nil
+}
+
+// callMsg assembles a call message on top of the given state. The sender is
+// topped up with enough balance to pay for any gas it may need.
+func (self *XKr) callMsg(statedb *state.StateDB, fromStr, toStr, valueStr, gasStr, gasPriceStr, dataStr string) callmsg {
 	var from *state.StateObject
 	if len(fromStr) == 0 {
 		accounts, err := self.backend.AccountManager().Accounts()
@@ -823,19 +909,18 @@ func (self *XKr) Call(fromStr, toStr, valueStr, gasStr, gasPriceStr, dataStr str
 		msg.to = &addr
 	}
 
-	if msg.gas.Cmp(big.NewInt(0)) == 0 {
-		msg.gas = big.NewInt(50000000)
-	}
-
 	if msg.gasPrice.Cmp(big.NewInt(0)) == 0 {
 		msg.gasPrice = self.DefaultGasPrice()
 	}
+	return msg
+}
 
-	header := self.CurrentBlock().Header()
+// applyCall executes the call message on the given state within the context
+// of header without enforcing the block gas limit.
+func (self *XKr) applyCall(statedb *state.StateDB, header *types.Header, msg callmsg) ([]byte, *big.Int, bool, error) {
 	vmenv := core.NewEnv(statedb, self.backend.BlockChain(), msg, header)
 	gp := new(core.GasPool).AddGas(common.MaxBig)
-	res, gas, err := core.ApplyMessage(vmenv, msg, gp)
-	return common.ToHex(res), gas.String(), err
+	return core.ApplyMessage(vmenv, msg, gp)
 }
 
 func (self *XKr) ConfirmTransaction(tx string) bool {
@@ -880,6 +965,16 @@ func (self *XKr) Frontend() Frontend {
 }
 
 func (self *XKr) SignTransaction(fromStr, toStr, nonceStr, valueStr, gasStr, gasPriceStr, codeStr string) (*types.Transaction, error) {
+	return self.signTransaction(fromStr, toStr, nonceStr, valueStr, gasStr, gasPriceStr, codeStr, self.signUnlocked)
+}
+
+// SignTransactionWithPassphrase signs a transaction like SignTransaction, but
+// unlocks the sender only for this single signature.
+func (self *XKr) SignTransactionWithPassphrase(passphrase, fromStr, toStr, nonceStr, valueStr, gasStr, gasPriceStr, codeStr string) (*types.Transaction, error) {
+	return self.signTransaction(fromStr, toStr, nonceStr, valueStr, gasStr, gasPriceStr, codeStr, self.signWithPassphrase(passphrase))
+}
+
+func (self *XKr) signTransaction(fromStr, toStr, nonceStr, valueStr, gasStr, gasPriceStr, codeStr string, sign signFn) (*types.Transaction, error) {
 	if len(toStr) > 0 && toStr != "0x" && !isAddress(toStr) {
 		return nil, errors.New("Invalid address")
 	}
@@ -925,7 +1020,7 @@ func (self *XKr) SignTransaction(fromStr, toStr, nonceStr, valueStr, gasStr, gas
 		tx = types.NewTransaction(nonce, to, value, gas, price, data)
 	}
 
-	signed, err := self.sign(tx, from, false)
+	signed, err := sign(tx, from)
 	if err != nil {
 		return nil, err
 	}
@@ -934,6 +1029,16 @@ func (self *XKr) SignTransaction(fromStr, toStr, nonceStr, valueStr, gasStr, gas
 }
 
 func (self *XKr) Transact(fromStr, toStr, nonceStr, valueStr, gasStr, gasPriceStr, codeStr string) (string, error) {
+	return self.transact(fromStr, toStr, nonceStr, valueStr, gasStr, gasPriceStr, codeStr, self.signUnlocked)
+}
+
+// TransactWithPassphrase sends a transaction like Transact, but unlocks the
+// sender only for signing this single transaction.
+func (self *XKr) TransactWithPassphrase(passphrase, fromStr, toStr, nonceStr, valueStr, gasStr, gasPriceStr, codeStr string) (string, error) {
+	return self.transact(fromStr, toStr, nonceStr, valueStr, gasStr, gasPriceStr, codeStr, self.signWithPassphrase(passphrase))
+}
+
+func (self *XKr) transact(fromStr, toStr, nonceStr, valueStr, gasStr, gasPriceStr, codeStr string, sign signFn) (string, error) {
 
 	// this minimalistic recoding is enough (works for natspec.js)
 	var jsontx = fmt.Sprintf(`{"params":[{"to":"%s","data": "%s"}]}`, toStr, codeStr)
@@ -996,15 +1101,13 @@ func (self *XKr) Transact(fromStr, toStr, nonceStr, valueStr, gasStr, gasPriceSt
 		}
 	*/
 
-	self.transactMu.Lock()
-	defer self.transactMu.Unlock()
-
+	// Reserve the nonce, but sign without holding the lock, as signing may wait
+	// for a passphrase decryption or an external signer's approval
 	var nonce uint64
 	if len(nonceStr) != 0 {
 		nonce = common.Big(nonceStr).Uint64()
 	} else {
-		state := self.backend.TxPool().State()
-		nonce = state.GetNonce(from)
+		nonce = self.reserveNonce(from)
 	}
 	var tx *types.Transaction
 	if contractCreation {
@@ -1013,11 +1116,16 @@ func (self *XKr) Transact(fromStr, toStr, nonceStr, valueStr, gasStr, gasPriceSt
 		tx = types.NewTransaction(nonce, to, value, gas, price, data)
 	}
 
-	signed, err := self.sign(tx, from, false)
-	if err != nil {
-		return "", err
+	signed, err := sign(tx, from)
+	if err == nil {
+		self.transactMu.Lock()
+		err = self.backend.TxPool().Add(signed)
+		self.transactMu.Unlock()
 	}
-	if err = self.backend.TxPool().Add(signed); err != nil {
+	if err != nil {
+		if len(nonceStr) == 0 {
+			self.releaseNonce(from, nonce)
+		}
 		return "", err
 	}
 
@@ -1031,13 +1139,89 @@ func (self *XKr) Transact(fromStr, toStr, nonceStr, valueStr, gasStr, gasPriceSt
 	return signed.Hash().Hex(), nil
 }
 
+// nonceReservations tracks the nonces handed out to the transactions of an
+// account which are being signed, ahead of their insertion into the pool.
+type nonceReservations struct {
+	next     uint64   // Nonce following the highest one handed out
+	released []uint64 // Nonces handed out whose transaction was abandoned
+}
+
+// reserveNonce hands out the lowest nonce of the account which is neither known
+// to the transaction pool nor reserved for a transaction being signed.
+func (self *XKr) reserveNonce(from common.Address) uint64 {
+	self.transactMu.Lock()
+	defer self.transactMu.Unlock()
+
+	pending := self.backend.TxPool().State().GetNonce(from)
+	if self.nonces == nil {
+		self.nonces = make(map[common.Address]*nonceReservations)
+	}
+	res := self.nonces[from]
+	if res == nil || res.next <= pending {
+		res = &nonceReservations{next: pending}
+		self.nonces[from] = res
+	}
+	// Reuse the lowest abandoned nonce the pool hasn't seen filled since
+	lowest := -1
+	for i, nonce := range res.released {
+		if nonce >= pending && (lowest < 0 || nonce < res.released[lowest]) {
+			lowest = i
+		}
+	}
+	if lowest >= 0 {
+		nonce := res.released[lowest]
+		res.released = append(res.released[:lowest], res.released[lowest+1:]...)
+		return nonce
+	}
+	res.next++
+	return res.next - 1
+}
+
+// releaseNonce returns the nonce of a transaction that failed to be signed or
+// added to the pool, so that it's handed out again instead of leaving a gap.
+func (self *XKr) releaseNonce(from common.Address, nonce uint64) {
+	self.transactMu.Lock()
+	defer self.transactMu.Unlock()
+
+	if res := self.nonces[from]; res != nil {
+		res.released = append(res.released, nonce)
+	}
+}
+
+// signFn signs a transaction in the name of the sender.
+type signFn func(tx *types.Transaction, from common.Address) (*types.Transaction, error)
+
+// signUnlocked signs with an unlocked account, asking the frontend to unlock
+// it if needed.
+func (self *XKr) signUnlocked(tx *types.Transaction, from common.Address) (*types.Transaction, error) {
+	return self.sign(tx, from, false)
+}
+
+// signWithPassphrase creates a signFn unlocking the sender for the single
+// signature only.
+func (self *XKr) signWithPassphrase(passphrase string) signFn {
+	return func(tx *types.Transaction, from common.Address) (*types.Transaction, error) {
+		return self.backend.AccountManager().SignTxWithPassphrase(accounts.Account{Address: from}, passphrase, tx)
+	}
+}
+
+// sign signs the whole transaction rather than its hash, so an external signer
+// can review what it is signing.
 func (self *XKr) sign(tx *types.Transaction, from common.Address, didUnlock bool) (*types.Transaction, error) {
-	hash := tx.SigHash()
-	sig, err := self.doSign(from, hash, didUnlock)
-	if err != nil {
+	signed, err := self.backend.AccountManager().SignTx(accounts.Account{Address: from}, tx)
+	if err == accounts.ErrLocked {
+		if didUnlock {
+			return tx, fmt.Errorf("signer account still locked after successful unlock")
+		}
+		if !self.frontend.UnlockAccount(from.Bytes()) {
+			return tx, fmt.Errorf("could not unlock signer account")
+		}
+		// retry signing, the account should now be unlocked.
+		return self.sign(tx, from, true)
+	} else if err != nil {
 		return tx, err
 	}
-	return tx.WithSignature(sig)
+	return signed, nil
 }
 
 // callmsg is the message type used for call transations.